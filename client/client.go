@@ -0,0 +1,153 @@
+// Package client is a reference Go client for the Accio127 API.
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"git.sr.ht/~jamesponddotco/accio127/internal/build"
+	"git.sr.ht/~jamesponddotco/xstd-go/xerrors"
+)
+
+const (
+	// DefaultBaseURL is the base URL of the public instance of the API.
+	DefaultBaseURL string = "https://api." + build.Hostname
+
+	// UserAgent is the User-Agent header sent with every request, which the
+	// API requires.
+	UserAgent string = build.Name + "-client/" + build.Version
+
+	// maxResponseSize is the largest response body read, in bytes.
+	maxResponseSize int64 = 1 << 16
+)
+
+// ErrUnexpectedStatus is returned when the API responds with a status other
+// than 200 OK.
+const ErrUnexpectedStatus xerrors.Error = "unexpected response status"
+
+// IP holds the IPv4 and IPv6 addresses of the client. Either may be empty if
+// the client doesn't have an address of that family.
+type IP struct {
+	V4 string `json:"ipv4,omitempty"`
+	V6 string `json:"ipv6,omitempty"`
+}
+
+// dualStack is a step of the dual-stack flow.
+type dualStack struct {
+	IP      *IP    `json:"ip"`
+	IPv4URL string `json:"ipv4URL"`
+	IPv6URL string `json:"ipv6URL"`
+}
+
+// Client is an Accio127 API client.
+type Client struct {
+	httpClient *http.Client
+	baseURL    string
+}
+
+// New creates a new Client for the API at baseURL, such as DefaultBaseURL,
+// sending requests with httpClient, or http.DefaultClient if it's nil.
+func New(baseURL string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	return &Client{
+		httpClient: httpClient,
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+	}
+}
+
+// DualStack returns both the IPv4 and the IPv6 address of the client.
+//
+// It asks the API for a token, then fetches it over the IPv4-only and the
+// IPv6-only hostnames of the API, skipping the family the first request was
+// made over. A family the client can't reach the API over is left empty
+// rather than reported as an error, since most clients lack either one.
+func (c *Client) DualStack(ctx context.Context) (*IP, error) {
+	step, err := c.dualStack(ctx, c.baseURL+"/"+build.APIVersion+"/ip/both")
+	if err != nil {
+		return nil, err
+	}
+
+	if step.IP.V4 == "" {
+		step, err = c.follow(ctx, step, step.IPv4URL)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if step.IP.V6 == "" {
+		step, err = c.follow(ctx, step, step.IPv6URL)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return step.IP, nil
+}
+
+// follow fetches the token of step from url, returning step unchanged if the
+// API couldn't be reached.
+func (c *Client) follow(ctx context.Context, step *dualStack, url string) (*dualStack, error) {
+	next, err := c.dualStack(ctx, url)
+	if err != nil {
+		if errors.Is(err, ErrUnexpectedStatus) || ctx.Err() != nil {
+			return nil, err
+		}
+
+		return step, nil
+	}
+
+	return next, nil
+}
+
+// dualStack fetches a step of the dual-stack flow from url.
+func (c *Client) dualStack(ctx context.Context, url string) (*dualStack, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, http.NoBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", UserAgent)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var apiError struct {
+			Message string `json:"message"`
+		}
+
+		// The message is only a nicety, so a body that isn't JSON is fine.
+		_ = json.Unmarshal(body, &apiError)
+
+		return nil, fmt.Errorf("%w: %s: %s", ErrUnexpectedStatus, resp.Status, apiError.Message)
+	}
+
+	var step dualStack
+
+	if err = json.Unmarshal(body, &step); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	if step.IP == nil {
+		step.IP = &IP{}
+	}
+
+	return &step, nil
+}
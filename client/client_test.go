@@ -0,0 +1,99 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"git.sr.ht/~jamesponddotco/accio127/client"
+)
+
+func TestClient_DualStack(t *testing.T) {
+	t.Parallel()
+
+	// unreachable is a URL nothing listens on, standing in for a family the
+	// client doesn't have.
+	unreachable := httptest.NewServer(http.NotFoundHandler())
+	unreachable.Close()
+
+	tests := []struct {
+		name    string
+		ipv6URL func(base string) string
+		token   int
+		want    client.IP
+		wantErr bool
+	}{
+		{
+			name:    "both",
+			ipv6URL: func(base string) string { return base + "/v6/t1" },
+			token:   http.StatusOK,
+			want:    client.IP{V4: "192.0.2.1", V6: "2001:db8::1"},
+		},
+		{
+			name:    "ipv6_unreachable",
+			ipv6URL: func(string) string { return unreachable.URL + "/v6/t1" },
+			token:   http.StatusOK,
+			want:    client.IP{V4: "192.0.2.1"},
+		},
+		{
+			name:    "invalid_token",
+			ipv6URL: func(base string) string { return base + "/v6/t1" },
+			token:   http.StatusBadRequest,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var server *httptest.Server
+
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.UserAgent() != client.UserAgent {
+					t.Errorf("User-Agent = %q, want %q", r.UserAgent(), client.UserAgent)
+				}
+
+				switch r.URL.Path {
+				case "/v1/ip/both":
+					fmt.Fprintf(w, `{"ip":{"ipv4":"192.0.2.1"},"ipv4URL":%q,"ipv6URL":%q}`,
+						server.URL+"/v4/t1", tt.ipv6URL(server.URL))
+				case "/v6/t1":
+					if tt.token != http.StatusOK {
+						w.WriteHeader(tt.token)
+						fmt.Fprint(w, `{"message":"Token is invalid or expired.","code":400}`)
+
+						return
+					}
+
+					fmt.Fprint(w, `{"ip":{"ipv4":"192.0.2.1","ipv6":"2001:db8::1"},"complete":true}`)
+				default:
+					t.Errorf("Unexpected request to %s", r.URL.Path)
+				}
+			}))
+			t.Cleanup(server.Close)
+
+			got, err := client.New(server.URL, server.Client()).DualStack(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DualStack() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				if !errors.Is(err, client.ErrUnexpectedStatus) {
+					t.Errorf("DualStack() error = %v, want %v", err, client.ErrUnexpectedStatus)
+				}
+
+				return
+			}
+
+			if *got != tt.want {
+				t.Errorf("DualStack() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"strconv"
 	"strings"
 	"syscall"
+	"time"
 
 	"git.sr.ht/~jamesponddotco/accio127/internal/build"
 	"git.sr.ht/~jamesponddotco/accio127/internal/config"
@@ -49,6 +50,7 @@ func Run() int {
 func AddCommands(rootCmd *cobra.Command, logger *zap.Logger) {
 	addStartCommand(rootCmd, logger)
 	addStopCommand(rootCmd, logger)
+	addReloadCommand(rootCmd, logger)
 }
 
 func addStartCommand(rootCmd *cobra.Command, logger *zap.Logger) {
@@ -65,7 +67,10 @@ func addStartCommand(rootCmd *cobra.Command, logger *zap.Logger) {
 				return
 			}
 
-			db, err := database.Open(logger, cfg.DSN)
+			db, err := database.Open(logger, cfg.DSN, database.Options{
+				FlushInterval: time.Duration(cfg.Counter.FlushInterval),
+				BatchSize:     cfg.Counter.BatchSize,
+			})
 			if err != nil {
 				logger.Error("Failed to open database", zap.Error(err))
 
@@ -170,6 +175,54 @@ func addStopCommand(rootCmd *cobra.Command, logger *zap.Logger) {
 	rootCmd.AddCommand(stopCmd)
 }
 
+func addReloadCommand(rootCmd *cobra.Command, logger *zap.Logger) {
+	var cfgPath string
+
+	reloadCmd := &cobra.Command{
+		Use:   "reload",
+		Short: "Reload the server's configuration and certificates.",
+		Run: func(cmd *cobra.Command, args []string) {
+			cfg, err := config.LoadConfig(cfgPath)
+			if err != nil {
+				logger.Error("Failed to load config", zap.Error(err))
+
+				return
+			}
+
+			pidFileData, err := os.ReadFile(cfg.PID)
+			if err != nil {
+				logger.Error("Failed to read PID file", zap.Error(err))
+
+				return
+			}
+
+			pid, err := strconv.Atoi(strings.TrimSpace(string(pidFileData)))
+			if err != nil {
+				logger.Error("Failed to parse PID file", zap.Error(err))
+
+				return
+			}
+
+			process, err := os.FindProcess(pid)
+			if err != nil {
+				logger.Error("Failed to find process", zap.Error(err))
+
+				return
+			}
+
+			if err = process.Signal(syscall.SIGHUP); err != nil {
+				logger.Error("Failed to send hangup signal", zap.Error(err))
+
+				return
+			}
+		},
+	}
+
+	reloadCmd.Flags().StringVarP(&cfgPath, "config", "c", "config.json", "Path to the configuration file.")
+
+	rootCmd.AddCommand(reloadCmd)
+}
+
 func Version() string {
 	var builder strings.Builder
 
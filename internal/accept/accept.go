@@ -0,0 +1,205 @@
+// Package accept picks the media type of responses from the Accept header of
+// requests, so that the packages writing responses agree on how it's read.
+package accept
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"git.sr.ht/~jamesponddotco/xstd-go/xerrors"
+	"git.sr.ht/~jamesponddotco/xstd-go/xnet/xhttp"
+)
+
+// ErrNotAcceptable is returned when none of the media types offered satisfy
+// the request.
+const ErrNotAcceptable xerrors.Error = "no acceptable representation"
+
+// Anything reports whether the request's Accept header expresses no real
+// preference, either because it's missing or because it only lists */*.
+func Anything(r *http.Request) bool {
+	return acceptsAnything(parseAccept(r))
+}
+
+// Preferred returns the media type in mediaTypes the request's Accept header
+// prefers. If the header is missing or accepts anything, the first media type
+// is returned.
+func Preferred(r *http.Request, mediaTypes ...string) (string, error) {
+	if len(mediaTypes) == 0 {
+		return "", ErrNotAcceptable
+	}
+
+	ranges := parseAccept(r)
+
+	if acceptsAnything(ranges) {
+		return mediaTypes[0], nil
+	}
+
+	var (
+		best      string
+		bestRange mediaRange
+	)
+
+	for _, mediaType := range mediaTypes {
+		match, ok := bestMatch(ranges, mediaType)
+		if !ok || match.quality == 0 {
+			continue
+		}
+
+		if best == "" || match.quality > bestRange.quality ||
+			(match.quality == bestRange.quality && match.preferredOver(bestRange)) {
+			best = mediaType
+			bestRange = match
+		}
+	}
+
+	if best == "" {
+		return "", ErrNotAcceptable
+	}
+
+	return best, nil
+}
+
+// mediaRange is a single entry of an Accept header.
+type mediaRange struct {
+	mainType string
+	subType  string
+	quality  float64
+	order    int
+}
+
+// specificity returns how specific the media range is, from 0 for */* to 2
+// for a concrete media type.
+func (m mediaRange) specificity() int {
+	switch {
+	case m.mainType == xhttp.Wildcard:
+		return 0
+	case m.subType == xhttp.Wildcard:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// matches reports whether the media range covers mediaType.
+func (m mediaRange) matches(mediaType string) bool {
+	mainType, subType, ok := strings.Cut(mediaType, "/")
+	if !ok {
+		return false
+	}
+
+	if m.mainType == xhttp.Wildcard {
+		return true
+	}
+
+	if !strings.EqualFold(m.mainType, mainType) {
+		return false
+	}
+
+	return m.subType == xhttp.Wildcard || strings.EqualFold(m.subType, subType)
+}
+
+// preferredOver breaks ties between two media ranges of equal quality by
+// preferring the more specific one, then the one listed first.
+func (m mediaRange) preferredOver(other mediaRange) bool {
+	if m.specificity() != other.specificity() {
+		return m.specificity() > other.specificity()
+	}
+
+	return m.order < other.order
+}
+
+// bestMatch returns the most specific media range covering mediaType, as the
+// most specific range determines its quality.
+func bestMatch(ranges []mediaRange, mediaType string) (mediaRange, bool) {
+	var (
+		best  mediaRange
+		found bool
+	)
+
+	for _, candidate := range ranges {
+		if !candidate.matches(mediaType) {
+			continue
+		}
+
+		if !found || candidate.specificity() > best.specificity() {
+			best = candidate
+			found = true
+		}
+	}
+
+	return best, found
+}
+
+// acceptsAnything reports whether the parsed Accept header expresses no real
+// preference, either because it's empty or because it only lists */*.
+func acceptsAnything(ranges []mediaRange) bool {
+	for _, r := range ranges {
+		if r.specificity() != 0 || r.quality == 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// parseAccept parses the Accept header of r into its media ranges. Malformed
+// entries are ignored.
+func parseAccept(r *http.Request) []mediaRange {
+	var (
+		parts  = strings.Split(strings.Join(r.Header.Values(xhttp.Accept), ","), ",")
+		ranges = make([]mediaRange, 0, len(parts))
+	)
+
+	for i, part := range parts {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		params := strings.Split(part, ";")
+
+		mainType, subType, ok := strings.Cut(strings.TrimSpace(params[0]), "/")
+		if !ok || mainType == "" || subType == "" {
+			continue
+		}
+
+		if mainType == xhttp.Wildcard && subType != xhttp.Wildcard {
+			continue
+		}
+
+		quality, ok := parseQuality(params[1:])
+		if !ok {
+			continue
+		}
+
+		ranges = append(ranges, mediaRange{
+			mainType: strings.ToLower(mainType),
+			subType:  strings.ToLower(subType),
+			quality:  quality,
+			order:    i,
+		})
+	}
+
+	return ranges
+}
+
+// parseQuality extracts the q parameter from a media range's parameters,
+// defaulting to 1.
+func parseQuality(params []string) (float64, bool) {
+	for _, param := range params {
+		key, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+		if !ok || !strings.EqualFold(strings.TrimSpace(key), "q") {
+			continue
+		}
+
+		quality, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || quality < 0 || quality > 1 {
+			return 0, false
+		}
+
+		return quality, true
+	}
+
+	return 1, true
+}
@@ -0,0 +1,126 @@
+package accept_test
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"git.sr.ht/~jamesponddotco/accio127/internal/accept"
+)
+
+func TestPreferred(t *testing.T) {
+	t.Parallel()
+
+	mediaTypes := []string{"application/json", "application/openmetrics-text", "text/plain"}
+
+	tests := []struct {
+		name    string
+		accept  string
+		want    string
+		wantErr error
+	}{
+		{
+			name: "no_preference",
+			want: "application/json",
+		},
+		{
+			name:   "browser",
+			accept: "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8",
+			want:   "application/json",
+		},
+		{
+			name:   "prometheus",
+			accept: "application/openmetrics-text;version=1.0.0,application/openmetrics-text;version=0.0.1;q=0.75,text/plain;version=0.0.4;q=0.5,*/*;q=0.1",
+			want:   "application/openmetrics-text",
+		},
+		{
+			name:   "text",
+			accept: "text/*",
+			want:   "text/plain",
+		},
+		{
+			name:    "not_acceptable",
+			accept:  "image/png",
+			wantErr: accept.ErrNotAcceptable,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			req, err := http.NewRequest(http.MethodGet, "http://localhost/", http.NoBody)
+			if err != nil {
+				t.Fatalf("Failed to create request: %v", err)
+			}
+
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+
+			got, err := accept.Preferred(req, mediaTypes...)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Preferred() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Errorf("Preferred() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAnything(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		accept string
+		want   bool
+	}{
+		{
+			name: "missing",
+			want: true,
+		},
+		{
+			name:   "wildcard",
+			accept: "*/*",
+			want:   true,
+		},
+		{
+			name:   "wildcard_rejected",
+			accept: "*/*;q=0",
+		},
+		{
+			name:   "media_type",
+			accept: "application/json",
+		},
+		{
+			name:   "browser",
+			accept: "text/html,*/*;q=0.8",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			req, err := http.NewRequest(http.MethodGet, "http://localhost/", http.NoBody)
+			if err != nil {
+				t.Fatalf("Failed to create request: %v", err)
+			}
+
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+
+			if got := accept.Anything(req); got != tt.want {
+				t.Errorf("Anything() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+// Package accesslog writes a line for every request served, either as JSON or
+// in the Common or Combined Log Format understood by most log analyzers.
+package accesslog
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	"git.sr.ht/~jamesponddotco/accio127/internal/config"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// TimeFormat is the format of timestamps in the Common and Combined Log
+// Formats.
+const TimeFormat string = "02/Jan/2006:15:04:05 -0700"
+
+// Entry describes a request served.
+type Entry struct {
+	// Time is when the request was received.
+	Time time.Time
+
+	// Client is the client's IP address, possibly anonymized or hashed. It's
+	// empty if IP addresses are left out of the log.
+	Client string
+
+	// Method, Path, and Proto make up the request line.
+	Method string
+	Path   string
+	Proto  string
+
+	// Route is the route that served the request, such as /v1/ip/:address.
+	// It's empty if no route matched.
+	Route string
+
+	// UserAgent and Referer are the headers of the same name.
+	UserAgent string
+	Referer   string
+
+	// RequestID identifies the request across logs.
+	RequestID string
+
+	// Latency is how long the request took to serve.
+	Latency time.Duration
+
+	// Bytes is the size of the response body.
+	Bytes int64
+
+	// Status is the status code of the response. It's zero for the echo
+	// servers, whose protocols don't have one.
+	Status int
+}
+
+// Logger writes entries to an io.Writer in one of the config.AccessLogFormat
+// formats.
+type Logger struct {
+	// json writes entries in the JSON format. It's nil for the other
+	// formats.
+	json *zap.Logger
+
+	w      io.Writer
+	format string
+	mu     sync.Mutex
+}
+
+// New creates a new Logger writing entries to w in format.
+func New(w io.Writer, format string) *Logger {
+	l := &Logger{
+		w:      w,
+		format: format,
+	}
+
+	if format == config.AccessLogFormatJSON {
+		encoderConfig := zap.NewProductionEncoderConfig()
+		encoderConfig.TimeKey = ""
+		encoderConfig.CallerKey = ""
+		encoderConfig.LevelKey = ""
+
+		l.json = zap.New(zapcore.NewCore(
+			zapcore.NewJSONEncoder(encoderConfig),
+			zapcore.Lock(zapcore.AddSync(w)),
+			zapcore.InfoLevel,
+		))
+	}
+
+	return l
+}
+
+// Log writes entry to the log.
+func (l *Logger) Log(entry *Entry) error {
+	if l.json != nil {
+		l.json.Info("Request served",
+			zap.String("time", entry.Time.UTC().Format(time.RFC3339Nano)),
+			zap.String("client", entry.Client),
+			zap.String("method", entry.Method),
+			zap.String("path", entry.Path),
+			zap.String("proto", entry.Proto),
+			zap.String("route", entry.Route),
+			zap.Int("status", entry.Status),
+			zap.Int64("bytes", entry.Bytes),
+			zap.Duration("latency", entry.Latency),
+			zap.String("userAgent", entry.UserAgent),
+			zap.String("referer", entry.Referer),
+			zap.String("requestId", entry.RequestID),
+		)
+
+		return nil
+	}
+
+	line := common(entry)
+	if l.format == config.AccessLogFormatCombined {
+		line += " " + quote(entry.Referer) + " " + quote(entry.UserAgent)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, err := io.WriteString(l.w, line+"\n"); err != nil {
+		return fmt.Errorf("failed to write access log: %w", err)
+	}
+
+	return nil
+}
+
+// common formats entry in the Common Log Format.
+func common(entry *Entry) string {
+	bytes := "-"
+	if entry.Bytes > 0 {
+		bytes = strconv.FormatInt(entry.Bytes, 10)
+	}
+
+	return fmt.Sprintf(
+		"%s - - [%s] %s %d %s",
+		dash(entry.Client),
+		entry.Time.Format(TimeFormat),
+		quote(entry.Method+" "+entry.Path+" "+entry.Proto),
+		entry.Status,
+		bytes,
+	)
+}
+
+// quote quotes s, escaping the quotes and control characters in it so that
+// clients can't forge log lines.
+func quote(s string) string {
+	if s == "" {
+		return `"-"`
+	}
+
+	return strconv.Quote(s)
+}
+
+// dash returns s, or "-" if it's empty.
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+
+	return s
+}
@@ -0,0 +1,121 @@
+package accesslog_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"git.sr.ht/~jamesponddotco/accio127/internal/accesslog"
+	"git.sr.ht/~jamesponddotco/accio127/internal/config"
+)
+
+func TestLogger_Log(t *testing.T) {
+	t.Parallel()
+
+	entry := &accesslog.Entry{
+		Time:      time.Date(2023, time.June, 1, 13, 55, 36, 0, time.UTC),
+		Client:    "203.0.0.0",
+		Method:    "GET",
+		Path:      "/v1/ip?format=json",
+		Proto:     "HTTP/1.1",
+		Route:     "/v1/ip",
+		UserAgent: `curl/8.0 "quoted"`,
+		RequestID: "abc123",
+		Latency:   1500 * time.Microsecond,
+		Bytes:     42,
+		Status:    200,
+	}
+
+	tests := []struct {
+		name   string
+		format string
+		entry  *accesslog.Entry
+		want   string
+	}{
+		{
+			name:   "common",
+			format: config.AccessLogFormatCommon,
+			entry:  entry,
+			want:   `203.0.0.0 - - [01/Jun/2023:13:55:36 +0000] "GET /v1/ip?format=json HTTP/1.1" 200 42` + "\n",
+		},
+		{
+			name:   "combined",
+			format: config.AccessLogFormatCombined,
+			entry:  entry,
+			want:   `203.0.0.0 - - [01/Jun/2023:13:55:36 +0000] "GET /v1/ip?format=json HTTP/1.1" 200 42 "-" "curl/8.0 \"quoted\""` + "\n",
+		},
+		{
+			name:   "common_omitted_client",
+			format: config.AccessLogFormatCommon,
+			entry: &accesslog.Entry{
+				Time:   entry.Time,
+				Method: "HEAD",
+				Path:   "/v1/ping",
+				Proto:  "HTTP/2.0",
+				Status: 204,
+			},
+			want: `- - - [01/Jun/2023:13:55:36 +0000] "HEAD /v1/ping HTTP/2.0" 204 -` + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+
+			if err := accesslog.New(&buf, tt.format).Log(tt.entry); err != nil {
+				t.Fatalf("Log() error = %v", err)
+			}
+
+			if got := buf.String(); got != tt.want {
+				t.Errorf("Log() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLogger_LogJSON(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+
+	err := accesslog.New(&buf, config.AccessLogFormatJSON).Log(&accesslog.Entry{
+		Time:      time.Date(2023, time.June, 1, 13, 55, 36, 0, time.UTC),
+		Client:    "203.0.0.0",
+		Method:    "GET",
+		Path:      "/v1/ip",
+		Route:     "/v1/ip",
+		RequestID: "abc123",
+		Bytes:     42,
+		Status:    200,
+	})
+	if err != nil {
+		t.Fatalf("Log() error = %v", err)
+	}
+
+	var got map[string]any
+
+	if err = json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("Log() wrote invalid JSON %q: %v", buf.String(), err)
+	}
+
+	want := map[string]any{
+		"time":      "2023-06-01T13:55:36Z",
+		"client":    "203.0.0.0",
+		"method":    "GET",
+		"route":     "/v1/ip",
+		"status":    float64(200),
+		"bytes":     float64(42),
+		"requestId": "abc123",
+	}
+
+	for key, value := range want {
+		if got[key] != value {
+			t.Errorf("Log() %s = %v, want %v", key, got[key], value)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package accesslog
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// filePerm is the permission access log files are created with.
+const filePerm os.FileMode = 0o640
+
+// File is an access log file that can be reopened, so that it can be rotated
+// by moving it away and reopening it. Standard output is used when the path
+// is empty.
+type File struct {
+	file *os.File
+	mu   sync.Mutex
+}
+
+// OpenFile opens the file at path for appending, creating it if needed.
+func OpenFile(path string) (*File, error) {
+	f := &File{}
+
+	if err := f.Reopen(path); err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// Reopen closes the current file and opens the one at path, which may be the
+// same. On error, the current file is kept.
+func (f *File) Reopen(path string) error {
+	file := os.Stdout
+
+	if path != "" {
+		var err error
+
+		file, err = os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, filePerm)
+		if err != nil {
+			return fmt.Errorf("failed to open access log: %w", err)
+		}
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	previous := f.file
+
+	f.file = file
+
+	if previous != nil && previous != os.Stdout {
+		if err := previous.Close(); err != nil {
+			return fmt.Errorf("failed to close previous access log: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Write implements the io.Writer interface.
+func (f *File) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.file.Write(p) //nolint:wrapcheck // must behave like the wrapped file
+}
+
+// Close closes the file, unless it's standard output.
+func (f *File) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == os.Stdout {
+		return nil
+	}
+
+	if err := f.file.Close(); err != nil {
+		return fmt.Errorf("failed to close access log: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,65 @@
+package config
+
+import "fmt"
+
+const (
+	// AccessLogFormatJSON writes access logs as JSON, one request per line.
+	AccessLogFormatJSON string = "json"
+
+	// AccessLogFormatCommon writes access logs in the Common Log Format.
+	AccessLogFormatCommon string = "common"
+
+	// AccessLogFormatCombined writes access logs in the Combined Log Format,
+	// which adds the referrer and user agent to the Common Log Format.
+	AccessLogFormatCombined string = "combined"
+)
+
+const (
+	// AccessLogIPRaw logs the client's IP address as is.
+	AccessLogIPRaw string = "raw"
+
+	// AccessLogIPAnonymized logs the client's IP address truncated to the
+	// default prefix lengths of the /ip/anonymized endpoint.
+	AccessLogIPAnonymized string = "anonymized"
+
+	// AccessLogIPHashed logs the keyed hash of the client's IP address, as
+	// returned by the /ip/hashed endpoint.
+	AccessLogIPHashed string = "hashed"
+
+	// AccessLogIPOmit leaves the client's IP address out of the log.
+	AccessLogIPOmit string = "omit"
+)
+
+// AccessLog configures the access log.
+type AccessLog struct {
+	// Path is the file access logs are appended to. Standard output is used
+	// if it's empty.
+	Path string `json:"path"`
+
+	// Format is one of "json", "common", or "combined".
+	Format string `json:"format"`
+
+	// IP is how the client's IP address is logged, one of "raw",
+	// "anonymized", "hashed", or "omit".
+	IP string `json:"ip"`
+
+	// Enabled enables the access log. It's disabled by default.
+	Enabled bool `json:"enabled"`
+}
+
+// Validate validates the access log configuration.
+func (a AccessLog) Validate() error {
+	switch a.Format {
+	case "", AccessLogFormatJSON, AccessLogFormatCommon, AccessLogFormatCombined:
+	default:
+		return fmt.Errorf("%w: unknown format %q", ErrInvalidAccessLog, a.Format)
+	}
+
+	switch a.IP {
+	case "", AccessLogIPRaw, AccessLogIPAnonymized, AccessLogIPHashed, AccessLogIPOmit:
+	default:
+		return fmt.Errorf("%w: unknown IP mode %q", ErrInvalidAccessLog, a.IP)
+	}
+
+	return nil
+}
@@ -0,0 +1,79 @@
+package config
+
+const (
+	// IPv4Bits is the length of an IPv4 address in bits.
+	IPv4Bits int = 32
+
+	// IPv6Bits is the length of an IPv6 address in bits.
+	IPv6Bits int = 128
+)
+
+// Prefix configures how many leading bits of an address family are kept when
+// anonymizing an IP address.
+type Prefix struct {
+	// Length is the number of bits kept by default.
+	Length int `json:"length"`
+
+	// Min is the smallest length clients may ask for. Defaults to Length.
+	Min int `json:"min"`
+
+	// Max is the largest length clients may ask for. Defaults to Length.
+	Max int `json:"max"`
+}
+
+// Allows reports whether clients may ask for the given prefix length.
+func (p Prefix) Allows(length int) bool {
+	return length >= p.Min && length <= p.Max
+}
+
+// Validate validates the prefix against the length of the address family.
+func (p Prefix) Validate(bits int) error {
+	if p.Min < 0 || p.Min > p.Length || p.Length > p.Max || p.Max > bits {
+		return ErrInvalidAnonymize
+	}
+
+	return nil
+}
+
+// setDefaults fills in the length and bounds of the prefix that weren't
+// configured.
+func (p *Prefix) setDefaults(length int) {
+	if p.Length == 0 {
+		p.Length = length
+	}
+
+	if p.Min == 0 {
+		p.Min = p.Length
+	}
+
+	if p.Max == 0 {
+		p.Max = p.Length
+	}
+}
+
+// Anonymize configures how the /ip/anonymized endpoint truncates IP addresses.
+type Anonymize struct {
+	// IPv4 is the prefix kept from IPv4 addresses.
+	IPv4 Prefix `json:"ipv4"`
+
+	// IPv6 is the prefix kept from IPv6 addresses.
+	IPv6 Prefix `json:"ipv6"`
+}
+
+// Validate validates the anonymization configuration. Lengths and bounds that
+// weren't configured are validated with their default values.
+func (a Anonymize) Validate() error {
+	a.setDefaults()
+
+	if err := a.IPv4.Validate(IPv4Bits); err != nil {
+		return err
+	}
+
+	return a.IPv6.Validate(IPv6Bits)
+}
+
+// setDefaults fills in the prefixes that weren't configured.
+func (a *Anonymize) setDefaults() {
+	a.IPv4.setDefaults(DefaultIPv4Prefix)
+	a.IPv6.setDefaults(DefaultIPv6Prefix)
+}
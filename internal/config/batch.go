@@ -0,0 +1,23 @@
+package config
+
+// Batch configures the endpoint that anonymizes and hashes many IP addresses
+// in a single request.
+type Batch struct {
+	// MaxBodySize is the largest request body accepted, in bytes.
+	MaxBodySize int64 `json:"maxBodySize"`
+
+	// MaxItems is the largest number of addresses accepted per request.
+	MaxItems int `json:"maxItems"`
+
+	// Enabled enables the endpoint. It's disabled by default.
+	Enabled bool `json:"enabled"`
+}
+
+// Validate validates the batch configuration.
+func (b Batch) Validate() error {
+	if b.MaxBodySize < 0 || b.MaxItems < 0 {
+		return ErrInvalidBatch
+	}
+
+	return nil
+}
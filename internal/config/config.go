@@ -25,10 +25,87 @@ const (
 	// address of the reverse proxy.
 	ErrProxyRequired xerrors.Error = "reverse proxy IP address is required"
 
+	// ErrInvalidProxy is returned when a trusted proxy address, CIDR range, or
+	// header is invalid.
+	ErrInvalidProxy xerrors.Error = "invalid reverse proxy"
+
+	// ErrProxyProtocolAllowRequired is returned when the PROXY protocol is
+	// enabled without a list of allowed load balancers.
+	ErrProxyProtocolAllowRequired xerrors.Error = "PROXY protocol requires a list of allowed load balancers"
+
 	// ErrCertRequired is returned when a Config is created without
 	// certification files.
 	ErrCertRequired xerrors.Error = "certification files are required"
 
+	// ErrInvalidTLSMode is returned when the TLS mode is unknown.
+	ErrInvalidTLSMode xerrors.Error = "invalid TLS mode"
+
+	// ErrACMEHostsRequired is returned when ACME is enabled without a list of
+	// hostnames to request certificates for.
+	ErrACMEHostsRequired xerrors.Error = "ACME requires a list of hostnames"
+
+	// ErrInvalidACMEDirectory is returned when the ACME directory URL is
+	// invalid.
+	ErrInvalidACMEDirectory xerrors.Error = "invalid ACME directory URL"
+
+	// ErrInvalidCounter is returned when the counter's flush interval or
+	// batch size is negative.
+	ErrInvalidCounter xerrors.Error = "invalid counter flush settings"
+
+	// ErrInvalidHashAlgorithm is returned when the algorithm used to hash IP
+	// addresses is unknown.
+	ErrInvalidHashAlgorithm xerrors.Error = "invalid hash algorithm"
+
+	// ErrInvalidHashSecret is returned when the secret used to hash IP
+	// addresses is too short or can't be read.
+	ErrInvalidHashSecret xerrors.Error = "invalid hash secret"
+
+	// ErrInvalidHashRotation is returned when the hash key rotation period is
+	// negative.
+	ErrInvalidHashRotation xerrors.Error = "invalid hash key rotation"
+
+	// ErrInvalidAnonymize is returned when an anonymization prefix length or
+	// its bounds don't fit the address family.
+	ErrInvalidAnonymize xerrors.Error = "invalid anonymization prefix"
+
+	// ErrInvalidGeoIP is returned when a GeoIP database path or the interval
+	// between checks for changes is invalid.
+	ErrInvalidGeoIP xerrors.Error = "invalid GeoIP settings"
+
+	// ErrInvalidBatch is returned when the batch endpoint's limits are
+	// invalid.
+	ErrInvalidBatch xerrors.Error = "invalid batch limits"
+
+	// ErrInvalidAccessLog is returned when the access log format or IP mode
+	// is unknown.
+	ErrInvalidAccessLog xerrors.Error = "invalid access log settings"
+
+	// ErrInvalidRateLimit is returned when a rate limiting rule, the number
+	// of clients tracked, or the allow-list is invalid.
+	ErrInvalidRateLimit xerrors.Error = "invalid rate limit"
+
+	// ErrInvalidDualStack is returned when the dual-stack endpoints are
+	// enabled without valid IPv4 and IPv6 URLs, or when the token lifetime
+	// is negative.
+	ErrInvalidDualStack xerrors.Error = "invalid dual-stack settings"
+
+	// ErrInvalidListener is returned when a listener's network, address,
+	// protocol, routes, socket mode, redirect port, or timeouts are invalid.
+	ErrInvalidListener xerrors.Error = "invalid listener"
+
+	// ErrInvalidHTTP3 is returned when the HTTP/3 address, port, or timeouts
+	// are invalid, or when HTTP/3 is enabled without TLS.
+	ErrInvalidHTTP3 xerrors.Error = "invalid HTTP/3 settings"
+
+	// ErrInvalidEcho is returned when an echo server is enabled without a
+	// valid address, or the DNS echo server without a valid name, or when
+	// the TCP timeout is negative.
+	ErrInvalidEcho xerrors.Error = "invalid echo server settings"
+
+	// ErrInvalidSTUN is returned when the STUN server address isn't a valid
+	// host and port, or when its timeout is negative.
+	ErrInvalidSTUN xerrors.Error = "invalid STUN server settings"
+
 	// ErrPrivacyPolicyRequired is returned when a Config is created without a
 	// privacy policy.
 	ErrPrivacyPolicyRequired xerrors.Error = "privacy policy is required"
@@ -44,6 +121,104 @@ const (
 	// DefaultDSN is the default data source name for the SQLite database.
 	DefaultDSN string = "file:/var/share/accio127/sqlite.db?cache=shared&mode=rwc&_pragma_cache_size=-20000&_journal_mode=WAL&_synchronous=NORMAL"
 
+	// DefaultFlushInterval is the default interval between writes of the
+	// access counter to the database.
+	DefaultFlushInterval jsonutil.Duration = jsonutil.Duration(time.Second)
+
+	// DefaultBatchSize is the default number of pending increments that
+	// triggers a write of the access counter.
+	DefaultBatchSize int = 1000
+
+	// DefaultHashAlgorithm is the default algorithm used to hash IP
+	// addresses.
+	DefaultHashAlgorithm string = HashAlgorithmHMACSHA256
+
+	// DefaultIPv4Prefix is the default number of bits kept when anonymizing
+	// IPv4 addresses.
+	DefaultIPv4Prefix int = 16
+
+	// DefaultIPv6Prefix is the default number of bits kept when anonymizing
+	// IPv6 addresses.
+	DefaultIPv6Prefix int = 48
+
+	// DefaultGeoIPCheckInterval is the default interval between checks for
+	// changes to the GeoIP databases.
+	DefaultGeoIPCheckInterval jsonutil.Duration = jsonutil.Duration(time.Minute)
+
+	// DefaultBatchMaxBodySize is the default largest request body accepted by
+	// the batch endpoint, in bytes.
+	DefaultBatchMaxBodySize int64 = 1 << 20
+
+	// DefaultBatchMaxItems is the default largest number of addresses
+	// accepted by the batch endpoint per request.
+	DefaultBatchMaxItems int = 10000
+
+	// DefaultRateLimitRequests is the default number of requests a client
+	// can make per DefaultRateLimitPeriod.
+	DefaultRateLimitRequests int = 60
+
+	// DefaultRateLimitPeriod is the default period over which
+	// DefaultRateLimitRequests are allowed.
+	DefaultRateLimitPeriod jsonutil.Duration = jsonutil.Duration(time.Minute)
+
+	// DefaultRateLimitMaxClients is the default number of clients tracked
+	// per rate limiting rule.
+	DefaultRateLimitMaxClients int = 100000
+
+	// DefaultRateLimitIPv4Prefix is the default number of bits identifying an
+	// IPv4 client.
+	DefaultRateLimitIPv4Prefix int = 32
+
+	// DefaultRateLimitIPv6Prefix is the default number of bits identifying an
+	// IPv6 client, the size of a typical end-user network.
+	DefaultRateLimitIPv6Prefix int = 64
+
+	// DefaultDualStackTokenTTL is the default lifetime of the tokens used by
+	// the dual-stack endpoints.
+	DefaultDualStackTokenTTL jsonutil.Duration = jsonutil.Duration(time.Minute)
+
+	// DefaultAccessLogFormat is the default format of the access log.
+	DefaultAccessLogFormat string = AccessLogFormatJSON
+
+	// DefaultAccessLogIP is the default way the client's IP address is
+	// logged, which keeps access logs in line with the privacy policy.
+	DefaultAccessLogIP string = AccessLogIPAnonymized
+
+	// DefaultListenerSocketMode is the default file mode of the socket of
+	// Unix domain socket listeners.
+	DefaultListenerSocketMode string = "0600"
+
+	// DefaultListenerRedirectPort is the default port redirect listeners
+	// send clients to.
+	DefaultListenerRedirectPort int = 443
+
+	// DefaultHTTP3MaxAge is the default time clients remember that HTTP/3
+	// is available.
+	DefaultHTTP3MaxAge jsonutil.Duration = jsonutil.Duration(24 * time.Hour)
+
+	// DefaultEchoTimeout is the default time writing the address to a TCP
+	// echo connection can take.
+	DefaultEchoTimeout jsonutil.Duration = jsonutil.Duration(5 * time.Second)
+
+	// DefaultSTUNAddress is the default address of the STUN server, on the
+	// port assigned to STUN.
+	DefaultSTUNAddress string = ":3478"
+
+	// DefaultSTUNTimeout is the default time an idle STUN connection is
+	// kept open.
+	DefaultSTUNTimeout jsonutil.Duration = jsonutil.Duration(10 * time.Second)
+
+	// DefaultTLSMode is the default TLS mode of the server.
+	DefaultTLSMode string = TLSModeFiles
+
+	// DefaultACMEDirectoryURL is the default ACME directory, Let's Encrypt's
+	// production environment.
+	DefaultACMEDirectoryURL string = "https://acme-v02.api.letsencrypt.org/directory"
+
+	// DefaultACMECacheDir is the default directory used to store certificates
+	// obtained through ACME.
+	DefaultACMECacheDir string = "/var/lib/accio127/acme"
+
 	// DefaultMinTLSVersion is the default minimum TLS version supported by the
 	// server.
 	DefaultMinTLSVersion string = "TLS13"
@@ -60,18 +235,72 @@ const (
 
 // Config holds shared configuration values for the application.
 type Config struct {
-	// Address is the address of the application.
+	// Address is the address of the application. It's only used if
+	// Listeners is empty.
 	Address string `json:"address"`
 
-	// Proxy is the IP address of the trusted reverse proxy.
-	Proxy string `json:"proxy"`
+	// Listeners is the list of sockets the server accepts connections on.
+	// Defaults to a single listener on Address.
+	Listeners []Listener `json:"listeners"`
+
+	// Proxy is the list of trusted reverse proxies and the headers they use to
+	// forward the client's IP address.
+	Proxy Proxies `json:"proxy"`
+
+	// HTTP3 configures the HTTP/3 listener.
+	HTTP3 HTTP3 `json:"http3"`
+
+	// Echo configures the servers returning the client's IP address over
+	// plain TCP, UDP, and DNS.
+	Echo Echo `json:"echo"`
+
+	// STUN configures the STUN server returning the client's NAT-mapped
+	// address and port.
+	STUN STUN `json:"stun"`
+
+	// ProxyProtocol configures support for the PROXY protocol.
+	ProxyProtocol ProxyProtocol `json:"proxyProtocol"`
 
 	// PID is the path to the process ID file.
 	PID string `json:"pid"`
 
-	// DSN is the data source name for the SQLite database.
+	// DSN is the data source name of the database. Its scheme selects the
+	// driver: "postgres://" for PostgreSQL, "memory://" to keep everything in
+	// memory, and SQLite otherwise.
 	DSN string `json:"dsn"`
 
+	// Counter configures how the access counter is written to the database.
+	Counter Counter `json:"counter"`
+
+	// TLS configures how the server handles TLS.
+	TLS TLS `json:"tls"`
+
+	// Hash configures how IP addresses are hashed.
+	Hash Hash `json:"hash"`
+
+	// Anonymize configures how IP addresses are anonymized.
+	Anonymize Anonymize `json:"anonymize"`
+
+	// GeoIP configures the offline GeoIP and ASN lookups.
+	GeoIP GeoIP `json:"geoip"`
+
+	// Lookup configures the endpoints describing arbitrary IP addresses.
+	Lookup Lookup `json:"lookup"`
+
+	// Batch configures the endpoint anonymizing and hashing many IP
+	// addresses at once.
+	Batch Batch `json:"batch"`
+
+	// RateLimit configures per-client rate limiting.
+	RateLimit RateLimit `json:"rateLimit"`
+
+	// DualStack configures the endpoints reporting both the IPv4 and the
+	// IPv6 address of a client.
+	DualStack DualStack `json:"dualStack"`
+
+	// AccessLog configures the access log.
+	AccessLog AccessLog `json:"accessLog"`
+
 	// CertFile is the path to the certificate file.
 	CertFile string `json:"certFile"`
 
@@ -92,6 +321,9 @@ type Config struct {
 
 	// IdleTimeout is the idle timeout for the server.
 	IdleTimeout jsonutil.Duration `json:"idleTimeout"`
+
+	// path is the file the configuration was loaded from.
+	path string
 }
 
 // LoadConfig loads the configuration from a file.
@@ -116,6 +348,16 @@ func LoadConfig(path string) (*Config, error) {
 		return nil, fmt.Errorf("%w: %w", ErrInvalidConfigFile, err)
 	}
 
+	cfg.path = path
+
+	if err := cfg.Hash.loadSecret(); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidConfigFile, err)
+	}
+
+	if cfg.Hash.Algorithm == "" {
+		cfg.Hash.Algorithm = DefaultHashAlgorithm
+	}
+
 	if cfg.Address == "" {
 		cfg.Address = DefaultAddress
 	}
@@ -140,20 +382,164 @@ func LoadConfig(path string) (*Config, error) {
 		cfg.DSN = DefaultDSN
 	}
 
+	if cfg.Counter.FlushInterval == 0 {
+		cfg.Counter.FlushInterval = DefaultFlushInterval
+	}
+
+	if cfg.Counter.BatchSize == 0 {
+		cfg.Counter.BatchSize = DefaultBatchSize
+	}
+
+	cfg.Anonymize.setDefaults()
+
+	if cfg.GeoIP.CheckInterval == 0 {
+		cfg.GeoIP.CheckInterval = DefaultGeoIPCheckInterval
+	}
+
+	if cfg.Batch.MaxBodySize == 0 {
+		cfg.Batch.MaxBodySize = DefaultBatchMaxBodySize
+	}
+
+	if cfg.Batch.MaxItems == 0 {
+		cfg.Batch.MaxItems = DefaultBatchMaxItems
+	}
+
+	cfg.RateLimit.RateLimitRule = cfg.RateLimit.RateLimitRule.withDefaults(RateLimitRule{
+		Period:   DefaultRateLimitPeriod,
+		Requests: DefaultRateLimitRequests,
+	})
+
+	if cfg.RateLimit.MaxClients == 0 {
+		cfg.RateLimit.MaxClients = DefaultRateLimitMaxClients
+	}
+
+	if cfg.RateLimit.IPv4Prefix == 0 {
+		cfg.RateLimit.IPv4Prefix = DefaultRateLimitIPv4Prefix
+	}
+
+	if cfg.RateLimit.IPv6Prefix == 0 {
+		cfg.RateLimit.IPv6Prefix = DefaultRateLimitIPv6Prefix
+	}
+
+	if cfg.DualStack.TokenTTL == 0 {
+		cfg.DualStack.TokenTTL = DefaultDualStackTokenTTL
+	}
+
+	if cfg.Echo.TCP.Timeout == 0 {
+		cfg.Echo.TCP.Timeout = DefaultEchoTimeout
+	}
+
+	if cfg.STUN.Address == "" {
+		cfg.STUN.Address = DefaultSTUNAddress
+	}
+
+	if cfg.STUN.Timeout == 0 {
+		cfg.STUN.Timeout = DefaultSTUNTimeout
+	}
+
+	if cfg.AccessLog.Format == "" {
+		cfg.AccessLog.Format = DefaultAccessLogFormat
+	}
+
+	if cfg.AccessLog.IP == "" {
+		cfg.AccessLog.IP = DefaultAccessLogIP
+	}
+
+	if cfg.TLS.Mode == "" {
+		cfg.TLS.Mode = DefaultTLSMode
+	}
+
+	if cfg.TLS.ACME.DirectoryURL == "" {
+		cfg.TLS.ACME.DirectoryURL = DefaultACMEDirectoryURL
+	}
+
+	if cfg.TLS.ACME.CacheDir == "" {
+		cfg.TLS.ACME.CacheDir = DefaultACMECacheDir
+	}
+
 	if cfg.MinTLSVersion != "TLS12" && cfg.MinTLSVersion != "TLS13" {
 		cfg.MinTLSVersion = DefaultMinTLSVersion
 	}
 
+	cfg.setListenerDefaults()
+	cfg.setHTTP3Defaults()
+
 	return cfg, nil
 }
 
+// Path returns the path of the file the configuration was loaded from, or an
+// empty string if it wasn't loaded from a file.
+func (cfg *Config) Path() string {
+	return cfg.path
+}
+
 // Validate validates the configuration.
 func (cfg *Config) Validate() error {
-	if cfg.Proxy == "" {
+	// Load balancers using the PROXY protocol forward the client's address
+	// without the help of a reverse proxy.
+	if len(cfg.Proxy) == 0 && !cfg.ProxyProtocol.Enabled {
 		return ErrProxyRequired
 	}
 
-	if cfg.CertFile == "" || cfg.CertKey == "" {
+	if err := cfg.ProxyProtocol.Validate(); err != nil {
+		return err
+	}
+
+	if err := cfg.Counter.Validate(); err != nil {
+		return err
+	}
+
+	if err := cfg.TLS.Validate(); err != nil {
+		return err
+	}
+
+	if err := cfg.Hash.Validate(); err != nil {
+		return err
+	}
+
+	if err := cfg.Anonymize.Validate(); err != nil {
+		return err
+	}
+
+	if err := cfg.GeoIP.Validate(); err != nil {
+		return err
+	}
+
+	if err := cfg.Batch.Validate(); err != nil {
+		return err
+	}
+
+	if err := cfg.RateLimit.Validate(); err != nil {
+		return err
+	}
+
+	if err := cfg.DualStack.Validate(); err != nil {
+		return err
+	}
+
+	for _, listener := range cfg.Listeners {
+		if err := listener.Validate(cfg.TLS.Mode != TLSModeOff); err != nil {
+			return err
+		}
+	}
+
+	if err := cfg.HTTP3.Validate(cfg.TLS.Mode != TLSModeOff, cfg.Listeners); err != nil {
+		return err
+	}
+
+	if err := cfg.Echo.Validate(); err != nil {
+		return err
+	}
+
+	if err := cfg.STUN.Validate(); err != nil {
+		return err
+	}
+
+	if err := cfg.AccessLog.Validate(); err != nil {
+		return err
+	}
+
+	if cfg.TLS.UsesFiles() && (cfg.CertFile == "" || cfg.CertKey == "") {
 		return ErrCertRequired
 	}
 
@@ -19,6 +19,191 @@ func TestLoadConfig(t *testing.T) {
 			path:    "testdata/valid-config.json",
 			wantErr: false,
 		},
+		{
+			name:    "valid_config_proxy_list",
+			path:    "testdata/valid-proxy-list-config.json",
+			wantErr: false,
+		},
+		{
+			name:    "valid_config_proxy_groups",
+			path:    "testdata/valid-proxy-groups-config.json",
+			wantErr: false,
+		},
+		{
+			name:    "invalid_config_proxy_cidr",
+			path:    "testdata/invalid-proxy-cidr-config.json",
+			wantErr: true,
+		},
+		{
+			name:    "valid_config_proxy_protocol",
+			path:    "testdata/valid-proxy-protocol-config.json",
+			wantErr: false,
+		},
+		{
+			name:    "invalid_config_proxy_protocol_missing_allow",
+			path:    "testdata/invalid-proxy-protocol-missing-allow-config.json",
+			wantErr: true,
+		},
+		{
+			name:    "valid_config_tls_off_without_cert",
+			path:    "testdata/valid-tls-off-config.json",
+			wantErr: false,
+		},
+		{
+			name:    "valid_config_tls_acme",
+			path:    "testdata/valid-tls-acme-config.json",
+			wantErr: false,
+		},
+		{
+			name:    "invalid_config_tls_acme_missing_hosts",
+			path:    "testdata/invalid-tls-acme-missing-hosts-config.json",
+			wantErr: true,
+		},
+		{
+			name:    "invalid_config_tls_mode",
+			path:    "testdata/invalid-tls-mode-config.json",
+			wantErr: true,
+		},
+		{
+			name:    "valid_config_counter",
+			path:    "testdata/valid-counter-config.json",
+			wantErr: false,
+		},
+		{
+			name:    "invalid_config_counter_negative_batch_size",
+			path:    "testdata/invalid-counter-config.json",
+			wantErr: true,
+		},
+		{
+			name:    "valid_config_hash",
+			path:    "testdata/valid-hash-config.json",
+			wantErr: false,
+		},
+		{
+			name:    "valid_config_hash_secret_file",
+			path:    "testdata/valid-hash-secret-file-config.json",
+			wantErr: false,
+		},
+		{
+			name:    "invalid_config_hash_short_secret",
+			path:    "testdata/invalid-hash-secret-config.json",
+			wantErr: true,
+		},
+		{
+			name:    "invalid_config_hash_algorithm",
+			path:    "testdata/invalid-hash-algorithm-config.json",
+			wantErr: true,
+		},
+		{
+			name:    "valid_config_anonymize",
+			path:    "testdata/valid-anonymize-config.json",
+			wantErr: false,
+		},
+		{
+			name:    "invalid_config_anonymize_max_too_long",
+			path:    "testdata/invalid-anonymize-config.json",
+			wantErr: true,
+		},
+		{
+			name:    "valid_config_geoip",
+			path:    "testdata/valid-geoip-config.json",
+			wantErr: false,
+		},
+		{
+			name:    "invalid_config_geoip_empty_database",
+			path:    "testdata/invalid-geoip-config.json",
+			wantErr: true,
+		},
+		{
+			name:    "valid_config_lookup",
+			path:    "testdata/valid-lookup-config.json",
+			wantErr: false,
+		},
+		{
+			name:    "valid_config_batch",
+			path:    "testdata/valid-batch-config.json",
+			wantErr: false,
+		},
+		{
+			name:    "invalid_config_batch_negative_max_items",
+			path:    "testdata/invalid-batch-config.json",
+			wantErr: true,
+		},
+		{
+			name:    "valid_config_rate_limit",
+			path:    "testdata/valid-ratelimit-config.json",
+			wantErr: false,
+		},
+		{
+			name:    "invalid_config_rate_limit_allow",
+			path:    "testdata/invalid-ratelimit-config.json",
+			wantErr: true,
+		},
+		{
+			name:    "valid_config_dual_stack",
+			path:    "testdata/valid-dualstack-config.json",
+			wantErr: false,
+		},
+		{
+			name:    "invalid_config_dual_stack_url",
+			path:    "testdata/invalid-dualstack-config.json",
+			wantErr: true,
+		},
+		{
+			name:    "valid_config_listeners",
+			path:    "testdata/valid-listeners-config.json",
+			wantErr: false,
+		},
+		{
+			name:    "invalid_config_listener_https_without_tls",
+			path:    "testdata/invalid-listeners-config.json",
+			wantErr: true,
+		},
+		{
+			name:    "invalid_config_unix_listener_ip_route",
+			path:    "testdata/invalid-unix-listener-config.json",
+			wantErr: true,
+		},
+		{
+			name:    "valid_config_http3",
+			path:    "testdata/valid-http3-config.json",
+			wantErr: false,
+		},
+		{
+			name:    "invalid_config_http3_without_tls",
+			path:    "testdata/invalid-http3-config.json",
+			wantErr: true,
+		},
+		{
+			name:    "valid_config_echo",
+			path:    "testdata/valid-echo-config.json",
+			wantErr: false,
+		},
+		{
+			name:    "invalid_config_echo_dns_without_name",
+			path:    "testdata/invalid-echo-config.json",
+			wantErr: true,
+		},
+		{
+			name:    "valid_config_stun",
+			path:    "testdata/valid-stun-config.json",
+			wantErr: false,
+		},
+		{
+			name:    "invalid_config_stun_address",
+			path:    "testdata/invalid-stun-config.json",
+			wantErr: true,
+		},
+		{
+			name:    "valid_config_access_log",
+			path:    "testdata/valid-accesslog-config.json",
+			wantErr: false,
+		},
+		{
+			name:    "invalid_config_access_log_format",
+			path:    "testdata/invalid-accesslog-config.json",
+			wantErr: true,
+		},
 		{
 			name:    "invalid_config_missing_proxy",
 			path:    "testdata/invalid-missing-proxy-config.json",
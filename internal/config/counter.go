@@ -0,0 +1,24 @@
+package config
+
+import "git.sr.ht/~jamesponddotco/accio127/internal/jsonutil"
+
+// Counter configures how the access counter and statistics are written to the
+// database.
+type Counter struct {
+	// FlushInterval is how often pending increments are written to the
+	// database.
+	FlushInterval jsonutil.Duration `json:"flushInterval"`
+
+	// BatchSize is the number of pending increments that triggers a write
+	// before FlushInterval elapses.
+	BatchSize int `json:"batchSize"`
+}
+
+// Validate validates the counter configuration.
+func (c Counter) Validate() error {
+	if c.FlushInterval < 0 || c.BatchSize < 0 {
+		return ErrInvalidCounter
+	}
+
+	return nil
+}
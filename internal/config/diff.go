@@ -0,0 +1,61 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// Change describes a setting that differs between two configurations.
+type Change struct {
+	// Setting is the JSON name of the setting.
+	Setting string
+
+	// Old is the JSON representation of the previous value.
+	Old string
+
+	// New is the JSON representation of the current value.
+	New string
+}
+
+// Diff returns the top-level settings that differ between previous and
+// current, in the order they're declared in Config.
+func Diff(previous, current *Config) []Change {
+	var (
+		changes       []Change
+		configType    = reflect.TypeOf(Config{})
+		previousValue = reflect.ValueOf(previous).Elem()
+		currentValue  = reflect.ValueOf(current).Elem()
+	)
+
+	for i := 0; i < configType.NumField(); i++ {
+		field := configType.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			name = field.Name
+		}
+
+		// Marshaling can't fail for the types used in Config, and comparing
+		// the JSON representation keeps unexported, derived state out of the
+		// comparison.
+		oldJSON, _ := json.Marshal(previousValue.Field(i).Interface()) //nolint:errchkjson // see above
+		newJSON, _ := json.Marshal(currentValue.Field(i).Interface())  //nolint:errchkjson // see above
+
+		if bytes.Equal(oldJSON, newJSON) {
+			continue
+		}
+
+		changes = append(changes, Change{
+			Setting: name,
+			Old:     string(oldJSON),
+			New:     string(newJSON),
+		})
+	}
+
+	return changes
+}
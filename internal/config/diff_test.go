@@ -0,0 +1,91 @@
+package config_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"git.sr.ht/~jamesponddotco/accio127/internal/config"
+	"git.sr.ht/~jamesponddotco/accio127/internal/jsonutil"
+)
+
+func TestDiff(t *testing.T) {
+	t.Parallel()
+
+	previous, err := config.LoadConfig("testdata/valid-config.json")
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(cfg *config.Config)
+		want   []config.Change
+	}{
+		{
+			name:   "no_changes",
+			modify: func(cfg *config.Config) {},
+			want:   nil,
+		},
+		{
+			name: "privacy_policy_and_timeout",
+			modify: func(cfg *config.Config) {
+				cfg.PrivacyPolicy = "https://example.com/privacy"
+				cfg.ReadTimeout = jsonutil.Duration(time.Second)
+			},
+			want: []config.Change{
+				{
+					Setting: "privacyPolicy",
+					Old:     `"https://example.com/privacy-policy"`,
+					New:     `"https://example.com/privacy"`,
+				},
+				{
+					Setting: "readTimeout",
+					Old:     `"5s"`,
+					New:     `"1s"`,
+				},
+			},
+		},
+		{
+			name: "nested_setting",
+			modify: func(cfg *config.Config) {
+				cfg.TLS.Mode = config.TLSModeOff
+			},
+			want: []config.Change{
+				{
+					Setting: "tls",
+					Old:     `{"mode":"files","acme":{"directoryURL":"https://acme-v02.api.letsencrypt.org/directory","cacheDir":"/var/lib/accio127/acme","email":"","hosts":null}}`,
+					New:     `{"mode":"off","acme":{"directoryURL":"https://acme-v02.api.letsencrypt.org/directory","cacheDir":"/var/lib/accio127/acme","email":"","hosts":null}}`,
+				},
+			},
+		},
+		{
+			name: "redacted_secret",
+			modify: func(cfg *config.Config) {
+				cfg.Hash.Secret = "0123456789abcdef0123456789abcdef"
+			},
+			want: []config.Change{
+				{
+					Setting: "hash",
+					Old:     `{"algorithm":"hmac-sha256","secret":"","secretFile":"","rotation":"0s"}`,
+					New:     `{"algorithm":"hmac-sha256","secret":"sha256:3eb1bd43","secretFile":"","rotation":"0s"}`,
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			current := *previous
+			tt.modify(&current)
+
+			if got := config.Diff(previous, &current); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Diff() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
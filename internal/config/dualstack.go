@@ -0,0 +1,49 @@
+package config
+
+import (
+	"net/url"
+
+	"git.sr.ht/~jamesponddotco/accio127/internal/jsonutil"
+)
+
+// DualStack configures the endpoints reporting both the IPv4 and the IPv6
+// address of a client.
+//
+// A single connection only ever uses one address family, so the client is
+// sent to two hostnames, one only reachable over IPv4 and the other only over
+// IPv6, carrying a token that ties its requests together.
+type DualStack struct {
+	// IPv4URL is the base URL of the server on a hostname with only an A
+	// record, such as "https://ipv4.accio127.com".
+	IPv4URL string `json:"ipv4URL"`
+
+	// IPv6URL is the base URL of the server on a hostname with only an AAAA
+	// record, such as "https://ipv6.accio127.com".
+	IPv6URL string `json:"ipv6URL"`
+
+	// TokenTTL is how long a token is valid for.
+	TokenTTL jsonutil.Duration `json:"tokenTTL"`
+
+	// Enabled enables the endpoints. It's disabled by default.
+	Enabled bool `json:"enabled"`
+}
+
+// Validate validates the dual-stack configuration.
+func (d DualStack) Validate() error {
+	if d.TokenTTL < 0 {
+		return ErrInvalidDualStack
+	}
+
+	if !d.Enabled {
+		return nil
+	}
+
+	for _, raw := range []string{d.IPv4URL, d.IPv6URL} {
+		u, err := url.Parse(raw)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return ErrInvalidDualStack
+		}
+	}
+
+	return nil
+}
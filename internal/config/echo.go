@@ -0,0 +1,96 @@
+package config
+
+import (
+	"net"
+
+	"git.sr.ht/~jamesponddotco/accio127/internal/jsonutil"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// Echo configures the servers returning the client's IP address without
+// HTTP, for clients such as embedded devices and scripts.
+type Echo struct {
+	// TCP configures the server writing the client's address to every
+	// connection and closing it.
+	TCP EchoServer `json:"tcp"`
+
+	// UDP configures the server answering every datagram with its source
+	// address.
+	UDP EchoServer `json:"udp"`
+
+	// DNS configures the authoritative DNS server answering queries for a
+	// name with the resolver's address.
+	DNS DNSEcho `json:"dns"`
+}
+
+// EchoServer configures a TCP or UDP echo server.
+type EchoServer struct {
+	// Address is the host and port to listen on.
+	Address string `json:"address"`
+
+	// Timeout is how long writing the address to a TCP connection can take.
+	Timeout jsonutil.Duration `json:"timeout"`
+
+	// Enabled enables the server. It's disabled by default.
+	Enabled bool `json:"enabled"`
+}
+
+// Validate validates the echo server configuration.
+func (e EchoServer) Validate() error {
+	if e.Timeout < 0 {
+		return ErrInvalidEcho
+	}
+
+	if !e.Enabled {
+		return nil
+	}
+
+	if _, _, err := net.SplitHostPort(e.Address); err != nil {
+		return ErrInvalidEcho
+	}
+
+	return nil
+}
+
+// DNSEcho configures the DNS echo server.
+type DNSEcho struct {
+	// Address is the UDP host and port to listen on, usually ":53".
+	Address string `json:"address"`
+
+	// Name is the domain name answered for, such as "ip.accio127.com". It
+	// must be delegated to the server with an NS record.
+	Name string `json:"name"`
+
+	// Enabled enables the server. It's disabled by default.
+	Enabled bool `json:"enabled"`
+}
+
+// Validate validates the DNS echo server configuration.
+func (d DNSEcho) Validate() error {
+	if !d.Enabled {
+		return nil
+	}
+
+	if _, _, err := net.SplitHostPort(d.Address); err != nil {
+		return ErrInvalidEcho
+	}
+
+	if _, err := dnsmessage.NewName(d.Name); d.Name == "" || err != nil {
+		return ErrInvalidEcho
+	}
+
+	return nil
+}
+
+// Validate validates the echo servers' configuration.
+func (e Echo) Validate() error {
+	if err := e.TCP.Validate(); err != nil {
+		return err
+	}
+
+	if err := e.UDP.Validate(); err != nil {
+		return err
+	}
+
+	return e.DNS.Validate()
+}
@@ -0,0 +1,34 @@
+package config
+
+import "git.sr.ht/~jamesponddotco/accio127/internal/jsonutil"
+
+// GeoIP configures the offline GeoIP and ASN lookups.
+type GeoIP struct {
+	// Databases is the list of MaxMind DB files, such as GeoLite2 City and
+	// ASN, to look addresses up in. Lookups are disabled if it's empty.
+	Databases []string `json:"databases"`
+
+	// CheckInterval is how often the files are checked for changes and
+	// reloaded.
+	CheckInterval jsonutil.Duration `json:"checkInterval"`
+}
+
+// Enabled reports whether GeoIP lookups are enabled.
+func (g GeoIP) Enabled() bool {
+	return len(g.Databases) > 0
+}
+
+// Validate validates the GeoIP configuration.
+func (g GeoIP) Validate() error {
+	if g.CheckInterval < 0 {
+		return ErrInvalidGeoIP
+	}
+
+	for _, database := range g.Databases {
+		if database == "" {
+			return ErrInvalidGeoIP
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,107 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"git.sr.ht/~jamesponddotco/accio127/internal/jsonutil"
+)
+
+const (
+	// HashAlgorithmHMACSHA256 hashes IP addresses with HMAC-SHA256.
+	HashAlgorithmHMACSHA256 string = "hmac-sha256"
+
+	// HashAlgorithmBLAKE2b hashes IP addresses with keyed BLAKE2b-256.
+	HashAlgorithmBLAKE2b string = "blake2b"
+)
+
+// MinHashSecretLength is the minimum length of the secret used to hash IP
+// addresses, in bytes.
+const MinHashSecretLength int = 32
+
+// Hash configures how the /ip/hashed endpoint hashes IP addresses.
+type Hash struct {
+	// Algorithm is either "hmac-sha256" or "blake2b".
+	Algorithm string `json:"algorithm"`
+
+	// Secret is the key IP addresses are hashed with. If neither Secret nor
+	// SecretFile are set, a random secret is generated on startup.
+	Secret string `json:"secret"`
+
+	// SecretFile is the path to a file holding the secret, as an alternative
+	// to keeping it in the configuration file.
+	SecretFile string `json:"secretFile"`
+
+	// Rotation is how often the key derived from the secret changes, making
+	// hashes unlinkable across periods. Periods are aligned to the Unix
+	// epoch, so a rotation of 24h changes keys at midnight UTC. Zero disables
+	// rotation.
+	Rotation jsonutil.Duration `json:"rotation"`
+}
+
+// Validate validates the hash configuration.
+func (h Hash) Validate() error {
+	switch h.Algorithm {
+	case "", HashAlgorithmHMACSHA256, HashAlgorithmBLAKE2b:
+	default:
+		return fmt.Errorf("%w: %q", ErrInvalidHashAlgorithm, h.Algorithm)
+	}
+
+	if h.Secret != "" && h.SecretFile != "" {
+		return fmt.Errorf("%w: secret and secretFile are mutually exclusive", ErrInvalidHashSecret)
+	}
+
+	if h.Secret != "" && len(h.Secret) < MinHashSecretLength {
+		return fmt.Errorf("%w: must be at least %d bytes long", ErrInvalidHashSecret, MinHashSecretLength)
+	}
+
+	if h.Rotation < 0 {
+		return ErrInvalidHashRotation
+	}
+
+	return nil
+}
+
+// loadSecret reads the secret from SecretFile, if set.
+func (h *Hash) loadSecret() error {
+	if h.SecretFile == "" {
+		return nil
+	}
+
+	secret, err := os.ReadFile(h.SecretFile)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidHashSecret, err)
+	}
+
+	h.Secret = strings.TrimSpace(string(secret))
+
+	if len(h.Secret) < MinHashSecretLength {
+		return fmt.Errorf("%w: must be at least %d bytes long", ErrInvalidHashSecret, MinHashSecretLength)
+	}
+
+	return nil
+}
+
+// MarshalJSON implements the json.Marshaler interface. The secret is replaced
+// by a fingerprint, so that it can be compared without being disclosed.
+func (h Hash) MarshalJSON() ([]byte, error) {
+	type hash Hash
+
+	redacted := hash(h)
+
+	if redacted.Secret != "" {
+		sum := sha256.Sum256([]byte(redacted.Secret))
+		redacted.Secret = "sha256:" + hex.EncodeToString(sum[:4])
+	}
+
+	b, err := json.Marshal(redacted)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal hash configuration: %w", err)
+	}
+
+	return b, nil
+}
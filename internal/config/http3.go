@@ -0,0 +1,101 @@
+package config
+
+import (
+	"net"
+	"strconv"
+
+	"git.sr.ht/~jamesponddotco/accio127/internal/jsonutil"
+)
+
+// HTTP3 configures the HTTP/3 listener, which serves the routes of the first
+// HTTPS listener over QUIC with the same certificates.
+type HTTP3 struct {
+	// Address is the UDP host and port to listen on. Defaults to the address
+	// of the first HTTPS listener.
+	Address string `json:"address"`
+
+	// Port is the port advertised in the Alt-Svc header. Defaults to the port
+	// of Address, and only needs to be set if a firewall or load balancer
+	// forwards another port to it.
+	Port int `json:"port"`
+
+	// MaxAge is how long clients remember that HTTP/3 is available.
+	MaxAge jsonutil.Duration `json:"maxAge"`
+
+	// IdleTimeout is how long an idle QUIC connection is kept open. Defaults
+	// to the server's idle timeout.
+	IdleTimeout jsonutil.Duration `json:"idleTimeout"`
+
+	// Enabled enables the listener. It's disabled by default.
+	Enabled bool `json:"enabled"`
+}
+
+// Validate validates the HTTP/3 configuration. TLS must be enabled, with at
+// least one HTTPS listener if the listeners are configured.
+func (h HTTP3) Validate(tlsEnabled bool, listeners []Listener) error {
+	if h.Port < 0 || h.Port > 65535 || h.MaxAge < 0 || h.IdleTimeout < 0 {
+		return ErrInvalidHTTP3
+	}
+
+	if h.Address != "" {
+		if _, _, err := net.SplitHostPort(h.Address); err != nil {
+			return ErrInvalidHTTP3
+		}
+	}
+
+	if !h.Enabled {
+		return nil
+	}
+
+	if !tlsEnabled {
+		return ErrInvalidHTTP3
+	}
+
+	if len(listeners) == 0 {
+		return nil
+	}
+
+	for _, listener := range listeners {
+		// Listeners without a protocol default to HTTPS when TLS is enabled,
+		// unless they're Unix domain sockets.
+		if listener.Protocol == ListenerProtocolHTTPS || (listener.Protocol == "" && listener.Network != ListenerNetworkUnix) {
+			return nil
+		}
+	}
+
+	return ErrInvalidHTTP3
+}
+
+// HTTPSListener returns the index of the first HTTPS listener, whose routes
+// are served over HTTP/3, or -1 if there's none.
+func (cfg *Config) HTTPSListener() int {
+	for i, listener := range cfg.Listeners {
+		if listener.Protocol == ListenerProtocolHTTPS {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// setHTTP3Defaults fills in the HTTP/3 settings that weren't configured. It
+// must run after the listeners' defaults are set.
+func (cfg *Config) setHTTP3Defaults() {
+	if cfg.HTTP3.MaxAge == 0 {
+		cfg.HTTP3.MaxAge = DefaultHTTP3MaxAge
+	}
+
+	if cfg.HTTP3.IdleTimeout == 0 {
+		cfg.HTTP3.IdleTimeout = cfg.IdleTimeout
+	}
+
+	if i := cfg.HTTPSListener(); cfg.HTTP3.Address == "" && i >= 0 {
+		cfg.HTTP3.Address = cfg.Listeners[i].Address
+	}
+
+	if cfg.HTTP3.Port == 0 {
+		if _, port, err := net.SplitHostPort(cfg.HTTP3.Address); err == nil {
+			cfg.HTTP3.Port, _ = strconv.Atoi(port)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"git.sr.ht/~jamesponddotco/accio127/internal/endpoint"
+	"git.sr.ht/~jamesponddotco/accio127/internal/jsonutil"
+)
+
+const (
+	// ListenerNetworkTCP listens on both IPv4 and IPv6, or on the address
+	// family of the host if it's given.
+	ListenerNetworkTCP string = "tcp"
+
+	// ListenerNetworkTCP4 only listens on IPv4.
+	ListenerNetworkTCP4 string = "tcp4"
+
+	// ListenerNetworkTCP6 only listens on IPv6.
+	ListenerNetworkTCP6 string = "tcp6"
+
+	// ListenerNetworkUnix listens on a Unix domain socket.
+	ListenerNetworkUnix string = "unix"
+)
+
+const (
+	// ListenerProtocolHTTPS serves the API over TLS, as configured by the TLS
+	// settings.
+	ListenerProtocolHTTPS string = "https"
+
+	// ListenerProtocolHTTP serves the API over plain HTTP.
+	ListenerProtocolHTTP string = "http"
+
+	// ListenerProtocolRedirect serves plain HTTP, redirecting every request
+	// to HTTPS.
+	ListenerProtocolRedirect string = "redirect"
+)
+
+// Listener configures a socket the server accepts connections on.
+type Listener struct {
+	// Network is one of "tcp", "tcp4", "tcp6", or "unix".
+	Network string `json:"network"`
+
+	// Address is the host and port to listen on, or the path of the socket
+	// for "unix" listeners.
+	Address string `json:"address"`
+
+	// Protocol is one of "https", the default unless TLS is off, "http", the
+	// default for "unix" listeners, or "redirect".
+	Protocol string `json:"protocol"`
+
+	// Routes is the list of routes served, such as "/v1/ip" or
+	// "/v1/metrics". Every route is served if it's empty, except by "unix"
+	// listeners, which default to the AdminRoutes and can't serve others.
+	// Redirect listeners ignore it.
+	Routes []string `json:"routes"`
+
+	// SocketMode is the octal file mode of the socket of "unix" listeners.
+	// Defaults to "0600".
+	SocketMode string `json:"socketMode"`
+
+	// RedirectPort is the port redirect listeners send clients to. Defaults
+	// to 443.
+	RedirectPort int `json:"redirectPort"`
+
+	// ReadTimeout is the read timeout of the listener. Defaults to the
+	// server's.
+	ReadTimeout jsonutil.Duration `json:"readTimeout"`
+
+	// WriteTimeout is the write timeout of the listener. Defaults to the
+	// server's.
+	WriteTimeout jsonutil.Duration `json:"writeTimeout"`
+
+	// IdleTimeout is the idle timeout of the listener. Defaults to the
+	// server's.
+	IdleTimeout jsonutil.Duration `json:"idleTimeout"`
+}
+
+// Validate validates the listener. TLS must be enabled for "https" listeners.
+func (l Listener) Validate(tlsEnabled bool) error {
+	switch l.Network {
+	case "", ListenerNetworkTCP, ListenerNetworkTCP4, ListenerNetworkTCP6:
+		if _, _, err := net.SplitHostPort(l.Address); err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidListener, err)
+		}
+	case ListenerNetworkUnix:
+		if l.Address == "" {
+			return fmt.Errorf("%w: missing socket path", ErrInvalidListener)
+		}
+	default:
+		return fmt.Errorf("%w: unknown network %q", ErrInvalidListener, l.Network)
+	}
+
+	switch l.Protocol {
+	case "", ListenerProtocolHTTP, ListenerProtocolRedirect:
+	case ListenerProtocolHTTPS:
+		if !tlsEnabled {
+			return fmt.Errorf("%w: %s requires TLS", ErrInvalidListener, l.Address)
+		}
+	default:
+		return fmt.Errorf("%w: unknown protocol %q", ErrInvalidListener, l.Protocol)
+	}
+
+	for _, route := range l.Routes {
+		if !strings.HasPrefix(route, "/") {
+			return fmt.Errorf("%w: invalid route %q", ErrInvalidListener, route)
+		}
+
+		if l.Network == ListenerNetworkUnix && !isAdminRoute(route) {
+			return fmt.Errorf("%w: %s can't be served over a Unix domain socket", ErrInvalidListener, route)
+		}
+	}
+
+	if l.SocketMode != "" {
+		if _, err := strconv.ParseUint(l.SocketMode, 8, 32); err != nil {
+			return fmt.Errorf("%w: invalid socket mode %q", ErrInvalidListener, l.SocketMode)
+		}
+	}
+
+	if l.RedirectPort < 0 || l.RedirectPort > 65535 {
+		return fmt.Errorf("%w: invalid redirect port %d", ErrInvalidListener, l.RedirectPort)
+	}
+
+	if l.ReadTimeout < 0 || l.WriteTimeout < 0 || l.IdleTimeout < 0 {
+		return fmt.Errorf("%w: negative timeout", ErrInvalidListener)
+	}
+
+	return nil
+}
+
+// FileMode returns the file mode of the socket of "unix" listeners.
+func (l Listener) FileMode() os.FileMode {
+	// Validate makes sure the mode parses.
+	mode, _ := strconv.ParseUint(l.SocketMode, 8, 32)
+
+	return os.FileMode(mode)
+}
+
+// Serves reports whether the listener serves route.
+func (l Listener) Serves(route string) bool {
+	if len(l.Routes) == 0 {
+		return true
+	}
+
+	for _, r := range l.Routes {
+		if r == route {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AdminRoutes returns the routes "unix" listeners serve. Connections over Unix
+// domain sockets don't have a client IP address, so the routes returning it
+// can't be served over them.
+func AdminRoutes() []string {
+	return []string{endpoint.Metrics, endpoint.Health, endpoint.Ping}
+}
+
+// isAdminRoute reports whether route is one of the AdminRoutes.
+func isAdminRoute(route string) bool {
+	for _, admin := range AdminRoutes() {
+		if route == admin {
+			return true
+		}
+	}
+
+	return false
+}
+
+// setListenerDefaults fills in the settings of the listeners that weren't
+// configured, listening on Address alone if there are none. It must run after
+// the server's timeouts are set.
+func (cfg *Config) setListenerDefaults() {
+	if len(cfg.Listeners) == 0 {
+		cfg.Listeners = []Listener{{Address: cfg.Address}}
+	}
+
+	for i := range cfg.Listeners {
+		l := &cfg.Listeners[i]
+
+		if l.Network == "" {
+			l.Network = ListenerNetworkTCP
+		}
+
+		if l.Protocol == "" {
+			l.Protocol = ListenerProtocolHTTPS
+
+			if l.Network == ListenerNetworkUnix || cfg.TLS.Mode == TLSModeOff {
+				l.Protocol = ListenerProtocolHTTP
+			}
+		}
+
+		if l.Network == ListenerNetworkUnix && len(l.Routes) == 0 {
+			l.Routes = AdminRoutes()
+		}
+
+		if l.SocketMode == "" {
+			l.SocketMode = DefaultListenerSocketMode
+		}
+
+		if l.RedirectPort == 0 {
+			l.RedirectPort = DefaultListenerRedirectPort
+		}
+
+		if l.ReadTimeout == 0 {
+			l.ReadTimeout = cfg.ReadTimeout
+		}
+
+		if l.WriteTimeout == 0 {
+			l.WriteTimeout = cfg.WriteTimeout
+		}
+
+		if l.IdleTimeout == 0 {
+			l.IdleTimeout = cfg.IdleTimeout
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package config_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"git.sr.ht/~jamesponddotco/accio127/internal/config"
+	"git.sr.ht/~jamesponddotco/accio127/internal/jsonutil"
+)
+
+func TestLoadConfig_Listeners(t *testing.T) {
+	t.Parallel()
+
+	var (
+		read  = jsonutil.Duration(5 * time.Second)
+		write = config.DefaultWriteTimeout
+		idle  = config.DefaultIdleTimeout
+	)
+
+	tests := []struct {
+		name string
+		path string
+		want []config.Listener
+	}{
+		{
+			name: "default",
+			path: "testdata/valid-config.json",
+			want: []config.Listener{
+				{
+					Network:      config.ListenerNetworkTCP,
+					Address:      config.DefaultAddress,
+					Protocol:     config.ListenerProtocolHTTPS,
+					SocketMode:   config.DefaultListenerSocketMode,
+					RedirectPort: config.DefaultListenerRedirectPort,
+					ReadTimeout:  config.DefaultReadTimeout,
+					WriteTimeout: write,
+					IdleTimeout:  idle,
+				},
+			},
+		},
+		{
+			name: "configured",
+			path: "testdata/valid-listeners-config.json",
+			want: []config.Listener{
+				{
+					Network:      config.ListenerNetworkTCP4,
+					Address:      "0.0.0.0:1997",
+					Protocol:     config.ListenerProtocolHTTP,
+					SocketMode:   config.DefaultListenerSocketMode,
+					RedirectPort: config.DefaultListenerRedirectPort,
+					ReadTimeout:  read,
+					WriteTimeout: write,
+					IdleTimeout:  idle,
+				},
+				{
+					Network:      config.ListenerNetworkTCP6,
+					Address:      "[::]:1997",
+					Protocol:     config.ListenerProtocolHTTP,
+					SocketMode:   config.DefaultListenerSocketMode,
+					RedirectPort: config.DefaultListenerRedirectPort,
+					ReadTimeout:  jsonutil.Duration(2 * time.Second),
+					WriteTimeout: write,
+					IdleTimeout:  idle,
+				},
+				{
+					Network:      config.ListenerNetworkTCP,
+					Address:      ":80",
+					Protocol:     config.ListenerProtocolRedirect,
+					SocketMode:   config.DefaultListenerSocketMode,
+					RedirectPort: 1997,
+					ReadTimeout:  read,
+					WriteTimeout: write,
+					IdleTimeout:  idle,
+				},
+				{
+					Network:      config.ListenerNetworkUnix,
+					Address:      "/run/accio127/admin.sock",
+					Protocol:     config.ListenerProtocolHTTP,
+					Routes:       []string{"/v1/metrics", "/v1/health"},
+					SocketMode:   "0660",
+					RedirectPort: config.DefaultListenerRedirectPort,
+					ReadTimeout:  read,
+					WriteTimeout: write,
+					IdleTimeout:  idle,
+				},
+			},
+		},
+		{
+			name: "unix_default_routes",
+			path: "testdata/valid-unix-listener-config.json",
+			want: []config.Listener{
+				{
+					Network:      config.ListenerNetworkUnix,
+					Address:      "/run/accio127/admin.sock",
+					Protocol:     config.ListenerProtocolHTTP,
+					Routes:       config.AdminRoutes(),
+					SocketMode:   config.DefaultListenerSocketMode,
+					RedirectPort: config.DefaultListenerRedirectPort,
+					ReadTimeout:  config.DefaultReadTimeout,
+					WriteTimeout: write,
+					IdleTimeout:  idle,
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg, err := config.LoadConfig(tt.path)
+			if err != nil {
+				t.Fatalf("LoadConfig() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(cfg.Listeners, tt.want) {
+				t.Errorf("LoadConfig() listeners = %+v, want %+v", cfg.Listeners, tt.want)
+			}
+		})
+	}
+}
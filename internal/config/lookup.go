@@ -0,0 +1,8 @@
+package config
+
+// Lookup configures the endpoints describing arbitrary IP addresses, such as
+// /v1/ip/{address}.
+type Lookup struct {
+	// Enabled enables the lookup endpoints. They're disabled by default.
+	Enabled bool `json:"enabled"`
+}
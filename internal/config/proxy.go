@@ -0,0 +1,276 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"git.sr.ht/~jamesponddotco/accio127/internal/jsonutil"
+)
+
+// Forwarded is the name of the RFC 7239 forwarding header.
+const Forwarded string = "Forwarded"
+
+// DefaultProxyHeaders returns the headers trusted by a proxy group that
+// doesn't configure its own, in order of precedence.
+func DefaultProxyHeaders() []string {
+	return []string{
+		"CF-Connecting-IP",
+		"True-Client-IP",
+		"X-Real-IP",
+		"X-Forwarded-For",
+	}
+}
+
+// ProxyGroup is a set of trusted reverse proxies that forward the client's IP
+// address through the same headers.
+type ProxyGroup struct {
+	// Addresses holds the IP addresses and CIDR ranges of the proxies.
+	Addresses []string `json:"addresses"`
+
+	// Headers is the ordered list of headers the proxies use to forward the
+	// client's IP address.
+	Headers []string `json:"headers"`
+
+	prefixes []netip.Prefix
+}
+
+// NewProxyGroup creates a new ProxyGroup from a list of IP addresses or CIDR
+// ranges and a list of headers. DefaultProxyHeaders is used if headers is
+// empty.
+func NewProxyGroup(addresses, headers []string) (ProxyGroup, error) {
+	if len(addresses) == 0 {
+		return ProxyGroup{}, ErrProxyRequired
+	}
+
+	if len(headers) == 0 {
+		headers = DefaultProxyHeaders()
+	}
+
+	group := ProxyGroup{
+		Addresses: addresses,
+		Headers:   make([]string, 0, len(headers)),
+	}
+
+	for _, header := range headers {
+		header = strings.TrimSpace(header)
+		if header == "" {
+			return ProxyGroup{}, fmt.Errorf("%w: empty header name", ErrInvalidProxy)
+		}
+
+		group.Headers = append(group.Headers, http.CanonicalHeaderKey(header))
+	}
+
+	prefixes, err := ParsePrefixes(addresses)
+	if err != nil {
+		return ProxyGroup{}, err
+	}
+
+	group.prefixes = prefixes
+
+	return group, nil
+}
+
+// Contains reports whether addr belongs to one of the group's proxies.
+func (g ProxyGroup) Contains(addr netip.Addr) bool {
+	addr = addr.Unmap()
+
+	for _, prefix := range g.prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (g *ProxyGroup) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Addresses []string `json:"addresses"`
+		Headers   []string `json:"headers"`
+	}
+
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidProxy, err)
+	}
+
+	group, err := NewProxyGroup(raw.Addresses, raw.Headers)
+	if err != nil {
+		return err
+	}
+
+	*g = group
+
+	return nil
+}
+
+// Proxies is the list of trusted reverse proxy groups.
+//
+// In JSON it can be written as a single IP address or CIDR range, as a list of
+// them, or as a list of ProxyGroup objects; bare addresses use
+// DefaultProxyHeaders.
+type Proxies []ProxyGroup
+
+// Group returns the group addr belongs to.
+func (p Proxies) Group(addr netip.Addr) (ProxyGroup, bool) {
+	for _, group := range p {
+		if group.Contains(addr) {
+			return group, true
+		}
+	}
+
+	return ProxyGroup{}, false
+}
+
+// Trusted reports whether addr belongs to any of the trusted proxies.
+func (p Proxies) Trusted(addr netip.Addr) bool {
+	_, ok := p.Group(addr)
+
+	return ok
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (p *Proxies) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+
+	if bytes.HasPrefix(data, []byte(`"`)) {
+		var address string
+		if err := json.Unmarshal(data, &address); err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidProxy, err)
+		}
+
+		if address == "" {
+			*p = nil
+
+			return nil
+		}
+
+		group, err := NewProxyGroup([]string{address}, nil)
+		if err != nil {
+			return err
+		}
+
+		*p = Proxies{group}
+
+		return nil
+	}
+
+	var entries []json.RawMessage
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidProxy, err)
+	}
+
+	var (
+		proxies   = make(Proxies, 0, len(entries))
+		addresses []string
+	)
+
+	for _, entry := range entries {
+		var address string
+		if err := json.Unmarshal(entry, &address); err == nil {
+			addresses = append(addresses, address)
+
+			continue
+		}
+
+		var group ProxyGroup
+		if err := json.Unmarshal(entry, &group); err != nil {
+			return err
+		}
+
+		proxies = append(proxies, group)
+	}
+
+	if len(addresses) > 0 {
+		group, err := NewProxyGroup(addresses, nil)
+		if err != nil {
+			return err
+		}
+
+		proxies = append(proxies, group)
+	}
+
+	*p = proxies
+
+	return nil
+}
+
+// ProxyProtocol configures support for the PROXY protocol, used by load
+// balancers working at the transport layer to forward the client's address.
+type ProxyProtocol struct {
+	// Allow is the list of IP addresses and CIDR ranges of the load balancers
+	// allowed to send PROXY protocol headers.
+	Allow []string `json:"allow"`
+
+	// Timeout is the maximum time to wait for the PROXY protocol header.
+	Timeout jsonutil.Duration `json:"timeout"`
+
+	// Enabled enables PROXY protocol parsing on the listener.
+	Enabled bool `json:"enabled"`
+}
+
+// Validate validates the PROXY protocol configuration.
+func (p ProxyProtocol) Validate() error {
+	if !p.Enabled {
+		return nil
+	}
+
+	if len(p.Allow) == 0 {
+		return ErrProxyProtocolAllowRequired
+	}
+
+	if _, err := ParsePrefixes(p.Allow); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ParsePrefixes parses a list of IP addresses and CIDR ranges. Single
+// addresses become single-address prefixes and IPv4-mapped IPv6 addresses are
+// unmapped.
+func ParsePrefixes(addresses []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(addresses))
+
+	for _, address := range addresses {
+		prefix, err := parsePrefix(address)
+		if err != nil {
+			return nil, err
+		}
+
+		prefixes = append(prefixes, prefix)
+	}
+
+	return prefixes, nil
+}
+
+// parsePrefix parses an IP address or CIDR range into a netip.Prefix.
+func parsePrefix(address string) (netip.Prefix, error) {
+	address = strings.TrimSpace(address)
+
+	if strings.Contains(address, "/") {
+		prefix, err := netip.ParsePrefix(address)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("%w: %w", ErrInvalidProxy, err)
+		}
+
+		if prefix.Addr().Is4In6() && prefix.Bits() >= 96 {
+			prefix = netip.PrefixFrom(prefix.Addr().Unmap(), prefix.Bits()-96)
+		}
+
+		return prefix.Masked(), nil
+	}
+
+	addr, err := netip.ParseAddr(address)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("%w: %w", ErrInvalidProxy, err)
+	}
+
+	addr = addr.Unmap()
+
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
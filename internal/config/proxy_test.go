@@ -0,0 +1,103 @@
+package config_test
+
+import (
+	"encoding/json"
+	"net/netip"
+	"reflect"
+	"testing"
+
+	"git.sr.ht/~jamesponddotco/accio127/internal/config"
+)
+
+func TestProxies_UnmarshalJSON(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		give        string
+		wantGroups  int
+		wantHeaders []string
+		trusted     []string
+		untrusted   []string
+		wantErr     bool
+	}{
+		{
+			name:        "single_address",
+			give:        `"127.0.0.1"`,
+			wantGroups:  1,
+			wantHeaders: []string{"Cf-Connecting-Ip", "True-Client-Ip", "X-Real-Ip", "X-Forwarded-For"},
+			trusted:     []string{"127.0.0.1", "::ffff:127.0.0.1"},
+			untrusted:   []string{"127.0.0.2"},
+		},
+		{
+			name:        "address_list",
+			give:        `["192.0.2.1", "10.0.0.0/8", "2001:db8::/32"]`,
+			wantGroups:  1,
+			wantHeaders: []string{"Cf-Connecting-Ip", "True-Client-Ip", "X-Real-Ip", "X-Forwarded-For"},
+			trusted:     []string{"192.0.2.1", "10.20.30.40", "2001:db8::1"},
+			untrusted:   []string{"192.0.2.2", "11.0.0.1", "2001:db9::1"},
+		},
+		{
+			name:        "groups",
+			give:        `[{"addresses": ["10.0.0.0/8"], "headers": ["forwarded", "x-forwarded-for"]}]`,
+			wantGroups:  1,
+			wantHeaders: []string{"Forwarded", "X-Forwarded-For"},
+			trusted:     []string{"10.1.1.1"},
+			untrusted:   []string{"192.0.2.1"},
+		},
+		{
+			name:       "empty_string",
+			give:       `""`,
+			wantGroups: 0,
+		},
+		{
+			name:    "invalid_address",
+			give:    `["not-an-ip"]`,
+			wantErr: true,
+		},
+		{
+			name:    "group_without_addresses",
+			give:    `[{"headers": ["X-Forwarded-For"]}]`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var proxies config.Proxies
+
+			err := json.Unmarshal([]byte(tt.give), &proxies)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("UnmarshalJSON() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if len(proxies) != tt.wantGroups {
+				t.Fatalf("UnmarshalJSON() got %d groups, want %d", len(proxies), tt.wantGroups)
+			}
+
+			if tt.wantGroups > 0 && !reflect.DeepEqual(proxies[0].Headers, tt.wantHeaders) {
+				t.Errorf("UnmarshalJSON() headers = %v, want %v", proxies[0].Headers, tt.wantHeaders)
+			}
+
+			for _, address := range tt.trusted {
+				if !proxies.Trusted(netip.MustParseAddr(address)) {
+					t.Errorf("Trusted(%s) = false, want true", address)
+				}
+			}
+
+			for _, address := range tt.untrusted {
+				if proxies.Trusted(netip.MustParseAddr(address)) {
+					t.Errorf("Trusted(%s) = true, want false", address)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"git.sr.ht/~jamesponddotco/accio127/internal/jsonutil"
+)
+
+// RateLimitRule is how many requests a client can make.
+type RateLimitRule struct {
+	// Period is the time over which Requests are allowed.
+	Period jsonutil.Duration `json:"period"`
+
+	// Requests is the number of requests allowed per Period.
+	Requests int `json:"requests"`
+
+	// Burst is the number of requests a client can make at once. Defaults
+	// to Requests.
+	Burst int `json:"burst"`
+}
+
+// Validate validates the rule.
+func (r RateLimitRule) Validate() error {
+	if r.Period < 0 || r.Requests < 0 || r.Burst < 0 {
+		return ErrInvalidRateLimit
+	}
+
+	return nil
+}
+
+// withDefaults returns the rule with the settings that weren't configured
+// taken from fallback.
+func (r RateLimitRule) withDefaults(fallback RateLimitRule) RateLimitRule {
+	if r.Period == 0 {
+		r.Period = fallback.Period
+	}
+
+	if r.Requests == 0 {
+		r.Requests = fallback.Requests
+	}
+
+	if r.Burst == 0 {
+		r.Burst = r.Requests
+	}
+
+	return r
+}
+
+// RateLimit configures per-client rate limiting.
+type RateLimit struct {
+	// Endpoints holds the rules of the endpoints that don't use the default
+	// one, keyed by route, such as "/v1/ip/batch". Each of these endpoints
+	// has its own buckets.
+	Endpoints map[string]RateLimitRule `json:"endpoints"`
+
+	// Allow is the list of IP addresses and CIDR ranges that aren't rate
+	// limited.
+	Allow []string `json:"allow"`
+
+	// RateLimitRule is the default rule, shared by every endpoint without a
+	// rule of its own.
+	RateLimitRule
+
+	// MaxClients is the number of clients tracked per rule. When it's
+	// reached, the client that has been idle for the longest is forgotten.
+	MaxClients int `json:"maxClients"`
+
+	// IPv4Prefix and IPv6Prefix are the number of leading bits of the
+	// client's address that identify it, so that clients can't get around
+	// the limits by rotating addresses within their network.
+	IPv4Prefix int `json:"ipv4Prefix"`
+	IPv6Prefix int `json:"ipv6Prefix"`
+
+	// Enabled enables rate limiting.
+	Enabled bool `json:"enabled"`
+}
+
+// Rule returns the rule of route, with the settings that weren't configured
+// filled in.
+func (r RateLimit) Rule(route string) (RateLimitRule, bool) {
+	rule, ok := r.Endpoints[route]
+	if !ok {
+		return r.RateLimitRule, false
+	}
+
+	return rule.withDefaults(r.RateLimitRule), true
+}
+
+// Validate validates the rate limiting configuration.
+func (r RateLimit) Validate() error {
+	if err := r.RateLimitRule.Validate(); err != nil {
+		return err
+	}
+
+	if r.MaxClients < 0 ||
+		r.IPv4Prefix < 0 || r.IPv4Prefix > IPv4Bits ||
+		r.IPv6Prefix < 0 || r.IPv6Prefix > IPv6Bits {
+		return ErrInvalidRateLimit
+	}
+
+	for route, rule := range r.Endpoints {
+		if !strings.HasPrefix(route, "/") {
+			return fmt.Errorf("%w: route %q must start with a slash", ErrInvalidRateLimit, route)
+		}
+
+		if err := rule.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if _, err := ParsePrefixes(r.Allow); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidRateLimit, err)
+	}
+
+	return nil
+}
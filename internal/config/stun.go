@@ -0,0 +1,37 @@
+package config
+
+import (
+	"net"
+
+	"git.sr.ht/~jamesponddotco/accio127/internal/jsonutil"
+)
+
+// STUN configures the STUN server answering Binding requests with the
+// client's address and port as seen through NATs, as defined in RFC 5389.
+type STUN struct {
+	// Address is the host and port to listen on, over both UDP and TCP.
+	Address string `json:"address"`
+
+	// Timeout is how long an idle TCP connection is kept open.
+	Timeout jsonutil.Duration `json:"timeout"`
+
+	// Enabled enables the server. It's disabled by default.
+	Enabled bool `json:"enabled"`
+}
+
+// Validate validates the STUN server configuration.
+func (s STUN) Validate() error {
+	if s.Timeout < 0 {
+		return ErrInvalidSTUN
+	}
+
+	if !s.Enabled || s.Address == "" {
+		return nil
+	}
+
+	if _, _, err := net.SplitHostPort(s.Address); err != nil {
+		return ErrInvalidSTUN
+	}
+
+	return nil
+}
@@ -0,0 +1,79 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+)
+
+const (
+	// TLSModeOff serves plain HTTP, for use behind a TLS-terminating proxy.
+	TLSModeOff string = "off"
+
+	// TLSModeFiles serves HTTPS using the certificate files in CertFile and
+	// CertKey.
+	TLSModeFiles string = "files"
+
+	// TLSModeACME serves HTTPS using certificates obtained automatically from
+	// an ACME directory.
+	TLSModeACME string = "acme"
+)
+
+// ACME configures automatic certificate management.
+type ACME struct {
+	// DirectoryURL is the URL of the ACME directory.
+	DirectoryURL string `json:"directoryURL"`
+
+	// CacheDir is the directory where certificates and account keys are
+	// stored.
+	CacheDir string `json:"cacheDir"`
+
+	// Email is the contact address registered with the ACME account.
+	Email string `json:"email"`
+
+	// Hosts is the list of hostnames certificates can be requested for.
+	Hosts []string `json:"hosts"`
+}
+
+// TLS configures how the server handles TLS.
+type TLS struct {
+	// Mode is either "off", "files", or "acme".
+	Mode string `json:"mode"`
+
+	// ACME configures automatic certificate management when Mode is "acme".
+	ACME ACME `json:"acme"`
+}
+
+// UsesFiles reports whether certificates are loaded from CertFile and CertKey,
+// which is the default.
+func (t TLS) UsesFiles() bool {
+	return t.Mode == "" || t.Mode == TLSModeFiles
+}
+
+// Validate validates the TLS configuration.
+func (t TLS) Validate() error {
+	switch t.Mode {
+	case "", TLSModeOff, TLSModeFiles:
+		return nil
+	case TLSModeACME:
+		if len(t.ACME.Hosts) == 0 {
+			return ErrACMEHostsRequired
+		}
+
+		if t.ACME.DirectoryURL == "" {
+			return nil
+		}
+
+		directory, err := url.Parse(t.ACME.DirectoryURL)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidACMEDirectory, err)
+		}
+
+		if directory.Scheme != "https" {
+			return fmt.Errorf("%w: %s must use https", ErrInvalidACMEDirectory, t.ACME.DirectoryURL)
+		}
+
+		return nil
+	default:
+		return fmt.Errorf("%w: %q", ErrInvalidTLSMode, t.Mode)
+	}
+}
@@ -2,29 +2,133 @@
 package database
 
 import (
-	"database/sql"
-	_ "embed"
-	"fmt"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"git.sr.ht/~jamesponddotco/accio127/internal/errors"
-	_ "github.com/mattn/go-sqlite3" //nolint:revive // SQLite3 driver
 	"go.uber.org/zap"
 )
 
-//go:embed schema.sql
-var schema string
+// Drivers a Store can use, selected by the scheme of the DSN given to Open.
+const (
+	// DriverSQLite stores data in a SQLite database. It's used for DSNs
+	// without a known scheme, such as "file:" URIs and plain paths.
+	DriverSQLite string = "sqlite"
 
-// DB wraps the database connection and stores the access counter.
+	// DriverPostgres stores data in a PostgreSQL database, which can be
+	// shared by several instances of the service. It's used for DSNs with
+	// the "postgres" or "postgresql" schemes.
+	DriverPostgres string = "postgres"
+
+	// DriverMemory keeps data in memory, losing it when the service stops.
+	// It's used for DSNs with the "memory" scheme.
+	DriverMemory string = "memory"
+)
+
+const (
+	// DefaultFlushInterval is the default interval between writes of pending
+	// increments to the database.
+	DefaultFlushInterval time.Duration = time.Second
+
+	// DefaultBatchSize is the default number of pending increments that
+	// triggers a write before the flush interval elapses.
+	DefaultBatchSize int = 1000
+)
+
+// Store stores the access counter and statistics.
+type Store interface {
+	// Driver returns the name of the driver used by the store.
+	Driver() string
+
+	// Count returns the current access counter.
+	Count() uint64
+
+	// Increment increments the access counter and the statistics for access,
+	// and returns the new value of the counter.
+	Increment(access Access) uint64
+
+	// FlushLag returns how long the oldest increment not yet persisted has
+	// been pending.
+	FlushLag() time.Duration
+
+	// Flush persists pending increments.
+	Flush() error
+
+	// Statistics returns the number of accesses matching query.
+	Statistics(query StatisticsQuery) ([]Statistic, error)
+
+	// Ping checks if the store is accessible.
+	Ping() error
+
+	// Close persists pending increments and releases the store's resources.
+	Close() error
+}
+
+// backend is the storage-specific part of a DB.
+type backend interface {
+	// name returns the name of the driver.
+	name() string
+
+	// load returns the access counter.
+	load() (uint64, error)
+
+	// write adds count to the access counter and the per-bucket counts in
+	// pending to the statistics, and returns the new value of the counter.
+	write(pending map[bucket]uint64, count uint64) (uint64, error)
+
+	// statistics returns the accesses between the from and to days,
+	// inclusive, grouped by columns.
+	statistics(from, to string, columns []string) ([]Statistic, error)
+
+	ping() error
+	close() error
+}
+
+// Options configures how increments are written to the database.
+type Options struct {
+	// FlushInterval is how often pending increments are written to the
+	// database. Defaults to DefaultFlushInterval.
+	FlushInterval time.Duration
+
+	// BatchSize is the number of pending increments that triggers a write
+	// before FlushInterval elapses. Defaults to DefaultBatchSize.
+	BatchSize int
+}
+
+// DB is a Store that keeps increments in memory and writes them to its backend
+// in batches from a background flusher, so the counter returned by Count may
+// be ahead of the database by up to one flush interval.
 type DB struct {
-	db     *sql.DB
-	logger *zap.Logger
-	count  uint64
-	mu     sync.Mutex
+	backend backend
+	logger  *zap.Logger
+	options Options
+
+	// count is the access counter, including pending increments.
+	count atomic.Uint64
+
+	// mu guards the increments that haven't been written to the database
+	// yet.
+	mu           sync.Mutex
+	pending      map[bucket]uint64
+	pendingCount uint64
+	pendingSince time.Time
+
+	// flushMu serializes writes to the database.
+	flushMu sync.Mutex
+
+	// wake asks the flusher to write pending increments before the interval
+	// elapses, done stops it, and stopped is closed once it returns.
+	wake      chan struct{}
+	done      chan struct{}
+	stopped   chan struct{}
+	closeOnce sync.Once
 }
 
-// Open opens a database connection and returns a DB instance.
-func Open(logger *zap.Logger, dsn string) (*DB, error) {
+// Open opens the database identified by dsn, using the driver matching its
+// scheme, starts the background flusher, and returns a Store.
+func Open(logger *zap.Logger, dsn string, options Options) (Store, error) {
 	if logger == nil {
 		return nil, errors.ErrNilLogger
 	}
@@ -33,100 +137,151 @@ func Open(logger *zap.Logger, dsn string) (*DB, error) {
 		return nil, errors.ErrEmptyDSN
 	}
 
-	db, err := sql.Open("sqlite3", dsn)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open database: %w", err)
+	var (
+		b   backend
+		err error
+	)
+
+	switch Driver(dsn) {
+	case DriverPostgres:
+		b, err = openPostgres(dsn)
+	case DriverMemory:
+		b = newMemory()
+	default:
+		b, err = openSQLite(dsn)
 	}
 
-	_, err = db.Exec(schema)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create schema: %w", err)
+		return nil, err
 	}
 
-	var count uint64
-
-	err = db.QueryRow("SELECT count FROM counter WHERE id = 1").Scan(&count)
+	count, err := b.load()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get access counter: %w", err)
+		b.close()
+
+		return nil, err
 	}
 
-	return &DB{
-		db:     db,
-		logger: logger,
-		count:  count,
-	}, nil
+	return newDB(logger, b, count, options), nil
 }
 
-// Close closes the database connection.
-func (d *DB) Close() error {
-	if err := d.db.Close(); err != nil {
-		return fmt.Errorf("failed to close database: %w", err)
+// Driver returns the driver Open uses for dsn.
+func Driver(dsn string) string {
+	scheme, _, ok := strings.Cut(dsn, "://")
+	if !ok {
+		return DriverSQLite
 	}
 
-	return nil
+	switch strings.ToLower(scheme) {
+	case "postgres", "postgresql":
+		return DriverPostgres
+	case "memory":
+		return DriverMemory
+	default:
+		return DriverSQLite
+	}
 }
 
-// Ping checks if the PostgreSQL database is accessible by executing a simple query.
-func (d *DB) Ping() error {
-	var result int
+// newDB creates a DB writing to b, and starts its background flusher.
+func newDB(logger *zap.Logger, b backend, count uint64, options Options) *DB {
+	if options.FlushInterval <= 0 {
+		options.FlushInterval = DefaultFlushInterval
+	}
 
-	err := d.db.QueryRow("SELECT 1").Scan(&result)
-	if err != nil || result != 1 {
-		return fmt.Errorf("failed to ping the database: %w", err)
+	if options.BatchSize <= 0 {
+		options.BatchSize = DefaultBatchSize
 	}
 
-	return nil
+	d := &DB{
+		backend: b,
+		logger:  logger,
+		options: options,
+		pending: make(map[bucket]uint64),
+		wake:    make(chan struct{}, 1),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+
+	d.count.Store(count)
+
+	go d.run()
+
+	return d
+}
+
+// Driver implements the Store interface.
+func (d *DB) Driver() string {
+	return d.backend.name()
+}
+
+// Close stops the background flusher, writes the pending increments to the
+// database, and closes the database connection.
+func (d *DB) Close() error {
+	var err error
+
+	d.closeOnce.Do(func() {
+		close(d.done)
+		<-d.stopped
+
+		if flushErr := d.Flush(); flushErr != nil {
+			d.logger.Error("Failed to flush access counter on close", zap.Error(flushErr))
+		}
+
+		err = d.backend.close()
+	})
+
+	return err
+}
+
+// Ping checks if the database is accessible.
+func (d *DB) Ping() error {
+	return d.backend.ping()
 }
 
 // Count returns the current access counter.
 func (d *DB) Count() uint64 {
-	d.mu.Lock()
-	defer d.mu.Unlock()
-
-	return d.count
+	return d.count.Load()
 }
 
-// Increment increments the access counter and stores the access in the database.
-func (d *DB) Increment() (uint64, error) {
+// Increment increments the access counter and the statistics for access, and
+// returns the new value of the counter. The increment is written to the
+// database by the background flusher.
+func (d *DB) Increment(access Access) uint64 {
 	d.mu.Lock()
-	defer d.mu.Unlock()
 
-	tx, err := d.db.Begin()
-	if err != nil {
-		return d.count, fmt.Errorf("failed to begin transaction: %w", err)
+	if d.pendingCount == 0 {
+		d.pendingSince = time.Now()
 	}
 
-	defer func() {
-		if p := recover(); p != nil {
-			if err = tx.Rollback(); err != nil {
-				d.logger.Error("failed to rollback after panic", zap.Error(err))
-			}
-
-			panic(p) // re-throw panic after Rollback
-		} else if err != nil {
-			if rbErr := tx.Rollback(); rbErr != nil {
-				d.logger.Error("failed to rollback transaction", zap.Error(rbErr))
-			}
-		}
+	d.pending[access.bucket()]++
+	d.pendingCount++
 
-		err = tx.Commit()
-		if err != nil {
-			d.logger.Error("failed to commit transaction", zap.Error(err))
-		}
-	}()
+	var (
+		count = d.count.Add(1)
+		full  = d.pendingCount >= uint64(d.options.BatchSize)
+	)
 
-	stmt, err := tx.Prepare("UPDATE counter SET count = ? WHERE id = 1")
-	if err != nil {
-		return d.count, fmt.Errorf("failed to prepare statement: %w", err)
+	d.mu.Unlock()
+
+	if full {
+		select {
+		case d.wake <- struct{}{}:
+		default:
+		}
 	}
-	defer stmt.Close()
 
-	d.count++
+	return count
+}
+
+// FlushLag returns how long the oldest increment not yet written to the
+// database has been pending, or zero if there are no pending increments.
+func (d *DB) FlushLag() time.Duration {
+	d.mu.Lock()
+	defer d.mu.Unlock()
 
-	_, err = stmt.Exec(d.count)
-	if err != nil {
-		return d.count, fmt.Errorf("failed to increment access counter: %w", err)
+	if d.pendingCount == 0 {
+		return 0
 	}
 
-	return d.count, nil
+	return time.Since(d.pendingSince)
 }
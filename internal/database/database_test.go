@@ -0,0 +1,472 @@
+package database_test
+
+import (
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"git.sr.ht/~jamesponddotco/accio127/internal/database"
+	"go.uber.org/zap"
+)
+
+// testAccess is the access recorded by tests that don't care about its
+// details.
+func testAccess() database.Access {
+	return database.Access{
+		Time:     time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC),
+		Endpoint: "/v1/ip",
+		Family:   database.FamilyIPv4,
+		Format:   "text",
+	}
+}
+
+func TestOpen_MigratesLegacySchema(t *testing.T) {
+	t.Parallel()
+
+	dsn := filepath.Join(t.TempDir(), "legacy.db")
+
+	legacy, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+
+	_, err = legacy.Exec(`CREATE TABLE counter (id INTEGER PRIMARY KEY, count INTEGER NOT NULL) STRICT;
+		INSERT INTO counter (id, count) VALUES (1, 42);`)
+	if err != nil {
+		t.Fatalf("Failed to create legacy schema: %v", err)
+	}
+
+	legacy.Close()
+
+	db, err := database.Open(zap.NewNop(), dsn, database.Options{})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer db.Close()
+
+	if got := db.Count(); got != 42 {
+		t.Errorf("Count() = %v, want %v", got, 42)
+	}
+
+	if got := db.Increment(testAccess()); got != 43 {
+		t.Errorf("Increment() = %v, want %v", got, 43)
+	}
+
+	if err = db.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+}
+
+func TestDB_Close_FlushesPendingIncrements(t *testing.T) {
+	t.Parallel()
+
+	dsn := filepath.Join(t.TempDir(), "close.db")
+
+	db, err := database.Open(zap.NewNop(), dsn, database.Options{FlushInterval: time.Hour})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			db.Increment(testAccess())
+		}()
+	}
+
+	wg.Wait()
+
+	if got := db.FlushLag(); got <= 0 {
+		t.Errorf("FlushLag() = %v, want > 0", got)
+	}
+
+	if err = db.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	db, err = database.Open(zap.NewNop(), dsn, database.Options{})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer db.Close()
+
+	if got := db.Count(); got != 100 {
+		t.Errorf("Count() = %v, want %v", got, 100)
+	}
+
+	if got := db.FlushLag(); got != 0 {
+		t.Errorf("FlushLag() = %v, want %v", got, 0)
+	}
+}
+
+func TestDB_Increment_FlushesFullBatch(t *testing.T) {
+	t.Parallel()
+
+	db, err := database.Open(zap.NewNop(), filepath.Join(t.TempDir(), "batch.db"), database.Options{
+		FlushInterval: time.Hour,
+		BatchSize:     10,
+	})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer db.Close()
+
+	for i := 0; i < 10; i++ {
+		db.Increment(testAccess())
+	}
+
+	access := testAccess()
+	query := database.StatisticsQuery{From: access.Time, To: access.Time}
+
+	deadline := time.Now().Add(5 * time.Second)
+
+	for {
+		got, err := db.Statistics(query)
+		if err != nil {
+			t.Fatalf("Statistics() error = %v", err)
+		}
+
+		if len(got) == 1 && got[0].Count == 10 {
+			return
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("Statistics() = %v, want a count of %v", got, 10)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestDB_Flush_SharesCounter(t *testing.T) {
+	t.Parallel()
+
+	dsn := filepath.Join(t.TempDir(), "shared.db")
+
+	testSharedCounter(t, dsn)
+}
+
+// testSharedCounter checks that two instances sharing the database identified
+// by dsn see each other's increments after flushing.
+func testSharedCounter(t *testing.T, dsn string) {
+	t.Helper()
+
+	first, err := database.Open(zap.NewNop(), dsn, database.Options{FlushInterval: time.Hour})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer first.Close()
+
+	second, err := database.Open(zap.NewNop(), dsn, database.Options{FlushInterval: time.Hour})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer second.Close()
+
+	start := first.Count()
+
+	for i := 0; i < 3; i++ {
+		first.Increment(testAccess())
+	}
+
+	second.Increment(testAccess())
+
+	if err = first.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+
+	if err = second.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+
+	if got, want := second.Count(), start+4; got != want {
+		t.Errorf("Count() = %v, want %v", got, want)
+	}
+
+	if err = first.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+
+	if got, want := first.Count(), start+4; got != want {
+		t.Errorf("Count() = %v, want %v", got, want)
+	}
+}
+
+func TestDriver(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		dsn  string
+		want string
+	}{
+		{
+			name: "sqlite_uri",
+			dsn:  "file:/var/lib/accio127/sqlite.db?mode=rwc",
+			want: database.DriverSQLite,
+		},
+		{
+			name: "sqlite_path",
+			dsn:  "/var/lib/accio127/sqlite.db",
+			want: database.DriverSQLite,
+		},
+		{
+			name: "postgres",
+			dsn:  "postgres://accio127@localhost/accio127?sslmode=disable",
+			want: database.DriverPostgres,
+		},
+		{
+			name: "postgresql",
+			dsn:  "PostgreSQL://localhost/accio127",
+			want: database.DriverPostgres,
+		},
+		{
+			name: "memory",
+			dsn:  "memory://",
+			want: database.DriverMemory,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := database.Driver(tt.dsn); got != tt.want {
+				t.Errorf("Driver() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDB_Statistics(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		dsn  string
+	}{
+		{
+			name: "sqlite",
+			dsn:  filepath.Join(t.TempDir(), "statistics.db"),
+		},
+		{
+			name: "memory",
+			dsn:  "memory://",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			db, err := database.Open(zap.NewNop(), tt.dsn, database.Options{})
+			if err != nil {
+				t.Fatalf("Open() error = %v", err)
+			}
+			t.Cleanup(func() { db.Close() })
+
+			testStatistics(t, db)
+		})
+	}
+}
+
+// testStatistics records a few accesses in db and checks the statistics
+// returned for them.
+func testStatistics(t *testing.T, db database.Store) {
+	t.Helper()
+
+	var (
+		first  = time.Date(2023, 6, 1, 23, 59, 0, 0, time.UTC)
+		second = time.Date(2023, 6, 2, 0, 1, 0, 0, time.UTC)
+	)
+
+	accesses := []database.Access{
+		{Time: first, Endpoint: "/v1/ip", Family: database.FamilyIPv4, Format: "text"},
+		{Time: first, Endpoint: "/v1/ip", Family: database.FamilyIPv4, Format: "text"},
+		{Time: first, Endpoint: "/v1/ip/hashed", Family: database.FamilyIPv6, Format: "json"},
+		{Time: second, Endpoint: "/v1/ip", Family: database.FamilyIPv6, Format: "json"},
+	}
+
+	for _, access := range accesses {
+		db.Increment(access)
+	}
+
+	if err := db.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		query   database.StatisticsQuery
+		want    []database.Statistic
+		wantErr bool
+	}{
+		{
+			name:  "total",
+			query: database.StatisticsQuery{From: first, To: second},
+			want:  []database.Statistic{{Count: 4}},
+		},
+		{
+			name:  "total_outside_range",
+			query: database.StatisticsQuery{From: second.AddDate(0, 0, 1), To: second.AddDate(0, 0, 2)},
+			want:  []database.Statistic{{Count: 0}},
+		},
+		{
+			name: "by_day",
+			query: database.StatisticsQuery{
+				From:    first,
+				To:      second,
+				GroupBy: []string{database.GroupDay},
+			},
+			want: []database.Statistic{
+				{Day: "2023-06-01", Count: 3},
+				{Day: "2023-06-02", Count: 1},
+			},
+		},
+		{
+			name: "by_endpoint_and_family_single_day",
+			query: database.StatisticsQuery{
+				From:    first,
+				To:      first,
+				GroupBy: []string{database.GroupEndpoint, database.GroupFamily},
+			},
+			want: []database.Statistic{
+				{Endpoint: "/v1/ip", Family: database.FamilyIPv4, Count: 2},
+				{Endpoint: "/v1/ip/hashed", Family: database.FamilyIPv6, Count: 1},
+			},
+		},
+		{
+			name: "by_format",
+			query: database.StatisticsQuery{
+				From:    first,
+				To:      second,
+				GroupBy: []string{database.GroupFormat},
+			},
+			want: []database.Statistic{
+				{Format: "json", Count: 2},
+				{Format: "text", Count: 2},
+			},
+		},
+		{
+			name: "invalid_group",
+			query: database.StatisticsQuery{
+				From:    first,
+				To:      second,
+				GroupBy: []string{"count; DROP TABLE statistics"},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := db.Statistics(tt.query)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Statistics() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Statistics() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func BenchmarkDB_Increment(b *testing.B) {
+	db, err := database.Open(zap.NewNop(), filepath.Join(b.TempDir(), "bench.db"), database.Options{})
+	if err != nil {
+		b.Fatalf("Open() error = %v", err)
+	}
+	defer db.Close()
+
+	access := testAccess()
+
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			db.Increment(access)
+		}
+	})
+}
+
+// BenchmarkTransactionPerIncrement measures the previous approach, which wrote
+// every increment to the database in its own transaction while holding a lock,
+// as a baseline for BenchmarkDB_Increment.
+func BenchmarkTransactionPerIncrement(b *testing.B) {
+	dsn := filepath.Join(b.TempDir(), "bench.db")
+
+	// Open creates the schema.
+	db, err := database.Open(zap.NewNop(), dsn, database.Options{})
+	if err != nil {
+		b.Fatalf("Open() error = %v", err)
+	}
+	db.Close()
+
+	conn, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		b.Fatalf("Failed to open database: %v", err)
+	}
+	defer conn.Close()
+
+	var (
+		access = testAccess()
+		mu     sync.Mutex
+		count  uint64
+	)
+
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			mu.Lock()
+
+			tx, err := conn.Begin()
+			if err != nil {
+				mu.Unlock()
+				b.Error(err)
+
+				return
+			}
+
+			count++
+
+			_, err = tx.Exec("UPDATE counter SET count = ? WHERE id = 1", count)
+			if err == nil {
+				_, err = tx.Exec(`INSERT INTO statistics (day, endpoint, family, format, count) VALUES (?, ?, ?, ?, 1)
+					ON CONFLICT (day, endpoint, family, format) DO UPDATE SET count = count + 1`,
+					access.Day(), access.Endpoint, access.Family, access.Format)
+			}
+
+			if err != nil {
+				_ = tx.Rollback()
+			} else {
+				err = tx.Commit()
+			}
+
+			mu.Unlock()
+
+			if err != nil {
+				b.Error(err)
+
+				return
+			}
+		}
+	})
+}
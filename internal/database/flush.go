@@ -0,0 +1,102 @@
+package database
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// bucket identifies the row of the statistics table an access is counted in.
+type bucket struct {
+	day      string
+	endpoint string
+	family   string
+	format   string
+}
+
+// bucket returns the statistics bucket of the access.
+func (a Access) bucket() bucket {
+	return bucket{
+		day:      a.Day(),
+		endpoint: a.Endpoint,
+		family:   a.Family,
+		format:   a.Format,
+	}
+}
+
+// run writes pending increments to the database every flush interval, or
+// sooner if a batch fills up, until the DB is closed.
+func (d *DB) run() {
+	defer close(d.stopped)
+
+	ticker := time.NewTicker(d.options.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-d.wake:
+		case <-d.done:
+			return
+		}
+
+		if err := d.Flush(); err != nil {
+			d.logger.Warn(
+				"Failed to flush access counter, will retry",
+				zap.Duration("lag", d.FlushLag()),
+				zap.Error(err),
+			)
+		}
+	}
+}
+
+// Flush writes the pending increments to the database in a single
+// transaction, and refreshes the access counter with the increments of other
+// instances sharing the database. If writing fails, the increments are kept
+// and retried on the next flush.
+func (d *DB) Flush() error {
+	d.flushMu.Lock()
+	defer d.flushMu.Unlock()
+
+	d.mu.Lock()
+
+	var (
+		pending = d.pending
+		count   = d.pendingCount
+		since   = d.pendingSince
+	)
+
+	d.pending = make(map[bucket]uint64, len(pending))
+	d.pendingCount = 0
+
+	d.mu.Unlock()
+
+	var (
+		total uint64
+		err   error
+	)
+
+	if count == 0 {
+		total, err = d.backend.load()
+	} else {
+		total, err = d.backend.write(pending, count)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err != nil {
+		for b, n := range pending {
+			d.pending[b] += n
+		}
+
+		d.pendingCount += count
+		d.pendingSince = since
+
+		return err //nolint:wrapcheck // backends wrap their errors
+	}
+
+	d.count.Store(total + d.pendingCount)
+
+	return nil
+}
@@ -0,0 +1,129 @@
+package database
+
+import (
+	"sort"
+	"sync"
+)
+
+// memoryBackend keeps the access counter and statistics in memory. Everything
+// is lost when the service stops, which makes it suitable for tests and
+// throwaway instances.
+type memoryBackend struct {
+	mu      sync.Mutex
+	count   uint64
+	buckets map[bucket]uint64
+}
+
+// newMemory creates an empty in-memory backend.
+func newMemory() *memoryBackend {
+	return &memoryBackend{
+		buckets: make(map[bucket]uint64),
+	}
+}
+
+// name implements the backend interface.
+func (*memoryBackend) name() string {
+	return DriverMemory
+}
+
+// load implements the backend interface.
+func (m *memoryBackend) load() (uint64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.count, nil
+}
+
+// write implements the backend interface.
+func (m *memoryBackend) write(pending map[bucket]uint64, count uint64) (uint64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.count += count
+
+	for b, n := range pending {
+		m.buckets[b] += n
+	}
+
+	return m.count, nil
+}
+
+// statistics implements the backend interface.
+func (m *memoryBackend) statistics(from, to string, columns []string) ([]Statistic, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var (
+		groups = make(map[bucket]uint64)
+		total  uint64
+	)
+
+	for b, n := range m.buckets {
+		if b.day < from || b.day > to {
+			continue
+		}
+
+		total += n
+
+		// Only keep the dimensions being grouped by.
+		var key bucket
+
+		for _, column := range columns {
+			switch column {
+			case GroupDay:
+				key.day = b.day
+			case GroupEndpoint:
+				key.endpoint = b.endpoint
+			case GroupFamily:
+				key.family = b.family
+			case GroupFormat:
+				key.format = b.format
+			}
+		}
+
+		groups[key] += n
+	}
+
+	if len(columns) == 0 {
+		return []Statistic{{Count: total}}, nil
+	}
+
+	statistics := make([]Statistic, 0, len(groups))
+
+	for key, n := range groups {
+		statistics = append(statistics, Statistic{
+			Day:      key.day,
+			Endpoint: key.endpoint,
+			Family:   key.family,
+			Format:   key.format,
+			Count:    n,
+		})
+	}
+
+	sort.Slice(statistics, func(i, j int) bool {
+		var (
+			a = statisticFields(&statistics[i], columns)
+			b = statisticFields(&statistics[j], columns)
+		)
+
+		for k := range a {
+			if *a[k] != *b[k] {
+				return *a[k] < *b[k]
+			}
+		}
+
+		return false
+	})
+
+	return statistics, nil
+}
+
+// ping implements the backend interface.
+func (*memoryBackend) ping() error {
+	return nil
+}
+
+// close implements the backend interface.
+func (*memoryBackend) close() error {
+	return nil
+}
@@ -0,0 +1,86 @@
+package database
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+)
+
+// migrations holds the changes needed to bring databases created by older
+// versions of the service up to date with schema.sql. Files are applied in
+// lexical order, so their names must start with a zero-padded sequence number.
+//
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// migrate creates the schema of a new database, or applies the migrations an
+// existing database is missing. The number of applied migrations is tracked
+// with SQLite's user_version pragma.
+func migrate(db *sql.DB) error {
+	names, err := fs.Glob(migrations, "migrations/*.sql")
+	if err != nil {
+		return fmt.Errorf("failed to list migrations: %w", err)
+	}
+
+	var exists bool
+
+	err = db.QueryRow("SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = 'counter')").Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("failed to inspect schema: %w", err)
+	}
+
+	if !exists {
+		// schema.sql already includes every migration.
+		return apply(db, schema, len(names))
+	}
+
+	var version int
+
+	if err = db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return fmt.Errorf("failed to get schema version: %w", err)
+	}
+
+	for i := version; i < len(names); i++ {
+		var migration []byte
+
+		migration, err = migrations.ReadFile(names[i])
+		if err != nil {
+			return fmt.Errorf("failed to read migration %s: %w", names[i], err)
+		}
+
+		if err = apply(db, string(migration), i+1); err != nil {
+			return fmt.Errorf("failed to apply migration %s: %w", names[i], err)
+		}
+	}
+
+	return nil
+}
+
+// apply executes statements and sets the schema version in a single
+// transaction.
+func apply(db *sql.DB, statements string, version int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	if _, err = tx.Exec(statements); err != nil {
+		_ = tx.Rollback()
+
+		return fmt.Errorf("failed to execute statements: %w", err)
+	}
+
+	// PRAGMA statements don't accept bound parameters.
+	if _, err = tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", version)); err != nil {
+		_ = tx.Rollback()
+
+		return fmt.Errorf("failed to set schema version: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
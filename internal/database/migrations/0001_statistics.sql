@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS statistics (
+	day TEXT NOT NULL,
+	endpoint TEXT NOT NULL,
+	family TEXT NOT NULL,
+	format TEXT NOT NULL,
+	count INTEGER NOT NULL,
+	PRIMARY KEY (day, endpoint, family, format)
+) STRICT, WITHOUT ROWID;
@@ -0,0 +1,37 @@
+package database
+
+import (
+	"database/sql"
+	_ "embed"
+	"fmt"
+
+	_ "github.com/lib/pq" //nolint:revive // PostgreSQL driver
+)
+
+// postgresSchema creates the PostgreSQL schema. Unlike SQLite databases,
+// PostgreSQL databases have no schema created by older versions of the
+// service, so there are no migrations yet.
+//
+//go:embed schema_postgres.sql
+var postgresSchema string
+
+// openPostgres opens a PostgreSQL database and creates its schema if needed.
+// Several instances of the service can share the database.
+func openPostgres(dsn string) (*sqlBackend, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	if _, err = db.Exec(postgresSchema); err != nil {
+		db.Close()
+
+		return nil, fmt.Errorf("failed to create schema: %w", err)
+	}
+
+	return &sqlBackend{
+		db:       db,
+		driver:   DriverPostgres,
+		numbered: true,
+	}, nil
+}
@@ -0,0 +1,70 @@
+//go:build postgres
+
+package database_test
+
+import (
+	"database/sql"
+	"os"
+	"testing"
+
+	"git.sr.ht/~jamesponddotco/accio127/internal/database"
+	"go.uber.org/zap"
+)
+
+// These tests need a PostgreSQL database, or a compatible one such as
+// CockroachDB, whose DSN is in the ACCIO127_POSTGRES_DSN environment variable.
+// Its tables are emptied before each test. Run them with:
+//
+//	ACCIO127_POSTGRES_DSN=postgres://... go test -tags postgres ./internal/database/
+
+// postgresDSN returns the DSN of the test database, after emptying it.
+func postgresDSN(t *testing.T) string {
+	t.Helper()
+
+	dsn := os.Getenv("ACCIO127_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("ACCIO127_POSTGRES_DSN isn't set")
+	}
+
+	// Open creates the schema.
+	db, err := database.Open(zap.NewNop(), dsn, database.Options{})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+
+	db.Close()
+
+	conn, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer conn.Close()
+
+	if _, err = conn.Exec("DELETE FROM statistics; UPDATE counter SET count = 0"); err != nil {
+		t.Fatalf("Failed to empty database: %v", err)
+	}
+
+	return dsn
+}
+
+func TestPostgres(t *testing.T) {
+	dsn := postgresDSN(t)
+
+	t.Run("statistics", func(t *testing.T) {
+		db, err := database.Open(zap.NewNop(), dsn, database.Options{})
+		if err != nil {
+			t.Fatalf("Open() error = %v", err)
+		}
+		t.Cleanup(func() { db.Close() })
+
+		if got := db.Driver(); got != database.DriverPostgres {
+			t.Errorf("Driver() = %v, want %v", got, database.DriverPostgres)
+		}
+
+		testStatistics(t, db)
+	})
+
+	t.Run("shared_counter", func(t *testing.T) {
+		testSharedCounter(t, dsn)
+	})
+}
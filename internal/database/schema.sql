@@ -4,3 +4,12 @@ CREATE TABLE IF NOT EXISTS counter (
 ) STRICT;
 
 INSERT OR IGNORE INTO counter (id, count) VALUES (1, 0);
+
+CREATE TABLE IF NOT EXISTS statistics (
+	day TEXT NOT NULL,
+	endpoint TEXT NOT NULL,
+	family TEXT NOT NULL,
+	format TEXT NOT NULL,
+	count INTEGER NOT NULL,
+	PRIMARY KEY (day, endpoint, family, format)
+) STRICT, WITHOUT ROWID;
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS counter (
+	id INTEGER PRIMARY KEY,
+	count BIGINT NOT NULL
+);
+
+INSERT INTO counter (id, count) VALUES (1, 0) ON CONFLICT DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS statistics (
+	day TEXT NOT NULL,
+	endpoint TEXT NOT NULL,
+	family TEXT NOT NULL,
+	format TEXT NOT NULL,
+	count BIGINT NOT NULL,
+	PRIMARY KEY (day, endpoint, family, format)
+);
@@ -0,0 +1,177 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// sqlBackend stores the access counter and statistics in a SQL database. The
+// queries are written for SQLite and rebound for databases using numbered
+// placeholders.
+type sqlBackend struct {
+	db     *sql.DB
+	driver string
+
+	// numbered is true if the database uses $1-style placeholders instead of
+	// question marks.
+	numbered bool
+}
+
+// name implements the backend interface.
+func (b *sqlBackend) name() string {
+	return b.driver
+}
+
+// load implements the backend interface.
+func (b *sqlBackend) load() (uint64, error) {
+	var count uint64
+
+	if err := b.db.QueryRow("SELECT count FROM counter WHERE id = 1").Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to get access counter: %w", err)
+	}
+
+	return count, nil
+}
+
+// write implements the backend interface.
+func (b *sqlBackend) write(pending map[bucket]uint64, count uint64) (uint64, error) {
+	tx, err := b.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	if _, err = tx.Exec(b.rebind("UPDATE counter SET count = count + ? WHERE id = 1"), count); err != nil {
+		_ = tx.Rollback()
+
+		return 0, fmt.Errorf("failed to increment access counter: %w", err)
+	}
+
+	stmt, err := tx.Prepare(b.rebind(`INSERT INTO statistics (day, endpoint, family, format, count) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (day, endpoint, family, format) DO UPDATE SET count = statistics.count + excluded.count`))
+	if err != nil {
+		_ = tx.Rollback()
+
+		return 0, fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	for bu, n := range pending {
+		if _, err = stmt.Exec(bu.day, bu.endpoint, bu.family, bu.format, n); err != nil {
+			_ = tx.Rollback()
+
+			return 0, fmt.Errorf("failed to increment access statistics: %w", err)
+		}
+	}
+
+	// Other instances sharing the database may have incremented the counter
+	// too.
+	var total uint64
+
+	if err = tx.QueryRow("SELECT count FROM counter WHERE id = 1").Scan(&total); err != nil {
+		_ = tx.Rollback()
+
+		return 0, fmt.Errorf("failed to get access counter: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return total, nil
+}
+
+// statistics implements the backend interface.
+func (b *sqlBackend) statistics(from, to string, columns []string) ([]Statistic, error) {
+	statement := "SELECT COALESCE(SUM(count), 0) FROM statistics WHERE day BETWEEN ? AND ?"
+
+	if len(columns) > 0 {
+		grouping := strings.Join(columns, ", ")
+
+		// Column names come from Groups, so they're safe to interpolate.
+		statement = "SELECT " + grouping + ", SUM(count) FROM statistics WHERE day BETWEEN ? AND ?" +
+			" GROUP BY " + grouping + " ORDER BY " + grouping
+	}
+
+	rows, err := b.db.Query(b.rebind(statement), from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query statistics: %w", err)
+	}
+	defer rows.Close()
+
+	var statistics []Statistic
+
+	for rows.Next() {
+		var (
+			statistic Statistic
+			dest      = make([]any, 0, len(columns)+1)
+		)
+
+		for _, field := range statisticFields(&statistic, columns) {
+			dest = append(dest, field)
+		}
+
+		dest = append(dest, &statistic.Count)
+
+		if err = rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("failed to scan statistics: %w", err)
+		}
+
+		statistics = append(statistics, statistic)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read statistics: %w", err)
+	}
+
+	return statistics, nil
+}
+
+// ping implements the backend interface.
+func (b *sqlBackend) ping() error {
+	var result int
+
+	err := b.db.QueryRow("SELECT 1").Scan(&result)
+	if err != nil || result != 1 {
+		return fmt.Errorf("failed to ping the database: %w", err)
+	}
+
+	return nil
+}
+
+// close implements the backend interface.
+func (b *sqlBackend) close() error {
+	if err := b.db.Close(); err != nil {
+		return fmt.Errorf("failed to close database: %w", err)
+	}
+
+	return nil
+}
+
+// rebind replaces the question mark placeholders in query with numbered ones
+// if the database requires them.
+func (b *sqlBackend) rebind(query string) string {
+	if !b.numbered {
+		return query
+	}
+
+	var (
+		builder strings.Builder
+		n       int
+	)
+
+	for _, r := range query {
+		if r != '?' {
+			builder.WriteRune(r)
+
+			continue
+		}
+
+		n++
+
+		builder.WriteString("$" + strconv.Itoa(n))
+	}
+
+	return builder.String()
+}
@@ -0,0 +1,31 @@
+package database
+
+import (
+	"database/sql"
+	_ "embed"
+	"fmt"
+
+	_ "github.com/mattn/go-sqlite3" //nolint:revive // SQLite3 driver
+)
+
+//go:embed schema.sql
+var schema string
+
+// openSQLite opens a SQLite database and brings its schema up to date.
+func openSQLite(dsn string) (*sqlBackend, error) {
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	if err = migrate(db); err != nil {
+		db.Close()
+
+		return nil, fmt.Errorf("failed to migrate schema: %w", err)
+	}
+
+	return &sqlBackend{
+		db:     db,
+		driver: DriverSQLite,
+	}, nil
+}
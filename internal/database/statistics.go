@@ -0,0 +1,116 @@
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"git.sr.ht/~jamesponddotco/accio127/internal/errors"
+)
+
+// DayLayout is the layout of the days statistics are bucketed by, in UTC.
+const DayLayout string = "2006-01-02"
+
+// IP families recorded in the statistics.
+const (
+	FamilyIPv4 string = "ipv4"
+	FamilyIPv6 string = "ipv6"
+)
+
+// Dimensions statistics can be grouped by.
+const (
+	GroupDay      string = "day"
+	GroupEndpoint string = "endpoint"
+	GroupFamily   string = "family"
+	GroupFormat   string = "format"
+)
+
+// Groups returns the dimensions statistics can be grouped by.
+func Groups() []string {
+	return []string{GroupDay, GroupEndpoint, GroupFamily, GroupFormat}
+}
+
+// Access describes a single request to an IP endpoint.
+type Access struct {
+	// Time is when the request was served.
+	Time time.Time
+
+	// Endpoint is the route that served the request.
+	Endpoint string
+
+	// Family is the IP family of the client, either FamilyIPv4 or FamilyIPv6.
+	Family string
+
+	// Format is the response format negotiated with the client.
+	Format string
+}
+
+// Day returns the day the access is bucketed in.
+func (a Access) Day() string {
+	return a.Time.UTC().Format(DayLayout)
+}
+
+// StatisticsQuery selects the statistics returned by DB.Statistics.
+type StatisticsQuery struct {
+	// From and To are the first and last days to include, inclusive.
+	From time.Time
+	To   time.Time
+
+	// GroupBy lists the dimensions to break the count down by. If empty, a
+	// single total is returned.
+	GroupBy []string
+}
+
+// Statistic is the number of accesses for a combination of dimensions. Fields
+// for dimensions that weren't grouped by are empty.
+type Statistic struct {
+	Day      string
+	Endpoint string
+	Family   string
+	Format   string
+	Count    uint64
+}
+
+// Statistics returns the number of accesses in the query's date range, grouped
+// by the requested dimensions and ordered by them. Increments that haven't been
+// flushed yet aren't included.
+func (d *DB) Statistics(query StatisticsQuery) ([]Statistic, error) {
+	for _, group := range query.GroupBy {
+		if !isGroup(group) {
+			return nil, fmt.Errorf("%w: %q", errors.ErrInvalidGroup, group)
+		}
+	}
+
+	return d.backend.statistics(query.From.UTC().Format(DayLayout), query.To.UTC().Format(DayLayout), query.GroupBy)
+}
+
+// statisticFields returns pointers to the fields of statistic matching
+// columns.
+func statisticFields(statistic *Statistic, columns []string) []*string {
+	fields := make([]*string, 0, len(columns))
+
+	for _, column := range columns {
+		switch column {
+		case GroupDay:
+			fields = append(fields, &statistic.Day)
+		case GroupEndpoint:
+			fields = append(fields, &statistic.Endpoint)
+		case GroupFamily:
+			fields = append(fields, &statistic.Family)
+		case GroupFormat:
+			fields = append(fields, &statistic.Format)
+		}
+	}
+
+	return fields
+}
+
+// isGroup reports whether group is a dimension statistics can be grouped by.
+func isGroup(group string) bool {
+	for _, g := range Groups() {
+		if g == group {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,189 @@
+// Package dualstack issues and opens the tokens that tie together the
+// requests a client makes over IPv4 and over IPv6, so that both of its
+// addresses can be reported at once without keeping state on the server.
+//
+// Tokens are encrypted, not just signed, since they carry the addresses seen
+// so far and end up in URLs, where they're likely to be logged.
+package dualstack
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+	"time"
+
+	"git.sr.ht/~jamesponddotco/xstd-go/xerrors"
+)
+
+const (
+	// ErrEmptySecret is returned when a Sealer is created without a secret.
+	ErrEmptySecret xerrors.Error = "dual-stack secret cannot be empty"
+
+	// ErrInvalidTTL is returned when a Sealer is created with a TTL that
+	// isn't positive.
+	ErrInvalidTTL xerrors.Error = "dual-stack token TTL must be positive"
+
+	// ErrInvalidToken is returned when a token is malformed or wasn't issued
+	// with the same secret.
+	ErrInvalidToken xerrors.Error = "invalid dual-stack token"
+
+	// ErrExpiredToken is returned when a token is past its expiry time.
+	ErrExpiredToken xerrors.Error = "expired dual-stack token"
+)
+
+const (
+	// keyLabel is the label the encryption key is derived from the secret
+	// with, so that it differs from the keys used to hash IP addresses.
+	keyLabel string = "accio127 dual-stack token"
+
+	// additionalData is authenticated along with each token, binding it to
+	// its format.
+	additionalData string = "accio127 dual-stack v1"
+
+	// flagIPv4 and flagIPv6 mark which addresses a token holds.
+	flagIPv4 byte = 1 << 0
+	flagIPv6 byte = 1 << 1
+
+	// payloadSize is the size of a token before encryption: the flags, the
+	// expiry time in seconds, and both addresses.
+	payloadSize int = 1 + 8 + 4 + 16
+)
+
+// Token is what the server knows about a client across its requests.
+type Token struct {
+	// Expires is when the token stops being accepted.
+	Expires time.Time
+
+	// IPv4 is the IPv4 address the client was seen with, if any.
+	IPv4 netip.Addr
+
+	// IPv6 is the IPv6 address the client was seen with, if any.
+	IPv6 netip.Addr
+}
+
+// With returns the token with addr recorded as the client's address of its
+// family. IPv4-mapped IPv6 addresses count as IPv4.
+func (t Token) With(addr netip.Addr) Token {
+	addr = addr.Unmap()
+
+	switch {
+	case addr.Is4():
+		t.IPv4 = addr
+	case addr.Is6():
+		t.IPv6 = addr.WithZone("")
+	}
+
+	return t
+}
+
+// Complete reports whether both addresses of the client are known.
+func (t Token) Complete() bool {
+	return t.IPv4.IsValid() && t.IPv6.IsValid()
+}
+
+// Sealer issues, seals, and opens tokens.
+type Sealer struct {
+	aead cipher.AEAD
+	ttl  time.Duration
+}
+
+// New creates a new Sealer issuing tokens valid for ttl, encrypted with a key
+// derived from secret. Servers sharing a secret accept each other's tokens.
+func New(secret []byte, ttl time.Duration) (*Sealer, error) {
+	if len(secret) == 0 {
+		return nil, ErrEmptySecret
+	}
+
+	if ttl <= 0 {
+		return nil, ErrInvalidTTL
+	}
+
+	m := hmac.New(sha256.New, secret)
+	m.Write([]byte(keyLabel))
+
+	// The key is 32 bytes long, a valid AES-256 key, so neither call can
+	// fail.
+	block, _ := aes.NewCipher(m.Sum(nil))
+	aead, _ := cipher.NewGCM(block)
+
+	return &Sealer{
+		aead: aead,
+		ttl:  ttl,
+	}, nil
+}
+
+// Issue returns a new, empty token expiring a TTL after now.
+func (s *Sealer) Issue(now time.Time) Token {
+	return Token{
+		Expires: now.Add(s.ttl).Truncate(time.Second).UTC(),
+	}
+}
+
+// Seal encrypts t into a URL-safe string.
+func (s *Sealer) Seal(t Token) (string, error) {
+	payload := make([]byte, payloadSize)
+
+	binary.BigEndian.PutUint64(payload[1:9], uint64(t.Expires.Unix()))
+
+	if t.IPv4.Is4() {
+		payload[0] |= flagIPv4
+		v4 := t.IPv4.As4()
+
+		copy(payload[9:13], v4[:])
+	}
+
+	if t.IPv6.Is6() {
+		payload[0] |= flagIPv6
+		v6 := t.IPv6.As16()
+
+		copy(payload[13:], v6[:])
+	}
+
+	nonce := make([]byte, s.aead.NonceSize(), s.aead.NonceSize()+payloadSize+s.aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	sealed := s.aead.Seal(nonce, nonce, payload, []byte(additionalData))
+
+	return base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts token, returning ErrExpiredToken if it expired before now.
+func (s *Sealer) Open(token string, now time.Time) (Token, error) {
+	sealed, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(sealed) < s.aead.NonceSize() {
+		return Token{}, ErrInvalidToken
+	}
+
+	nonce, ciphertext := sealed[:s.aead.NonceSize()], sealed[s.aead.NonceSize():]
+
+	payload, err := s.aead.Open(nil, nonce, ciphertext, []byte(additionalData))
+	if err != nil || len(payload) != payloadSize {
+		return Token{}, ErrInvalidToken
+	}
+
+	t := Token{
+		Expires: time.Unix(int64(binary.BigEndian.Uint64(payload[1:9])), 0).UTC(),
+	}
+
+	if payload[0]&flagIPv4 != 0 {
+		t.IPv4 = netip.AddrFrom4([4]byte(payload[9:13]))
+	}
+
+	if payload[0]&flagIPv6 != 0 {
+		t.IPv6 = netip.AddrFrom16([16]byte(payload[13:]))
+	}
+
+	if !now.Before(t.Expires) {
+		return Token{}, ErrExpiredToken
+	}
+
+	return t, nil
+}
@@ -0,0 +1,161 @@
+package dualstack_test
+
+import (
+	"errors"
+	"net/netip"
+	"testing"
+	"time"
+
+	"git.sr.ht/~jamesponddotco/accio127/internal/dualstack"
+)
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		secret  []byte
+		ttl     time.Duration
+		wantErr bool
+	}{
+		{
+			name:   "valid",
+			secret: []byte("secret"),
+			ttl:    time.Minute,
+		},
+		{
+			name:    "empty_secret",
+			ttl:     time.Minute,
+			wantErr: true,
+		},
+		{
+			name:    "zero_ttl",
+			secret:  []byte("secret"),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := dualstack.New(tt.secret, tt.ttl)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("New() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSealer_Open(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2023, time.June, 1, 12, 0, 0, 0, time.UTC)
+
+	sealer, err := dualstack.New([]byte("secret"), time.Minute)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	other, err := dualstack.New([]byte("other secret"), time.Minute)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	var (
+		empty = sealer.Issue(now)
+		ipv4  = empty.With(netip.MustParseAddr("::ffff:192.0.2.1"))
+		both  = ipv4.With(netip.MustParseAddr("2001:db8::1"))
+	)
+
+	tests := []struct {
+		name    string
+		sealer  *dualstack.Sealer
+		token   dualstack.Token
+		now     time.Time
+		want    dualstack.Token
+		wantErr error
+	}{
+		{
+			name:   "empty",
+			sealer: sealer,
+			token:  empty,
+			now:    now,
+			want:   empty,
+		},
+		{
+			name:   "ipv4_only",
+			sealer: sealer,
+			token:  ipv4,
+			now:    now,
+			want: dualstack.Token{
+				Expires: now.Add(time.Minute),
+				IPv4:    netip.MustParseAddr("192.0.2.1"),
+			},
+		},
+		{
+			name:   "both",
+			sealer: sealer,
+			token:  both,
+			now:    now.Add(59 * time.Second),
+			want: dualstack.Token{
+				Expires: now.Add(time.Minute),
+				IPv4:    netip.MustParseAddr("192.0.2.1"),
+				IPv6:    netip.MustParseAddr("2001:db8::1"),
+			},
+		},
+		{
+			name:    "expired",
+			sealer:  sealer,
+			token:   both,
+			now:     now.Add(time.Minute),
+			wantErr: dualstack.ErrExpiredToken,
+		},
+		{
+			name:    "different_secret",
+			sealer:  other,
+			token:   both,
+			now:     now,
+			wantErr: dualstack.ErrInvalidToken,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			token, err := sealer.Seal(tt.token)
+			if err != nil {
+				t.Fatalf("Seal() error = %v", err)
+			}
+
+			got, err := tt.sealer.Open(token, tt.now)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Open() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Errorf("Open() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSealer_Open_Malformed(t *testing.T) {
+	t.Parallel()
+
+	sealer, err := dualstack.New([]byte("secret"), time.Minute)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	for _, token := range []string{"", "not base64!", "c2hvcnQ", "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA"} {
+		if _, err := sealer.Open(token, time.Now()); !errors.Is(err, dualstack.ErrInvalidToken) {
+			t.Errorf("Open(%q) error = %v, want %v", token, err, dualstack.ErrInvalidToken)
+		}
+	}
+}
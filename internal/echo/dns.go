@@ -0,0 +1,391 @@
+package echo
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+	"time"
+
+	"git.sr.ht/~jamesponddotco/accio127/internal/retry"
+	"git.sr.ht/~jamesponddotco/xstd-go/xerrors"
+	"go.uber.org/zap"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// ErrInvalidName is returned when a DNSServer is created with an invalid
+// domain name.
+const ErrInvalidName xerrors.Error = "invalid domain name"
+
+const (
+	// optionClientSubnet is the code of the EDNS Client Subnet option, as
+	// defined in RFC 7871.
+	optionClientSubnet uint16 = 8
+
+	// ednsPayloadSize is the UDP payload size advertised in responses to
+	// EDNS queries, as recommended by the DNS Flag Day 2020.
+	ednsPayloadSize int = 1232
+)
+
+// errInvalidClientSubnet is returned when parsing a malformed EDNS Client
+// Subnet option, which is answered with FORMERR.
+const errInvalidClientSubnet xerrors.Error = "invalid EDNS Client Subnet option"
+
+// DNSServer is an authoritative DNS server for a single name, answering A,
+// AAAA, and TXT queries for it with the address of the resolver asking, or
+// with the client subnet the resolver sent along with the query, if any.
+//
+// Answers have a TTL of zero, so that resolvers don't cache them, and queries
+// for other names are refused.
+type DNSServer struct {
+	record Recorder
+	logger *zap.Logger
+	name   string
+}
+
+// NewDNSServer creates a new DNSServer answering queries for name and
+// reporting the clients it answers to record.
+func NewDNSServer(name string, record Recorder, logger *zap.Logger) (*DNSServer, error) {
+	if !strings.HasSuffix(name, ".") {
+		name += "."
+	}
+
+	if _, err := dnsmessage.NewName(name); err != nil || name == "." {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidName, name)
+	}
+
+	return &DNSServer{
+		record: record,
+		logger: logger,
+		name:   name,
+	}, nil
+}
+
+// Serve answers the queries received on conn until it's closed. Temporary
+// errors are retried with a growing delay.
+func (s *DNSServer) Serve(conn net.PacketConn) error {
+	var (
+		buf     = make([]byte, maxDatagramSize)
+		backoff retry.Backoff
+	)
+
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+
+			if !retry.Temporary(err) {
+				return fmt.Errorf("failed to read DNS query: %w", err)
+			}
+
+			delay := backoff.Next()
+
+			s.logger.Warn("Failed to read DNS query, retrying", zap.Duration("delay", delay), zap.Error(err))
+			time.Sleep(delay)
+
+			continue
+		}
+
+		backoff.Reset()
+
+		start := time.Now()
+
+		resolver, ok := addrOf(addr)
+		if !ok {
+			continue
+		}
+
+		response, hit := s.answer(buf[:n], resolver)
+		if response == nil {
+			continue
+		}
+
+		if _, err = conn.WriteTo(response, addr); err != nil {
+			s.logger.Debug("Failed to write DNS response", zap.Error(err))
+
+			continue
+		}
+
+		if hit != nil {
+			hit.Time = start
+			hit.Latency = time.Since(start)
+			hit.Bytes = len(response)
+
+			s.record(hit)
+		}
+	}
+}
+
+// query is the part of a DNS query the response depends on.
+type query struct {
+	header   dnsmessage.Header
+	question dnsmessage.Question
+
+	// subnet is the client subnet sent by the resolver. It's invalid if
+	// there wasn't one.
+	subnet netip.Prefix
+
+	// edns is true if the query had an OPT record.
+	edns bool
+}
+
+// answer returns the response to msg, sent by resolver, along with the hit to
+// record if the query was answered. It returns a nil response for messages
+// that shouldn't be answered at all, such as responses.
+func (s *DNSServer) answer(msg []byte, resolver netip.Addr) ([]byte, *Hit) {
+	q, rcode, ok := parseQuery(msg)
+	if !ok {
+		return nil, nil
+	}
+
+	if rcode == dnsmessage.RCodeSuccess {
+		switch {
+		case q.header.OpCode != 0:
+			rcode = dnsmessage.RCodeNotImplemented
+		case q.question.Class != dnsmessage.ClassINET || !strings.EqualFold(q.question.Name.String(), s.name):
+			rcode = dnsmessage.RCodeRefused
+		}
+	}
+
+	client := resolver
+	if q.subnet.IsValid() && q.subnet.Bits() > 0 {
+		client = q.subnet.Addr()
+	}
+
+	b := dnsmessage.NewBuilder(make([]byte, 0, 512), dnsmessage.Header{
+		ID:               q.header.ID,
+		Response:         true,
+		OpCode:           q.header.OpCode,
+		Authoritative:    rcode == dnsmessage.RCodeSuccess,
+		RecursionDesired: q.header.RecursionDesired,
+		RCode:            rcode,
+	})
+	b.EnableCompression()
+
+	// The builder only fails when sections are built out of order or
+	// records are too large, neither of which can happen here.
+	_ = b.StartQuestions()
+
+	if q.question.Name.Length > 0 {
+		_ = b.Question(q.question)
+	}
+
+	if rcode == dnsmessage.RCodeSuccess {
+		_ = b.StartAnswers()
+
+		addAnswer(&b, q.question, client)
+	}
+
+	_ = b.StartAdditionals()
+
+	if q.edns {
+		var (
+			header  dnsmessage.ResourceHeader
+			options []dnsmessage.Option
+		)
+
+		_ = header.SetEDNS0(ednsPayloadSize, rcode, false)
+
+		if q.subnet.IsValid() {
+			options = append(options, clientSubnetOption(q.subnet))
+		}
+
+		_ = b.OPTResource(header, dnsmessage.OPTResource{Options: options})
+	}
+
+	response, err := b.Finish()
+	if err != nil {
+		s.logger.Error("Failed to build DNS response", zap.Error(err))
+
+		return nil, nil
+	}
+
+	if rcode != dnsmessage.RCodeSuccess {
+		return response, nil
+	}
+
+	qtype := strings.TrimPrefix(q.question.Type.String(), "Type")
+
+	return response, &Hit{
+		Client:   client,
+		Protocol: ProtocolDNS,
+		Method:   "QUERY",
+		Query:    q.question.Name.String() + " " + qtype,
+		Format:   strings.ToLower(qtype),
+	}
+}
+
+// addAnswer adds the record answering question with client to b, if any: A
+// queries are answered with IPv4 addresses, AAAA queries with IPv6 ones, and
+// TXT queries with either. Other queries get an empty answer.
+func addAnswer(b *dnsmessage.Builder, question dnsmessage.Question, client netip.Addr) {
+	header := dnsmessage.ResourceHeader{
+		Name:  question.Name,
+		Type:  question.Type,
+		Class: dnsmessage.ClassINET,
+		TTL:   0,
+	}
+
+	switch {
+	case question.Type == dnsmessage.TypeA && client.Is4():
+		_ = b.AResource(header, dnsmessage.AResource{A: client.As4()})
+	case question.Type == dnsmessage.TypeAAAA && client.Is6():
+		_ = b.AAAAResource(header, dnsmessage.AAAAResource{AAAA: client.As16()})
+	case question.Type == dnsmessage.TypeTXT:
+		_ = b.TXTResource(header, dnsmessage.TXTResource{TXT: []string{client.String()}})
+	}
+}
+
+// parseQuery parses the query in msg. It returns false if msg isn't a query
+// worth answering, and an RCode other than success if it's malformed in a way
+// that can be reported to the resolver.
+func parseQuery(msg []byte) (query, dnsmessage.RCode, bool) {
+	var (
+		p      dnsmessage.Parser
+		q      query
+		header dnsmessage.ResourceHeader
+		opt    dnsmessage.OPTResource
+		err    error
+	)
+
+	q.header, err = p.Start(msg)
+	if err != nil || q.header.Response {
+		return q, 0, false
+	}
+
+	q.question, err = p.Question()
+	if err != nil {
+		return q, dnsmessage.RCodeFormatError, true
+	}
+
+	if err = p.SkipAllQuestions(); err != nil {
+		return q, dnsmessage.RCodeFormatError, true
+	}
+
+	if err = p.SkipAllAnswers(); err != nil {
+		return q, dnsmessage.RCodeFormatError, true
+	}
+
+	if err = p.SkipAllAuthorities(); err != nil {
+		return q, dnsmessage.RCodeFormatError, true
+	}
+
+	for {
+		header, err = p.AdditionalHeader()
+		if errors.Is(err, dnsmessage.ErrSectionDone) {
+			break
+		}
+
+		if err != nil {
+			return q, dnsmessage.RCodeFormatError, true
+		}
+
+		if header.Type != dnsmessage.TypeOPT {
+			if err = p.SkipAdditional(); err != nil {
+				return q, dnsmessage.RCodeFormatError, true
+			}
+
+			continue
+		}
+
+		opt, err = p.OPTResource()
+		if err != nil {
+			return q, dnsmessage.RCodeFormatError, true
+		}
+
+		q.edns = true
+
+		for _, option := range opt.Options {
+			if option.Code != optionClientSubnet {
+				continue
+			}
+
+			q.subnet, err = parseClientSubnet(option.Data)
+			if err != nil {
+				return q, dnsmessage.RCodeFormatError, true
+			}
+		}
+	}
+
+	return q, dnsmessage.RCodeSuccess, true
+}
+
+// parseClientSubnet parses the data of an EDNS Client Subnet option, made of
+// the address family, the source and scope prefix lengths, and as many bytes
+// of the address as the source prefix length covers.
+func parseClientSubnet(data []byte) (netip.Prefix, error) {
+	if len(data) < 4 {
+		return netip.Prefix{}, errInvalidClientSubnet
+	}
+
+	var (
+		family = binary.BigEndian.Uint16(data)
+		bits   = int(data[2])
+		scope  = data[3]
+		raw    = data[4:]
+		addr   netip.Addr
+	)
+
+	switch family {
+	case 1:
+		var ip [4]byte
+
+		if bits > 32 || len(raw) > len(ip) {
+			return netip.Prefix{}, errInvalidClientSubnet
+		}
+
+		copy(ip[:], raw)
+		addr = netip.AddrFrom4(ip)
+	case 2:
+		var ip [16]byte
+
+		if bits > 128 || len(raw) > len(ip) {
+			return netip.Prefix{}, errInvalidClientSubnet
+		}
+
+		copy(ip[:], raw)
+		addr = netip.AddrFrom16(ip)
+	default:
+		return netip.Prefix{}, errInvalidClientSubnet
+	}
+
+	if scope != 0 || len(raw) != (bits+7)/8 {
+		return netip.Prefix{}, errInvalidClientSubnet
+	}
+
+	prefix := netip.PrefixFrom(addr, bits)
+	if prefix.Masked() != prefix {
+		return netip.Prefix{}, errInvalidClientSubnet
+	}
+
+	return prefix, nil
+}
+
+// clientSubnetOption returns the EDNS Client Subnet option of a response to a
+// query for subnet, with a scope as long as the source prefix, as the answer
+// is only valid for it.
+func clientSubnetOption(subnet netip.Prefix) dnsmessage.Option {
+	family := uint16(1)
+	if subnet.Addr().Is6() {
+		family = 2
+	}
+
+	var (
+		bits = subnet.Bits()
+		raw  = subnet.Addr().AsSlice()
+		data = make([]byte, 4, 4+(bits+7)/8)
+	)
+
+	binary.BigEndian.PutUint16(data, family)
+	data[2] = byte(bits)
+	data[3] = byte(bits)
+
+	return dnsmessage.Option{
+		Code: optionClientSubnet,
+		Data: append(data, raw[:(bits+7)/8]...),
+	}
+}
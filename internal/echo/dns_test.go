@@ -0,0 +1,281 @@
+package echo_test
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"git.sr.ht/~jamesponddotco/accio127/internal/echo"
+	"go.uber.org/zap"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func TestNewDNSServer(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		domain  string
+		wantErr bool
+	}{
+		{
+			name:   "name",
+			domain: "ip.example.com",
+		},
+		{
+			name:   "fully_qualified_name",
+			domain: "ip.example.com.",
+		},
+		{
+			name:    "empty_name",
+			domain:  "",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := echo.NewDNSServer(tt.domain, func(*echo.Hit) {}, zap.NewNop())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewDNSServer() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDNSServer(t *testing.T) {
+	t.Parallel()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	t.Cleanup(func() { conn.Close() })
+
+	server, err := echo.NewDNSServer("ip.example.com", func(*echo.Hit) {}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+
+	go server.Serve(conn) //nolint:errcheck // the connection is closed by the cleanup
+
+	tests := []struct {
+		name string
+		// domain and qtype are the question asked.
+		domain string
+		qtype  dnsmessage.Type
+		// subnet is the EDNS Client Subnet option sent, if any.
+		subnet     []byte
+		wantRCode  dnsmessage.RCode
+		wantAnswer string
+		// wantScope is the scope of the EDNS Client Subnet option of the
+		// response, or -1 if it shouldn't have one.
+		wantScope int
+	}{
+		{
+			name:       "txt",
+			domain:     "IP.Example.com.",
+			qtype:      dnsmessage.TypeTXT,
+			wantRCode:  dnsmessage.RCodeSuccess,
+			wantAnswer: "127.0.0.1",
+			wantScope:  -1,
+		},
+		{
+			name:       "a",
+			domain:     "ip.example.com.",
+			qtype:      dnsmessage.TypeA,
+			wantRCode:  dnsmessage.RCodeSuccess,
+			wantAnswer: "127.0.0.1",
+			wantScope:  -1,
+		},
+		{
+			name:      "aaaa_without_ipv6",
+			domain:    "ip.example.com.",
+			qtype:     dnsmessage.TypeAAAA,
+			wantRCode: dnsmessage.RCodeSuccess,
+			wantScope: -1,
+		},
+		{
+			name:      "other_name",
+			domain:    "example.com.",
+			qtype:     dnsmessage.TypeA,
+			wantRCode: dnsmessage.RCodeRefused,
+			wantScope: -1,
+		},
+		{
+			name:       "client_subnet_ipv4",
+			domain:     "ip.example.com.",
+			qtype:      dnsmessage.TypeTXT,
+			subnet:     []byte{0, 1, 24, 0, 203, 0, 113},
+			wantRCode:  dnsmessage.RCodeSuccess,
+			wantAnswer: "203.0.113.0",
+			wantScope:  24,
+		},
+		{
+			name:       "client_subnet_ipv6",
+			domain:     "ip.example.com.",
+			qtype:      dnsmessage.TypeAAAA,
+			subnet:     []byte{0, 2, 56, 0, 0x20, 0x01, 0x0d, 0xb8, 0, 0, 0x01},
+			wantRCode:  dnsmessage.RCodeSuccess,
+			wantAnswer: "2001:db8:0:100::",
+			wantScope:  56,
+		},
+		{
+			name:       "client_subnet_without_address",
+			domain:     "ip.example.com.",
+			qtype:      dnsmessage.TypeTXT,
+			subnet:     []byte{0, 1, 0, 0},
+			wantRCode:  dnsmessage.RCodeSuccess,
+			wantAnswer: "127.0.0.1",
+			wantScope:  0,
+		},
+		{
+			name:      "client_subnet_with_extra_bits",
+			domain:    "ip.example.com.",
+			qtype:     dnsmessage.TypeTXT,
+			subnet:    []byte{0, 1, 20, 0, 203, 0, 113},
+			wantRCode: dnsmessage.RCodeFormatError,
+			wantScope: -1,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			response := exchange(t, conn.LocalAddr().String(), tt.domain, tt.qtype, tt.subnet)
+
+			if response.RCode != tt.wantRCode {
+				t.Fatalf("RCode = %v, want %v", response.RCode, tt.wantRCode)
+			}
+
+			if response.RCode == dnsmessage.RCodeSuccess && !response.Authoritative {
+				t.Errorf("Authoritative = false, want true")
+			}
+
+			var answer string
+
+			if len(response.Answers) > 0 {
+				record := response.Answers[0]
+
+				if record.Header.TTL != 0 {
+					t.Errorf("TTL = %d, want 0", record.Header.TTL)
+				}
+
+				switch body := record.Body.(type) {
+				case *dnsmessage.TXTResource:
+					answer = body.TXT[0]
+				case *dnsmessage.AResource:
+					answer = netip.AddrFrom4(body.A).String()
+				case *dnsmessage.AAAAResource:
+					answer = netip.AddrFrom16(body.AAAA).String()
+				}
+			}
+
+			if answer != tt.wantAnswer {
+				t.Errorf("answer = %q, want %q", answer, tt.wantAnswer)
+			}
+
+			if got := responseScope(response); got != tt.wantScope {
+				t.Errorf("client subnet scope = %d, want %d", got, tt.wantScope)
+			}
+		})
+	}
+}
+
+// exchange sends a query for domain and qtype to addr, with an OPT record if
+// subnet isn't nil, and returns the response.
+func exchange(t *testing.T, addr, domain string, qtype dnsmessage.Type, subnet []byte) *dnsmessage.Message {
+	t.Helper()
+
+	query := dnsmessage.Message{
+		Header: dnsmessage.Header{ID: 42, RecursionDesired: true},
+		Questions: []dnsmessage.Question{
+			{
+				Name:  dnsmessage.MustNewName(domain),
+				Type:  qtype,
+				Class: dnsmessage.ClassINET,
+			},
+		},
+	}
+
+	if subnet != nil {
+		var header dnsmessage.ResourceHeader
+
+		if err := header.SetEDNS0(1232, dnsmessage.RCodeSuccess, false); err != nil {
+			t.Fatalf("Failed to set EDNS0: %v", err)
+		}
+
+		query.Additionals = append(query.Additionals, dnsmessage.Resource{
+			Header: header,
+			Body: &dnsmessage.OPTResource{
+				Options: []dnsmessage.Option{{Code: 8, Data: subnet}},
+			},
+		})
+	}
+
+	msg, err := query.Pack()
+	if err != nil {
+		t.Fatalf("Failed to pack query: %v", err)
+	}
+
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer conn.Close()
+
+	if err = conn.SetDeadline(time.Now().Add(time.Second)); err != nil {
+		t.Fatalf("Failed to set deadline: %v", err)
+	}
+
+	if _, err = conn.Write(msg); err != nil {
+		t.Fatalf("Failed to send query: %v", err)
+	}
+
+	buf := make([]byte, 1232)
+
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("Failed to read response: %v", err)
+	}
+
+	var response dnsmessage.Message
+
+	if err = response.Unpack(buf[:n]); err != nil {
+		t.Fatalf("Failed to unpack response: %v", err)
+	}
+
+	if response.ID != query.ID || !response.Response {
+		t.Fatalf("response = %+v, want a response to query %d", response.Header, query.ID)
+	}
+
+	return &response
+}
+
+// responseScope returns the scope of the EDNS Client Subnet option of
+// response, or -1 if it doesn't have one.
+func responseScope(response *dnsmessage.Message) int {
+	for _, additional := range response.Additionals {
+		opt, ok := additional.Body.(*dnsmessage.OPTResource)
+		if !ok {
+			continue
+		}
+
+		for _, option := range opt.Options {
+			if option.Code == 8 && len(option.Data) >= 4 {
+				return int(option.Data[3])
+			}
+		}
+	}
+
+	return -1
+}
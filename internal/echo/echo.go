@@ -0,0 +1,283 @@
+// Package echo serves the client's IP address without HTTP: over TCP, by
+// writing it to every connection, over UDP, by answering every datagram with
+// it, over DNS, by answering queries for a name with the resolver's or the
+// EDNS Client Subnet address, and over STUN, by answering Binding requests
+// with the address and port the client's NAT mapped it to.
+package echo
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"sync"
+	"time"
+
+	"git.sr.ht/~jamesponddotco/accio127/internal/retry"
+	"go.uber.org/zap"
+)
+
+const (
+	// ProtocolTCP identifies hits on the TCP server.
+	ProtocolTCP string = "tcp"
+
+	// ProtocolUDP identifies hits on the UDP server.
+	ProtocolUDP string = "udp"
+
+	// ProtocolDNS identifies hits on the DNS server.
+	ProtocolDNS string = "dns"
+
+	// ProtocolSTUN identifies hits on the STUN server.
+	ProtocolSTUN string = "stun"
+
+	// FormatText is the format of the addresses returned by the TCP and UDP
+	// servers.
+	FormatText string = "text"
+)
+
+// Hit describes a client served.
+type Hit struct {
+	// Time is when the connection, datagram, or query was received.
+	Time time.Time
+
+	// Client is the address returned to the client.
+	Client netip.Addr
+
+	// Protocol is one of the Protocol constants.
+	Protocol string
+
+	// Method is the kind of request served: "ECHO" for the TCP and UDP
+	// servers, "QUERY" for the DNS server, and "BINDING" for the STUN
+	// server.
+	Method string
+
+	// Query is the name and type asked for, such as "ip.accio127.com. TXT".
+	// It's empty unless Protocol is ProtocolDNS.
+	Query string
+
+	// Format is FormatText for the TCP and UDP servers, the lowercase query
+	// type, such as "txt", for the DNS server, and the transport, "udp" or
+	// "tcp", for the STUN server.
+	Format string
+
+	// Latency is how long the client took to serve.
+	Latency time.Duration
+
+	// Bytes is the size of the response.
+	Bytes int
+}
+
+// Recorder is called with every client served, to count and log it.
+type Recorder func(hit *Hit)
+
+// TCPServer writes the client's IP address, followed by a newline, to every
+// connection it accepts, then closes it.
+//
+// Behind a load balancer, the listener must require PROXY protocol headers
+// from it: as the server speaks first, connections from the load balancer
+// without a header would otherwise wait for one until they time out.
+type TCPServer struct {
+	record  Recorder
+	logger  *zap.Logger
+	timeout time.Duration
+	wg      sync.WaitGroup
+}
+
+// NewTCPServer creates a new TCPServer giving clients timeout to receive their
+// address and reporting them to record.
+func NewTCPServer(timeout time.Duration, record Recorder, logger *zap.Logger) *TCPServer {
+	return &TCPServer{
+		record:  record,
+		logger:  logger,
+		timeout: timeout,
+	}
+}
+
+// Serve accepts connections on listener until it's closed, serving each in
+// the background. Temporary errors, such as running out of file descriptors,
+// are retried with a growing delay.
+func (s *TCPServer) Serve(listener net.Listener) error {
+	var backoff retry.Backoff
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+
+			if !retry.Temporary(err) {
+				return fmt.Errorf("failed to accept TCP echo connection: %w", err)
+			}
+
+			delay := backoff.Next()
+
+			s.logger.Warn("Failed to accept TCP echo connection, retrying", zap.Duration("delay", delay), zap.Error(err))
+			time.Sleep(delay)
+
+			continue
+		}
+
+		backoff.Reset()
+
+		s.wg.Add(1)
+
+		go s.serveConn(conn)
+	}
+}
+
+// Wait waits for the connections being served to be closed.
+func (s *TCPServer) Wait() {
+	s.wg.Wait()
+}
+
+// serveConn writes the address of the client of conn to it and closes it.
+func (s *TCPServer) serveConn(conn net.Conn) {
+	defer s.wg.Done()
+	defer conn.Close()
+
+	start := time.Now()
+
+	if err := conn.SetDeadline(start.Add(s.timeout)); err != nil {
+		return
+	}
+
+	// Connections accepted through the PROXY protocol read their header on
+	// the first read, failing if it's invalid or missing, rather than
+	// falling back to the load balancer's address. Empty reads return at
+	// once otherwise.
+	if _, err := conn.Read(nil); err != nil {
+		s.logger.Debug("Dropping TCP echo connection", zap.Error(err))
+
+		return
+	}
+
+	client, ok := addrOf(conn.RemoteAddr())
+	if !ok {
+		return
+	}
+
+	n, err := io.WriteString(conn, client.String()+"\n")
+	if err != nil {
+		s.logger.Debug("Failed to write TCP echo response", zap.Error(err))
+
+		return
+	}
+
+	s.record(&Hit{
+		Time:     start,
+		Client:   client,
+		Protocol: ProtocolTCP,
+		Method:   "ECHO",
+		Format:   FormatText,
+		Latency:  time.Since(start),
+		Bytes:    n,
+	})
+}
+
+// UDPServer answers every datagram with its source IP address, followed by a
+// newline.
+//
+// So that it can't be used to amplify attacks with spoofed source addresses,
+// the answer is never larger than the datagram: datagrams shorter than the
+// address are ignored.
+type UDPServer struct {
+	record Recorder
+	logger *zap.Logger
+}
+
+// NewUDPServer creates a new UDPServer reporting the clients it answers to
+// record.
+func NewUDPServer(record Recorder, logger *zap.Logger) *UDPServer {
+	return &UDPServer{
+		record: record,
+		logger: logger,
+	}
+}
+
+// Serve answers the datagrams received on conn until it's closed. Temporary
+// errors are retried with a growing delay.
+func (s *UDPServer) Serve(conn net.PacketConn) error {
+	var (
+		buf     = make([]byte, maxDatagramSize)
+		backoff retry.Backoff
+	)
+
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+
+			if !retry.Temporary(err) {
+				return fmt.Errorf("failed to read UDP echo datagram: %w", err)
+			}
+
+			delay := backoff.Next()
+
+			s.logger.Warn("Failed to read UDP echo datagram, retrying", zap.Duration("delay", delay), zap.Error(err))
+			time.Sleep(delay)
+
+			continue
+		}
+
+		backoff.Reset()
+
+		start := time.Now()
+
+		client, ok := addrOf(addr)
+		if !ok {
+			continue
+		}
+
+		response := client.String() + "\n"
+		if len(response) > n {
+			continue
+		}
+
+		if _, err = conn.WriteTo([]byte(response), addr); err != nil {
+			s.logger.Debug("Failed to write UDP echo response", zap.Error(err))
+
+			continue
+		}
+
+		s.record(&Hit{
+			Time:     start,
+			Client:   client,
+			Protocol: ProtocolUDP,
+			Method:   "ECHO",
+			Format:   FormatText,
+			Latency:  time.Since(start),
+			Bytes:    len(response),
+		})
+	}
+}
+
+// maxDatagramSize is the size of the largest datagram read by the UDP, DNS,
+// and STUN servers. Larger ones are truncated.
+const maxDatagramSize int = 1500
+
+// addrOf returns the IP address of addr, with IPv4-mapped IPv6 addresses
+// unmapped.
+func addrOf(addr net.Addr) (netip.Addr, bool) {
+	addrPort, ok := addrPortOf(addr)
+
+	return addrPort.Addr(), ok
+}
+
+// addrPortOf returns the IP address and port of addr, with IPv4-mapped IPv6
+// addresses unmapped.
+func addrPortOf(addr net.Addr) (netip.AddrPort, bool) {
+	if addr == nil {
+		return netip.AddrPort{}, false
+	}
+
+	addrPort, err := netip.ParseAddrPort(addr.String())
+	if err != nil {
+		return netip.AddrPort{}, false
+	}
+
+	return netip.AddrPortFrom(addrPort.Addr().Unmap().WithZone(""), addrPort.Port()), true
+}
@@ -0,0 +1,348 @@
+package echo_test
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/netip"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"git.sr.ht/~jamesponddotco/accio127/internal/echo"
+	"git.sr.ht/~jamesponddotco/accio127/internal/proxyproto"
+	"go.uber.org/zap"
+)
+
+// hits collects the hits recorded by a server.
+type hits struct {
+	mu   sync.Mutex
+	hits []echo.Hit
+}
+
+func (h *hits) record(hit *echo.Hit) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.hits = append(h.hits, *hit)
+}
+
+func (h *hits) get() []echo.Hit {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return append([]echo.Hit(nil), h.hits...)
+}
+
+// failingListener is a net.Listener whose first calls to Accept fail with
+// errs.
+type failingListener struct {
+	net.Listener
+	mu   sync.Mutex
+	errs []error
+}
+
+func (l *failingListener) Accept() (net.Conn, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.errs) > 0 {
+		err := l.errs[0]
+		l.errs = l.errs[1:]
+
+		return nil, err
+	}
+
+	return l.Listener.Accept() //nolint:wrapcheck // must behave like the wrapped listener
+}
+
+// acceptError returns the error Accept fails with when errno is raised.
+func acceptError(errno syscall.Errno) error {
+	return &net.OpError{Op: "accept", Net: "tcp", Err: os.NewSyscallError("accept4", errno)}
+}
+
+func TestTCPServer(t *testing.T) {
+	t.Parallel()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	var (
+		recorded hits
+		server   = echo.NewTCPServer(time.Second, recorded.record, zap.NewNop())
+		served   = make(chan error, 1)
+	)
+
+	go func() { served <- server.Serve(listener) }()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+
+	body, err := io.ReadAll(conn)
+	conn.Close()
+
+	if err != nil {
+		t.Fatalf("Failed to read response: %v", err)
+	}
+
+	if got, want := string(body), "127.0.0.1\n"; got != want {
+		t.Errorf("response = %q, want %q", got, want)
+	}
+
+	listener.Close()
+
+	if err = <-served; err != nil {
+		t.Errorf("Serve() = %v, want nil", err)
+	}
+
+	server.Wait()
+
+	got := recorded.get()
+	if len(got) != 1 {
+		t.Fatalf("recorded %d hits, want 1", len(got))
+	}
+
+	if got[0].Protocol != echo.ProtocolTCP || got[0].Client.String() != "127.0.0.1" || got[0].Bytes != len(body) {
+		t.Errorf("hit = %+v, want a TCP hit from 127.0.0.1 of %d bytes", got[0], len(body))
+	}
+}
+
+func TestTCPServer_ProxyProtocol(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		send     string
+		want     string
+		wantHits int
+	}{
+		{
+			name:     "header",
+			send:     "PROXY TCP4 192.0.2.1 198.51.100.1 56324 1998\r\n",
+			want:     "192.0.2.1\n",
+			wantHits: 1,
+		},
+		{
+			name:     "no_header",
+			send:     "",
+			want:     "",
+			wantHits: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			inner, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatalf("Failed to listen: %v", err)
+			}
+
+			listener := proxyproto.NewListener(inner, []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8")}, 100*time.Millisecond)
+			listener.Required = true
+
+			var (
+				recorded hits
+				server   = echo.NewTCPServer(time.Second, recorded.record, zap.NewNop())
+				served   = make(chan error, 1)
+			)
+
+			go func() { served <- server.Serve(listener) }()
+
+			conn, err := net.Dial("tcp", inner.Addr().String())
+			if err != nil {
+				t.Fatalf("Failed to dial: %v", err)
+			}
+
+			if _, err = io.WriteString(conn, tt.send); err != nil {
+				t.Fatalf("Failed to write header: %v", err)
+			}
+
+			body, err := io.ReadAll(conn)
+			conn.Close()
+
+			if err != nil {
+				t.Fatalf("Failed to read response: %v", err)
+			}
+
+			if got := string(body); got != tt.want {
+				t.Errorf("response = %q, want %q", got, tt.want)
+			}
+
+			listener.Close()
+
+			if err = <-served; err != nil {
+				t.Errorf("Serve() = %v, want nil", err)
+			}
+
+			server.Wait()
+
+			if got, want := len(recorded.get()), tt.wantHits; got != want {
+				t.Errorf("recorded %d hits, want %d", got, want)
+			}
+		})
+	}
+}
+
+func TestTCPServer_AcceptErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		errs    []error
+		wantErr bool
+	}{
+		{
+			name: "too_many_open_files",
+			errs: []error{acceptError(syscall.EMFILE), acceptError(syscall.EMFILE), acceptError(syscall.ENFILE)},
+		},
+		{
+			name: "connection_aborted",
+			errs: []error{acceptError(syscall.ECONNABORTED)},
+		},
+		{
+			name:    "permanent",
+			errs:    []error{acceptError(syscall.EINVAL)},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			inner, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatalf("Failed to listen: %v", err)
+			}
+
+			defer inner.Close()
+
+			var (
+				listener = &failingListener{Listener: inner, errs: tt.errs}
+				server   = echo.NewTCPServer(time.Second, func(*echo.Hit) {}, zap.NewNop())
+				served   = make(chan error, 1)
+			)
+
+			go func() { served <- server.Serve(listener) }()
+
+			if tt.wantErr {
+				if err = <-served; err == nil {
+					t.Error("Serve() = nil, want an error")
+				}
+
+				return
+			}
+
+			conn, err := net.Dial("tcp", inner.Addr().String())
+			if err != nil {
+				t.Fatalf("Failed to dial: %v", err)
+			}
+
+			body, err := io.ReadAll(conn)
+			conn.Close()
+
+			if err != nil {
+				t.Fatalf("Failed to read response: %v", err)
+			}
+
+			if got, want := string(body), "127.0.0.1\n"; got != want {
+				t.Errorf("response = %q, want %q", got, want)
+			}
+
+			inner.Close()
+
+			if err = <-served; err != nil {
+				t.Errorf("Serve() = %v, want nil", err)
+			}
+
+			server.Wait()
+		})
+	}
+}
+
+func TestUDPServer(t *testing.T) {
+	t.Parallel()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	var (
+		recorded hits
+		server   = echo.NewUDPServer(recorded.record, zap.NewNop())
+		served   = make(chan error, 1)
+	)
+
+	go func() { served <- server.Serve(conn) }()
+
+	client, err := net.Dial("udp", conn.LocalAddr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer client.Close()
+
+	tests := []struct {
+		name     string
+		datagram string
+		want     string
+	}{
+		{
+			name:     "too_short",
+			datagram: "\n",
+			want:     "",
+		},
+		{
+			name:     "long_enough",
+			datagram: strings.Repeat(" ", 10),
+			want:     "127.0.0.1\n",
+		},
+	}
+
+	// The cases share the server, so they can't run in parallel.
+	for _, tt := range tests {
+		if _, err = client.Write([]byte(tt.datagram)); err != nil {
+			t.Fatalf("%s: failed to write datagram: %v", tt.name, err)
+		}
+
+		if err = client.SetReadDeadline(time.Now().Add(200 * time.Millisecond)); err != nil {
+			t.Fatalf("%s: failed to set deadline: %v", tt.name, err)
+		}
+
+		buf := make([]byte, 64)
+
+		n, readErr := client.Read(buf)
+
+		var netErr net.Error
+		if readErr != nil && !(tt.want == "" && errors.As(readErr, &netErr) && netErr.Timeout()) {
+			t.Fatalf("%s: failed to read response: %v", tt.name, readErr)
+		}
+
+		if got := string(buf[:n]); got != tt.want {
+			t.Errorf("%s: response = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	conn.Close()
+
+	if err = <-served; err != nil {
+		t.Errorf("Serve() = %v, want nil", err)
+	}
+
+	if got := recorded.get(); len(got) != 1 || got[0].Protocol != echo.ProtocolUDP || got[0].Format != echo.FormatText {
+		t.Errorf("recorded %+v, want a single UDP hit", got)
+	}
+}
@@ -0,0 +1,442 @@
+package echo
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net"
+	"net/netip"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"git.sr.ht/~jamesponddotco/accio127/internal/retry"
+	"go.uber.org/zap"
+)
+
+const (
+	// stunHeaderSize is the size of the header of STUN messages.
+	stunHeaderSize int = 20
+
+	// stunMagicCookie is the value of the magic cookie field of STUN
+	// messages, which tells them apart from the RFC 3489 ones.
+	stunMagicCookie uint32 = 0x2112A442
+
+	// stunFingerprintXOR is XORed with the CRC-32 of a message to compute
+	// its FINGERPRINT attribute.
+	stunFingerprintXOR uint32 = 0x5354554E
+)
+
+// STUN message types, combining the Binding method with a class.
+const (
+	stunBindingRequest uint16 = 0x0001
+	stunBindingSuccess uint16 = 0x0101
+	stunBindingError   uint16 = 0x0111
+)
+
+// stunComprehensionOptional is set in the types of the attributes that can be
+// ignored if they aren't understood.
+const stunComprehensionOptional uint16 = 0x8000
+
+// STUN attribute types.
+const (
+	stunAttrMappedAddress     uint16 = 0x0001
+	stunAttrUsername          uint16 = 0x0006
+	stunAttrMessageIntegrity  uint16 = 0x0008
+	stunAttrErrorCode         uint16 = 0x0009
+	stunAttrUnknownAttributes uint16 = 0x000A
+	stunAttrRealm             uint16 = 0x0014
+	stunAttrNonce             uint16 = 0x0015
+	stunAttrXORMappedAddress  uint16 = 0x0020
+	stunAttrFingerprint       uint16 = 0x8028
+)
+
+// STUNServer answers STUN Binding requests, as defined in RFC 5389, with
+// the address and port they came from in an XOR-MAPPED-ADDRESS attribute, so
+// that clients behind NATs learn how they're mapped. It serves the same
+// requests over UDP and TCP.
+//
+// Requests aren't authenticated, so MESSAGE-INTEGRITY and the attributes
+// going with it are ignored. Requests with other unknown attributes that
+// must be understood get a 420 error, while other messages and requests
+// denied by the rate limiter are dropped.
+type STUNServer struct {
+	record  Recorder
+	allow   func(client netip.Addr) bool
+	logger  *zap.Logger
+	timeout time.Duration
+	wg      sync.WaitGroup
+
+	// udp and tcp report whether ServeUDP and ServeTCP are serving.
+	udp atomic.Bool
+	tcp atomic.Bool
+}
+
+// NewSTUNServer creates a new STUNServer closing TCP connections idle for
+// timeout, answering the requests allow accepts, and reporting the clients
+// it answers to record.
+func NewSTUNServer(
+	timeout time.Duration,
+	allow func(client netip.Addr) bool,
+	record Recorder,
+	logger *zap.Logger,
+) *STUNServer {
+	return &STUNServer{
+		record:  record,
+		allow:   allow,
+		logger:  logger,
+		timeout: timeout,
+	}
+}
+
+// Serving reports whether the server is serving over both UDP and TCP.
+func (s *STUNServer) Serving() bool {
+	return s.udp.Load() && s.tcp.Load()
+}
+
+// ServeUDP answers the requests received on conn until it's closed. Temporary
+// errors are retried with a growing delay.
+func (s *STUNServer) ServeUDP(conn net.PacketConn) error {
+	s.udp.Store(true)
+	defer s.udp.Store(false)
+
+	var (
+		buf     = make([]byte, maxDatagramSize)
+		backoff retry.Backoff
+	)
+
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+
+			if !retry.Temporary(err) {
+				return fmt.Errorf("failed to read STUN request: %w", err)
+			}
+
+			delay := backoff.Next()
+
+			s.logger.Warn("Failed to read STUN request, retrying", zap.Duration("delay", delay), zap.Error(err))
+			time.Sleep(delay)
+
+			continue
+		}
+
+		backoff.Reset()
+
+		start := time.Now()
+
+		client, ok := addrPortOf(addr)
+		if !ok {
+			continue
+		}
+
+		response, answered := s.answer(buf[:n], client)
+		if response == nil {
+			continue
+		}
+
+		if _, err = conn.WriteTo(response, addr); err != nil {
+			s.logger.Debug("Failed to write STUN response", zap.Error(err))
+
+			continue
+		}
+
+		if answered {
+			s.recordHit(start, client.Addr(), len(response), "udp")
+		}
+	}
+}
+
+// ServeTCP accepts connections on listener until it's closed, answering the
+// requests on each in the background. Temporary errors, such as running out of
+// file descriptors, are retried with a growing delay; once another error stops
+// it, Serving reports false.
+func (s *STUNServer) ServeTCP(listener net.Listener) error {
+	s.tcp.Store(true)
+	defer s.tcp.Store(false)
+
+	var backoff retry.Backoff
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+
+			if !retry.Temporary(err) {
+				return fmt.Errorf("failed to accept STUN connection: %w", err)
+			}
+
+			delay := backoff.Next()
+
+			s.logger.Warn("Failed to accept STUN connection, retrying", zap.Duration("delay", delay), zap.Error(err))
+			time.Sleep(delay)
+
+			continue
+		}
+
+		backoff.Reset()
+
+		s.wg.Add(1)
+
+		go s.serveConn(conn)
+	}
+}
+
+// Wait waits for the TCP connections being served to be closed.
+func (s *STUNServer) Wait() {
+	s.wg.Wait()
+}
+
+// serveConn answers the requests sent over conn until the client closes it,
+// sends something other than STUN, or stays idle for the timeout.
+func (s *STUNServer) serveConn(conn net.Conn) {
+	defer s.wg.Done()
+	defer conn.Close()
+
+	buf := make([]byte, maxDatagramSize)
+
+	for {
+		if err := conn.SetDeadline(time.Now().Add(s.timeout)); err != nil {
+			return
+		}
+
+		if _, err := io.ReadFull(conn, buf[:stunHeaderSize]); err != nil {
+			return
+		}
+
+		start := time.Now()
+
+		length := int(binary.BigEndian.Uint16(buf[2:4]))
+		if stunHeaderSize+length > len(buf) {
+			return
+		}
+
+		if _, err := io.ReadFull(conn, buf[stunHeaderSize:stunHeaderSize+length]); err != nil {
+			return
+		}
+
+		client, ok := addrPortOf(conn.RemoteAddr())
+		if !ok {
+			return
+		}
+
+		response, answered := s.answer(buf[:stunHeaderSize+length], client)
+		if response == nil {
+			return
+		}
+
+		if _, err := conn.Write(response); err != nil {
+			s.logger.Debug("Failed to write STUN response", zap.Error(err))
+
+			return
+		}
+
+		if answered {
+			s.recordHit(start, client.Addr(), len(response), "tcp")
+		}
+	}
+}
+
+// recordHit reports a Binding request from client, received at start over
+// transport and answered with size bytes.
+func (s *STUNServer) recordHit(start time.Time, client netip.Addr, size int, transport string) {
+	s.record(&Hit{
+		Time:     start,
+		Client:   client,
+		Protocol: ProtocolSTUN,
+		Method:   "BINDING",
+		Format:   transport,
+		Latency:  time.Since(start),
+		Bytes:    size,
+	})
+}
+
+// answer returns the response to msg, sent from client, and whether it's a
+// success response. It returns a nil response for messages that shouldn't be
+// answered.
+func (s *STUNServer) answer(msg []byte, client netip.AddrPort) ([]byte, bool) {
+	if len(msg) < stunHeaderSize ||
+		binary.BigEndian.Uint16(msg[0:2]) != stunBindingRequest ||
+		int(binary.BigEndian.Uint16(msg[2:4])) != len(msg)-stunHeaderSize ||
+		binary.BigEndian.Uint32(msg[4:8]) != stunMagicCookie {
+		return nil, false
+	}
+
+	unknown, fingerprint, ok := parseSTUNAttributes(msg[stunHeaderSize:])
+	if !ok {
+		return nil, false
+	}
+
+	if !s.allow(client.Addr()) {
+		return nil, false
+	}
+
+	var transactionID [12]byte
+
+	copy(transactionID[:], msg[8:stunHeaderSize])
+
+	if len(unknown) > 0 {
+		return newSTUNMessage(stunBindingError, transactionID).
+			unknownAttributes(unknown).
+			finish(fingerprint), false
+	}
+
+	return newSTUNMessage(stunBindingSuccess, transactionID).
+		xorMappedAddress(client).
+		finish(fingerprint), true
+}
+
+// parseSTUNAttributes returns the comprehension-required attributes of a
+// request that aren't understood, and whether it has a FINGERPRINT
+// attribute. It returns false if the attributes are malformed.
+func parseSTUNAttributes(attributes []byte) ([]uint16, bool, bool) {
+	var (
+		unknown     []uint16
+		fingerprint bool
+	)
+
+	for len(attributes) > 0 {
+		if len(attributes) < 4 {
+			return nil, false, false
+		}
+
+		var (
+			typ    = binary.BigEndian.Uint16(attributes[0:2])
+			length = int(binary.BigEndian.Uint16(attributes[2:4]))
+			padded = 4 + (length+3)/4*4
+		)
+
+		if padded > len(attributes) {
+			return nil, false, false
+		}
+
+		switch {
+		case typ == stunAttrFingerprint:
+			fingerprint = true
+		case typ&stunComprehensionOptional == 0 && !knownSTUNAttribute(typ):
+			unknown = append(unknown, typ)
+		}
+
+		attributes = attributes[padded:]
+	}
+
+	return unknown, fingerprint, true
+}
+
+// knownSTUNAttribute reports whether typ is one of the comprehension-required
+// attributes defined by RFC 5389, which are understood even if ignored.
+func knownSTUNAttribute(typ uint16) bool {
+	switch typ {
+	case stunAttrMappedAddress, stunAttrUsername, stunAttrMessageIntegrity, stunAttrErrorCode,
+		stunAttrUnknownAttributes, stunAttrRealm, stunAttrNonce, stunAttrXORMappedAddress:
+		return true
+	default:
+		return false
+	}
+}
+
+// stunMessage builds a STUN message.
+type stunMessage struct {
+	buf []byte
+}
+
+// newSTUNMessage starts a message of typ for the transaction transactionID.
+func newSTUNMessage(typ uint16, transactionID [12]byte) *stunMessage {
+	buf := make([]byte, stunHeaderSize, 64)
+
+	binary.BigEndian.PutUint16(buf[0:2], typ)
+	binary.BigEndian.PutUint32(buf[4:8], stunMagicCookie)
+	copy(buf[8:stunHeaderSize], transactionID[:])
+
+	return &stunMessage{buf: buf}
+}
+
+// attribute appends an attribute of typ holding value, padded to a multiple
+// of four bytes.
+func (m *stunMessage) attribute(typ uint16, value []byte) *stunMessage {
+	header := make([]byte, 4, 4+len(value)+3)
+
+	binary.BigEndian.PutUint16(header[0:2], typ)
+	binary.BigEndian.PutUint16(header[2:4], uint16(len(value)))
+
+	m.buf = append(m.buf, append(header, value...)...)
+
+	for len(m.buf)%4 != 0 {
+		m.buf = append(m.buf, 0)
+	}
+
+	return m
+}
+
+// xorMappedAddress appends an XOR-MAPPED-ADDRESS attribute holding client,
+// XORed with the magic cookie and, for IPv6, the transaction ID.
+func (m *stunMessage) xorMappedAddress(client netip.AddrPort) *stunMessage {
+	var (
+		addr   = client.Addr().AsSlice()
+		family = byte(0x01)
+	)
+
+	if len(addr) == net.IPv6len {
+		family = 0x02
+	}
+
+	value := make([]byte, 4, 4+len(addr))
+	value[1] = family
+	binary.BigEndian.PutUint16(value[2:4], client.Port()^uint16(stunMagicCookie>>16))
+
+	// The magic cookie is followed by the transaction ID in the header,
+	// which is what IPv6 addresses are XORed with.
+	key := m.buf[4:stunHeaderSize]
+
+	for i, b := range addr {
+		value = append(value, b^key[i])
+	}
+
+	return m.attribute(stunAttrXORMappedAddress, value)
+}
+
+// unknownAttributes appends a 420 ERROR-CODE attribute and an
+// UNKNOWN-ATTRIBUTES one listing unknown.
+func (m *stunMessage) unknownAttributes(unknown []uint16) *stunMessage {
+	const (
+		code   = 420
+		reason = "Unknown Attribute"
+	)
+
+	errorCode := make([]byte, 4, 4+len(reason))
+	errorCode[2] = code / 100
+	errorCode[3] = code % 100
+	errorCode = append(errorCode, reason...)
+
+	list := make([]byte, 0, 2*len(unknown))
+	for _, typ := range unknown {
+		list = binary.BigEndian.AppendUint16(list, typ)
+	}
+
+	return m.attribute(stunAttrErrorCode, errorCode).attribute(stunAttrUnknownAttributes, list)
+}
+
+// finish sets the length of the message, appends a FINGERPRINT attribute if
+// fingerprint is true, and returns the message.
+func (m *stunMessage) finish(fingerprint bool) []byte {
+	if fingerprint {
+		// The length covers the FINGERPRINT attribute, which is computed
+		// over the message up to it.
+		binary.BigEndian.PutUint16(m.buf[2:4], uint16(len(m.buf)-stunHeaderSize+8))
+
+		value := binary.BigEndian.AppendUint32(nil, crc32.ChecksumIEEE(m.buf)^stunFingerprintXOR)
+
+		m.attribute(stunAttrFingerprint, value)
+	}
+
+	binary.BigEndian.PutUint16(m.buf[2:4], uint16(len(m.buf)-stunHeaderSize))
+
+	return m.buf
+}
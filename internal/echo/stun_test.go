@@ -0,0 +1,383 @@
+package echo_test
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"net"
+	"net/netip"
+	"syscall"
+	"testing"
+	"time"
+
+	"git.sr.ht/~jamesponddotco/accio127/internal/echo"
+	"go.uber.org/zap"
+)
+
+const magicCookie uint32 = 0x2112A442
+
+// stunAttribute is a STUN attribute of a test message.
+type stunAttribute struct {
+	value []byte
+	typ   uint16
+}
+
+// stunRequest returns a Binding request with attributes, followed by a
+// FINGERPRINT attribute if fingerprint is true.
+func stunRequest(attributes []stunAttribute, fingerprint bool) []byte {
+	msg := make([]byte, 20)
+
+	binary.BigEndian.PutUint16(msg[0:2], 0x0001)
+	binary.BigEndian.PutUint32(msg[4:8], magicCookie)
+	copy(msg[8:20], "transaction!")
+
+	for _, attribute := range attributes {
+		msg = binary.BigEndian.AppendUint16(msg, attribute.typ)
+		msg = binary.BigEndian.AppendUint16(msg, uint16(len(attribute.value)))
+		msg = append(msg, attribute.value...)
+
+		for len(msg)%4 != 0 {
+			msg = append(msg, 0)
+		}
+	}
+
+	if fingerprint {
+		binary.BigEndian.PutUint16(msg[2:4], uint16(len(msg)-20+8))
+
+		crc := crc32.ChecksumIEEE(msg) ^ 0x5354554E
+
+		msg = binary.BigEndian.AppendUint16(msg, 0x8028)
+		msg = binary.BigEndian.AppendUint16(msg, 4)
+		msg = binary.BigEndian.AppendUint32(msg, crc)
+	}
+
+	binary.BigEndian.PutUint16(msg[2:4], uint16(len(msg)-20))
+
+	return msg
+}
+
+// stunResponse is the part of a STUN response the tests check.
+type stunResponse struct {
+	mapped      netip.AddrPort
+	unknown     []uint16
+	typ         uint16
+	errorCode   int
+	fingerprint bool
+}
+
+// parseSTUNResponse parses msg, a response to a request made by stunRequest,
+// failing the test if it's malformed.
+func parseSTUNResponse(t *testing.T, msg []byte) stunResponse {
+	t.Helper()
+
+	if len(msg) < 20 || int(binary.BigEndian.Uint16(msg[2:4])) != len(msg)-20 {
+		t.Fatalf("response %x has an invalid length", msg)
+	}
+
+	if binary.BigEndian.Uint32(msg[4:8]) != magicCookie || string(msg[8:20]) != "transaction!" {
+		t.Fatalf("response %x doesn't match the request", msg)
+	}
+
+	response := stunResponse{typ: binary.BigEndian.Uint16(msg[0:2])}
+
+	for offset := 20; offset < len(msg); {
+		var (
+			typ    = binary.BigEndian.Uint16(msg[offset : offset+2])
+			length = int(binary.BigEndian.Uint16(msg[offset+2 : offset+4]))
+			value  = msg[offset+4 : offset+4+length]
+		)
+
+		switch typ {
+		case 0x0020:
+			port := binary.BigEndian.Uint16(value[2:4]) ^ uint16(magicCookie>>16)
+
+			var ip [4]byte
+
+			binary.BigEndian.PutUint32(ip[:], binary.BigEndian.Uint32(value[4:8])^magicCookie)
+			response.mapped = netip.AddrPortFrom(netip.AddrFrom4(ip), port)
+		case 0x0009:
+			response.errorCode = int(value[2])*100 + int(value[3])
+		case 0x000A:
+			for i := 0; i < len(value); i += 2 {
+				response.unknown = append(response.unknown, binary.BigEndian.Uint16(value[i:i+2]))
+			}
+		case 0x8028:
+			want := crc32.ChecksumIEEE(msg[:offset]) ^ 0x5354554E
+			if got := binary.BigEndian.Uint32(value); got != want {
+				t.Errorf("FINGERPRINT = %x, want %x", got, want)
+			}
+
+			response.fingerprint = true
+		}
+
+		offset += 4 + (length+3)/4*4
+	}
+
+	return response
+}
+
+func TestSTUNServer_ServeUDP(t *testing.T) {
+	t.Parallel()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	t.Cleanup(func() { conn.Close() })
+
+	var (
+		limited = netip.MustParseAddr("127.0.0.2")
+		allow   = func(client netip.Addr) bool { return client != limited }
+		server  = echo.NewSTUNServer(time.Second, allow, func(*echo.Hit) {}, zap.NewNop())
+	)
+
+	go server.ServeUDP(conn) //nolint:errcheck // the connection is closed by the cleanup
+
+	tests := []struct {
+		name        string
+		from        string
+		request     []byte
+		wantType    uint16
+		wantError   int
+		wantUnknown []uint16
+		wantReply   bool
+		fingerprint bool
+	}{
+		{
+			name:      "binding_request",
+			from:      "127.0.0.1:0",
+			request:   stunRequest(nil, false),
+			wantType:  0x0101,
+			wantReply: true,
+		},
+		{
+			name:        "binding_request_with_fingerprint",
+			from:        "127.0.0.1:0",
+			request:     stunRequest([]stunAttribute{{typ: 0x8022, value: []byte("client")}}, true),
+			wantType:    0x0101,
+			wantReply:   true,
+			fingerprint: true,
+		},
+		{
+			name:        "unknown_attribute",
+			from:        "127.0.0.1:0",
+			request:     stunRequest([]stunAttribute{{typ: 0x0024, value: []byte{0, 0, 0, 1}}}, false),
+			wantType:    0x0111,
+			wantError:   420,
+			wantUnknown: []uint16{0x0024},
+			wantReply:   true,
+		},
+		{
+			name:    "not_stun",
+			from:    "127.0.0.1:0",
+			request: []byte("GET / HTTP/1.1\r\n\r\n"),
+		},
+		{
+			name:    "rate_limited",
+			from:    "127.0.0.2:0",
+			request: stunRequest(nil, false),
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			client, err := net.ListenPacket("udp", tt.from)
+			if err != nil {
+				t.Fatalf("Failed to listen: %v", err)
+			}
+			defer client.Close()
+
+			if _, err = client.WriteTo(tt.request, conn.LocalAddr()); err != nil {
+				t.Fatalf("Failed to send request: %v", err)
+			}
+
+			if err = client.SetReadDeadline(time.Now().Add(200 * time.Millisecond)); err != nil {
+				t.Fatalf("Failed to set deadline: %v", err)
+			}
+
+			buf := make([]byte, 1500)
+
+			n, _, err := client.ReadFrom(buf)
+			if (err == nil) != tt.wantReply {
+				t.Fatalf("ReadFrom() error = %v, want a reply %v", err, tt.wantReply)
+			}
+
+			if !tt.wantReply {
+				return
+			}
+
+			response := parseSTUNResponse(t, buf[:n])
+
+			if response.typ != tt.wantType {
+				t.Errorf("type = %#04x, want %#04x", response.typ, tt.wantType)
+			}
+
+			if tt.wantType == 0x0101 {
+				if want := netip.MustParseAddrPort(client.LocalAddr().String()); response.mapped != want {
+					t.Errorf("XOR-MAPPED-ADDRESS = %v, want %v", response.mapped, want)
+				}
+			}
+
+			if response.errorCode != tt.wantError {
+				t.Errorf("ERROR-CODE = %d, want %d", response.errorCode, tt.wantError)
+			}
+
+			if len(response.unknown) != len(tt.wantUnknown) ||
+				(len(tt.wantUnknown) > 0 && response.unknown[0] != tt.wantUnknown[0]) {
+				t.Errorf("UNKNOWN-ATTRIBUTES = %#04x, want %#04x", response.unknown, tt.wantUnknown)
+			}
+
+			if response.fingerprint != tt.fingerprint {
+				t.Errorf("FINGERPRINT present = %v, want %v", response.fingerprint, tt.fingerprint)
+			}
+		})
+	}
+}
+
+func TestSTUNServer_ServeTCP(t *testing.T) {
+	t.Parallel()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	var (
+		recorded hits
+		allow    = func(netip.Addr) bool { return true }
+		server   = echo.NewSTUNServer(time.Second, allow, recorded.record, zap.NewNop())
+		served   = make(chan error, 1)
+	)
+
+	go func() { served <- server.ServeTCP(listener) }()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+
+	// Requests are answered in turn over the same connection.
+	for i := 0; i < 2; i++ {
+		if _, err = conn.Write(stunRequest(nil, false)); err != nil {
+			t.Fatalf("Failed to send request: %v", err)
+		}
+
+		msg := make([]byte, 32)
+
+		if _, err = io.ReadFull(conn, msg); err != nil {
+			t.Fatalf("Failed to read response: %v", err)
+		}
+
+		response := parseSTUNResponse(t, msg)
+
+		if want := netip.MustParseAddrPort(conn.LocalAddr().String()); response.mapped != want {
+			t.Errorf("XOR-MAPPED-ADDRESS = %v, want %v", response.mapped, want)
+		}
+	}
+
+	conn.Close()
+	listener.Close()
+
+	if err = <-served; err != nil {
+		t.Errorf("ServeTCP() = %v, want nil", err)
+	}
+
+	server.Wait()
+
+	got := recorded.get()
+	if len(got) != 2 || got[0].Protocol != echo.ProtocolSTUN || got[0].Format != "tcp" {
+		t.Errorf("recorded %+v, want two STUN hits over TCP", got)
+	}
+}
+
+func TestSTUNServer_ServeTCPAcceptErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		errs        []error
+		wantServing bool
+	}{
+		{
+			name:        "too_many_open_files",
+			errs:        []error{acceptError(syscall.EMFILE), acceptError(syscall.ENFILE)},
+			wantServing: true,
+		},
+		{
+			name:        "permanent",
+			errs:        []error{acceptError(syscall.EINVAL)},
+			wantServing: false,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			packetConn, err := net.ListenPacket("udp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatalf("Failed to listen on UDP: %v", err)
+			}
+
+			t.Cleanup(func() { packetConn.Close() })
+
+			inner, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatalf("Failed to listen on TCP: %v", err)
+			}
+
+			t.Cleanup(func() { inner.Close() })
+
+			var (
+				listener = &failingListener{Listener: inner, errs: tt.errs}
+				allow    = func(netip.Addr) bool { return true }
+				server   = echo.NewSTUNServer(time.Second, allow, func(*echo.Hit) {}, zap.NewNop())
+				served   = make(chan error, 1)
+			)
+
+			go server.ServeUDP(packetConn) //nolint:errcheck // the connection is closed by the cleanup
+			go func() { served <- server.ServeTCP(listener) }()
+
+			if !tt.wantServing {
+				if err = <-served; err == nil {
+					t.Error("ServeTCP() = nil, want an error")
+				}
+
+				if server.Serving() {
+					t.Error("Serving() = true after ServeTCP() failed, want false")
+				}
+
+				return
+			}
+
+			conn, err := net.Dial("tcp", inner.Addr().String())
+			if err != nil {
+				t.Fatalf("Failed to dial: %v", err)
+			}
+
+			defer conn.Close()
+
+			if _, err = conn.Write(stunRequest(nil, false)); err != nil {
+				t.Fatalf("Failed to send request: %v", err)
+			}
+
+			if _, err = io.ReadFull(conn, make([]byte, 32)); err != nil {
+				t.Fatalf("Failed to read response: %v", err)
+			}
+
+			// ServeUDP starts in the background too.
+			for deadline := time.Now().Add(time.Second); !server.Serving(); time.Sleep(10 * time.Millisecond) {
+				if time.Now().After(deadline) {
+					t.Fatal("Serving() = false after retrying, want true")
+				}
+			}
+		})
+	}
+}
@@ -16,6 +16,42 @@ const (
 	// IPHashed is the endpoint for the IPHashed handler.
 	IPHashed string = Slash + build.APIVersion + "/ip/hashed"
 
+	// IPDetails is the endpoint for the IPDetails handler.
+	IPDetails string = Slash + build.APIVersion + "/ip/details"
+
+	// IPGeo is the endpoint for the GeoIP handler.
+	IPGeo string = Slash + build.APIVersion + "/ip/geo"
+
+	// IPBatch is the endpoint for the BatchIP handler.
+	IPBatch string = Slash + build.APIVersion + "/ip/batch"
+
+	// IPBoth is the endpoint for the DualStack handler.
+	IPBoth string = Slash + build.APIVersion + "/ip/both"
+
+	// TokenParam is the name of the route parameter holding a dual-stack
+	// token.
+	TokenParam string = "token"
+
+	// IPBothToken is the endpoint for the DualStack handler when following
+	// up on a token.
+	IPBothToken string = IPBoth + "/:" + TokenParam
+
+	// AddressParam is the name of the route parameter holding the IP address
+	// to look up.
+	AddressParam string = "address"
+
+	// IPLookup is the endpoint for the IP handler when looking up an
+	// arbitrary address.
+	IPLookup string = IP + "/:" + AddressParam
+
+	// IPLookupAnonymize is the endpoint for the IPAnonymize handler when
+	// looking up an arbitrary address.
+	IPLookupAnonymize string = IPLookup + "/anonymized"
+
+	// IPLookupHashed is the endpoint for the IPHashed handler when looking up
+	// an arbitrary address.
+	IPLookupHashed string = IPLookup + "/hashed"
+
 	// Metrics is the endpoint for the Metrics handler.
 	Metrics string = Slash + build.APIVersion + "/metrics"
 
@@ -5,7 +5,10 @@ import (
 	"encoding/json"
 	"net/http"
 
+	"git.sr.ht/~jamesponddotco/accio127/internal/accept"
+	"git.sr.ht/~jamesponddotco/accio127/internal/requestid"
 	"git.sr.ht/~jamesponddotco/xstd-go/xerrors"
+	"git.sr.ht/~jamesponddotco/xstd-go/xnet/xhttp"
 	"go.uber.org/zap"
 )
 
@@ -15,23 +18,110 @@ const (
 
 	// ErrEmptyDSN is returned when an empty DSN is passed to a function.
 	ErrEmptyDSN xerrors.Error = "dsn cannot be empty"
+
+	// ErrInvalidGroup is returned when statistics are grouped by an unknown
+	// dimension.
+	ErrInvalidGroup xerrors.Error = "invalid statistics group"
 )
 
+// ApplicationProblemJSON is the media type of RFC 7807 problem details.
+const ApplicationProblemJSON string = "application/problem+json"
+
 // ErrorResponse is the response returned by the API when an error occurs.
 type ErrorResponse struct {
 	// Message is a human-readable message describing the error.
 	Message string `json:"message"`
 
+	// RequestID identifies the request that caused the error, so that it
+	// can be found in the server logs.
+	RequestID string `json:"requestId,omitempty"`
+
+	// Type is the URI identifying the kind of error, one of the Type
+	// constants. It's only sent in problem details, where it defaults to
+	// TypeBlank.
+	Type string `json:"-"`
+
 	// Code is a machine-readable code describing the error.
 	Code uint `json:"code"`
 }
 
-// JSON sends an ErrorResponse to the HTTP response writer as JSON.
-func JSON(w http.ResponseWriter, logger *zap.Logger, response ErrorResponse) {
-	w.Header().Set("Content-Type", "application/json")
+// Problem is an ErrorResponse in the RFC 7807 problem details format.
+type Problem struct {
+	// Type is the URI identifying the kind of problem.
+	Type string `json:"type"`
+
+	// Title is a short summary of the kind of problem, which is the same for
+	// every problem of its type.
+	Title string `json:"title"`
+
+	// Detail explains this occurrence of the problem.
+	Detail string `json:"detail,omitempty"`
+
+	// Instance is the path of the request that caused the problem.
+	Instance string `json:"instance,omitempty"`
+
+	// RequestID identifies the request that caused the problem, so that it
+	// can be found in the server logs.
+	RequestID string `json:"requestId,omitempty"`
+
+	// Status is the HTTP status code of the response.
+	Status uint `json:"status"`
+}
+
+// NewProblem converts response into problem details about a request to
+// instance.
+func NewProblem(response ErrorResponse, instance string) *Problem {
+	problemType := response.Type
+	if problemType == "" {
+		problemType = TypeBlank
+	}
+
+	return &Problem{
+		Type:      problemType,
+		Title:     Title(problemType, response.Code),
+		Detail:    response.Message,
+		Instance:  instance,
+		RequestID: response.RequestID,
+		Status:    response.Code,
+	}
+}
+
+// JSON sends an ErrorResponse to the HTTP response writer as JSON. Clients
+// preferring application/problem+json in their Accept header receive it as
+// RFC 7807 problem details, and everyone else in the legacy format. Unless
+// it's already set, the response's request ID is taken from the X-Request-ID
+// header of the HTTP response.
+func JSON(w http.ResponseWriter, r *http.Request, logger *zap.Logger, response ErrorResponse) {
+	if response.RequestID == "" {
+		response.RequestID = w.Header().Get(requestid.Header)
+	}
+
+	addVary(w.Header(), xhttp.Accept)
+
+	var body any = response
+
+	mediaType, err := accept.Preferred(r, xhttp.ApplicationJSON, ApplicationProblemJSON)
+	if err == nil && mediaType == ApplicationProblemJSON {
+		body = NewProblem(response, r.URL.Path)
+	} else {
+		mediaType = xhttp.ApplicationJSON
+	}
+
+	w.Header().Set(xhttp.ContentType, mediaType)
 	w.WriteHeader(int(response.Code))
 
-	if err := json.NewEncoder(w).Encode(response); err != nil {
+	if err = json.NewEncoder(w).Encode(body); err != nil {
 		logger.Error("failed to encode error response", zap.Error(err))
 	}
 }
+
+// addVary adds value to the Vary header, unless it's already there.
+func addVary(header http.Header, value string) {
+	for _, vary := range header.Values(xhttp.Vary) {
+		if vary == value {
+			return
+		}
+	}
+
+	header.Add(xhttp.Vary, value)
+}
@@ -13,9 +13,12 @@ func TestJSON(t *testing.T) {
 	t.Parallel()
 
 	tests := []struct {
-		name     string
-		response errors.ErrorResponse
-		wantBody string
+		name            string
+		accept          string
+		requestID       string
+		response        errors.ErrorResponse
+		wantBody        string
+		wantContentType string
 	}{
 		{
 			name: "valid_error_response",
@@ -23,7 +26,51 @@ func TestJSON(t *testing.T) {
 				Code:    http.StatusBadRequest,
 				Message: "User agent is missing. Please provide a valid user agent.",
 			},
-			wantBody: `{"message":"User agent is missing. Please provide a valid user agent.","code":400}`,
+			wantBody:        `{"message":"User agent is missing. Please provide a valid user agent.","code":400}`,
+			wantContentType: "application/json",
+		},
+		{
+			name:      "request_id_from_header",
+			requestID: "f3b2c1a0",
+			response: errors.ErrorResponse{
+				Code:    http.StatusNotFound,
+				Message: "Page not found. Check the URL and try again.",
+			},
+			wantBody:        `{"message":"Page not found. Check the URL and try again.","requestId":"f3b2c1a0","code":404}`,
+			wantContentType: "application/json",
+		},
+		{
+			name:   "problem_details",
+			accept: "application/problem+json",
+			response: errors.ErrorResponse{
+				Code:    http.StatusBadRequest,
+				Type:    errors.TypeMissingUserAgent,
+				Message: "User agent is missing. Please provide a valid user agent.",
+			},
+			wantBody:        `{"type":"tag:accio127.com,2023:missing-user-agent","title":"Missing user agent","detail":"User agent is missing. Please provide a valid user agent.","instance":"/v1/ip","status":400}`,
+			wantContentType: "application/problem+json",
+		},
+		{
+			name:      "problem_details_blank_type",
+			accept:    "application/json;q=0.5, application/problem+json",
+			requestID: "f3b2c1a0",
+			response: errors.ErrorResponse{
+				Code:    http.StatusNotAcceptable,
+				Message: "Requested format is not available.",
+			},
+			wantBody:        `{"type":"about:blank","title":"Not Acceptable","detail":"Requested format is not available.","instance":"/v1/ip","requestId":"f3b2c1a0","status":406}`,
+			wantContentType: "application/problem+json",
+		},
+		{
+			name:   "legacy_fallback",
+			accept: "text/html",
+			response: errors.ErrorResponse{
+				Code:    http.StatusMethodNotAllowed,
+				Type:    errors.TypeMethodNotAllowed,
+				Message: "Method POST not allowed. Must be GET, HEAD, or OPTIONS.",
+			},
+			wantBody:        `{"message":"Method POST not allowed. Must be GET, HEAD, or OPTIONS.","code":405}`,
+			wantContentType: "application/json",
 		},
 	}
 
@@ -33,20 +80,31 @@ func TestJSON(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			t.Parallel()
 
-			recorder := httptest.NewRecorder()
+			var (
+				recorder = httptest.NewRecorder()
+				req      = httptest.NewRequest(http.MethodGet, "http://localhost/v1/ip?format=json", http.NoBody)
+			)
+
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+
+			if tt.requestID != "" {
+				recorder.Header().Set("X-Request-ID", tt.requestID)
+			}
 
 			logger, err := zap.NewProduction()
 			if err != nil {
 				t.Fatalf("Failed to create logger: %v", err)
 			}
 
-			errors.JSON(recorder, logger, tt.response)
+			errors.JSON(recorder, req, logger, tt.response)
 
 			if got := recorder.Body.String(); got != tt.wantBody+"\n" {
 				t.Errorf("Expected body %q, but got %q", tt.wantBody, got)
 			}
 
-			if got, want := recorder.Header().Get("Content-Type"), "application/json"; got != want {
+			if got, want := recorder.Header().Get("Content-Type"), tt.wantContentType; got != want {
 				t.Errorf("Expected Content-Type %s, but got %s", want, got)
 			}
 
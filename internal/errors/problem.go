@@ -0,0 +1,63 @@
+package errors
+
+import "net/http"
+
+// Problem types identify the kinds of errors the API returns, so that clients
+// can handle them without parsing messages. They're tag URIs, which are
+// stable identifiers that aren't meant to be dereferenced.
+const (
+	// TypeBlank is the problem type of errors without a more specific type,
+	// whose title is the text of their status code.
+	TypeBlank string = "about:blank"
+
+	// TypeMissingUserAgent is the problem type of requests without a
+	// User-Agent header.
+	TypeMissingUserAgent string = "tag:accio127.com,2023:missing-user-agent"
+
+	// TypeMethodNotAllowed is the problem type of requests using a method the
+	// route doesn't accept.
+	TypeMethodNotAllowed string = "tag:accio127.com,2023:method-not-allowed"
+
+	// TypeNotFound is the problem type of requests to routes that don't
+	// exist.
+	TypeNotFound string = "tag:accio127.com,2023:not-found"
+
+	// TypeInvalidIP is the problem type of requests asking about something
+	// that isn't a valid IP address.
+	TypeInvalidIP string = "tag:accio127.com,2023:invalid-ip"
+
+	// TypeIPParseFailure is the problem type of requests whose client IP
+	// address couldn't be determined or parsed.
+	TypeIPParseFailure string = "tag:accio127.com,2023:ip-parse-failure"
+
+	// TypeRateLimited is the problem type of requests denied by rate
+	// limiting.
+	TypeRateLimited string = "tag:accio127.com,2023:rate-limited"
+
+	// TypeInvalidToken is the problem type of dual-stack requests carrying a
+	// token that's malformed or expired.
+	TypeInvalidToken string = "tag:accio127.com,2023:invalid-token"
+)
+
+// Title returns the title of problemType. Problems of type TypeBlank, or of an
+// unknown type, are titled after status.
+func Title(problemType string, status uint) string {
+	switch problemType {
+	case TypeMissingUserAgent:
+		return "Missing user agent"
+	case TypeMethodNotAllowed:
+		return "Method not allowed"
+	case TypeNotFound:
+		return "Not found"
+	case TypeInvalidIP:
+		return "Invalid IP address"
+	case TypeIPParseFailure:
+		return "Failed to determine IP address"
+	case TypeRateLimited:
+		return "Too many requests"
+	case TypeInvalidToken:
+		return "Invalid token"
+	default:
+		return http.StatusText(int(status))
+	}
+}
@@ -0,0 +1,261 @@
+// Package geoip looks up the location and network of IP addresses in MaxMind
+// DB files, such as GeoLite2 City and ASN, without leaving the machine. Files
+// are checked for changes periodically and reloaded in the background.
+package geoip
+
+import (
+	"fmt"
+	"net/netip"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/oschwald/maxminddb-golang"
+	"go.uber.org/zap"
+)
+
+// Language is the language of the names returned by lookups.
+const Language string = "en"
+
+// Location is what the databases know about an IP address. Fields the
+// databases don't have are left empty.
+type Location struct {
+	// CountryCode is the ISO 3166-1 code of the country.
+	CountryCode string
+
+	// Country is the name of the country.
+	Country string
+
+	// RegionCode is the ISO 3166-2 code of the region, without the country
+	// prefix.
+	RegionCode string
+
+	// Region is the name of the region.
+	Region string
+
+	// City is the name of the city.
+	City string
+
+	// Organization is the name of the organization that owns the autonomous
+	// system.
+	Organization string
+
+	// ASN is the number of the autonomous system.
+	ASN uint
+}
+
+// Database describes a loaded database file.
+type Database struct {
+	// BuildTime is when the database was built.
+	BuildTime time.Time
+
+	// Path is the path of the file.
+	Path string
+
+	// Type is the type of the database, such as GeoLite2-City.
+	Type string
+}
+
+// Reader looks up IP addresses in a set of database files.
+type Reader struct {
+	logger  *zap.Logger
+	files   []*file
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+// Open loads the database files at paths and checks them for changes every
+// interval, reloading the ones that changed. An interval of zero disables the
+// checks.
+func Open(logger *zap.Logger, paths []string, interval time.Duration) (*Reader, error) {
+	r := &Reader{
+		logger:  logger,
+		files:   make([]*file, 0, len(paths)),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+
+	for _, path := range paths {
+		f := &file{path: path}
+
+		if _, err := f.reload(); err != nil {
+			return nil, err
+		}
+
+		r.files = append(r.files, f)
+	}
+
+	if interval <= 0 {
+		close(r.stopped)
+
+		return r, nil
+	}
+
+	go r.watch(interval)
+
+	return r, nil
+}
+
+// Lookup returns what the databases know about addr. When several databases
+// have the same field, the first one listed wins.
+func (r *Reader) Lookup(addr netip.Addr) (Location, error) {
+	var location Location
+
+	ip := addr.Unmap().AsSlice()
+
+	for _, f := range r.files {
+		var rec record
+
+		if err := f.reader.Load().Lookup(ip, &rec); err != nil {
+			return Location{}, fmt.Errorf("failed to look up %s in %s: %w", addr, f.path, err)
+		}
+
+		rec.merge(&location)
+	}
+
+	return location, nil
+}
+
+// Databases describes the loaded database files.
+func (r *Reader) Databases() []Database {
+	databases := make([]Database, 0, len(r.files))
+
+	for _, f := range r.files {
+		metadata := f.reader.Load().Metadata
+
+		databases = append(databases, Database{
+			BuildTime: time.Unix(int64(metadata.BuildEpoch), 0).UTC(),
+			Path:      f.path,
+			Type:      metadata.DatabaseType,
+		})
+	}
+
+	return databases
+}
+
+// Close stops checking the files for changes.
+func (r *Reader) Close() {
+	select {
+	case <-r.done:
+	default:
+		close(r.done)
+	}
+
+	<-r.stopped
+}
+
+// watch reloads the files that changed every interval until the reader is
+// closed.
+func (r *Reader) watch(interval time.Duration) {
+	defer close(r.stopped)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			for _, f := range r.files {
+				reloaded, err := f.reload()
+				if err != nil {
+					r.logger.Error("Failed to reload GeoIP database", zap.String("path", f.path), zap.Error(err))
+
+					continue
+				}
+
+				if reloaded {
+					r.logger.Info("Reloaded GeoIP database", zap.String("path", f.path))
+				}
+			}
+		case <-r.done:
+			return
+		}
+	}
+}
+
+// file is a database file and the reader for its current contents.
+type file struct {
+	// reader is replaced when the file changes, so lookups never block on
+	// reloads.
+	reader atomic.Pointer[maxminddb.Reader]
+
+	// modTime and size identify the loaded version of the file. They're only
+	// used by Open and the goroutine checking for changes.
+	modTime time.Time
+	path    string
+	size    int64
+}
+
+// reload loads the file if it changed since it was last loaded, and reports
+// whether it did. On error, the previous version is kept and loading is
+// retried on the next call.
+func (f *file) reload() (bool, error) {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return false, fmt.Errorf("failed to stat GeoIP database: %w", err)
+	}
+
+	if f.reader.Load() != nil && info.ModTime().Equal(f.modTime) && info.Size() == f.size {
+		return false, nil
+	}
+
+	// Reading the file into memory, instead of mapping it, keeps replaced
+	// readers valid for the lookups still using them.
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return false, fmt.Errorf("failed to read GeoIP database: %w", err)
+	}
+
+	reader, err := maxminddb.FromBytes(data)
+	if err != nil {
+		return false, fmt.Errorf("failed to parse GeoIP database %s: %w", f.path, err)
+	}
+
+	f.reader.Store(reader)
+	f.modTime = info.ModTime()
+	f.size = info.Size()
+
+	return true, nil
+}
+
+// record holds the fields read from the GeoIP2 and GeoLite2 City, Country,
+// and ASN databases, and compatible ones.
+type record struct {
+	Country struct {
+		Names   map[string]string `maxminddb:"names"`
+		ISOCode string            `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+	AutonomousSystemOrganization string `maxminddb:"autonomous_system_organization"`
+	Subdivisions                 []struct {
+		Names   map[string]string `maxminddb:"names"`
+		ISOCode string            `maxminddb:"iso_code"`
+	} `maxminddb:"subdivisions"`
+	AutonomousSystemNumber uint `maxminddb:"autonomous_system_number"`
+}
+
+// merge fills in the fields of location that are still empty.
+func (rec *record) merge(location *Location) {
+	setIfEmpty(&location.CountryCode, rec.Country.ISOCode)
+	setIfEmpty(&location.Country, rec.Country.Names[Language])
+	setIfEmpty(&location.City, rec.City.Names[Language])
+	setIfEmpty(&location.Organization, rec.AutonomousSystemOrganization)
+
+	if len(rec.Subdivisions) > 0 {
+		setIfEmpty(&location.RegionCode, rec.Subdivisions[0].ISOCode)
+		setIfEmpty(&location.Region, rec.Subdivisions[0].Names[Language])
+	}
+
+	if location.ASN == 0 {
+		location.ASN = rec.AutonomousSystemNumber
+	}
+}
+
+// setIfEmpty sets field to value if it's empty.
+func setIfEmpty(field *string, value string) {
+	if *field == "" {
+		*field = value
+	}
+}
@@ -0,0 +1,185 @@
+package geoip_test
+
+import (
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"git.sr.ht/~jamesponddotco/accio127/internal/geoip"
+	"go.uber.org/zap"
+)
+
+func TestReader_Lookup(t *testing.T) {
+	t.Parallel()
+
+	reader, err := geoip.Open(zap.NewNop(), []string{"testdata/test-city.mmdb", "testdata/test-asn.mmdb"}, 0)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+
+	t.Cleanup(reader.Close)
+
+	tests := []struct {
+		name string
+		give string
+		want geoip.Location
+	}{
+		{
+			name: "ipv4",
+			give: "81.2.69.142",
+			want: geoip.Location{
+				CountryCode:  "GB",
+				Country:      "United Kingdom",
+				RegionCode:   "ENG",
+				Region:       "England",
+				City:         "London",
+				Organization: "Andrews & Arnold Ltd",
+				ASN:          20712,
+			},
+		},
+		{
+			name: "ipv4_mapped",
+			give: "::ffff:81.2.69.142",
+			want: geoip.Location{
+				CountryCode:  "GB",
+				Country:      "United Kingdom",
+				RegionCode:   "ENG",
+				Region:       "England",
+				City:         "London",
+				Organization: "Andrews & Arnold Ltd",
+				ASN:          20712,
+			},
+		},
+		{
+			name: "ipv6",
+			give: "2001:db8::1",
+			want: geoip.Location{
+				CountryCode:  "SE",
+				Country:      "Sweden",
+				RegionCode:   "E",
+				Region:       "Östergötland County",
+				City:         "Linköping",
+				Organization: "Documentation ASN",
+				ASN:          64496,
+			},
+		},
+		{
+			name: "unknown",
+			give: "192.0.2.1",
+			want: geoip.Location{},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := reader.Lookup(netip.MustParseAddr(tt.give))
+			if err != nil {
+				t.Fatalf("Lookup(%q) error = %v", tt.give, err)
+			}
+
+			if got != tt.want {
+				t.Errorf("Lookup(%q) = %+v, want %+v", tt.give, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReader_Databases(t *testing.T) {
+	t.Parallel()
+
+	reader, err := geoip.Open(zap.NewNop(), []string{"testdata/test-city.mmdb"}, 0)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer reader.Close()
+
+	var (
+		got  = reader.Databases()
+		want = []geoip.Database{
+			{
+				BuildTime: time.Date(2023, time.June, 1, 0, 0, 0, 0, time.UTC),
+				Path:      "testdata/test-city.mmdb",
+				Type:      "GeoLite2-City",
+			},
+		}
+	)
+
+	if len(got) != len(want) || got[0] != want[0] {
+		t.Errorf("Databases() = %+v, want %+v", got, want)
+	}
+}
+
+func TestOpen_MissingFile(t *testing.T) {
+	t.Parallel()
+
+	if _, err := geoip.Open(zap.NewNop(), []string{"testdata/nonexistent.mmdb"}, 0); err == nil {
+		t.Error("Open() error = nil, want error")
+	}
+}
+
+func TestReader_Reload(t *testing.T) {
+	t.Parallel()
+
+	var (
+		path = filepath.Join(t.TempDir(), "geoip.mmdb")
+		addr = netip.MustParseAddr("81.2.69.142")
+	)
+
+	copyFile(t, "testdata/test-asn.mmdb", path)
+
+	reader, err := geoip.Open(zap.NewNop(), []string{path}, 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer reader.Close()
+
+	location, err := reader.Lookup(addr)
+	if err != nil || location.City != "" || location.ASN != 20712 {
+		t.Fatalf("Lookup() = %+v, %v, want the ASN database", location, err)
+	}
+
+	// A partially written file must not replace the loaded database.
+	if err = os.WriteFile(path, []byte("not a database"), 0o600); err != nil {
+		t.Fatalf("Failed to write database: %v", err)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+
+	copyFile(t, "testdata/test-city.mmdb", path)
+
+	deadline := time.Now().Add(5 * time.Second)
+
+	for time.Now().Before(deadline) {
+		location, err = reader.Lookup(addr)
+		if err != nil {
+			t.Fatalf("Lookup() error = %v", err)
+		}
+
+		if location.City == "London" {
+			return
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Errorf("Lookup() = %+v, want the reloaded city database", location)
+}
+
+func copyFile(t *testing.T, src, dst string) {
+	t.Helper()
+
+	data, err := os.ReadFile(src)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", src, err)
+	}
+
+	if err = os.WriteFile(dst, data, 0o600); err != nil {
+		t.Fatalf("Failed to write %s: %v", dst, err)
+	}
+}
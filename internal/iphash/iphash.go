@@ -0,0 +1,122 @@
+// Package iphash hashes IP addresses with a secret key that can rotate on a
+// schedule, so that hashes can't be reversed by enumerating the address space
+// and can't be linked across rotation periods.
+package iphash
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"strconv"
+	"time"
+
+	"git.sr.ht/~jamesponddotco/accio127/internal/config"
+	"git.sr.ht/~jamesponddotco/xstd-go/xerrors"
+	"golang.org/x/crypto/blake2b"
+)
+
+const (
+	// ErrUnknownAlgorithm is returned when a Hasher is created with an
+	// unknown algorithm.
+	ErrUnknownAlgorithm xerrors.Error = "unknown hash algorithm"
+
+	// ErrEmptySecret is returned when a Hasher is created without a secret.
+	ErrEmptySecret xerrors.Error = "hash secret cannot be empty"
+)
+
+// Hasher hashes IP addresses with keys derived from a secret.
+//
+// Each rotation period, or epoch, uses its own key, derived from the secret
+// and the number of the epoch with HMAC-SHA256. Without rotation, every hash
+// belongs to epoch 0.
+type Hasher struct {
+	algorithm string
+	secret    []byte
+	keyID     string
+	rotation  time.Duration
+}
+
+// Result is the hash of an IP address along with the key it was computed
+// with.
+type Result struct {
+	// Expires is when the key stops being used. It's the zero time if keys
+	// don't rotate.
+	Expires time.Time
+
+	// Hash is the hex-encoded hash of the IP address.
+	Hash string
+
+	// KeyID identifies the secret, without disclosing it.
+	KeyID string
+
+	// Epoch is the number of the rotation period the key belongs to.
+	Epoch int64
+}
+
+// New creates a new Hasher using algorithm, one of the config.HashAlgorithm
+// constants, keyed with secret and rotating keys every rotation. A rotation of
+// zero disables rotation.
+func New(algorithm string, secret []byte, rotation time.Duration) (*Hasher, error) {
+	switch algorithm {
+	case config.HashAlgorithmHMACSHA256, config.HashAlgorithmBLAKE2b:
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownAlgorithm, algorithm)
+	}
+
+	if len(secret) == 0 {
+		return nil, ErrEmptySecret
+	}
+
+	id := mac(secret, "accio127 key id")
+
+	return &Hasher{
+		algorithm: algorithm,
+		secret:    secret,
+		keyID:     hex.EncodeToString(id[:4]),
+		rotation:  rotation,
+	}, nil
+}
+
+// Hash hashes ip with the key of the epoch now belongs to.
+func (h *Hasher) Hash(ip string, now time.Time) Result {
+	var (
+		epoch   int64
+		expires time.Time
+	)
+
+	if h.rotation > 0 {
+		epoch = now.UnixNano() / int64(h.rotation)
+		expires = time.Unix(0, (epoch+1)*int64(h.rotation)).UTC()
+	}
+
+	key := mac(h.secret, "accio127 epoch "+strconv.FormatInt(epoch, 10))
+
+	var digest hash.Hash
+
+	if h.algorithm == config.HashAlgorithmBLAKE2b {
+		// The key is 32 bytes long, well within BLAKE2b's limit of 64, so
+		// creating the hash can't fail.
+		digest, _ = blake2b.New256(key)
+	} else {
+		digest = hmac.New(sha256.New, key)
+	}
+
+	digest.Write([]byte(ip))
+
+	return Result{
+		Hash:    hex.EncodeToString(digest.Sum(nil)),
+		KeyID:   h.keyID,
+		Epoch:   epoch,
+		Expires: expires,
+	}
+}
+
+// mac returns the HMAC-SHA256 of message keyed with secret.
+func mac(secret []byte, message string) []byte {
+	m := hmac.New(sha256.New, secret)
+	m.Write([]byte(message))
+
+	return m.Sum(nil)
+}
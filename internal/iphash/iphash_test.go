@@ -0,0 +1,144 @@
+package iphash_test
+
+import (
+	"testing"
+	"time"
+
+	"git.sr.ht/~jamesponddotco/accio127/internal/config"
+	"git.sr.ht/~jamesponddotco/accio127/internal/iphash"
+)
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		algorithm string
+		secret    []byte
+		wantErr   bool
+	}{
+		{
+			name:      "hmac_sha256",
+			algorithm: config.HashAlgorithmHMACSHA256,
+			secret:    []byte("secret"),
+		},
+		{
+			name:      "blake2b",
+			algorithm: config.HashAlgorithmBLAKE2b,
+			secret:    []byte("secret"),
+		},
+		{
+			name:      "unknown_algorithm",
+			algorithm: "md5",
+			secret:    []byte("secret"),
+			wantErr:   true,
+		},
+		{
+			name:      "empty_secret",
+			algorithm: config.HashAlgorithmHMACSHA256,
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := iphash.New(tt.algorithm, tt.secret, 0)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("New() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHasher_Hash(t *testing.T) {
+	t.Parallel()
+
+	var (
+		secret   = []byte("0123456789abcdef0123456789abcdef")
+		now      = time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+		tomorrow = now.Add(24 * time.Hour)
+	)
+
+	newHasher := func(t *testing.T, algorithm string, secret []byte, rotation time.Duration) *iphash.Hasher {
+		t.Helper()
+
+		h, err := iphash.New(algorithm, secret, rotation)
+		if err != nil {
+			t.Fatalf("New() error = %v", err)
+		}
+
+		return h
+	}
+
+	t.Run("stable_without_rotation", func(t *testing.T) {
+		t.Parallel()
+
+		var (
+			h      = newHasher(t, config.HashAlgorithmHMACSHA256, secret, 0)
+			first  = h.Hash("192.0.2.1", now)
+			second = h.Hash("192.0.2.1", tomorrow)
+		)
+
+		if first.Hash != second.Hash {
+			t.Errorf("Hash() = %v, want %v", second.Hash, first.Hash)
+		}
+
+		if first.Epoch != 0 || !first.Expires.IsZero() {
+			t.Errorf("Hash() epoch = %v, expires = %v, want 0 and the zero time", first.Epoch, first.Expires)
+		}
+
+		if len(first.Hash) != 64 || len(first.KeyID) != 8 {
+			t.Errorf("Hash() = %v, key ID = %v, want 64 and 8 hex characters", first.Hash, first.KeyID)
+		}
+	})
+
+	t.Run("rotates_daily", func(t *testing.T) {
+		t.Parallel()
+
+		var (
+			h      = newHasher(t, config.HashAlgorithmHMACSHA256, secret, 24*time.Hour)
+			first  = h.Hash("192.0.2.1", now)
+			same   = h.Hash("192.0.2.1", now.Add(time.Hour))
+			second = h.Hash("192.0.2.1", tomorrow)
+		)
+
+		if first.Hash != same.Hash {
+			t.Errorf("Hash() = %v, want %v within the same day", same.Hash, first.Hash)
+		}
+
+		if first.Hash == second.Hash {
+			t.Errorf("Hash() = %v on two different days, want different hashes", first.Hash)
+		}
+
+		if second.Epoch != first.Epoch+1 {
+			t.Errorf("Hash() epoch = %v, want %v", second.Epoch, first.Epoch+1)
+		}
+
+		if want := time.Date(2023, 6, 2, 0, 0, 0, 0, time.UTC); !first.Expires.Equal(want) {
+			t.Errorf("Hash() expires = %v, want %v", first.Expires, want)
+		}
+	})
+
+	t.Run("depends_on_secret_and_algorithm", func(t *testing.T) {
+		t.Parallel()
+
+		var (
+			hmacHash   = newHasher(t, config.HashAlgorithmHMACSHA256, secret, 0).Hash("192.0.2.1", now)
+			blakeHash  = newHasher(t, config.HashAlgorithmBLAKE2b, secret, 0).Hash("192.0.2.1", now)
+			otherHash  = newHasher(t, config.HashAlgorithmHMACSHA256, []byte("another secret"), 0).Hash("192.0.2.1", now)
+			otherInput = newHasher(t, config.HashAlgorithmHMACSHA256, secret, 0).Hash("192.0.2.2", now)
+		)
+
+		if hmacHash.Hash == blakeHash.Hash || hmacHash.Hash == otherHash.Hash || hmacHash.Hash == otherInput.Hash {
+			t.Errorf("Hash() returned the same hash for different inputs")
+		}
+
+		if hmacHash.KeyID == otherHash.KeyID {
+			t.Errorf("Hash() returned the same key ID for different secrets")
+		}
+	})
+}
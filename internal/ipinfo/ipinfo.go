@@ -0,0 +1,246 @@
+// Package ipinfo classifies IP addresses using only local data, without
+// querying DNS or any external database.
+package ipinfo
+
+import (
+	"encoding/hex"
+	"math/big"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+const (
+	// EmbeddingMapped is an IPv4-mapped IPv6 address, in ::ffff:0:0/96.
+	EmbeddingMapped string = "ipv4-mapped"
+
+	// Embedding6to4 is a 6to4 address, in 2002::/16.
+	Embedding6to4 string = "6to4"
+
+	// EmbeddingTeredo is a Teredo address, in 2001::/32. The embedded
+	// address is the client's public IPv4 address.
+	EmbeddingTeredo string = "teredo"
+
+	// EmbeddingNAT64 is a NAT64 address, in the well-known 64:ff9b::/96 prefix
+	// or the local-use 64:ff9b:1::/48 prefix.
+	EmbeddingNAT64 string = "nat64"
+)
+
+const (
+	// ipv4Network is the length of the network reported for IPv4 addresses.
+	ipv4Network int = 24
+
+	// ipv6Network is the length of the network reported for IPv6 addresses.
+	ipv6Network int = 64
+)
+
+// Details describes an IP address.
+type Details struct {
+	// Addr is the address, without a zone.
+	Addr netip.Addr
+
+	// Network is the /24 enclosing IPv4 addresses or the /64 enclosing IPv6
+	// addresses. IPv4-mapped addresses are treated as IPv4.
+	Network netip.Prefix
+
+	// Embedded is the IPv4 address embedded in an IPv6 address, if any.
+	Embedded netip.Addr
+
+	// Embedding is how the IPv4 address is embedded, one of the Embedding
+	// constants, or empty.
+	Embedding string
+
+	// Private reports whether the address is in a private range, as defined
+	// by RFC 1918 and RFC 4193.
+	Private bool
+
+	// Loopback reports whether the address is a loopback address.
+	Loopback bool
+
+	// LinkLocal reports whether the address is a link-local unicast address.
+	LinkLocal bool
+
+	// CGNAT reports whether the address is in the shared address space used
+	// by carrier-grade NAT, 100.64.0.0/10.
+	CGNAT bool
+
+	// Documentation reports whether the address is reserved for
+	// documentation.
+	Documentation bool
+
+	// Multicast reports whether the address is a multicast address.
+	Multicast bool
+
+	// Unspecified reports whether the address is the unspecified address.
+	Unspecified bool
+}
+
+// Classify describes addr.
+func Classify(addr netip.Addr) Details {
+	addr = addr.WithZone("")
+
+	var (
+		unmapped = addr.Unmap()
+		details  = Details{
+			Addr:          addr,
+			Private:       unmapped.IsPrivate(),
+			Loopback:      unmapped.IsLoopback(),
+			LinkLocal:     unmapped.IsLinkLocalUnicast(),
+			CGNAT:         isCGNAT(unmapped),
+			Documentation: isDocumentation(unmapped),
+			Multicast:     unmapped.IsMulticast(),
+			Unspecified:   unmapped.IsUnspecified(),
+		}
+	)
+
+	bits := ipv6Network
+	if unmapped.Is4() {
+		bits = ipv4Network
+	}
+
+	// Prefix only fails for invalid addresses, which have no network.
+	details.Network, _ = unmapped.Prefix(bits)
+	details.Embedded, details.Embedding = embedded(addr)
+
+	return details
+}
+
+// ReverseName returns the name used to look up the PTR record of addr, in the
+// in-addr.arpa or ip6.arpa zones.
+func ReverseName(addr netip.Addr) string {
+	addr = addr.Unmap()
+
+	if !addr.IsValid() {
+		return ""
+	}
+
+	var builder strings.Builder
+
+	if addr.Is4() {
+		octets := addr.As4()
+
+		for i := len(octets) - 1; i >= 0; i-- {
+			builder.WriteString(strconv.Itoa(int(octets[i])))
+			builder.WriteString(".")
+		}
+
+		builder.WriteString("in-addr.arpa")
+
+		return builder.String()
+	}
+
+	nibbles := hex.EncodeToString(addr.AsSlice())
+
+	for i := len(nibbles) - 1; i >= 0; i-- {
+		builder.WriteByte(nibbles[i])
+		builder.WriteString(".")
+	}
+
+	builder.WriteString("ip6.arpa")
+
+	return builder.String()
+}
+
+// Decimal returns addr as a decimal integer.
+func Decimal(addr netip.Addr) string {
+	if !addr.IsValid() {
+		return ""
+	}
+
+	return new(big.Int).SetBytes(addr.AsSlice()).String()
+}
+
+// Hex returns addr as a hexadecimal integer, prefixed with 0x and padded to
+// the length of the address.
+func Hex(addr netip.Addr) string {
+	if !addr.IsValid() {
+		return ""
+	}
+
+	return "0x" + hex.EncodeToString(addr.AsSlice())
+}
+
+// isCGNAT reports whether addr is in 100.64.0.0/10.
+func isCGNAT(addr netip.Addr) bool {
+	if !addr.Is4() {
+		return false
+	}
+
+	octets := addr.As4()
+
+	return octets[0] == 100 && octets[1]&0xc0 == 64
+}
+
+// isDocumentation reports whether addr is in one of the ranges reserved for
+// documentation: 192.0.2.0/24, 198.51.100.0/24, and 203.0.113.0/24 by RFC
+// 5737, 2001:db8::/32 by RFC 3849, and 3fff::/20 by RFC 9637.
+func isDocumentation(addr netip.Addr) bool {
+	if addr.Is4() {
+		octets := addr.As4()
+
+		switch {
+		case octets[0] == 192 && octets[1] == 0 && octets[2] == 2,
+			octets[0] == 198 && octets[1] == 51 && octets[2] == 100,
+			octets[0] == 203 && octets[1] == 0 && octets[2] == 113:
+			return true
+		default:
+			return false
+		}
+	}
+
+	if !addr.Is6() {
+		return false
+	}
+
+	octets := addr.As16()
+
+	return (octets[0] == 0x20 && octets[1] == 0x01 && octets[2] == 0x0d && octets[3] == 0xb8) ||
+		(octets[0] == 0x3f && octets[1] == 0xff && octets[2]&0xf0 == 0)
+}
+
+// embedded returns the IPv4 address embedded in addr and how it's embedded,
+// or the zero Addr and an empty string if there isn't one.
+func embedded(addr netip.Addr) (netip.Addr, string) {
+	if addr.Is4In6() {
+		return addr.Unmap(), EmbeddingMapped
+	}
+
+	if !addr.Is6() {
+		return netip.Addr{}, ""
+	}
+
+	octets := addr.As16()
+
+	switch {
+	case octets[0] == 0x20 && octets[1] == 0x02:
+		return netip.AddrFrom4([4]byte{octets[2], octets[3], octets[4], octets[5]}), Embedding6to4
+	case octets[0] == 0x20 && octets[1] == 0x01 && octets[2] == 0 && octets[3] == 0:
+		// The client's address is stored with every bit flipped.
+		return netip.AddrFrom4([4]byte{^octets[12], ^octets[13], ^octets[14], ^octets[15]}), EmbeddingTeredo
+	case hasZeroPaddedPrefix(octets[:12], 0x00, 0x64, 0xff, 0x9b):
+		return netip.AddrFrom4([4]byte{octets[12], octets[13], octets[14], octets[15]}), EmbeddingNAT64
+	case octets[0] == 0x00 && octets[1] == 0x64 && octets[2] == 0xff && octets[3] == 0x9b &&
+		octets[4] == 0x00 && octets[5] == 0x01:
+		// RFC 6052 skips bits 64 to 71 for prefixes shorter than /64.
+		return netip.AddrFrom4([4]byte{octets[6], octets[7], octets[9], octets[10]}), EmbeddingNAT64
+	default:
+		return netip.Addr{}, ""
+	}
+}
+
+// hasZeroPaddedPrefix reports whether octets starts with prefix and every
+// octet after it is zero.
+func hasZeroPaddedPrefix(octets []byte, prefix ...byte) bool {
+	for i, octet := range octets {
+		want := byte(0)
+		if i < len(prefix) {
+			want = prefix[i]
+		}
+
+		if octet != want {
+			return false
+		}
+	}
+
+	return true
+}
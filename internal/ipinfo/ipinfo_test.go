@@ -0,0 +1,249 @@
+package ipinfo_test
+
+import (
+	"net/netip"
+	"testing"
+
+	"git.sr.ht/~jamesponddotco/accio127/internal/ipinfo"
+)
+
+func TestClassify(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		give string
+		want ipinfo.Details
+	}{
+		{
+			name: "public_ipv4",
+			give: "8.8.8.8",
+			want: ipinfo.Details{
+				Network: netip.MustParsePrefix("8.8.8.0/24"),
+			},
+		},
+		{
+			name: "private_ipv4",
+			give: "192.168.1.10",
+			want: ipinfo.Details{
+				Network: netip.MustParsePrefix("192.168.1.0/24"),
+				Private: true,
+			},
+		},
+		{
+			name: "cgnat",
+			give: "100.127.255.1",
+			want: ipinfo.Details{
+				Network: netip.MustParsePrefix("100.127.255.0/24"),
+				CGNAT:   true,
+			},
+		},
+		{
+			name: "not_cgnat",
+			give: "100.128.0.1",
+			want: ipinfo.Details{
+				Network: netip.MustParsePrefix("100.128.0.0/24"),
+			},
+		},
+		{
+			name: "loopback_ipv4",
+			give: "127.0.0.1",
+			want: ipinfo.Details{
+				Network:  netip.MustParsePrefix("127.0.0.0/24"),
+				Loopback: true,
+			},
+		},
+		{
+			name: "documentation_ipv4",
+			give: "203.0.113.7",
+			want: ipinfo.Details{
+				Network:       netip.MustParsePrefix("203.0.113.0/24"),
+				Documentation: true,
+			},
+		},
+		{
+			name: "multicast_ipv4",
+			give: "224.0.0.251",
+			want: ipinfo.Details{
+				Network:   netip.MustParsePrefix("224.0.0.0/24"),
+				Multicast: true,
+			},
+		},
+		{
+			name: "link_local_ipv6",
+			give: "fe80::1%eth0",
+			want: ipinfo.Details{
+				Network:   netip.MustParsePrefix("fe80::/64"),
+				LinkLocal: true,
+			},
+		},
+		{
+			name: "documentation_ipv6",
+			give: "2001:db8:1:2::68",
+			want: ipinfo.Details{
+				Network:       netip.MustParsePrefix("2001:db8:1:2::/64"),
+				Documentation: true,
+			},
+		},
+		{
+			name: "unique_local_ipv6",
+			give: "fd00::1",
+			want: ipinfo.Details{
+				Network: netip.MustParsePrefix("fd00::/64"),
+				Private: true,
+			},
+		},
+		{
+			name: "unspecified_ipv6",
+			give: "::",
+			want: ipinfo.Details{
+				Network:     netip.MustParsePrefix("::/64"),
+				Unspecified: true,
+			},
+		},
+		{
+			name: "ipv4_mapped",
+			give: "::ffff:10.1.2.3",
+			want: ipinfo.Details{
+				Network:   netip.MustParsePrefix("10.1.2.0/24"),
+				Embedded:  netip.MustParseAddr("10.1.2.3"),
+				Embedding: ipinfo.EmbeddingMapped,
+				Private:   true,
+			},
+		},
+		{
+			name: "6to4",
+			give: "2002:c000:204::1",
+			want: ipinfo.Details{
+				Network:   netip.MustParsePrefix("2002:c000:204::/64"),
+				Embedded:  netip.MustParseAddr("192.0.2.4"),
+				Embedding: ipinfo.Embedding6to4,
+			},
+		},
+		{
+			name: "teredo",
+			give: "2001:0:4136:e378:8000:63bf:3fff:fdd2",
+			want: ipinfo.Details{
+				Network:   netip.MustParsePrefix("2001:0:4136:e378::/64"),
+				Embedded:  netip.MustParseAddr("192.0.2.45"),
+				Embedding: ipinfo.EmbeddingTeredo,
+			},
+		},
+		{
+			name: "nat64_well_known",
+			give: "64:ff9b::c000:221",
+			want: ipinfo.Details{
+				Network:   netip.MustParsePrefix("64:ff9b::/64"),
+				Embedded:  netip.MustParseAddr("192.0.2.33"),
+				Embedding: ipinfo.EmbeddingNAT64,
+			},
+		},
+		{
+			name: "nat64_local_use",
+			give: "64:ff9b:1:c000:2:2100::",
+			want: ipinfo.Details{
+				Network:   netip.MustParsePrefix("64:ff9b:1:c000::/64"),
+				Embedded:  netip.MustParseAddr("192.0.2.33"),
+				Embedding: ipinfo.EmbeddingNAT64,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			addr := netip.MustParseAddr(tt.give)
+
+			tt.want.Addr = addr.WithZone("")
+
+			got := ipinfo.Classify(addr)
+			if got != tt.want {
+				t.Errorf("Classify(%q) = %+v, want %+v", tt.give, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReverseName(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		give string
+		want string
+	}{
+		{
+			name: "ipv4",
+			give: "192.0.2.1",
+			want: "1.2.0.192.in-addr.arpa",
+		},
+		{
+			name: "ipv4_mapped",
+			give: "::ffff:192.0.2.1",
+			want: "1.2.0.192.in-addr.arpa",
+		},
+		{
+			name: "ipv6",
+			give: "2001:db8::567:89ab",
+			want: "b.a.9.8.7.6.5.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := ipinfo.ReverseName(netip.MustParseAddr(tt.give))
+			if got != tt.want {
+				t.Errorf("ReverseName(%q) = %v, want %v", tt.give, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecimalAndHex(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		give        string
+		wantDecimal string
+		wantHex     string
+	}{
+		{
+			name:        "ipv4",
+			give:        "192.0.2.1",
+			wantDecimal: "3221225985",
+			wantHex:     "0xc0000201",
+		},
+		{
+			name:        "ipv6",
+			give:        "2001:db8::1",
+			wantDecimal: "42540766411282592856903984951653826561",
+			wantHex:     "0x20010db8000000000000000000000001",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			addr := netip.MustParseAddr(tt.give)
+
+			if got := ipinfo.Decimal(addr); got != tt.wantDecimal {
+				t.Errorf("Decimal(%q) = %v, want %v", tt.give, got, tt.wantDecimal)
+			}
+
+			if got := ipinfo.Hex(addr); got != tt.wantHex {
+				t.Errorf("Hex(%q) = %v, want %v", tt.give, got, tt.wantHex)
+			}
+		})
+	}
+}
@@ -26,3 +26,13 @@ func (d *Duration) UnmarshalJSON(b []byte) error {
 
 	return nil
 }
+
+// MarshalJSON implements the json.Marshaler interface.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	b, err := json.Marshal(time.Duration(d).String())
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal duration as string: %w", err)
+	}
+
+	return b, nil
+}
@@ -62,3 +62,41 @@ func TestDuration_UnmarshalJSON(t *testing.T) {
 		})
 	}
 }
+
+func TestDuration_MarshalJSON(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		give jsonutil.Duration
+		want string
+	}{
+		{
+			name: "hour",
+			give: jsonutil.Duration(time.Hour),
+			want: `"1h0m0s"`,
+		},
+		{
+			name: "zero",
+			give: jsonutil.Duration(0),
+			want: `"0s"`,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := json.Marshal(tt.give)
+			if err != nil {
+				t.Fatalf("MarshalJSON() error = %v", err)
+			}
+
+			if string(got) != tt.want {
+				t.Errorf("MarshalJSON() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
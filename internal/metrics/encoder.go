@@ -0,0 +1,212 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	// OpenMetricsType is the media type of the OpenMetrics text format.
+	OpenMetricsType string = "application/openmetrics-text"
+
+	// OpenMetricsContentType is the Content-Type of responses in the
+	// OpenMetrics text format.
+	OpenMetricsContentType string = OpenMetricsType + "; version=1.0.0; charset=utf-8"
+
+	// PrometheusType is the media type of the Prometheus text format.
+	PrometheusType string = "text/plain"
+
+	// PrometheusContentType is the Content-Type of responses in the
+	// Prometheus text format.
+	PrometheusContentType string = PrometheusType + "; version=0.0.4; charset=utf-8"
+)
+
+// Label is a name and value pair identifying a sample.
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Sample is a single value of a metric.
+type Sample struct {
+	Labels []Label
+	Value  float64
+}
+
+// HistogramSample is a single series of a histogram. Counts holds the number of
+// observations in each bucket, not cumulative, with one extra entry for the
+// +Inf bucket.
+type HistogramSample struct {
+	Labels []Label
+	Counts []uint64
+	Sum    float64
+}
+
+// Encoder writes metrics in the OpenMetrics or Prometheus text formats, which
+// differ in how counters and info metrics are declared and in the trailing
+// EOF marker.
+//
+// The first write error is kept and returned by Close, so callers don't have
+// to check every call.
+type Encoder struct {
+	w           io.Writer
+	err         error
+	openMetrics bool
+}
+
+// NewEncoder creates a new Encoder writing to w, in the OpenMetrics format if
+// openMetrics is true and in the Prometheus format otherwise.
+func NewEncoder(w io.Writer, openMetrics bool) *Encoder {
+	return &Encoder{
+		w:           w,
+		openMetrics: openMetrics,
+	}
+}
+
+// ContentType returns the Content-Type of the encoder's output.
+func (e *Encoder) ContentType() string {
+	if e.openMetrics {
+		return OpenMetricsContentType
+	}
+
+	return PrometheusContentType
+}
+
+// Counter writes a counter. The name must not include the _total suffix,
+// which is added to every sample.
+func (e *Encoder) Counter(name, help string, samples ...Sample) {
+	family := name + "_total"
+
+	if e.openMetrics {
+		family = name
+	}
+
+	e.header(family, "counter", help)
+
+	for _, sample := range samples {
+		e.sample(name+"_total", sample.Labels, sample.Value)
+	}
+}
+
+// Gauge writes a gauge.
+func (e *Encoder) Gauge(name, help string, samples ...Sample) {
+	e.header(name, "gauge", help)
+
+	for _, sample := range samples {
+		e.sample(name, sample.Labels, sample.Value)
+	}
+}
+
+// Info writes an info metric, whose value is always 1. The name must not
+// include the _info suffix, which is added to the sample.
+func (e *Encoder) Info(name, help string, labels ...Label) {
+	if e.openMetrics {
+		e.header(name, "info", help)
+	} else {
+		e.header(name+"_info", "gauge", help)
+	}
+
+	e.sample(name+"_info", labels, 1)
+}
+
+// Histogram writes a histogram whose buckets have the given upper bounds.
+func (e *Encoder) Histogram(name, help string, bounds []float64, samples ...HistogramSample) {
+	e.header(name, "histogram", help)
+
+	for _, sample := range samples {
+		var cumulative uint64
+
+		for i, count := range sample.Counts {
+			cumulative += count
+
+			bound := math.Inf(1)
+			if i < len(bounds) {
+				bound = bounds[i]
+			}
+
+			labels := append(append(make([]Label, 0, len(sample.Labels)+1), sample.Labels...), Label{
+				Name:  "le",
+				Value: formatFloat(bound),
+			})
+
+			e.sample(name+"_bucket", labels, float64(cumulative))
+		}
+
+		e.sample(name+"_sum", sample.Labels, sample.Sum)
+		e.sample(name+"_count", sample.Labels, float64(cumulative))
+	}
+}
+
+// Close writes the EOF marker required by OpenMetrics, and returns the first
+// error encountered while writing.
+func (e *Encoder) Close() error {
+	if e.openMetrics {
+		e.printf("# EOF\n")
+	}
+
+	return e.err
+}
+
+// header writes the HELP and TYPE lines of a metric family.
+func (e *Encoder) header(name, typ, help string) {
+	e.printf("# HELP %s %s\n", name, escape(help, false))
+	e.printf("# TYPE %s %s\n", name, typ)
+}
+
+// sample writes a single sample line.
+func (e *Encoder) sample(name string, labels []Label, value float64) {
+	if len(labels) == 0 {
+		e.printf("%s %s\n", name, formatFloat(value))
+
+		return
+	}
+
+	pairs := make([]string, 0, len(labels))
+
+	for _, label := range labels {
+		pairs = append(pairs, label.Name+`="`+escape(label.Value, true)+`"`)
+	}
+
+	e.printf("%s{%s} %s\n", name, strings.Join(pairs, ","), formatFloat(value))
+}
+
+// printf writes to the underlying writer unless a previous write failed.
+func (e *Encoder) printf(format string, args ...any) {
+	if e.err != nil {
+		return
+	}
+
+	if _, err := fmt.Fprintf(e.w, format, args...); err != nil {
+		e.err = fmt.Errorf("failed to write metrics: %w", err)
+	}
+}
+
+// escape escapes backslashes and line feeds, and double quotes in label
+// values.
+func escape(s string, quotes bool) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+
+	if quotes {
+		s = strings.ReplaceAll(s, `"`, `\"`)
+	}
+
+	return s
+}
+
+// formatFloat formats a sample value or bucket bound.
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	case math.IsNaN(f):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+}
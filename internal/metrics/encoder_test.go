@@ -0,0 +1,95 @@
+package metrics_test
+
+import (
+	"strings"
+	"testing"
+
+	"git.sr.ht/~jamesponddotco/accio127/internal/metrics"
+)
+
+func TestEncoder(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		openMetrics bool
+		want        string
+	}{
+		{
+			name:        "openmetrics",
+			openMetrics: true,
+			want: `# HELP accesses Accesses.
+# TYPE accesses counter
+accesses_total 3
+# HELP up Whether the "database" is up.
+# TYPE up gauge
+up{service="sql\"ite\\"} 1
+# HELP build Build information.
+# TYPE build info
+build_info{version="1.0"} 1
+# HELP latency Latency.
+# TYPE latency histogram
+latency_bucket{endpoint="/",le="0.1"} 1
+latency_bucket{endpoint="/",le="1"} 1
+latency_bucket{endpoint="/",le="+Inf"} 3
+latency_sum{endpoint="/"} 4.5
+latency_count{endpoint="/"} 3
+# EOF
+`,
+		},
+		{
+			name:        "prometheus",
+			openMetrics: false,
+			want: `# HELP accesses_total Accesses.
+# TYPE accesses_total counter
+accesses_total 3
+# HELP up Whether the "database" is up.
+# TYPE up gauge
+up{service="sql\"ite\\"} 1
+# HELP build_info Build information.
+# TYPE build_info gauge
+build_info{version="1.0"} 1
+# HELP latency Latency.
+# TYPE latency histogram
+latency_bucket{endpoint="/",le="0.1"} 1
+latency_bucket{endpoint="/",le="1"} 1
+latency_bucket{endpoint="/",le="+Inf"} 3
+latency_sum{endpoint="/"} 4.5
+latency_count{endpoint="/"} 3
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var (
+				got     strings.Builder
+				encoder = metrics.NewEncoder(&got, tt.openMetrics)
+			)
+
+			encoder.Counter("accesses", "Accesses.", metrics.Sample{Value: 3})
+			encoder.Gauge("up", `Whether the "database" is up.`, metrics.Sample{
+				Labels: []metrics.Label{{Name: "service", Value: `sql"ite\`}},
+				Value:  1,
+			})
+			encoder.Info("build", "Build information.", metrics.Label{Name: "version", Value: "1.0"})
+			encoder.Histogram("latency", "Latency.", []float64{0.1, 1}, metrics.HistogramSample{
+				Labels: []metrics.Label{{Name: "endpoint", Value: "/"}},
+				Counts: []uint64{1, 0, 2},
+				Sum:    4.5,
+			})
+
+			if err := encoder.Close(); err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+
+			if got.String() != tt.want {
+				t.Errorf("Encoder output = %s, want %s", got.String(), tt.want)
+			}
+		})
+	}
+}
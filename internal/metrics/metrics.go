@@ -0,0 +1,134 @@
+// Package metrics collects request metrics and writes them in the OpenMetrics
+// and Prometheus text exposition formats.
+package metrics
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultBuckets returns the upper bounds, in seconds, of the buckets of the
+// request latency histogram.
+func DefaultBuckets() []float64 {
+	return []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5}
+}
+
+// requestKey identifies the series of the request counter.
+type requestKey struct {
+	endpoint string
+	code     int
+}
+
+// histogram counts observations in buckets.
+type histogram struct {
+	counts []uint64
+	sum    float64
+}
+
+// Registry holds the metrics of the HTTP requests served by the service. It's
+// safe for concurrent use.
+type Registry struct {
+	buckets  []float64
+	inFlight atomic.Int64
+
+	// mu guards the fields below.
+	mu        sync.Mutex
+	requests  map[requestKey]uint64
+	latencies map[string]*histogram
+}
+
+// NewRegistry creates a new Registry with the DefaultBuckets.
+func NewRegistry() *Registry {
+	return &Registry{
+		buckets:   DefaultBuckets(),
+		requests:  make(map[requestKey]uint64),
+		latencies: make(map[string]*histogram),
+	}
+}
+
+// Start marks the beginning of a request.
+func (r *Registry) Start() {
+	r.inFlight.Add(1)
+}
+
+// Done marks the end of a request to endpoint, which was answered with code
+// after duration.
+func (r *Registry) Done(endpoint string, code int, duration time.Duration) {
+	r.inFlight.Add(-1)
+
+	seconds := duration.Seconds()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.requests[requestKey{endpoint: endpoint, code: code}]++
+
+	h, ok := r.latencies[endpoint]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(r.buckets)+1)}
+		r.latencies[endpoint] = h
+	}
+
+	i := sort.SearchFloat64s(r.buckets, seconds)
+
+	h.counts[i]++
+	h.sum += seconds
+}
+
+// Write writes the request metrics to e.
+func (r *Registry) Write(e *Encoder) {
+	r.mu.Lock()
+
+	var (
+		requests  = make([]Sample, 0, len(r.requests))
+		latencies = make([]HistogramSample, 0, len(r.latencies))
+	)
+
+	for key, count := range r.requests {
+		requests = append(requests, Sample{
+			Labels: []Label{
+				{Name: "endpoint", Value: key.endpoint},
+				{Name: "code", Value: strconv.Itoa(key.code)},
+			},
+			Value: float64(count),
+		})
+	}
+
+	for endpoint, h := range r.latencies {
+		latencies = append(latencies, HistogramSample{
+			Labels: []Label{{Name: "endpoint", Value: endpoint}},
+			Counts: append([]uint64(nil), h.counts...),
+			Sum:    h.sum,
+		})
+	}
+
+	r.mu.Unlock()
+
+	sort.Slice(requests, func(i, j int) bool {
+		return lessLabels(requests[i].Labels, requests[j].Labels)
+	})
+
+	sort.Slice(latencies, func(i, j int) bool {
+		return lessLabels(latencies[i].Labels, latencies[j].Labels)
+	})
+
+	e.Counter("accio127_http_requests", "HTTP requests served, by endpoint and status code.", requests...)
+	e.Histogram("accio127_http_request_duration_seconds", "Latency of HTTP requests, by endpoint.", r.buckets, latencies...)
+	e.Gauge("accio127_http_requests_in_flight", "HTTP requests currently being served.", Sample{
+		Value: float64(r.inFlight.Load()),
+	})
+}
+
+// lessLabels orders label sets by their values, so that the output is stable.
+func lessLabels(a, b []Label) bool {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i].Value != b[i].Value {
+			return a[i].Value < b[i].Value
+		}
+	}
+
+	return len(a) < len(b)
+}
@@ -0,0 +1,45 @@
+package metrics_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"git.sr.ht/~jamesponddotco/accio127/internal/metrics"
+)
+
+func TestRegistry_Write(t *testing.T) {
+	t.Parallel()
+
+	registry := metrics.NewRegistry()
+
+	registry.Start()
+	registry.Start()
+	registry.Done("/v1/ip", 200, 2*time.Millisecond)
+	registry.Done("/v1/ip", 200, time.Second)
+
+	var output strings.Builder
+
+	encoder := metrics.NewEncoder(&output, true)
+	registry.Write(encoder)
+
+	if err := encoder.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	want := []string{
+		`accio127_http_requests_total{endpoint="/v1/ip",code="200"} 2`,
+		`accio127_http_request_duration_seconds_bucket{endpoint="/v1/ip",le="0.001"} 0`,
+		`accio127_http_request_duration_seconds_bucket{endpoint="/v1/ip",le="0.0025"} 1`,
+		`accio127_http_request_duration_seconds_bucket{endpoint="/v1/ip",le="1"} 2`,
+		`accio127_http_request_duration_seconds_bucket{endpoint="/v1/ip",le="+Inf"} 2`,
+		`accio127_http_request_duration_seconds_count{endpoint="/v1/ip"} 2`,
+		`accio127_http_requests_in_flight 0`,
+	}
+
+	for _, line := range want {
+		if !strings.Contains(output.String(), line+"\n") {
+			t.Errorf("Write() output = %s, want it to contain %s", output.String(), line)
+		}
+	}
+}
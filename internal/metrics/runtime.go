@@ -0,0 +1,39 @@
+package metrics
+
+import (
+	"runtime"
+	"time"
+)
+
+// WriteRuntime writes statistics about the Go runtime to e.
+func WriteRuntime(e *Encoder) {
+	var stats runtime.MemStats
+
+	runtime.ReadMemStats(&stats)
+
+	e.Info("go", "Information about the Go runtime.", Label{Name: "version", Value: runtime.Version()})
+	e.Gauge("go_goroutines", "Number of goroutines that currently exist.", Sample{
+		Value: float64(runtime.NumGoroutine()),
+	})
+	e.Gauge("go_gomaxprocs", "Maximum number of CPUs executing Go code simultaneously.", Sample{
+		Value: float64(runtime.GOMAXPROCS(0)),
+	})
+	e.Gauge("go_memstats_alloc_bytes", "Bytes of allocated heap objects.", Sample{
+		Value: float64(stats.HeapAlloc),
+	})
+	e.Gauge("go_memstats_heap_inuse_bytes", "Bytes in in-use heap spans.", Sample{
+		Value: float64(stats.HeapInuse),
+	})
+	e.Gauge("go_memstats_sys_bytes", "Bytes of memory obtained from the OS.", Sample{
+		Value: float64(stats.Sys),
+	})
+	e.Counter("go_memstats_mallocs", "Heap objects allocated.", Sample{
+		Value: float64(stats.Mallocs),
+	})
+	e.Counter("go_gc_cycles", "Completed GC cycles.", Sample{
+		Value: float64(stats.NumGC),
+	})
+	e.Counter("go_gc_pause_seconds", "Time spent in GC stop-the-world pauses.", Sample{
+		Value: time.Duration(stats.PauseTotalNs).Seconds(),
+	})
+}
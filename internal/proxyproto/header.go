@@ -0,0 +1,258 @@
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+const (
+	// maxV1HeaderLength is the maximum length of a version 1 header, including
+	// the CRLF.
+	maxV1HeaderLength int = 107
+
+	// v2HeaderLength is the length of the fixed part of a version 2 header.
+	v2HeaderLength int = 16
+
+	// v2MaxAddressLength caps the variable part of a version 2 header, which
+	// is far larger than anything a sane load balancer sends.
+	v2MaxAddressLength int = 4096
+)
+
+// Version 2 commands.
+const (
+	commandLocal byte = 0x0
+	commandProxy byte = 0x1
+)
+
+// Version 2 address families, combined with the transport protocol.
+const (
+	familyTCP4 byte = 0x11
+	familyUDP4 byte = 0x12
+	familyTCP6 byte = 0x21
+	familyUDP6 byte = 0x22
+)
+
+// Header is a parsed PROXY protocol header.
+type Header struct {
+	// Source is the address of the client.
+	Source net.Addr
+
+	// Destination is the address the client connected to.
+	Destination net.Addr
+
+	// Version is the version of the PROXY protocol used.
+	Version int
+
+	// Local is true if the connection was established by the load balancer
+	// itself, for health checks for example, and the real addresses should be
+	// used.
+	Local bool
+}
+
+// v1Signature returns the signature of version 1 headers.
+func v1Signature() []byte {
+	return []byte("PROXY ")
+}
+
+// v2Signature returns the signature of version 2 headers.
+func v2Signature() []byte {
+	return []byte{0x0D, 0x0A, 0x0D, 0x0A, 0x00, 0x0D, 0x0A, 0x51, 0x55, 0x49, 0x54, 0x0A}
+}
+
+// ReadHeader reads a PROXY protocol header from reader. It returns a nil
+// Header without consuming anything if the stream doesn't start with one.
+func ReadHeader(reader *bufio.Reader) (*Header, error) {
+	signature, err := reader.Peek(len(v1Signature()))
+	if err != nil {
+		if err == io.EOF { //nolint:errorlint // bufio returns io.EOF unwrapped
+			return nil, nil //nolint:nilnil // no header is a valid outcome
+		}
+
+		return nil, fmt.Errorf("failed to read PROXY protocol header: %w", err)
+	}
+
+	if bytes.Equal(signature, v1Signature()) {
+		return readV1(reader)
+	}
+
+	if !bytes.HasPrefix(v2Signature(), signature) {
+		return nil, nil //nolint:nilnil // no header is a valid outcome
+	}
+
+	signature, err = reader.Peek(len(v2Signature()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PROXY protocol header: %w", err)
+	}
+
+	if !bytes.Equal(signature, v2Signature()) {
+		return nil, nil //nolint:nilnil // no header is a valid outcome
+	}
+
+	return readV2(reader)
+}
+
+// readV1 parses a human-readable version 1 header.
+func readV1(reader *bufio.Reader) (*Header, error) {
+	var line []byte
+
+	for len(line) < maxV1HeaderLength {
+		b, err := reader.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidHeader, err)
+		}
+
+		line = append(line, b)
+
+		if b == '\n' {
+			break
+		}
+	}
+
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, fmt.Errorf("%w: missing CRLF", ErrInvalidHeader)
+	}
+
+	fields := strings.Split(string(line[:len(line)-2]), " ")
+
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return &Header{
+			Version: 1,
+			Local:   true,
+		}, nil
+	}
+
+	if len(fields) != 6 {
+		return nil, fmt.Errorf("%w: expected 6 fields, got %d", ErrInvalidHeader, len(fields))
+	}
+
+	source, err := parseV1Address(fields[1], fields[2], fields[4])
+	if err != nil {
+		return nil, err
+	}
+
+	destination, err := parseV1Address(fields[1], fields[3], fields[5])
+	if err != nil {
+		return nil, err
+	}
+
+	return &Header{
+		Source:      source,
+		Destination: destination,
+		Version:     1,
+	}, nil
+}
+
+// parseV1Address parses an address and port from a version 1 header, checking
+// that the address matches the announced protocol.
+func parseV1Address(protocol, address, port string) (*net.TCPAddr, error) {
+	ip, err := netip.ParseAddr(address)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidHeader, err)
+	}
+
+	switch protocol {
+	case "TCP4":
+		if !ip.Is4() {
+			return nil, fmt.Errorf("%w: %s is not an IPv4 address", ErrInvalidHeader, address)
+		}
+	case "TCP6":
+		if !ip.Is6() {
+			return nil, fmt.Errorf("%w: %s is not an IPv6 address", ErrInvalidHeader, address)
+		}
+	default:
+		return nil, fmt.Errorf("%w: unknown protocol %q", ErrInvalidHeader, protocol)
+	}
+
+	portNumber, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidHeader, err)
+	}
+
+	return net.TCPAddrFromAddrPort(netip.AddrPortFrom(ip, uint16(portNumber))), nil
+}
+
+// readV2 parses a binary version 2 header.
+func readV2(reader *bufio.Reader) (*Header, error) {
+	fixed := make([]byte, v2HeaderLength)
+	if _, err := io.ReadFull(reader, fixed); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidHeader, err)
+	}
+
+	var (
+		version = fixed[12] >> 4
+		command = fixed[12] & 0x0F
+		family  = fixed[13]
+		length  = int(binary.BigEndian.Uint16(fixed[14:16]))
+	)
+
+	if version != 2 {
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedVersion, version)
+	}
+
+	if length > v2MaxAddressLength {
+		return nil, fmt.Errorf("%w: address block too long", ErrInvalidHeader)
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(reader, payload); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidHeader, err)
+	}
+
+	switch command {
+	case commandLocal:
+		return &Header{
+			Version: 2,
+			Local:   true,
+		}, nil
+	case commandProxy:
+	default:
+		return nil, fmt.Errorf("%w: unknown command %d", ErrInvalidHeader, command)
+	}
+
+	var addressLength int
+
+	switch family {
+	case familyTCP4, familyUDP4:
+		addressLength = 4
+	case familyTCP6, familyUDP6:
+		addressLength = 16
+	default:
+		// Unix sockets and unspecified families carry no usable IP address.
+		return &Header{
+			Version: 2,
+			Local:   true,
+		}, nil
+	}
+
+	if len(payload) < 2*addressLength+4 {
+		return nil, fmt.Errorf("%w: address block too short", ErrInvalidHeader)
+	}
+
+	var (
+		sourceIP, _      = netip.AddrFromSlice(payload[:addressLength])
+		destinationIP, _ = netip.AddrFromSlice(payload[addressLength : 2*addressLength])
+		sourcePort       = binary.BigEndian.Uint16(payload[2*addressLength:])
+		destinationPort  = binary.BigEndian.Uint16(payload[2*addressLength+2:])
+	)
+
+	header := &Header{
+		Version: 2,
+	}
+
+	if family == familyUDP4 || family == familyUDP6 {
+		header.Source = net.UDPAddrFromAddrPort(netip.AddrPortFrom(sourceIP, sourcePort))
+		header.Destination = net.UDPAddrFromAddrPort(netip.AddrPortFrom(destinationIP, destinationPort))
+	} else {
+		header.Source = net.TCPAddrFromAddrPort(netip.AddrPortFrom(sourceIP, sourcePort))
+		header.Destination = net.TCPAddrFromAddrPort(netip.AddrPortFrom(destinationIP, destinationPort))
+	}
+
+	return header, nil
+}
@@ -0,0 +1,157 @@
+package proxyproto_test
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"git.sr.ht/~jamesponddotco/accio127/internal/proxyproto"
+)
+
+func v2Header(command, family byte, addresses []byte) []byte {
+	header := []byte{0x0D, 0x0A, 0x0D, 0x0A, 0x00, 0x0D, 0x0A, 0x51, 0x55, 0x49, 0x54, 0x0A}
+	header = append(header, 0x20|command, family, byte(len(addresses)>>8), byte(len(addresses)))
+
+	return append(header, addresses...)
+}
+
+func TestReadHeader(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name            string
+		give            []byte
+		wantSource      string
+		wantDestination string
+		wantLocal       bool
+		wantNil         bool
+		wantRest        string
+		wantErr         error
+	}{
+		{
+			name:            "v1_tcp4",
+			give:            []byte("PROXY TCP4 192.0.2.1 198.51.100.1 56324 443\r\nGET / HTTP/1.1"),
+			wantSource:      "192.0.2.1:56324",
+			wantDestination: "198.51.100.1:443",
+			wantRest:        "GET / HTTP/1.1",
+		},
+		{
+			name:            "v1_tcp6",
+			give:            []byte("PROXY TCP6 2001:db8::1 2001:db8::2 56324 443\r\n"),
+			wantSource:      "[2001:db8::1]:56324",
+			wantDestination: "[2001:db8::2]:443",
+		},
+		{
+			name:      "v1_unknown",
+			give:      []byte("PROXY UNKNOWN\r\n"),
+			wantLocal: true,
+		},
+		{
+			name:    "v1_family_mismatch",
+			give:    []byte("PROXY TCP4 2001:db8::1 198.51.100.1 56324 443\r\n"),
+			wantErr: proxyproto.ErrInvalidHeader,
+		},
+		{
+			name:    "v1_missing_crlf",
+			give:    []byte("PROXY TCP4 192.0.2.1 198.51.100.1 56324 443\n"),
+			wantErr: proxyproto.ErrInvalidHeader,
+		},
+		{
+			name: "v2_tcp4",
+			give: append(v2Header(0x1, 0x11, []byte{
+				192, 0, 2, 1,
+				198, 51, 100, 1,
+				0xDC, 0x04,
+				0x01, 0xBB,
+			}), []byte("rest")...),
+			wantSource:      "192.0.2.1:56324",
+			wantDestination: "198.51.100.1:443",
+			wantRest:        "rest",
+		},
+		{
+			name: "v2_tcp6_with_tlv",
+			give: v2Header(0x1, 0x21, []byte{
+				0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1,
+				0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 2,
+				0xDC, 0x04,
+				0x01, 0xBB,
+				0x04, 0x00, 0x01, 0x00,
+			}),
+			wantSource:      "[2001:db8::1]:56324",
+			wantDestination: "[2001:db8::2]:443",
+		},
+		{
+			name:      "v2_local",
+			give:      v2Header(0x0, 0x00, nil),
+			wantLocal: true,
+		},
+		{
+			name:    "v2_short_address_block",
+			give:    v2Header(0x1, 0x11, []byte{192, 0, 2, 1}),
+			wantErr: proxyproto.ErrInvalidHeader,
+		},
+		{
+			name:     "no_header",
+			give:     []byte("\x16\x03\x01\x02\x00\x01\x00"),
+			wantNil:  true,
+			wantRest: "\x16\x03\x01\x02\x00\x01\x00",
+		},
+		{
+			name:    "empty",
+			give:    nil,
+			wantNil: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			reader := bufio.NewReader(bytes.NewReader(tt.give))
+
+			header, err := proxyproto.ReadHeader(reader)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ReadHeader() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr != nil {
+				return
+			}
+
+			if tt.wantNil {
+				if header != nil {
+					t.Fatalf("ReadHeader() = %+v, want nil", header)
+				}
+			} else {
+				if header == nil {
+					t.Fatal("ReadHeader() = nil, want header")
+				}
+
+				if header.Local != tt.wantLocal {
+					t.Errorf("ReadHeader() Local = %v, want %v", header.Local, tt.wantLocal)
+				}
+
+				if !tt.wantLocal && header.Source.String() != tt.wantSource {
+					t.Errorf("ReadHeader() Source = %s, want %s", header.Source, tt.wantSource)
+				}
+
+				if !tt.wantLocal && header.Destination.String() != tt.wantDestination {
+					t.Errorf("ReadHeader() Destination = %s, want %s", header.Destination, tt.wantDestination)
+				}
+			}
+
+			rest, err := io.ReadAll(reader)
+			if err != nil {
+				t.Fatalf("Failed to read rest of stream: %v", err)
+			}
+
+			if string(rest) != tt.wantRest {
+				t.Errorf("ReadHeader() left %q in the stream, want %q", rest, tt.wantRest)
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+// Package proxyproto implements a net.Listener that understands versions 1 and
+// 2 of the HAProxy PROXY protocol, replacing the remote address of accepted
+// connections with the client address announced by a trusted load balancer.
+//
+// See https://www.haproxy.org/download/2.8/doc/proxy-protocol.txt for the
+// specification.
+package proxyproto
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/netip"
+	"sync"
+	"time"
+
+	"git.sr.ht/~jamesponddotco/xstd-go/xerrors"
+)
+
+const (
+	// ErrInvalidHeader is returned when a connection starts with a malformed
+	// PROXY protocol header.
+	ErrInvalidHeader xerrors.Error = "invalid PROXY protocol header"
+
+	// ErrUnsupportedVersion is returned when a connection uses an unknown
+	// version of the PROXY protocol.
+	ErrUnsupportedVersion xerrors.Error = "unsupported PROXY protocol version"
+
+	// ErrMissingHeader is returned when a connection from an allowed
+	// upstream doesn't start with a PROXY protocol header, and the Listener
+	// requires one.
+	ErrMissingHeader xerrors.Error = "missing PROXY protocol header"
+)
+
+// DefaultTimeout is the default maximum time to wait for the PROXY protocol
+// header.
+const DefaultTimeout time.Duration = 5 * time.Second
+
+// Listener wraps a net.Listener and parses PROXY protocol headers sent by
+// allowed upstreams.
+type Listener struct {
+	net.Listener
+
+	allow   []netip.Prefix
+	timeout time.Duration
+
+	// Required makes the header mandatory for allowed upstreams, whose
+	// connections fail with ErrMissingHeader without one, instead of keeping
+	// the upstream's address. Protocols where the server speaks first need
+	// it, as a header is only told apart from data once the upstream sends
+	// something.
+	Required bool
+}
+
+// NewListener creates a new Listener. Only connections coming from one of the
+// allow prefixes have their PROXY protocol header parsed; everyone else keeps
+// their real remote address. A zero timeout uses DefaultTimeout.
+func NewListener(listener net.Listener, allow []netip.Prefix, timeout time.Duration) *Listener {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	return &Listener{
+		Listener: listener,
+		allow:    allow,
+		timeout:  timeout,
+	}
+}
+
+// Accept implements the net.Listener interface. The PROXY protocol header is
+// parsed lazily, on the first call to Read or RemoteAddr, so that a slow
+// upstream can't block the accept loop.
+func (l *Listener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err //nolint:wrapcheck // callers expect the listener's error as is
+	}
+
+	return &Conn{
+		Conn:     conn,
+		reader:   bufio.NewReader(conn),
+		allowed:  l.allowed(conn.RemoteAddr()),
+		required: l.Required,
+		timeout:  l.timeout,
+	}, nil
+}
+
+// allowed reports whether addr may send PROXY protocol headers.
+func (l *Listener) allowed(addr net.Addr) bool {
+	addrPort, err := netip.ParseAddrPort(addr.String())
+	if err != nil {
+		return false
+	}
+
+	ip := addrPort.Addr().Unmap()
+
+	for _, prefix := range l.allow {
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Conn is a connection accepted by a Listener.
+type Conn struct {
+	net.Conn
+
+	reader     *bufio.Reader
+	remoteAddr net.Addr
+	localAddr  net.Addr
+	err        error
+	once       sync.Once
+	allowed    bool
+	required   bool
+	timeout    time.Duration
+}
+
+// Read implements the net.Conn interface.
+func (c *Conn) Read(b []byte) (int, error) {
+	c.once.Do(c.readHeader)
+
+	if c.err != nil {
+		return 0, c.err
+	}
+
+	return c.reader.Read(b) //nolint:wrapcheck // callers expect the connection's error as is
+}
+
+// RemoteAddr returns the client address announced in the PROXY protocol
+// header, or the real remote address if there wasn't one.
+func (c *Conn) RemoteAddr() net.Addr {
+	c.once.Do(c.readHeader)
+
+	if c.remoteAddr != nil {
+		return c.remoteAddr
+	}
+
+	return c.Conn.RemoteAddr()
+}
+
+// LocalAddr returns the destination address announced in the PROXY protocol
+// header, or the real local address if there wasn't one.
+func (c *Conn) LocalAddr() net.Addr {
+	c.once.Do(c.readHeader)
+
+	if c.localAddr != nil {
+		return c.localAddr
+	}
+
+	return c.Conn.LocalAddr()
+}
+
+// readHeader parses the PROXY protocol header, if the upstream is allowed to
+// send one and did.
+func (c *Conn) readHeader() {
+	if !c.allowed {
+		return
+	}
+
+	if err := c.Conn.SetReadDeadline(time.Now().Add(c.timeout)); err != nil {
+		c.err = fmt.Errorf("failed to set PROXY protocol header deadline: %w", err)
+
+		return
+	}
+
+	header, err := ReadHeader(c.reader)
+	if err != nil {
+		c.err = err
+
+		return
+	}
+
+	if err := c.Conn.SetReadDeadline(time.Time{}); err != nil {
+		c.err = fmt.Errorf("failed to reset read deadline: %w", err)
+
+		return
+	}
+
+	if header == nil && c.required {
+		c.err = ErrMissingHeader
+
+		return
+	}
+
+	if header == nil || header.Local {
+		return
+	}
+
+	c.remoteAddr = header.Source
+	c.localAddr = header.Destination
+}
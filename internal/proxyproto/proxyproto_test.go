@@ -0,0 +1,132 @@
+package proxyproto_test
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"git.sr.ht/~jamesponddotco/accio127/internal/proxyproto"
+)
+
+func TestListener(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name           string
+		allow          []netip.Prefix
+		send           string
+		wantRemoteHost string
+		wantBody       string
+		required       bool
+		wantErr        bool
+	}{
+		{
+			name:           "allowed_upstream_with_header",
+			allow:          []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8")},
+			send:           "PROXY TCP4 192.0.2.1 198.51.100.1 56324 443\r\nhello",
+			wantRemoteHost: "192.0.2.1",
+			wantBody:       "hello",
+		},
+		{
+			name:           "allowed_upstream_without_header",
+			allow:          []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8")},
+			send:           "hello",
+			wantRemoteHost: "127.0.0.1",
+			wantBody:       "hello",
+		},
+		{
+			name:           "allowed_upstream_with_required_header",
+			allow:          []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8")},
+			send:           "PROXY TCP4 192.0.2.1 198.51.100.1 56324 443\r\nhello",
+			wantRemoteHost: "192.0.2.1",
+			wantBody:       "hello",
+			required:       true,
+		},
+		{
+			name:           "allowed_upstream_without_required_header",
+			allow:          []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8")},
+			send:           "hello",
+			wantRemoteHost: "127.0.0.1",
+			required:       true,
+			wantErr:        true,
+		},
+		{
+			name:           "untrusted_upstream_without_required_header",
+			allow:          []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")},
+			send:           "hello",
+			wantRemoteHost: "127.0.0.1",
+			wantBody:       "hello",
+			required:       true,
+		},
+		{
+			name:           "untrusted_upstream_header_ignored",
+			allow:          []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")},
+			send:           "PROXY TCP4 192.0.2.1 198.51.100.1 56324 443\r\n",
+			wantRemoteHost: "127.0.0.1",
+			wantBody:       "PROXY TCP4 192.0.2.1 198.51.100.1 56324 443\r\n",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			inner, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatalf("Failed to listen: %v", err)
+			}
+
+			listener := proxyproto.NewListener(inner, tt.allow, time.Second)
+			listener.Required = tt.required
+
+			defer listener.Close()
+
+			go func() {
+				client, err := net.Dial("tcp", inner.Addr().String())
+				if err != nil {
+					return
+				}
+				defer client.Close()
+
+				client.Write([]byte(tt.send))
+			}()
+
+			conn, err := listener.Accept()
+			if err != nil {
+				t.Fatalf("Failed to accept connection: %v", err)
+			}
+			defer conn.Close()
+
+			host, _, err := net.SplitHostPort(conn.RemoteAddr().String())
+			if err != nil {
+				t.Fatalf("Failed to split remote address: %v", err)
+			}
+
+			if host != tt.wantRemoteHost {
+				t.Errorf("RemoteAddr() host = %s, want %s", host, tt.wantRemoteHost)
+			}
+
+			body, err := io.ReadAll(conn)
+			if tt.wantErr {
+				if !errors.Is(err, proxyproto.ErrMissingHeader) {
+					t.Errorf("Read() error = %v, want %v", err, proxyproto.ErrMissingHeader)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Failed to read connection: %v", err)
+			}
+
+			if string(body) != tt.wantBody {
+				t.Errorf("Read() = %q, want %q", body, tt.wantBody)
+			}
+		})
+	}
+}
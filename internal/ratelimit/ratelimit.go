@@ -0,0 +1,159 @@
+// Package ratelimit limits how often clients can make requests with token
+// buckets, keeping memory bounded by evicting the least recently used
+// buckets.
+package ratelimit
+
+import (
+	"container/list"
+	"math"
+	"sync"
+	"time"
+)
+
+// Rule is how many requests a client can make.
+type Rule struct {
+	// Period is the time it takes for Requests tokens to be added to a
+	// bucket.
+	Period time.Duration
+
+	// Requests is the number of requests allowed per Period.
+	Requests int
+
+	// Burst is the number of requests a client can make at once, the size
+	// of the bucket.
+	Burst int
+}
+
+// Result is the outcome of a request to the limiter.
+type Result struct {
+	// Reset is how long it takes for the bucket to be full again.
+	Reset time.Duration
+
+	// RetryAfter is how long the client must wait before its next request
+	// is allowed. It's zero if the request was allowed.
+	RetryAfter time.Duration
+
+	// Limit is the size of the bucket.
+	Limit int
+
+	// Remaining is the number of requests the client can still make at
+	// once.
+	Remaining int
+
+	// Allowed reports whether the request is allowed.
+	Allowed bool
+}
+
+// Limiter limits requests per key, such as a client's IP address.
+type Limiter struct {
+	buckets map[string]*list.Element
+	lru     *list.List
+	rule    Rule
+	rate    float64
+	maxKeys int
+	mu      sync.Mutex
+}
+
+// bucket holds the tokens of a single key.
+type bucket struct {
+	updated time.Time
+	key     string
+	tokens  float64
+}
+
+// New creates a new Limiter applying rule to each key, and tracking up to
+// maxKeys keys. When a new key comes in with maxKeys keys tracked, the least
+// recently used one is forgotten, which gives it a full bucket.
+func New(rule Rule, maxKeys int) *Limiter {
+	if maxKeys < 1 {
+		maxKeys = 1
+	}
+
+	return &Limiter{
+		buckets: make(map[string]*list.Element),
+		lru:     list.New(),
+		rule:    rule,
+		rate:    float64(rule.Requests) / rule.Period.Seconds(),
+		maxKeys: maxKeys,
+	}
+}
+
+// Rule returns the rule the limiter applies.
+func (l *Limiter) Rule() Rule {
+	return l.rule
+}
+
+// Len returns the number of keys tracked.
+func (l *Limiter) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.lru.Len()
+}
+
+// Allow takes a token from the bucket of key at now, and reports whether
+// there was one.
+func (l *Limiter) Allow(key string, now time.Time) Result {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b := l.bucket(key, now)
+
+	// Refill the bucket for the time elapsed since it was last used.
+	if elapsed := now.Sub(b.updated).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(float64(l.rule.Burst), b.tokens+elapsed*l.rate)
+		b.updated = now
+	}
+
+	result := Result{
+		Limit: l.rule.Burst,
+	}
+
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = l.duration(1 - b.tokens)
+	}
+
+	result.Remaining = int(b.tokens)
+	result.Reset = l.duration(float64(l.rule.Burst) - b.tokens)
+
+	return result
+}
+
+// bucket returns the bucket of key, creating it with a full bucket and
+// evicting the least recently used one if needed.
+func (l *Limiter) bucket(key string, now time.Time) *bucket {
+	if element, ok := l.buckets[key]; ok {
+		l.lru.MoveToFront(element)
+
+		return element.Value.(*bucket) //nolint:forcetypeassert // the list only holds buckets
+	}
+
+	if l.lru.Len() >= l.maxKeys {
+		oldest := l.lru.Back()
+
+		l.lru.Remove(oldest)
+		delete(l.buckets, oldest.Value.(*bucket).key) //nolint:forcetypeassert // the list only holds buckets
+	}
+
+	b := &bucket{
+		updated: now,
+		key:     key,
+		tokens:  float64(l.rule.Burst),
+	}
+
+	l.buckets[key] = l.lru.PushFront(b)
+
+	return b
+}
+
+// duration returns how long it takes for tokens to be added to a bucket.
+func (l *Limiter) duration(tokens float64) time.Duration {
+	if tokens <= 0 || l.rate <= 0 {
+		return 0
+	}
+
+	return time.Duration(tokens / l.rate * float64(time.Second))
+}
@@ -0,0 +1,99 @@
+package ratelimit_test
+
+import (
+	"testing"
+	"time"
+
+	"git.sr.ht/~jamesponddotco/accio127/internal/ratelimit"
+)
+
+func TestLimiter_Allow(t *testing.T) {
+	t.Parallel()
+
+	var (
+		start   = time.Date(2023, time.June, 1, 0, 0, 0, 0, time.UTC)
+		limiter = ratelimit.New(ratelimit.Rule{Period: time.Minute, Requests: 60, Burst: 3}, 10)
+	)
+
+	tests := []struct {
+		name  string
+		key   string
+		after time.Duration
+		want  ratelimit.Result
+	}{
+		{
+			name: "first_request",
+			key:  "a",
+			want: ratelimit.Result{Reset: time.Second, Limit: 3, Remaining: 2, Allowed: true},
+		},
+		{
+			name: "second_request",
+			key:  "a",
+			want: ratelimit.Result{Reset: 2 * time.Second, Limit: 3, Remaining: 1, Allowed: true},
+		},
+		{
+			name: "third_request",
+			key:  "a",
+			want: ratelimit.Result{Reset: 3 * time.Second, Limit: 3, Remaining: 0, Allowed: true},
+		},
+		{
+			name: "bucket_empty",
+			key:  "a",
+			want: ratelimit.Result{Reset: 3 * time.Second, RetryAfter: time.Second, Limit: 3, Remaining: 0},
+		},
+		{
+			name: "other_key",
+			key:  "b",
+			want: ratelimit.Result{Reset: time.Second, Limit: 3, Remaining: 2, Allowed: true},
+		},
+		{
+			name:  "refilled",
+			key:   "a",
+			after: 1500 * time.Millisecond,
+			want:  ratelimit.Result{Reset: 2500 * time.Millisecond, Limit: 3, Remaining: 0, Allowed: true},
+		},
+	}
+
+	// The cases share the limiter, so they can't run in parallel.
+	now := start
+
+	for _, tt := range tests {
+		now = now.Add(tt.after)
+
+		got := limiter.Allow(tt.key, now)
+		if got != tt.want {
+			t.Errorf("%s: Allow(%q) = %+v, want %+v", tt.name, tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestLimiter_Eviction(t *testing.T) {
+	t.Parallel()
+
+	var (
+		now     = time.Date(2023, time.June, 1, 0, 0, 0, 0, time.UTC)
+		limiter = ratelimit.New(ratelimit.Rule{Period: time.Hour, Requests: 1, Burst: 1}, 2)
+	)
+
+	limiter.Allow("a", now)
+	limiter.Allow("b", now)
+
+	// Using "a" again makes "b" the least recently used key.
+	if got := limiter.Allow("a", now); got.Allowed {
+		t.Fatalf("Allow(%q) = %+v, want denied", "a", got)
+	}
+
+	limiter.Allow("c", now)
+
+	if got := limiter.Len(); got != 2 {
+		t.Errorf("Len() = %d, want %d", got, 2)
+	}
+
+	if got := limiter.Allow("b", now); !got.Allowed {
+		t.Errorf("Allow(%q) = %+v, want allowed after eviction", "b", got)
+	}
+
+	if got := limiter.Allow("c", now); got.Allowed {
+		t.Errorf("Allow(%q) = %+v, want denied", "c", got)
+	}
+}
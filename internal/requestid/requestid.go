@@ -0,0 +1,92 @@
+// Package requestid identifies requests, so that log lines and error responses
+// can be traced back to the request that caused them.
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Header is the header request IDs are read from and written to.
+const Header string = "X-Request-ID"
+
+// MaxLength is the length of the longest request ID accepted from clients.
+const MaxLength int = 128
+
+// idLength is the number of random bytes in generated request IDs.
+const idLength int = 16
+
+// contextKey is the type of the keys used to store values in a context.
+type contextKey int
+
+const (
+	// idKey is the key of the request ID.
+	idKey contextKey = iota
+
+	// loggerKey is the key of the per-request logger.
+	loggerKey
+)
+
+// New generates a random request ID.
+func New() string {
+	id := make([]byte, idLength)
+
+	if _, err := rand.Read(id); err != nil {
+		// Request IDs only need to be unique enough to tell requests apart
+		// in the logs, so the clock is good enough if randomness runs out.
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+
+	return hex.EncodeToString(id)
+}
+
+// Valid reports whether id is acceptable as a request ID. IDs must be at most
+// MaxLength characters long, and made of letters, digits, and the characters
+// in UUIDs and base64, so that they can't be used to forge log lines or
+// headers.
+func Valid(id string) bool {
+	if id == "" || len(id) > MaxLength {
+		return false
+	}
+
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':', c == '+', c == '/', c == '=':
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// NewContext returns a copy of ctx holding id, and logger with id attached to
+// it.
+func NewContext(ctx context.Context, id string, logger *zap.Logger) context.Context {
+	ctx = context.WithValue(ctx, idKey, id)
+
+	return context.WithValue(ctx, loggerKey, logger.With(zap.String("requestId", id)))
+}
+
+// FromContext returns the request ID in ctx, or an empty string if there's
+// none.
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(idKey).(string)
+
+	return id
+}
+
+// Logger returns the per-request logger in ctx, or fallback if there's none.
+func Logger(ctx context.Context, fallback *zap.Logger) *zap.Logger {
+	if logger, ok := ctx.Value(loggerKey).(*zap.Logger); ok {
+		return logger
+	}
+
+	return fallback
+}
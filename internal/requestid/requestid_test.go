@@ -0,0 +1,109 @@
+package requestid_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"git.sr.ht/~jamesponddotco/accio127/internal/requestid"
+	"go.uber.org/zap"
+)
+
+func TestValid(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		give string
+		want bool
+	}{
+		{
+			name: "hex",
+			give: "0af7651916cd43dd8448eb211c80319c",
+			want: true,
+		},
+		{
+			name: "uuid",
+			give: "123e4567-e89b-12d3-a456-426614174000",
+			want: true,
+		},
+		{
+			name: "base64",
+			give: "aGVsbG8+d29ybGQ/Lw==",
+			want: true,
+		},
+		{
+			name: "empty",
+			give: "",
+			want: false,
+		},
+		{
+			name: "too_long",
+			give: strings.Repeat("a", requestid.MaxLength+1),
+			want: false,
+		},
+		{
+			name: "newline",
+			give: "abc\ndef",
+			want: false,
+		},
+		{
+			name: "space",
+			give: "abc def",
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := requestid.Valid(tt.give); got != tt.want {
+				t.Errorf("Valid(%q) = %v, want %v", tt.give, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	first, second := requestid.New(), requestid.New()
+
+	if !requestid.Valid(first) {
+		t.Errorf("New() = %q, want a valid request ID", first)
+	}
+
+	if first == second {
+		t.Errorf("New() = %q twice, want different request IDs", first)
+	}
+}
+
+func TestNewContext(t *testing.T) {
+	t.Parallel()
+
+	var (
+		fallback = zap.NewNop()
+		ctx      = context.Background()
+	)
+
+	if got := requestid.FromContext(ctx); got != "" {
+		t.Errorf("FromContext() = %q, want empty request ID", got)
+	}
+
+	if got := requestid.Logger(ctx, fallback); got != fallback {
+		t.Errorf("Logger() = %v, want fallback logger", got)
+	}
+
+	ctx = requestid.NewContext(ctx, "abc123", fallback)
+
+	if got := requestid.FromContext(ctx); got != "abc123" {
+		t.Errorf("FromContext() = %q, want %q", got, "abc123")
+	}
+
+	if got := requestid.Logger(ctx, fallback); got == fallback {
+		t.Errorf("Logger() = fallback logger, want per-request logger")
+	}
+}
@@ -0,0 +1,69 @@
+// Package retry backs off between attempts at network operations that failed
+// for reasons that may go away by themselves, such as the process running out
+// of file descriptors.
+package retry
+
+import (
+	"errors"
+	"net"
+	"syscall"
+	"time"
+)
+
+const (
+	// MinDelay is the delay before the first retry.
+	MinDelay time.Duration = 5 * time.Millisecond
+
+	// MaxDelay is the longest delay between retries.
+	MaxDelay time.Duration = time.Second
+)
+
+// Backoff doubles the delay between retries from MinDelay up to MaxDelay, the
+// way net/http.Server does between failed calls to Accept. The zero value is
+// ready to use.
+type Backoff struct {
+	delay time.Duration
+}
+
+// Next returns how long to wait before the next retry.
+func (b *Backoff) Next() time.Duration {
+	if b.delay == 0 {
+		b.delay = MinDelay
+	} else {
+		b.delay *= 2
+	}
+
+	if b.delay > MaxDelay {
+		b.delay = MaxDelay
+	}
+
+	return b.delay
+}
+
+// Reset starts the delays over from MinDelay, once an attempt succeeded.
+func (b *Backoff) Reset() {
+	b.delay = 0
+}
+
+// Temporary reports whether err may go away if the operation is retried: if
+// it's a timeout, if the process or the system ran out of file descriptors,
+// buffers, or memory, or if a connection was aborted before it was accepted.
+func Temporary(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	var errno syscall.Errno
+	if !errors.As(err, &errno) {
+		return false
+	}
+
+	switch errno { //nolint:exhaustive // every other errno is permanent
+	case syscall.EMFILE, syscall.ENFILE, syscall.ENOBUFS, syscall.ENOMEM,
+		syscall.ECONNABORTED, syscall.ECONNRESET, syscall.EINTR, syscall.EAGAIN:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,108 @@
+package retry_test
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"git.sr.ht/~jamesponddotco/accio127/internal/retry"
+)
+
+func TestBackoff(t *testing.T) {
+	t.Parallel()
+
+	var backoff retry.Backoff
+
+	want := []time.Duration{
+		5 * time.Millisecond,
+		10 * time.Millisecond,
+		20 * time.Millisecond,
+		40 * time.Millisecond,
+		80 * time.Millisecond,
+		160 * time.Millisecond,
+		320 * time.Millisecond,
+		640 * time.Millisecond,
+		time.Second,
+		time.Second,
+	}
+
+	for i, wantDelay := range want {
+		if got := backoff.Next(); got != wantDelay {
+			t.Errorf("Next() #%d = %v, want %v", i+1, got, wantDelay)
+		}
+	}
+
+	backoff.Reset()
+
+	if got := backoff.Next(); got != retry.MinDelay {
+		t.Errorf("Next() after Reset() = %v, want %v", got, retry.MinDelay)
+	}
+}
+
+func TestTemporary(t *testing.T) {
+	t.Parallel()
+
+	acceptErr := func(errno syscall.Errno) error {
+		return &net.OpError{Op: "accept", Net: "tcp", Err: os.NewSyscallError("accept4", errno)}
+	}
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{
+			name: "too_many_open_files",
+			err:  acceptErr(syscall.EMFILE),
+			want: true,
+		},
+		{
+			name: "file_table_overflow",
+			err:  acceptErr(syscall.ENFILE),
+			want: true,
+		},
+		{
+			name: "connection_aborted",
+			err:  acceptErr(syscall.ECONNABORTED),
+			want: true,
+		},
+		{
+			name: "wrapped",
+			err:  fmt.Errorf("failed to accept connection: %w", acceptErr(syscall.ENOBUFS)),
+			want: true,
+		},
+		{
+			name: "timeout",
+			err:  os.ErrDeadlineExceeded,
+			want: true,
+		},
+		{
+			name: "closed",
+			err:  net.ErrClosed,
+		},
+		{
+			name: "invalid_argument",
+			err:  acceptErr(syscall.EINVAL),
+		},
+		{
+			name: "other",
+			err:  errors.New("something else"),
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := retry.Temporary(tt.err); got != tt.want {
+				t.Errorf("Temporary(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
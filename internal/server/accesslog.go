@@ -0,0 +1,74 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"git.sr.ht/~jamesponddotco/accio127/internal/accesslog"
+	"git.sr.ht/~jamesponddotco/accio127/internal/config"
+	"git.sr.ht/~jamesponddotco/accio127/internal/iphash"
+	"git.sr.ht/~jamesponddotco/accio127/internal/server/handler"
+)
+
+// openAccessLog opens the access log file configured in cfg, or reopens it if
+// it's already open, so that it can be rotated or moved on reload. It does
+// nothing if the access log is disabled.
+func (s *Server) openAccessLog(cfg *config.Config) error {
+	if !cfg.AccessLog.Enabled {
+		return nil
+	}
+
+	if s.accessLogFile != nil {
+		return s.accessLogFile.Reopen(cfg.AccessLog.Path) //nolint:wrapcheck // already wrapped by accesslog
+	}
+
+	file, err := accesslog.OpenFile(cfg.AccessLog.Path)
+	if err != nil {
+		return err //nolint:wrapcheck // already wrapped by accesslog
+	}
+
+	s.accessLogFile = file
+
+	return nil
+}
+
+// accessLogClient returns a function giving the client's IP address as it
+// should appear in the access log, according to cfg.
+func accessLogClient(cfg *config.Config, hasher *iphash.Hasher) func(r *http.Request) string {
+	address := accessLogAddress(cfg, hasher)
+
+	return func(r *http.Request) string {
+		if cfg.AccessLog.IP == config.AccessLogIPOmit {
+			return ""
+		}
+
+		clientIP, err := handler.ClientIP(r, cfg.Proxy)
+		if err != nil {
+			return ""
+		}
+
+		return address(clientIP)
+	}
+}
+
+// accessLogAddress returns a function giving ip as it should appear in the
+// access log, according to cfg.
+func accessLogAddress(cfg *config.Config, hasher *iphash.Hasher) func(ip string) string {
+	return func(ip string) string {
+		switch cfg.AccessLog.IP {
+		case config.AccessLogIPOmit:
+			return ""
+		case config.AccessLogIPRaw:
+			return ip
+		case config.AccessLogIPHashed:
+			return hasher.Hash(ip, time.Now()).Hash
+		default:
+			prefix := handler.AnonymizeIP(ip, cfg.Anonymize.IPv4.Length, cfg.Anonymize.IPv6.Length)
+			if !prefix.IsValid() {
+				return ""
+			}
+
+			return prefix.Addr().String()
+		}
+	}
+}
@@ -0,0 +1,305 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"git.sr.ht/~jamesponddotco/accio127/internal/accesslog"
+	"git.sr.ht/~jamesponddotco/accio127/internal/config"
+	"git.sr.ht/~jamesponddotco/accio127/internal/database"
+	"git.sr.ht/~jamesponddotco/accio127/internal/echo"
+	"git.sr.ht/~jamesponddotco/accio127/internal/proxyproto"
+	"go.uber.org/zap"
+)
+
+// echoServers holds the servers returning the client's IP address without
+// HTTP, along with the sockets they serve. Servers are nil if disabled.
+type echoServers struct {
+	stun         *echo.STUNServer
+	stunConn     net.PacketConn
+	stunListener net.Listener
+
+	tcp         *echo.TCPServer
+	tcpListener net.Listener
+
+	udp     *echo.UDPServer
+	udpConn net.PacketConn
+
+	dns     *echo.DNSServer
+	dnsConn net.PacketConn
+
+	// log holds how hits are written to the access log, and limiters the
+	// rate limiters of the HTTP servers, which the STUN server shares.
+	// They're replaced on reload.
+	log      atomic.Pointer[echoLog]
+	limiters atomic.Pointer[rateLimiters]
+}
+
+// echoLog is how hits on the echo servers are written to the access log.
+type echoLog struct {
+	// accessLog is nil if the access log is disabled.
+	accessLog *accesslog.Logger
+
+	// address gives the client's IP address as it should appear in the
+	// access log.
+	address func(ip string) string
+}
+
+// newEchoServers creates the echo servers enabled in cfg.
+func (s *Server) newEchoServers(cfg *config.Config) error {
+	if cfg.Echo.TCP.Enabled {
+		s.echoes.tcp = echo.NewTCPServer(time.Duration(cfg.Echo.TCP.Timeout), s.recordEcho, s.logger)
+	}
+
+	if cfg.Echo.UDP.Enabled {
+		s.echoes.udp = echo.NewUDPServer(s.recordEcho, s.logger)
+	}
+
+	if cfg.Echo.DNS.Enabled {
+		dns, err := echo.NewDNSServer(cfg.Echo.DNS.Name, s.recordEcho, s.logger)
+		if err != nil {
+			return fmt.Errorf("failed to create DNS echo server: %w", err)
+		}
+
+		s.echoes.dns = dns
+	}
+
+	if cfg.STUN.Enabled {
+		s.echoes.stun = echo.NewSTUNServer(time.Duration(cfg.STUN.Timeout), s.allowSTUN, s.recordEcho, s.logger)
+	}
+
+	return s.storeEchoLog(cfg)
+}
+
+// allowSTUN reports whether a STUN request from client is allowed by the rate
+// limiters.
+func (s *Server) allowSTUN(client netip.Addr) bool {
+	limiters := s.echoes.limiters.Load()
+	if limiters == nil {
+		return true
+	}
+
+	return limiters.allowAddr(client)
+}
+
+// storeEchoLog replaces how hits on the echo servers are written to the
+// access log with the settings in cfg.
+func (s *Server) storeEchoLog(cfg *config.Config) error {
+	hasher, err := s.newHasher(cfg)
+	if err != nil {
+		return err
+	}
+
+	log := &echoLog{
+		address: accessLogAddress(cfg, hasher),
+	}
+
+	if cfg.AccessLog.Enabled {
+		log.accessLog = accesslog.New(s.accessLogFile, cfg.AccessLog.Format)
+	}
+
+	s.echoes.log.Store(log)
+
+	return nil
+}
+
+// listenEcho opens the sockets of the echo servers. TCP listeners are wrapped
+// to parse PROXY protocol headers if enabled. The TCP echo server speaks
+// first, so it requires allowed upstreams to send a header, rather than
+// waiting for data that never comes to tell whether there's one. If any
+// socket fails to open, the ones already opened are closed.
+func (s *Server) listenEcho(cfg *config.Config) error {
+	var err error
+
+	defer func() {
+		if err != nil {
+			s.closeEcho()
+		}
+	}()
+
+	if s.echoes.tcp != nil {
+		s.echoes.tcpListener, err = s.listen(config.Listener{
+			Network: config.ListenerNetworkTCP,
+			Address: cfg.Echo.TCP.Address,
+		})
+		if err != nil {
+			return err
+		}
+
+		if proxied, ok := s.echoes.tcpListener.(*proxyproto.Listener); ok {
+			proxied.Required = true
+		}
+
+		s.logListening("tcp", cfg.Echo.TCP.Address, echo.ProtocolTCP)
+	}
+
+	if s.echoes.udp != nil {
+		s.echoes.udpConn, err = net.ListenPacket("udp", cfg.Echo.UDP.Address)
+		if err != nil {
+			return fmt.Errorf("failed to listen on %s: %w", cfg.Echo.UDP.Address, err)
+		}
+
+		s.logListening("udp", cfg.Echo.UDP.Address, echo.ProtocolUDP)
+	}
+
+	if s.echoes.dns != nil {
+		s.echoes.dnsConn, err = net.ListenPacket("udp", cfg.Echo.DNS.Address)
+		if err != nil {
+			return fmt.Errorf("failed to listen on %s: %w", cfg.Echo.DNS.Address, err)
+		}
+
+		s.logListening("udp", cfg.Echo.DNS.Address, echo.ProtocolDNS)
+	}
+
+	if s.echoes.stun != nil {
+		s.echoes.stunConn, err = net.ListenPacket("udp", cfg.STUN.Address)
+		if err != nil {
+			return fmt.Errorf("failed to listen on %s: %w", cfg.STUN.Address, err)
+		}
+
+		s.logListening("udp", cfg.STUN.Address, echo.ProtocolSTUN)
+
+		s.echoes.stunListener, err = s.listen(config.Listener{
+			Network: config.ListenerNetworkTCP,
+			Address: cfg.STUN.Address,
+		})
+		if err != nil {
+			return err
+		}
+
+		s.logListening("tcp", cfg.STUN.Address, echo.ProtocolSTUN)
+	}
+
+	return nil
+}
+
+// serveEcho serves the echo servers in the background. They're optional, so
+// an unexpected error stops the server it came from, and is logged, rather
+// than the HTTP listeners.
+func (s *Server) serveEcho() {
+	serve := func(protocol, network string, serve func() error) {
+		go func() {
+			if err := serve(); err != nil {
+				s.logger.Error(
+					"Echo server stopped",
+					zap.String("protocol", protocol),
+					zap.String("network", network),
+					zap.Error(err),
+				)
+			}
+		}()
+	}
+
+	if s.echoes.tcpListener != nil {
+		serve(echo.ProtocolTCP, "tcp", func() error { return s.echoes.tcp.Serve(s.echoes.tcpListener) })
+	}
+
+	if s.echoes.udpConn != nil {
+		serve(echo.ProtocolUDP, "udp", func() error { return s.echoes.udp.Serve(s.echoes.udpConn) })
+	}
+
+	if s.echoes.dnsConn != nil {
+		serve(echo.ProtocolDNS, "udp", func() error { return s.echoes.dns.Serve(s.echoes.dnsConn) })
+	}
+
+	if s.echoes.stunConn != nil {
+		serve(echo.ProtocolSTUN, "udp", func() error { return s.echoes.stun.ServeUDP(s.echoes.stunConn) })
+	}
+
+	if s.echoes.stunListener != nil {
+		serve(echo.ProtocolSTUN, "tcp", func() error { return s.echoes.stun.ServeTCP(s.echoes.stunListener) })
+	}
+}
+
+// closeEcho closes the sockets of the echo servers, then waits for the TCP
+// connections being served, which are closed once idle for their timeout.
+func (s *Server) closeEcho() error {
+	var closed []error
+
+	if s.echoes.tcpListener != nil {
+		closed = append(closed, s.echoes.tcpListener.Close())
+	}
+
+	if s.echoes.udpConn != nil {
+		closed = append(closed, s.echoes.udpConn.Close())
+	}
+
+	if s.echoes.dnsConn != nil {
+		closed = append(closed, s.echoes.dnsConn.Close())
+	}
+
+	if s.echoes.stunConn != nil {
+		closed = append(closed, s.echoes.stunConn.Close())
+	}
+
+	if s.echoes.stunListener != nil {
+		closed = append(closed, s.echoes.stunListener.Close())
+	}
+
+	if s.echoes.tcp != nil {
+		s.echoes.tcp.Wait()
+	}
+
+	if s.echoes.stun != nil {
+		s.echoes.stun.Wait()
+	}
+
+	var err error
+
+	for _, closeErr := range closed {
+		if closeErr != nil && !errors.Is(closeErr, net.ErrClosed) {
+			err = errors.Join(err, closeErr)
+		}
+	}
+
+	return err
+}
+
+// recordEcho counts hit in the access counter and writes it to the access
+// log, if it's enabled. Hits are counted under the name of their protocol,
+// and logged with a request line made of their method, the name and type
+// asked for or a dash, and their protocol, such as "ECHO - TCP" or "QUERY
+// ip.accio127.com. TXT DNS".
+func (s *Server) recordEcho(hit *echo.Hit) {
+	family := database.FamilyIPv4
+	if hit.Client.Is6() {
+		family = database.FamilyIPv6
+	}
+
+	s.db.Increment(database.Access{
+		Time:     hit.Time,
+		Endpoint: hit.Protocol,
+		Family:   family,
+		Format:   hit.Format,
+	})
+
+	log := s.echoes.log.Load()
+	if log == nil || log.accessLog == nil {
+		return
+	}
+
+	path := hit.Query
+	if path == "" {
+		path = "-"
+	}
+
+	entry := &accesslog.Entry{
+		Time:    hit.Time,
+		Client:  log.address(hit.Client.String()),
+		Method:  hit.Method,
+		Path:    path,
+		Proto:   strings.ToUpper(hit.Protocol),
+		Route:   hit.Protocol,
+		Latency: hit.Latency,
+		Bytes:   int64(hit.Bytes),
+	}
+
+	if err := log.accessLog.Log(entry); err != nil {
+		s.logger.Error("Failed to write access log", zap.Error(err))
+	}
+}
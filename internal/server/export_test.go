@@ -0,0 +1,18 @@
+package server
+
+import (
+	"net"
+
+	"go.uber.org/zap"
+)
+
+// Dispatch starts a dispatcher accepting connections on listener and returns
+// the generation they're handed to.
+func Dispatch(listener net.Listener, logger *zap.Logger) net.Listener {
+	d := newDispatcher(listener, logger)
+	gen := d.next()
+
+	go d.run()
+
+	return gen
+}
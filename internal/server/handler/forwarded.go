@@ -0,0 +1,91 @@
+package handler
+
+import "strings"
+
+// ForwardedFor extracts the "for" parameter of every element of RFC 7239
+// Forwarded header values, in order. Quoted values are unquoted, and elements
+// without a "for" parameter yield an empty string so that callers walking the
+// list can tell a hop is missing.
+func ForwardedFor(values []string) []string {
+	var entries []string
+
+	for _, value := range values {
+		for _, element := range splitQuoted(value, ',') {
+			if strings.TrimSpace(element) == "" {
+				continue
+			}
+
+			var forValue string
+
+			for _, pair := range splitQuoted(element, ';') {
+				key, val, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if !ok || !strings.EqualFold(strings.TrimSpace(key), "for") {
+					continue
+				}
+
+				forValue = unquote(strings.TrimSpace(val))
+
+				break
+			}
+
+			entries = append(entries, forValue)
+		}
+	}
+
+	return entries
+}
+
+// splitQuoted splits s on sep, ignoring separators inside quoted strings.
+func splitQuoted(s string, sep byte) []string {
+	var (
+		parts   []string
+		start   int
+		quoted  bool
+		escaped bool
+	)
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+
+		switch {
+		case escaped:
+			escaped = false
+		case quoted && c == '\\':
+			escaped = true
+		case c == '"':
+			quoted = !quoted
+		case !quoted && c == sep:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+
+	return append(parts, s[start:])
+}
+
+// unquote removes the quotes and escapes of an RFC 7230 quoted-string. Tokens
+// are returned unchanged.
+func unquote(s string) string {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return s
+	}
+
+	var (
+		builder strings.Builder
+		escaped bool
+	)
+
+	for i := 1; i < len(s)-1; i++ {
+		if !escaped && s[i] == '\\' {
+			escaped = true
+
+			continue
+		}
+
+		escaped = false
+
+		builder.WriteByte(s[i])
+	}
+
+	return builder.String()
+}
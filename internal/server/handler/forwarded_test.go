@@ -0,0 +1,56 @@
+package handler_test
+
+import (
+	"reflect"
+	"testing"
+
+	"git.sr.ht/~jamesponddotco/accio127/internal/server/handler"
+)
+
+func TestForwardedFor(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		give []string
+		want []string
+	}{
+		{
+			name: "single_element",
+			give: []string{"for=192.0.2.43"},
+			want: []string{"192.0.2.43"},
+		},
+		{
+			name: "multiple_elements_and_params",
+			give: []string{`for=192.0.2.43;proto=http, For="[2001:db8:cafe::17]:4711";by=203.0.113.43`},
+			want: []string{"192.0.2.43", "[2001:db8:cafe::17]:4711"},
+		},
+		{
+			name: "multiple_header_lines",
+			give: []string{"for=192.0.2.43", "for=198.51.100.17"},
+			want: []string{"192.0.2.43", "198.51.100.17"},
+		},
+		{
+			name: "quoted_separator",
+			give: []string{`for="_hidden,;x";proto=https`},
+			want: []string{"_hidden,;x"},
+		},
+		{
+			name: "element_without_for",
+			give: []string{"proto=https, for=192.0.2.43"},
+			want: []string{"", "192.0.2.43"},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := handler.ForwardedFor(tt.give); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ForwardedFor(%q) = %q, want %q", tt.give, got, tt.want)
+			}
+		})
+	}
+}
@@ -1 +1,171 @@
+// Package handler contains the HTTP handlers for the service.
 package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/netip"
+	"strings"
+	"time"
+
+	"git.sr.ht/~jamesponddotco/accio127/internal/config"
+	"git.sr.ht/~jamesponddotco/accio127/internal/database"
+	"git.sr.ht/~jamesponddotco/accio127/internal/endpoint"
+	apierror "git.sr.ht/~jamesponddotco/accio127/internal/errors"
+	"git.sr.ht/~jamesponddotco/accio127/internal/server/model"
+	"git.sr.ht/~jamesponddotco/accio127/internal/server/render"
+	"git.sr.ht/~jamesponddotco/xstd-go/xnet/xhttp"
+	"github.com/julienschmidt/httprouter"
+	"go.uber.org/zap"
+)
+
+// negotiate picks the renderer for the request, replying with a 406 Not
+// Acceptable error if there isn't one. It returns nil if the request was
+// already answered.
+func negotiate(w http.ResponseWriter, r *http.Request, negotiator *render.Negotiator, logger *zap.Logger) render.Renderer {
+	w.Header().Add(xhttp.Vary, xhttp.Accept)
+	w.Header().Add(xhttp.Vary, xhttp.ContentType)
+
+	renderer, err := negotiator.Negotiate(r)
+	if err != nil {
+		apierror.JSON(w, r, logger, apierror.ErrorResponse{
+			Code:    http.StatusNotAcceptable,
+			Message: "Requested format is not available. Supported media types: " + strings.Join(negotiator.MediaTypes(), ", ") + ".",
+		})
+
+		return nil
+	}
+
+	return renderer
+}
+
+// targetIP returns the IP address a request asks about: the address in the
+// route parameter for lookups, or the client's address otherwise. It replies
+// with an error and returns false if there isn't a valid address.
+func targetIP(w http.ResponseWriter, r *http.Request, ps httprouter.Params, proxies config.Proxies, logger *zap.Logger) (string, bool) {
+	if !isLookup(ps) {
+		ip, err := ClientIP(r, proxies)
+		if err != nil {
+			logger.Error("Failed to get client IP address", zap.Error(err))
+
+			apierror.JSON(w, r, logger, apierror.ErrorResponse{
+				Code:    http.StatusInternalServerError,
+				Type:    apierror.TypeIPParseFailure,
+				Message: "Failed to get IP address. Please try again later.",
+			})
+
+			return "", false
+		}
+
+		return ip, true
+	}
+
+	addr, err := netip.ParseAddr(ps.ByName(endpoint.AddressParam))
+	if err != nil {
+		apierror.JSON(w, r, logger, apierror.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Type:    apierror.TypeInvalidIP,
+			Message: "Invalid IP address. Please use a valid IPv4 or IPv6 address.",
+		})
+
+		return "", false
+	}
+
+	return addr.Unmap().WithZone("").String(), true
+}
+
+// isLookup reports whether the request asks about an arbitrary address rather
+// than the client's.
+func isLookup(ps httprouter.Params) bool {
+	return ps.ByName(endpoint.AddressParam) != ""
+}
+
+// lookupRoute returns lookup if the request asks about an arbitrary address,
+// or route otherwise.
+func lookupRoute(ps httprouter.Params, route, lookup string) string {
+	if isLookup(ps) {
+		return lookup
+	}
+
+	return route
+}
+
+// writeIP renders ip using renderer and writes it to the response. It returns
+// false if the response had to be replaced by an error.
+func writeIP(w http.ResponseWriter, r *http.Request, renderer render.Renderer, ip *model.IP, logger *zap.Logger) bool {
+	if ip == nil {
+		logger.Error("Failed to parse client IP address")
+
+		apierror.JSON(w, r, logger, apierror.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Type:    apierror.TypeIPParseFailure,
+			Message: "Failed to parse IP address. Please try again later.",
+		})
+
+		return false
+	}
+
+	body, err := renderer.Render(r, ip)
+	if err != nil {
+		if errors.Is(err, render.ErrInvalidCallback) {
+			apierror.JSON(w, r, logger, apierror.ErrorResponse{
+				Code:    http.StatusBadRequest,
+				Message: "Invalid JSONP callback. Please use a valid JavaScript identifier.",
+			})
+
+			return false
+		}
+
+		logger.Error("Failed to render IP address", zap.String("format", renderer.Format()), zap.Error(err))
+
+		apierror.JSON(w, r, logger, apierror.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Failed to render IP address. Please try again later.",
+		})
+
+		return false
+	}
+
+	w.Header().Set(xhttp.ContentType, renderer.MediaTypes()[0])
+
+	_, err = w.Write(body)
+	if err != nil {
+		logger.Error("Failed to write IP address to response", zap.Error(err))
+
+		apierror.JSON(w, r, logger, apierror.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Failed to write IP address to response. Please try again later.",
+		})
+
+		return false
+	}
+
+	return true
+}
+
+// recordAccess increments the access counter and the statistics for a request
+// to route from ip, answered in format. HEAD requests aren't counted, since
+// they don't get the IP address.
+func recordAccess(db database.Store, r *http.Request, route, ip, format string) {
+	if r.Method == http.MethodHead {
+		return
+	}
+
+	db.Increment(database.Access{
+		Time:     time.Now(),
+		Endpoint: route,
+		Family:   ipFamily(ip),
+		Format:   format,
+	})
+}
+
+// ipFamily returns the statistics family of ip. Addresses that fail to parse
+// are counted as IPv4, as are IPv4-mapped IPv6 addresses.
+func ipFamily(ip string) string {
+	addr, err := netip.ParseAddr(ip)
+	if err == nil && addr.Unmap().Is6() {
+		return database.FamilyIPv6
+	}
+
+	return database.FamilyIPv4
+}
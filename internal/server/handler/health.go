@@ -3,10 +3,14 @@ package handler
 import (
 	"encoding/json"
 	"net/http"
+	"time"
 
 	"git.sr.ht/~jamesponddotco/accio127/internal/build"
 	"git.sr.ht/~jamesponddotco/accio127/internal/database"
+	"git.sr.ht/~jamesponddotco/accio127/internal/echo"
 	"git.sr.ht/~jamesponddotco/accio127/internal/errors"
+	"git.sr.ht/~jamesponddotco/accio127/internal/geoip"
+	"git.sr.ht/~jamesponddotco/accio127/internal/requestid"
 	"git.sr.ht/~jamesponddotco/accio127/internal/server/model"
 	"git.sr.ht/~jamesponddotco/xstd-go/xnet/xhttp"
 	"github.com/julienschmidt/httprouter"
@@ -23,44 +27,77 @@ const (
 
 // HealthHandler is an HTTP handler for the /health endpoint.
 type HealthHandler struct {
-	db     *database.DB
+	db     database.Store
+	geo    *geoip.Reader
+	stun   *echo.STUNServer
 	logger *zap.Logger
 }
 
-// NewHealthHandler creates a new HealthHandler instance.
-func NewHealthHandler(db *database.DB, logger *zap.Logger) *HealthHandler {
+// NewHealthHandler creates a new HealthHandler instance. geo may be nil if
+// GeoIP lookups are disabled, and stun if the STUN server is.
+func NewHealthHandler(
+	db database.Store,
+	geo *geoip.Reader,
+	stun *echo.STUNServer,
+	logger *zap.Logger,
+) *HealthHandler {
 	return &HealthHandler{
 		db:     db,
+		geo:    geo,
+		stun:   stun,
 		logger: logger,
 	}
 }
 
 // ServeHTTP serves the /health endpoint.
-func (h *HealthHandler) Handle(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+func (h *HealthHandler) Handle(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	logger := requestid.Logger(r.Context(), h.logger)
+
 	databaseStatus := Online
 
 	err := h.db.Ping()
 	if err != nil {
 		databaseStatus = Offline
 
-		h.logger.Warn("Database is offline", zap.Error(err))
+		logger.Warn("Database is offline", zap.Error(err))
+	}
+
+	dependencies := []model.Dependency{
+		{
+			Service: h.db.Driver(),
+			Status:  databaseStatus,
+		},
+	}
+
+	if h.geo != nil {
+		for _, geoDatabase := range h.geo.Databases() {
+			dependencies = append(dependencies, model.Dependency{
+				Service:   geoDatabase.Type,
+				Status:    Online,
+				BuildDate: geoDatabase.BuildTime.Format(time.RFC3339),
+			})
+		}
 	}
 
-	var (
-		dependencies = []model.Dependency{
-			{
-				Service: "sqlite",
-				Status:  databaseStatus,
-			},
+	if h.stun != nil {
+		stunStatus := Online
+		if !h.stun.Serving() {
+			stunStatus = Offline
 		}
-		status = model.NewHealth(build.Name, build.Version, dependencies)
-	)
+
+		dependencies = append(dependencies, model.Dependency{
+			Service: echo.ProtocolSTUN,
+			Status:  stunStatus,
+		})
+	}
+
+	status := model.NewHealth(build.Name, build.Version, dependencies)
 
 	statusJSON, err := json.Marshal(status) //nolint:errchkjson // if we don't check here, another linter complains
 	if err != nil {
-		h.logger.Error("Failed to marshal status to JSON", zap.Error(err))
+		logger.Error("Failed to marshal status to JSON", zap.Error(err))
 
-		errors.JSON(w, h.logger, errors.ErrorResponse{
+		errors.JSON(w, r, logger, errors.ErrorResponse{
 			Code:    http.StatusInternalServerError,
 			Message: "Failed to marshal status to JSON.",
 		})
@@ -72,9 +109,9 @@ func (h *HealthHandler) Handle(w http.ResponseWriter, _ *http.Request, _ httprou
 
 	_, err = w.Write(statusJSON)
 	if err != nil {
-		h.logger.Error("Failed to write status JSON to response", zap.Error(err))
+		logger.Error("Failed to write status JSON to response", zap.Error(err))
 
-		errors.JSON(w, h.logger, errors.ErrorResponse{
+		errors.JSON(w, r, logger, errors.ErrorResponse{
 			Code:    http.StatusInternalServerError,
 			Message: "Failed to write status JSON to response.",
 		})
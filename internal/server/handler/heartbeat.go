@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"git.sr.ht/~jamesponddotco/accio127/internal/errors"
+	"git.sr.ht/~jamesponddotco/accio127/internal/requestid"
 	"git.sr.ht/~jamesponddotco/xstd-go/xnet/xhttp"
 	"github.com/julienschmidt/httprouter"
 	"go.uber.org/zap"
@@ -22,14 +23,16 @@ func NewHeartbeatHandler(logger *zap.Logger) *HeartbeatHandler {
 }
 
 // ServeHTTP serves the /heartbeat endpoint.
-func (h *HeartbeatHandler) Handle(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+func (h *HeartbeatHandler) Handle(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	logger := requestid.Logger(r.Context(), h.logger)
+
 	w.Header().Set(xhttp.ContentType, xhttp.TextPlain)
 
 	_, err := w.Write([]byte("pong"))
 	if err != nil {
-		h.logger.Error("Failed to write response", zap.Error(err))
+		logger.Error("Failed to write response", zap.Error(err))
 
-		errors.JSON(w, h.logger, errors.ErrorResponse{
+		errors.JSON(w, r, logger, errors.ErrorResponse{
 			Code:    http.StatusInternalServerError,
 			Message: "Failed to write response. Please try again later.",
 		})
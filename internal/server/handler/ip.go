@@ -1,142 +1,168 @@
 package handler
 
 import (
-	"encoding/json"
 	"fmt"
 	"net"
 	"net/http"
+	"net/netip"
 	"strings"
 
 	"git.sr.ht/~jamesponddotco/accio127/internal/config"
 	"git.sr.ht/~jamesponddotco/accio127/internal/database"
-	"git.sr.ht/~jamesponddotco/accio127/internal/errors"
+	"git.sr.ht/~jamesponddotco/accio127/internal/endpoint"
+	"git.sr.ht/~jamesponddotco/accio127/internal/requestid"
 	"git.sr.ht/~jamesponddotco/accio127/internal/server/model"
-	"git.sr.ht/~jamesponddotco/xstd-go/xnet/xhttp"
+	"git.sr.ht/~jamesponddotco/accio127/internal/server/render"
 	"github.com/julienschmidt/httprouter"
 	"go.uber.org/zap"
 )
 
 // IPHandler is an HTTP handler for the /ip endpoint.
 type IPHandler struct {
-	cfg    *config.Config
-	db     *database.DB
-	logger *zap.Logger
+	cfg        *config.Config
+	db         database.Store
+	negotiator *render.Negotiator
+	logger     *zap.Logger
 }
 
 // NewIPHandler creates a new IPHandler instance.
-func NewIPHandler(cfg *config.Config, db *database.DB, logger *zap.Logger) *IPHandler {
+func NewIPHandler(cfg *config.Config, db database.Store, negotiator *render.Negotiator, logger *zap.Logger) *IPHandler {
 	return &IPHandler{
-		cfg:    cfg,
-		db:     db,
-		logger: logger,
+		cfg:        cfg,
+		db:         db,
+		negotiator: negotiator,
+		logger:     logger,
 	}
 }
 
-// ServeHTTP serves the /ip endpoint.
-func (h *IPHandler) Handle(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
-	ip, err := ClientIP(r, h.cfg.Proxy)
-	if err != nil {
-		h.logger.Error("Failed to get client IP address", zap.Error(err))
-
-		errors.JSON(w, h.logger, errors.ErrorResponse{
-			Code:    http.StatusInternalServerError,
-			Message: "Failed to get IP address. Please try again later.",
-		})
+// ServeHTTP serves the /ip and /ip/{address} endpoints.
+func (h *IPHandler) Handle(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	logger := requestid.Logger(r.Context(), h.logger)
 
+	renderer := negotiate(w, r, h.negotiator, logger)
+	if renderer == nil {
 		return
 	}
 
-	contentType := r.Header.Get(xhttp.ContentType)
-
-	if contentType == xhttp.ApplicationJSON {
-		w.Header().Set(xhttp.ContentType, xhttp.ApplicationJSON)
+	ip, ok := targetIP(w, r, ps, h.cfg.Proxy, logger)
+	if !ok {
+		return
+	}
 
-		ipModel := model.NewIP(ip)
+	if !writeIP(w, r, renderer, model.NewIP(ip), logger) {
+		return
+	}
 
-		ipJSON, err := json.Marshal(ipModel) //nolint:errchkjson // if we don't check here, another linter complains
-		if err != nil {
-			h.logger.Error("Failed to marshal IP address to JSON", zap.Error(err))
+	recordAccess(h.db, r, lookupRoute(ps, endpoint.IP, endpoint.IPLookup), ip, renderer.Format())
+}
 
-			errors.JSON(w, h.logger, errors.ErrorResponse{
-				Code:    http.StatusInternalServerError,
-				Message: "Failed to marshal IP address to JSON. Please try again later.",
-			})
+// ClientIP returns the client's IP address from the request headers or
+// RemoteAddr.
+//
+// Headers are only trusted when the request comes from one of the trusted
+// proxies, and only the headers configured for that proxy's group are read, in
+// order. List headers such as X-Forwarded-For and Forwarded are walked from
+// right to left, skipping the addresses of trusted proxies, since only the
+// entries appended by our own proxies can be trusted.
+//
+// See https://adam-p.ca/blog/2022/03/x-forwarded-for/ for details.
+func ClientIP(r *http.Request, proxies config.Proxies) (string, error) {
+	remoteIP, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return "", fmt.Errorf("failed to split remote address: %w", err)
+	}
 
-			return
-		}
+	remoteAddr, err := netip.ParseAddr(strings.Trim(remoteIP, "[]"))
+	if err != nil {
+		return "", fmt.Errorf("failed to parse remote address: %w", err)
+	}
 
-		_, err = w.Write(ipJSON)
-		if err != nil {
-			h.logger.Error("Failed to write IP address JSON to response", zap.Error(err))
+	remoteAddr = remoteAddr.Unmap().WithZone("")
 
-			errors.JSON(w, h.logger, errors.ErrorResponse{
-				Code:    http.StatusInternalServerError,
-				Message: "Failed to write IP address JSON to response. Please try again later.",
-			})
+	// If the remote IP isn't a trusted proxy, we can't trust the headers.
+	group, ok := proxies.Group(remoteAddr)
+	if !ok {
+		return remoteAddr.String(), nil
+	}
 
-			return
+	for _, header := range group.Headers {
+		values := r.Header.Values(header)
+		if len(values) == 0 {
+			continue
 		}
-	} else {
-		w.Header().Set(xhttp.ContentType, xhttp.TextPlain)
 
-		_, err := w.Write([]byte(ip))
-		if err != nil {
-			h.logger.Error("Failed to write IP address to response", zap.Error(err))
+		var entries []string
 
-			errors.JSON(w, h.logger, errors.ErrorResponse{
-				Code:    http.StatusInternalServerError,
-				Message: "Failed to write IP address to response. Please try again later.",
-			})
+		if header == config.Forwarded {
+			entries = ForwardedFor(values)
+		} else {
+			entries = splitList(values)
+		}
 
-			return
+		if ip, ok := rightmostUntrusted(entries, proxies); ok {
+			return ip.String(), nil
 		}
 	}
 
-	go func() {
-		_, err := h.db.Increment()
-		if err != nil {
-			h.logger.Error("Failed to increment access counter", zap.Error(err))
-		}
-	}()
+	return remoteAddr.String(), nil
 }
 
-// ClientIP returns the client's IP address from the request headers or RemoteAddr.
-func ClientIP(r *http.Request, proxy string) (string, error) {
+// rightmostUntrusted walks the entries from right to left and returns the first
+// address that doesn't belong to a trusted proxy. If every entry is trusted,
+// the left-most one is returned. It stops at the first entry that isn't a
+// valid IP address, as nothing to its left can be trusted.
+func rightmostUntrusted(entries []string, proxies config.Proxies) (netip.Addr, bool) {
 	var (
-		headers = []string{
-			"CF-Connecting-IP",
-			"True-Client-IP",
-			"X-Real-IP",
-			"X-Forwarded-For",
-		}
-		trustedProxy = proxy
+		last  netip.Addr
+		found bool
 	)
 
-	// Check the remote IP. If it's not the trusted proxy, we can't trust the headers.
-	// https://adam-p.ca/blog/2022/03/x-forwarded-for/
-	remoteIP, _, err := net.SplitHostPort(r.RemoteAddr)
-	if err != nil {
-		return "", fmt.Errorf("failed to split remote address: %w", err)
-	}
+	for i := len(entries) - 1; i >= 0; i-- {
+		addr, err := parseHostAddr(entries[i])
+		if err != nil {
+			return netip.Addr{}, false
+		}
 
-	remoteIP = strings.Trim(remoteIP, "[]")
+		if !proxies.Trusted(addr) {
+			return addr, true
+		}
 
-	if remoteIP != trustedProxy {
-		return remoteIP, nil
+		last = addr
+		found = true
 	}
 
-	// If the request comes from a trusted proxy, check the headers.
-	for _, header := range headers {
-		ip := r.Header.Get(header)
-		if ip != "" {
-			ips := strings.Split(ip, ", ")
+	return last, found
+}
+
+// splitList splits comma-separated header values into their entries.
+func splitList(values []string) []string {
+	entries := make([]string, 0, len(values))
+
+	for _, value := range values {
+		for _, entry := range strings.Split(value, ",") {
+			entry = strings.TrimSpace(entry)
+			if entry == "" {
+				continue
+			}
 
-			// If we find multiple IPs in the header, we want to take the
-			// left-most one since we're going from client to server, left to
-			// right.
-			return ips[0], nil
+			entries = append(entries, entry)
 		}
 	}
 
-	return remoteIP, nil
+	return entries
+}
+
+// parseHostAddr parses an IP address that may be wrapped in brackets or
+// followed by a port.
+func parseHostAddr(host string) (netip.Addr, error) {
+	if addrPort, err := netip.ParseAddrPort(host); err == nil {
+		return addrPort.Addr().Unmap().WithZone(""), nil
+	}
+
+	addr, err := netip.ParseAddr(strings.Trim(host, "[]"))
+	if err != nil {
+		return netip.Addr{}, fmt.Errorf("failed to parse IP address: %w", err)
+	}
+
+	return addr.Unmap().WithZone(""), nil
 }
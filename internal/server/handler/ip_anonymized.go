@@ -1,126 +1,138 @@
 package handler
 
 import (
-	"encoding/json"
-	"net"
 	"net/http"
+	"net/netip"
+	"strconv"
 
 	"git.sr.ht/~jamesponddotco/accio127/internal/config"
 	"git.sr.ht/~jamesponddotco/accio127/internal/database"
+	"git.sr.ht/~jamesponddotco/accio127/internal/endpoint"
 	"git.sr.ht/~jamesponddotco/accio127/internal/errors"
+	"git.sr.ht/~jamesponddotco/accio127/internal/requestid"
 	"git.sr.ht/~jamesponddotco/accio127/internal/server/model"
-	"git.sr.ht/~jamesponddotco/xstd-go/xnet/xhttp"
+	"git.sr.ht/~jamesponddotco/accio127/internal/server/render"
 	"github.com/julienschmidt/httprouter"
 	"go.uber.org/zap"
 )
 
 // AnonymizedIPHandler is an HTTP handler for the /ip/anonymized endpoint.
 type AnonymizedIPHandler struct {
-	cfg    *config.Config
-	db     *database.DB
-	logger *zap.Logger
+	cfg        *config.Config
+	db         database.Store
+	negotiator *render.Negotiator
+	logger     *zap.Logger
 }
 
 // NewAnonymizedIPHandler creates a new AnonymizedIPHandler instance.
-func NewAnonymizedIPHandler(cfg *config.Config, db *database.DB, logger *zap.Logger) *AnonymizedIPHandler {
+func NewAnonymizedIPHandler(cfg *config.Config, db database.Store, negotiator *render.Negotiator, logger *zap.Logger) *AnonymizedIPHandler {
 	return &AnonymizedIPHandler{
-		cfg:    cfg,
-		db:     db,
-		logger: logger,
+		cfg:        cfg,
+		db:         db,
+		negotiator: negotiator,
+		logger:     logger,
 	}
 }
 
-// ServeHTTP serves the /ip/anonymized endpoint.
-func (h *AnonymizedIPHandler) Handle(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
-	ip, err := ClientIP(r, h.cfg.Proxy)
-	if err != nil {
-		h.logger.Error("Failed to get client IP address", zap.Error(err))
-
-		errors.JSON(w, h.logger, errors.ErrorResponse{
-			Code:    http.StatusInternalServerError,
-			Message: "Failed to get IP address. Please try again later.",
-		})
+// ServeHTTP serves the /ip/anonymized and /ip/{address}/anonymized endpoints.
+//
+// The ipv4Prefix and ipv6Prefix query parameters override the number of bits
+// kept from the address, within the bounds allowed by the configuration.
+func (h *AnonymizedIPHandler) Handle(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	logger := requestid.Logger(r.Context(), h.logger)
 
+	renderer := negotiate(w, r, h.negotiator, logger)
+	if renderer == nil {
 		return
 	}
 
-	anonymizedIP := AnonymizeIP(ip)
-	if anonymizedIP == "" {
-		h.logger.Error("Failed to anonymize client IP address", zap.Error(err))
-
-		errors.JSON(w, h.logger, errors.ErrorResponse{
-			Code:    http.StatusInternalServerError,
-			Message: "Failed to anonymize IP address. Please try again later.",
+	ipv4Bits, message := prefixLength(r, "ipv4Prefix", h.cfg.Anonymize.IPv4)
+	if message != "" {
+		errors.JSON(w, r, logger, errors.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: message,
 		})
 
 		return
 	}
 
-	contentType := r.Header.Get(xhttp.ContentType)
+	ipv6Bits, message := prefixLength(r, "ipv6Prefix", h.cfg.Anonymize.IPv6)
+	if message != "" {
+		errors.JSON(w, r, logger, errors.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: message,
+		})
 
-	if contentType == xhttp.ApplicationJSON {
-		w.Header().Set(xhttp.ContentType, xhttp.ApplicationJSON)
+		return
+	}
 
-		ipModel := model.NewIP(anonymizedIP)
+	ip, ok := targetIP(w, r, ps, h.cfg.Proxy, logger)
+	if !ok {
+		return
+	}
 
-		ipJSON, err := json.Marshal(ipModel) //nolint:errchkjson // if we don't check here, another linter complains
-		if err != nil {
-			h.logger.Error("Failed to marshal IP address to JSON", zap.Error(err))
+	anonymizedIP := model.NewAnonymizedIP(AnonymizeIP(ip, ipv4Bits, ipv6Bits))
+	if anonymizedIP == nil {
+		logger.Error("Failed to anonymize client IP address", zap.String("ip", ip))
 
-			errors.JSON(w, h.logger, errors.ErrorResponse{
-				Code:    http.StatusInternalServerError,
-				Message: "Failed to marshal IP address to JSON. Please try again later.",
-			})
+		errors.JSON(w, r, logger, errors.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Type:    errors.TypeIPParseFailure,
+			Message: "Failed to anonymize IP address. Please try again later.",
+		})
 
-			return
-		}
+		return
+	}
 
-		_, err = w.Write(ipJSON)
-		if err != nil {
-			h.logger.Error("Failed to write IP address JSON to response", zap.Error(err))
+	if !writeIP(w, r, renderer, anonymizedIP, logger) {
+		return
+	}
 
-			errors.JSON(w, h.logger, errors.ErrorResponse{
-				Code:    http.StatusInternalServerError,
-				Message: "Failed to write IP address JSON to response. Please try again later.",
-			})
+	recordAccess(h.db, r, lookupRoute(ps, endpoint.IPAnonymize, endpoint.IPLookupAnonymize), ip, renderer.Format())
+}
 
-			return
-		}
-	} else {
-		w.Header().Set(xhttp.ContentType, xhttp.TextPlain)
+// AnonymizeIP masks an IP address, keeping its first ipv4Bits bits if it's an
+// IPv4 address or its first ipv6Bits bits otherwise. It returns an invalid
+// prefix if the address can't be parsed.
+func AnonymizeIP(ipStr string, ipv4Bits, ipv6Bits int) netip.Prefix {
+	addr, err := netip.ParseAddr(ipStr)
+	if err != nil {
+		return netip.Prefix{}
+	}
 
-		_, err := w.Write([]byte(anonymizedIP))
-		if err != nil {
-			h.logger.Error("Failed to write IP address to response", zap.Error(err))
+	addr = addr.Unmap().WithZone("")
 
-			errors.JSON(w, h.logger, errors.ErrorResponse{
-				Code:    http.StatusInternalServerError,
-				Message: "Failed to write IP address to response. Please try again later.",
-			})
+	bits := ipv6Bits
+	if addr.Is4() {
+		bits = ipv4Bits
+	}
 
-			return
-		}
+	prefix, err := addr.Prefix(bits)
+	if err != nil {
+		return netip.Prefix{}
 	}
 
-	go func() {
-		_, err := h.db.Increment()
-		if err != nil {
-			h.logger.Error("Failed to increment access counter", zap.Error(err))
-		}
-	}()
+	return prefix
 }
 
-// AnonymizeIP anonymizes the last two octets of an IPv4 address or the last 80
-// bits of an IPv6 address.
-func AnonymizeIP(ipStr string) string {
-	ip := net.ParseIP(ipStr)
-	if ip == nil {
-		return ""
+// prefixLength returns the prefix length requested in the query parameter, or
+// the configured length if it's missing. If the parameter is invalid, it
+// returns a message explaining why.
+func prefixLength(r *http.Request, param string, prefix config.Prefix) (int, string) {
+	value := r.URL.Query().Get(param)
+	if value == "" {
+		return prefix.Length, ""
+	}
+
+	length, err := strconv.Atoi(value)
+	if err == nil && prefix.Allows(length) {
+		return length, ""
 	}
 
-	if ip.To4() != nil {
-		return ip.Mask(net.CIDRMask(16, 32)).String()
+	if prefix.Min == prefix.Max {
+		return 0, "Invalid '" + param + "' prefix length. This server only allows " + strconv.Itoa(prefix.Length) + "."
 	}
 
-	return ip.Mask(net.CIDRMask(48, 128)).String()
+	return 0, "Invalid '" + param + "' prefix length. Please use a number between " +
+		strconv.Itoa(prefix.Min) + " and " + strconv.Itoa(prefix.Max) + "."
 }
@@ -0,0 +1,75 @@
+package handler_test
+
+import (
+	"testing"
+
+	"git.sr.ht/~jamesponddotco/accio127/internal/server/handler"
+)
+
+func TestAnonymizeIP(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		give     string
+		ipv4Bits int
+		ipv6Bits int
+		want     string
+	}{
+		{
+			name:     "ipv4_default",
+			give:     "192.0.2.123",
+			ipv4Bits: 16,
+			ipv6Bits: 48,
+			want:     "192.0.0.0/16",
+		},
+		{
+			name:     "ipv4_24",
+			give:     "192.0.2.123",
+			ipv4Bits: 24,
+			ipv6Bits: 48,
+			want:     "192.0.2.0/24",
+		},
+		{
+			name:     "ipv4_mapped",
+			give:     "::ffff:192.0.2.123",
+			ipv4Bits: 8,
+			ipv6Bits: 48,
+			want:     "192.0.0.0/8",
+		},
+		{
+			name:     "ipv6_64",
+			give:     "2001:db8:1:2:3:4:5:6",
+			ipv4Bits: 16,
+			ipv6Bits: 64,
+			want:     "2001:db8:1:2::/64",
+		},
+		{
+			name:     "ipv4_too_long",
+			give:     "192.0.2.123",
+			ipv4Bits: 40,
+			ipv6Bits: 48,
+			want:     "invalid Prefix",
+		},
+		{
+			name:     "invalid_ip",
+			give:     "invalid",
+			ipv4Bits: 16,
+			ipv6Bits: 48,
+			want:     "invalid Prefix",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := handler.AnonymizeIP(tt.give, tt.ipv4Bits, tt.ipv6Bits)
+			if got.String() != tt.want {
+				t.Errorf("AnonymizeIP(%q, %d, %d) = %v, want %v", tt.give, tt.ipv4Bits, tt.ipv6Bits, got, tt.want)
+			}
+		})
+	}
+}
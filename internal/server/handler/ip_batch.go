@@ -0,0 +1,442 @@
+package handler
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"git.sr.ht/~jamesponddotco/accio127/internal/accept"
+	"git.sr.ht/~jamesponddotco/accio127/internal/config"
+	"git.sr.ht/~jamesponddotco/accio127/internal/database"
+	"git.sr.ht/~jamesponddotco/accio127/internal/endpoint"
+	apierror "git.sr.ht/~jamesponddotco/accio127/internal/errors"
+	"git.sr.ht/~jamesponddotco/accio127/internal/iphash"
+	"git.sr.ht/~jamesponddotco/accio127/internal/requestid"
+	"git.sr.ht/~jamesponddotco/accio127/internal/server/model"
+	"git.sr.ht/~jamesponddotco/accio127/internal/server/render"
+	"git.sr.ht/~jamesponddotco/xstd-go/xerrors"
+	"git.sr.ht/~jamesponddotco/xstd-go/xnet/xhttp"
+	"github.com/julienschmidt/httprouter"
+	"go.uber.org/zap"
+)
+
+// FormatNDJSON is the format newline-delimited JSON responses are counted
+// under in the statistics.
+const FormatNDJSON string = "ndjson"
+
+// errTooManyItems is returned when a batch request holds more addresses than
+// allowed.
+const errTooManyItems xerrors.Error = "too many addresses"
+
+// errWriteFailed is returned when a batch result can't be written to the
+// response.
+const errWriteFailed xerrors.Error = "failed to write batch results"
+
+// BatchIPHandler is an HTTP handler for the /ip/batch endpoint.
+type BatchIPHandler struct {
+	cfg    *config.Config
+	db     database.Store
+	hasher *iphash.Hasher
+	logger *zap.Logger
+}
+
+// NewBatchIPHandler creates a new BatchIPHandler instance.
+func NewBatchIPHandler(
+	cfg *config.Config,
+	db database.Store,
+	hasher *iphash.Hasher,
+	logger *zap.Logger,
+) *BatchIPHandler {
+	return &BatchIPHandler{
+		cfg:    cfg,
+		db:     db,
+		hasher: hasher,
+		logger: logger,
+	}
+}
+
+// ServeHTTP serves the /ip/batch endpoint.
+//
+// The request body is either a JSON array of addresses or a newline-delimited
+// list of them, picked by its Content-Type. Every address is answered with its
+// family, anonymized form, and hashed form, or with an error, in the order it
+// was sent. The response is a JSON array, or newline-delimited JSON if the
+// client asks for it, and is streamed as it's written.
+//
+// Addresses are decoded one at a time and every result is flushed as soon as
+// it's ready. Problems found before the first result is written get an error
+// status; problems found after that end the response with a result holding
+// only an error.
+func (h *BatchIPHandler) Handle(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	logger := requestid.Logger(r.Context(), h.logger)
+
+	mediaType, err := accept.Preferred(r, xhttp.ApplicationJSON, render.ApplicationNDJSON)
+	if err != nil {
+		apierror.JSON(w, r, logger, apierror.ErrorResponse{
+			Code:    http.StatusNotAcceptable,
+			Message: "Requested format is not available. Supported media types: " + xhttp.ApplicationJSON + ", " + render.ApplicationNDJSON + ".",
+		})
+
+		return
+	}
+
+	ipv4Bits, message := prefixLength(r, "ipv4Prefix", h.cfg.Anonymize.IPv4)
+	if message != "" {
+		apierror.JSON(w, r, logger, apierror.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: message,
+		})
+
+		return
+	}
+
+	ipv6Bits, message := prefixLength(r, "ipv6Prefix", h.cfg.Anonymize.IPv6)
+	if message != "" {
+		apierror.JSON(w, r, logger, apierror.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: message,
+		})
+
+		return
+	}
+
+	clientIP, err := ClientIP(r, h.cfg.Proxy)
+	if err != nil {
+		logger.Error("Failed to get client IP address", zap.Error(err))
+
+		apierror.JSON(w, r, logger, apierror.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Type:    apierror.TypeIPParseFailure,
+			Message: "Failed to get IP address. Please try again later.",
+		})
+
+		return
+	}
+
+	inputs, code, message := h.openInputs(w, r)
+	if message != "" {
+		apierror.JSON(w, r, logger, apierror.ErrorResponse{
+			Code:    code,
+			Message: message,
+		})
+
+		return
+	}
+
+	// Reading the first address before writing anything lets an empty,
+	// malformed, or oversized body still get an error status.
+	first, err := inputs.Next()
+	if err != nil && !errors.Is(err, io.EOF) {
+		code, message = h.inputError(err)
+
+		apierror.JSON(w, r, logger, apierror.ErrorResponse{
+			Code:    code,
+			Message: message,
+		})
+
+		return
+	}
+
+	var (
+		ndjson = mediaType == render.ApplicationNDJSON
+		format = render.JSON{}.Format()
+		writer = newBatchWriter(w, ndjson)
+		now    = time.Now()
+	)
+
+	if ndjson {
+		format = FormatNDJSON
+	}
+
+	w.Header().Set(xhttp.ContentType, mediaType)
+
+	// HTTP/1.x servers stop reading the request body once the response starts,
+	// unless told otherwise. Other protocols are full duplex already.
+	if duplexErr := http.NewResponseController(w).EnableFullDuplex(); duplexErr != nil && !errors.Is(duplexErr, http.ErrNotSupported) {
+		logger.Error("Failed to enable full duplex for batch response", zap.Error(duplexErr))
+	}
+
+	for input := first; err == nil; input, err = inputs.Next() {
+		if err = writer.write(h.result(input, now, ipv4Bits, ipv6Bits)); err != nil {
+			break
+		}
+	}
+
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, errWriteFailed) {
+		_, message = h.inputError(err)
+
+		err = writer.write(&model.BatchResult{
+			Error: message,
+		})
+	}
+
+	if err == nil || errors.Is(err, io.EOF) {
+		err = writer.close()
+	}
+
+	if err != nil {
+		// The status code is gone by now, so all that's left is to stop.
+		logger.Error("Failed to write batch results to response", zap.Error(err))
+
+		return
+	}
+
+	recordAccess(h.db, r, endpoint.IPBatch, clientIP, format)
+}
+
+// openInputs prepares to read the addresses in the request body. If the body
+// can't be read, it returns the status code and a message explaining why.
+func (h *BatchIPHandler) openInputs(w http.ResponseWriter, r *http.Request) (batchInputs, uint, string) {
+	contentType := r.Header.Get(xhttp.ContentType)
+	if contentType == "" {
+		contentType = xhttp.TextPlain
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, http.StatusUnsupportedMediaType, "Invalid Content-Type header."
+	}
+
+	var (
+		body  = http.MaxBytesReader(w, r.Body, h.cfg.Batch.MaxBodySize)
+		limit = h.cfg.Batch.MaxItems
+	)
+
+	switch mediaType {
+	case xhttp.ApplicationJSON:
+		return newJSONInputs(body, limit), 0, ""
+	case render.ApplicationNDJSON, xhttp.TextPlain:
+		return newLineInputs(body, limit, h.cfg.Batch.MaxBodySize), 0, ""
+	default:
+		return nil, http.StatusUnsupportedMediaType, "Unsupported Content-Type. Send " + xhttp.ApplicationJSON + ", " + render.ApplicationNDJSON + ", or text/plain."
+	}
+}
+
+// inputError returns the status code and the message explaining an error
+// returned while reading the request body.
+func (h *BatchIPHandler) inputError(err error) (uint, string) {
+	var maxBytesErr *http.MaxBytesError
+
+	switch {
+	case errors.As(err, &maxBytesErr):
+		return http.StatusRequestEntityTooLarge, "Request body too large. The limit is " + strconv.FormatInt(maxBytesErr.Limit, 10) + " bytes."
+	case errors.Is(err, errTooManyItems):
+		return http.StatusRequestEntityTooLarge, "Too many addresses. The limit is " + strconv.Itoa(h.cfg.Batch.MaxItems) + " per request."
+	default:
+		return http.StatusBadRequest, "Invalid request body. Send a JSON array of addresses or one address per line."
+	}
+}
+
+// result anonymizes and hashes a single address.
+func (h *BatchIPHandler) result(input string, now time.Time, ipv4Bits, ipv6Bits int) *model.BatchResult {
+	result := &model.BatchResult{
+		Input: input,
+	}
+
+	addr, err := netip.ParseAddr(strings.TrimSpace(input))
+	if err != nil {
+		result.Error = "Invalid IP address."
+
+		return result
+	}
+
+	ip := addr.Unmap().WithZone("").String()
+
+	result.Family = ipFamily(ip)
+	result.Anonymized = model.NewAnonymizedIP(AnonymizeIP(ip, ipv4Bits, ipv6Bits))
+	result.Hashed = hashIP(h.hasher, ip, now)
+
+	return result
+}
+
+// batchWriter writes batch results to the response as a JSON array or as
+// newline-delimited JSON, flushing each one as it's written.
+type batchWriter struct {
+	w          http.ResponseWriter
+	controller *http.ResponseController
+	ndjson     bool
+	written    bool
+}
+
+// newBatchWriter creates a new batchWriter writing to w.
+func newBatchWriter(w http.ResponseWriter, ndjson bool) *batchWriter {
+	return &batchWriter{
+		w:          w,
+		controller: http.NewResponseController(w),
+		ndjson:     ndjson,
+	}
+}
+
+// write writes a single result and flushes it to the client.
+func (b *batchWriter) write(result *model.BatchResult) error {
+	resultJSON, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("%w: failed to marshal batch result: %w", errWriteFailed, err)
+	}
+
+	switch {
+	case b.ndjson:
+		resultJSON = append(resultJSON, '\n')
+	case b.written:
+		resultJSON = append([]byte{','}, resultJSON...)
+	default:
+		resultJSON = append([]byte{'['}, resultJSON...)
+	}
+
+	b.written = true
+
+	return b.send(resultJSON)
+}
+
+// close ends the response, closing the JSON array if there is one.
+func (b *batchWriter) close() error {
+	switch {
+	case b.ndjson:
+		return nil
+	case b.written:
+		return b.send([]byte("]\n"))
+	default:
+		return b.send([]byte("[]\n"))
+	}
+}
+
+// send writes p to the response and flushes it.
+func (b *batchWriter) send(p []byte) error {
+	if _, err := b.w.Write(p); err != nil {
+		return fmt.Errorf("%w: %w", errWriteFailed, err)
+	}
+
+	if err := b.controller.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return fmt.Errorf("%w: %w", errWriteFailed, err)
+	}
+
+	return nil
+}
+
+// batchInputs reads the addresses in a batch request body one at a time.
+type batchInputs interface {
+	// Next returns the next address, or io.EOF once there are none left.
+	Next() (string, error)
+}
+
+// jsonInputs reads a JSON array of addresses. Items that aren't strings are
+// kept as their JSON text, so they're reported as invalid addresses instead
+// of failing the whole request.
+type jsonInputs struct {
+	decoder *json.Decoder
+	limit   int
+	count   int
+	started bool
+}
+
+// newJSONInputs creates a new jsonInputs reading from r.
+func newJSONInputs(r io.Reader, limit int) *jsonInputs {
+	return &jsonInputs{
+		decoder: json.NewDecoder(r),
+		limit:   limit,
+	}
+}
+
+// Next implements the batchInputs interface.
+func (j *jsonInputs) Next() (string, error) {
+	if !j.started {
+		token, err := j.decoder.Token()
+		if err != nil {
+			return "", fmt.Errorf("failed to read JSON array: %w", err)
+		}
+
+		if delim, ok := token.(json.Delim); !ok || delim != '[' {
+			return "", fmt.Errorf("failed to read JSON array: unexpected %v", token) //nolint:goerr113 // only used to reject the body
+		}
+
+		j.started = true
+	}
+
+	if !j.decoder.More() {
+		if _, err := j.decoder.Token(); err != nil {
+			return "", fmt.Errorf("failed to read JSON array: %w", err)
+		}
+
+		return "", io.EOF
+	}
+
+	if j.count == j.limit {
+		return "", errTooManyItems
+	}
+
+	var raw json.RawMessage
+
+	if err := j.decoder.Decode(&raw); err != nil {
+		return "", fmt.Errorf("failed to read JSON array: %w", err)
+	}
+
+	j.count++
+
+	var input string
+
+	if err := json.Unmarshal(raw, &input); err != nil {
+		input = string(raw)
+	}
+
+	return input, nil
+}
+
+// lineInputs reads one address per line, skipping blank lines. Lines holding
+// a JSON string are unquoted, so newline-delimited JSON works too.
+type lineInputs struct {
+	scanner *bufio.Scanner
+	limit   int
+	count   int
+}
+
+// newLineInputs creates a new lineInputs reading from r.
+func newLineInputs(r io.Reader, limit int, maxLineSize int64) *lineInputs {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), int(maxLineSize)+1)
+
+	return &lineInputs{
+		scanner: scanner,
+		limit:   limit,
+	}
+}
+
+// Next implements the batchInputs interface.
+func (l *lineInputs) Next() (string, error) {
+	for l.scanner.Scan() {
+		line := bytes.TrimSpace(l.scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		if l.count == l.limit {
+			return "", errTooManyItems
+		}
+
+		l.count++
+
+		input := string(line)
+
+		if line[0] == '"' {
+			if err := json.Unmarshal(line, &input); err != nil {
+				input = string(line)
+			}
+		}
+
+		return input, nil
+	}
+
+	if err := l.scanner.Err(); err != nil {
+		return "", fmt.Errorf("failed to read addresses: %w", err)
+	}
+
+	return "", io.EOF
+}
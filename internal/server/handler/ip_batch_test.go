@@ -0,0 +1,279 @@
+package handler_test
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"git.sr.ht/~jamesponddotco/accio127/internal/config"
+	"git.sr.ht/~jamesponddotco/accio127/internal/database"
+	"git.sr.ht/~jamesponddotco/accio127/internal/iphash"
+	"git.sr.ht/~jamesponddotco/accio127/internal/server/handler"
+	"go.uber.org/zap"
+)
+
+func TestBatchIPHandler(t *testing.T) {
+	t.Parallel()
+
+	db, err := database.Open(zap.NewNop(), "memory://", database.Options{})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+
+	t.Cleanup(func() { db.Close() })
+
+	hasher, err := iphash.New(config.HashAlgorithmHMACSHA256, []byte(strings.Repeat("s", config.MinHashSecretLength)), 0)
+	if err != nil {
+		t.Fatalf("Failed to create hasher: %v", err)
+	}
+
+	cfg := &config.Config{
+		Anonymize: config.Anonymize{
+			IPv4: config.Prefix{Length: 16, Min: 8, Max: 24},
+			IPv6: config.Prefix{Length: 48, Min: 48, Max: 48},
+		},
+		Batch: config.Batch{
+			MaxBodySize: 256,
+			MaxItems:    3,
+			Enabled:     true,
+		},
+	}
+
+	h := handler.NewBatchIPHandler(cfg, db, hasher, zap.NewNop())
+
+	tests := []struct {
+		name        string
+		url         string
+		contentType string
+		accept      string
+		body        string
+		wantCode    int
+		wantType    string
+		wantParts   []string
+	}{
+		{
+			name:        "json_array",
+			contentType: "application/json",
+			body:        `["192.0.2.1", "not an address", 42]`,
+			wantCode:    http.StatusOK,
+			wantType:    "application/json",
+			wantParts: []string{
+				`[{"anonymized":{"ipv4":"192.0.0.0","cidr":"192.0.0.0/16","prefixLength":16},"hashed":{"key":{"id":`,
+				`"input":"192.0.2.1","family":"ipv4"}`,
+				`,{"input":"not an address","error":"Invalid IP address."}`,
+				`,{"input":"42","error":"Invalid IP address."}]`,
+			},
+		},
+		{
+			name:        "lines_to_ndjson",
+			url:         "/v1/ip/batch?ipv4Prefix=24",
+			contentType: "text/plain",
+			accept:      "application/x-ndjson",
+			body:        "192.0.2.1\n\n\"2001:db8::1\"\n",
+			wantCode:    http.StatusOK,
+			wantType:    "application/x-ndjson",
+			wantParts: []string{
+				`"cidr":"192.0.2.0/24"`,
+				"\"input\":\"192.0.2.1\",\"family\":\"ipv4\"}\n{",
+				`"cidr":"2001:db8::/48"`,
+				"\"input\":\"2001:db8::1\",\"family\":\"ipv6\"}\n",
+			},
+		},
+		{
+			name:        "empty_array",
+			contentType: "application/json",
+			body:        `[]`,
+			wantCode:    http.StatusOK,
+			wantParts:   []string{"[]\n"},
+		},
+		{
+			name:        "too_many_items",
+			contentType: "application/json",
+			body:        `["192.0.2.1", "192.0.2.2", "192.0.2.3", "192.0.2.4"]`,
+			wantCode:    http.StatusOK,
+			wantParts: []string{
+				`"input":"192.0.2.3","family":"ipv4"}`,
+				`,{"input":"","error":"Too many addresses. The limit is 3 per request."}]`,
+			},
+		},
+		{
+			name:        "invalid_json_after_first_item",
+			contentType: "application/json",
+			accept:      "application/x-ndjson",
+			body:        `["192.0.2.1", {]`,
+			wantCode:    http.StatusOK,
+			wantParts: []string{
+				"\"input\":\"192.0.2.1\",\"family\":\"ipv4\"}\n",
+				"{\"input\":\"\",\"error\":\"Invalid request body.",
+			},
+		},
+		{
+			name:        "body_too_large",
+			contentType: "text/plain",
+			body:        strings.Repeat(" ", 300) + "192.0.2.1",
+			wantCode:    http.StatusRequestEntityTooLarge,
+			wantParts:   []string{"Request body too large"},
+		},
+		{
+			name:        "invalid_json",
+			contentType: "application/json",
+			body:        `{"ip": "192.0.2.1"}`,
+			wantCode:    http.StatusBadRequest,
+		},
+		{
+			name:        "unsupported_content_type",
+			contentType: "application/xml",
+			body:        `<ip>192.0.2.1</ip>`,
+			wantCode:    http.StatusUnsupportedMediaType,
+		},
+		{
+			name:        "prefix_out_of_bounds",
+			url:         "/v1/ip/batch?ipv6Prefix=64",
+			contentType: "application/json",
+			body:        `[]`,
+			wantCode:    http.StatusBadRequest,
+		},
+		{
+			name:        "not_acceptable",
+			contentType: "application/json",
+			accept:      "text/csv",
+			body:        `[]`,
+			wantCode:    http.StatusNotAcceptable,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			url := tt.url
+			if url == "" {
+				url = "/v1/ip/batch"
+			}
+
+			var (
+				w = httptest.NewRecorder()
+				r = httptest.NewRequest(http.MethodPost, url, strings.NewReader(tt.body))
+			)
+
+			r.Header.Set("Content-Type", tt.contentType)
+
+			if tt.accept != "" {
+				r.Header.Set("Accept", tt.accept)
+			}
+
+			h.Handle(w, r, nil)
+
+			if w.Code != tt.wantCode {
+				t.Fatalf("Handle() code = %d, want %d; body = %s", w.Code, tt.wantCode, w.Body.String())
+			}
+
+			if tt.wantType != "" && w.Header().Get("Content-Type") != tt.wantType {
+				t.Errorf("Handle() Content-Type = %q, want %q", w.Header().Get("Content-Type"), tt.wantType)
+			}
+
+			for _, part := range tt.wantParts {
+				if !strings.Contains(w.Body.String(), part) {
+					t.Errorf("Handle() body = %s, want it to contain %s", w.Body.String(), part)
+				}
+			}
+		})
+	}
+}
+
+func TestBatchIPHandler_Streaming(t *testing.T) {
+	t.Parallel()
+
+	db, err := database.Open(zap.NewNop(), "memory://", database.Options{})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+
+	t.Cleanup(func() { db.Close() })
+
+	hasher, err := iphash.New(config.HashAlgorithmHMACSHA256, []byte(strings.Repeat("s", config.MinHashSecretLength)), 0)
+	if err != nil {
+		t.Fatalf("Failed to create hasher: %v", err)
+	}
+
+	cfg := &config.Config{
+		Anonymize: config.Anonymize{
+			IPv4: config.Prefix{Length: 16, Min: 8, Max: 24},
+			IPv6: config.Prefix{Length: 48, Min: 48, Max: 48},
+		},
+		Batch: config.Batch{
+			MaxBodySize: 1024,
+			MaxItems:    10,
+			Enabled:     true,
+		},
+	}
+
+	var (
+		h   = handler.NewBatchIPHandler(cfg, db, hasher, zap.NewNop())
+		srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h.Handle(w, r, nil)
+		}))
+	)
+
+	t.Cleanup(srv.Close)
+
+	bodyReader, bodyWriter := io.Pipe()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, srv.URL+"/v1/ip/batch", bodyReader)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+
+	req.Header.Set("Content-Type", "text/plain")
+	req.Header.Set("Accept", "application/x-ndjson")
+
+	go func() {
+		// Blocks until the client starts sending the body.
+		_, _ = io.WriteString(bodyWriter, "192.0.2.1\n")
+	}()
+
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+
+	defer resp.Body.Close()
+
+	reader := bufio.NewReader(resp.Body)
+
+	// The last address hasn't been sent yet, so the first result can only
+	// arrive if it's flushed as soon as it's ready.
+	first, err := reader.ReadString('\n')
+	if err != nil {
+		t.Fatalf("Failed to read first result: %v", err)
+	}
+
+	if !strings.Contains(first, `"input":"192.0.2.1"`) {
+		t.Errorf("first result = %s, want it to hold 192.0.2.1", first)
+	}
+
+	if _, err = io.WriteString(bodyWriter, "2001:db8::1\n"); err != nil {
+		t.Fatalf("Failed to write last address: %v", err)
+	}
+
+	bodyWriter.Close()
+
+	rest, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("Failed to read remaining results: %v", err)
+	}
+
+	if want := "\"input\":\"2001:db8::1\",\"family\":\"ipv6\"}\n"; !strings.HasSuffix(string(rest), want) {
+		t.Errorf("remaining results = %s, want them to end with %s", rest, want)
+	}
+}
@@ -0,0 +1,143 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/netip"
+	"time"
+
+	"git.sr.ht/~jamesponddotco/accio127/internal/config"
+	"git.sr.ht/~jamesponddotco/accio127/internal/database"
+	"git.sr.ht/~jamesponddotco/accio127/internal/dualstack"
+	"git.sr.ht/~jamesponddotco/accio127/internal/endpoint"
+	"git.sr.ht/~jamesponddotco/accio127/internal/errors"
+	"git.sr.ht/~jamesponddotco/accio127/internal/requestid"
+	"git.sr.ht/~jamesponddotco/accio127/internal/server/model"
+	"git.sr.ht/~jamesponddotco/accio127/internal/server/render"
+	"git.sr.ht/~jamesponddotco/xstd-go/xnet/xhttp"
+	"github.com/julienschmidt/httprouter"
+	"go.uber.org/zap"
+)
+
+// DualStackHandler is an HTTP handler for the /ip/both endpoint.
+type DualStackHandler struct {
+	cfg    *config.Config
+	db     database.Store
+	sealer *dualstack.Sealer
+	logger *zap.Logger
+}
+
+// NewDualStackHandler creates a new DualStackHandler instance.
+func NewDualStackHandler(cfg *config.Config, db database.Store, sealer *dualstack.Sealer, logger *zap.Logger) *DualStackHandler {
+	return &DualStackHandler{
+		cfg:    cfg,
+		db:     db,
+		sealer: sealer,
+		logger: logger,
+	}
+}
+
+// ServeHTTP serves the /ip/both and /ip/both/{token} endpoints.
+//
+// The first issues a token holding the client's address, and the second adds
+// the client's address to the token it's given. Fetching the token over both
+// the IPv4-only and IPv6-only hostnames yields both addresses of the client.
+// Tokens aren't extended when followed up on, so the whole flow has to fit in
+// the TTL of the first one.
+func (h *DualStackHandler) Handle(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	logger := requestid.Logger(r.Context(), h.logger)
+
+	ip, err := ClientIP(r, h.cfg.Proxy)
+	if err != nil {
+		logger.Error("Failed to get client IP address", zap.Error(err))
+
+		errors.JSON(w, r, logger, errors.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Type:    errors.TypeIPParseFailure,
+			Message: "Failed to get IP address. Please try again later.",
+		})
+
+		return
+	}
+
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		logger.Error("Failed to parse client IP address", zap.Error(err))
+
+		errors.JSON(w, r, logger, errors.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Type:    errors.TypeIPParseFailure,
+			Message: "Failed to parse IP address. Please try again later.",
+		})
+
+		return
+	}
+
+	var (
+		now   = time.Now()
+		route = endpoint.IPBoth
+		token = h.sealer.Issue(now)
+	)
+
+	if sealed := ps.ByName(endpoint.TokenParam); sealed != "" {
+		route = endpoint.IPBothToken
+
+		token, err = h.sealer.Open(sealed, now)
+		if err != nil {
+			errors.JSON(w, r, logger, errors.ErrorResponse{
+				Code:    http.StatusBadRequest,
+				Type:    errors.TypeInvalidToken,
+				Message: "Token is invalid or expired. Request a new one from " + endpoint.IPBoth + ".",
+			})
+
+			return
+		}
+	}
+
+	token = token.With(addr)
+
+	sealed, err := h.sealer.Seal(token)
+	if err != nil {
+		logger.Error("Failed to seal dual-stack token", zap.Error(err))
+
+		errors.JSON(w, r, logger, errors.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Failed to issue token. Please try again later.",
+		})
+
+		return
+	}
+
+	response := model.NewDualStack(token, sealed, h.cfg.DualStack.IPv4URL, h.cfg.DualStack.IPv6URL, endpoint.IPBoth)
+
+	responseJSON, err := json.Marshal(response) //nolint:errchkjson // if we don't check here, another linter complains
+	if err != nil {
+		logger.Error("Failed to marshal dual-stack response to JSON", zap.Error(err))
+
+		errors.JSON(w, r, logger, errors.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Failed to marshal dual-stack response to JSON.",
+		})
+
+		return
+	}
+
+	// Tokens are single-client and short-lived, so they must never be served
+	// from a cache.
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set(xhttp.ContentType, xhttp.ApplicationJSON)
+
+	_, err = w.Write(responseJSON)
+	if err != nil {
+		logger.Error("Failed to write dual-stack JSON to response", zap.Error(err))
+
+		errors.JSON(w, r, logger, errors.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Failed to write dual-stack JSON to response.",
+		})
+
+		return
+	}
+
+	recordAccess(h.db, r, route, ip, render.JSON{}.Format())
+}
@@ -0,0 +1,105 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"git.sr.ht/~jamesponddotco/accio127/internal/config"
+	"git.sr.ht/~jamesponddotco/accio127/internal/database"
+	"git.sr.ht/~jamesponddotco/accio127/internal/dualstack"
+	"git.sr.ht/~jamesponddotco/accio127/internal/endpoint"
+	"git.sr.ht/~jamesponddotco/accio127/internal/server/handler"
+	"git.sr.ht/~jamesponddotco/accio127/internal/server/model"
+	"github.com/julienschmidt/httprouter"
+	"go.uber.org/zap"
+)
+
+func TestDualStackHandler(t *testing.T) {
+	t.Parallel()
+
+	db, err := database.Open(zap.NewNop(), "memory://", database.Options{})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+
+	t.Cleanup(func() { db.Close() })
+
+	sealer, err := dualstack.New([]byte("secret"), time.Minute)
+	if err != nil {
+		t.Fatalf("Failed to create sealer: %v", err)
+	}
+
+	cfg := &config.Config{
+		DualStack: config.DualStack{
+			IPv4URL: "https://ipv4.example.com",
+			IPv6URL: "https://ipv6.example.com",
+		},
+	}
+
+	h := handler.NewDualStackHandler(cfg, db, sealer, zap.NewNop())
+
+	// request fetches the token, or issues one if it's empty, from
+	// remoteAddr.
+	request := func(remoteAddr, token string) *httptest.ResponseRecorder {
+		var (
+			w  = httptest.NewRecorder()
+			r  = httptest.NewRequest(http.MethodGet, endpoint.IPBoth+"/"+token, http.NoBody)
+			ps httprouter.Params
+		)
+
+		if token != "" {
+			ps = httprouter.Params{{Key: endpoint.TokenParam, Value: token}}
+		}
+
+		r.RemoteAddr = remoteAddr
+
+		h.Handle(w, r, ps)
+
+		return w
+	}
+
+	var issued model.DualStack
+
+	w := request("192.0.2.1:1234", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Handle() issue code = %d, want %d", w.Code, http.StatusOK)
+	}
+
+	if err = json.Unmarshal(w.Body.Bytes(), &issued); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+
+	if issued.Complete || issued.IP.V4 != "192.0.2.1" || issued.IP.V6 != "" {
+		t.Errorf("Handle() issue = %+v, want only the IPv4 address", issued)
+	}
+
+	if want := "https://ipv6.example.com/v1/ip/both/" + issued.Token; issued.IPv6URL != want {
+		t.Errorf("Handle() issue IPv6URL = %q, want %q", issued.IPv6URL, want)
+	}
+
+	var completed model.DualStack
+
+	w = request("[2001:db8::1]:1234", issued.Token)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Handle() token code = %d, want %d", w.Code, http.StatusOK)
+	}
+
+	if err = json.Unmarshal(w.Body.Bytes(), &completed); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+
+	if !completed.Complete || completed.IP.V4 != "192.0.2.1" || completed.IP.V6 != "2001:db8::1" {
+		t.Errorf("Handle() token = %+v, want both addresses", completed)
+	}
+
+	if completed.Expires != issued.Expires {
+		t.Errorf("Handle() token expires = %q, want %q", completed.Expires, issued.Expires)
+	}
+
+	if w = request("192.0.2.1:1234", "garbage"); w.Code != http.StatusBadRequest {
+		t.Errorf("Handle() invalid token code = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/netip"
+
+	"git.sr.ht/~jamesponddotco/accio127/internal/config"
+	"git.sr.ht/~jamesponddotco/accio127/internal/database"
+	"git.sr.ht/~jamesponddotco/accio127/internal/endpoint"
+	"git.sr.ht/~jamesponddotco/accio127/internal/errors"
+	"git.sr.ht/~jamesponddotco/accio127/internal/ipinfo"
+	"git.sr.ht/~jamesponddotco/accio127/internal/requestid"
+	"git.sr.ht/~jamesponddotco/accio127/internal/server/model"
+	"git.sr.ht/~jamesponddotco/accio127/internal/server/render"
+	"git.sr.ht/~jamesponddotco/xstd-go/xnet/xhttp"
+	"github.com/julienschmidt/httprouter"
+	"go.uber.org/zap"
+)
+
+// IPDetailsHandler is an HTTP handler for the /ip/details endpoint.
+type IPDetailsHandler struct {
+	cfg    *config.Config
+	db     database.Store
+	logger *zap.Logger
+}
+
+// NewIPDetailsHandler creates a new IPDetailsHandler instance.
+func NewIPDetailsHandler(cfg *config.Config, db database.Store, logger *zap.Logger) *IPDetailsHandler {
+	return &IPDetailsHandler{
+		cfg:    cfg,
+		db:     db,
+		logger: logger,
+	}
+}
+
+// ServeHTTP serves the /ip/details endpoint.
+func (h *IPDetailsHandler) Handle(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	logger := requestid.Logger(r.Context(), h.logger)
+
+	ip, err := ClientIP(r, h.cfg.Proxy)
+	if err != nil {
+		logger.Error("Failed to get client IP address", zap.Error(err))
+
+		errors.JSON(w, r, logger, errors.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Type:    errors.TypeIPParseFailure,
+			Message: "Failed to get IP address. Please try again later.",
+		})
+
+		return
+	}
+
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		logger.Error("Failed to parse client IP address", zap.Error(err))
+
+		errors.JSON(w, r, logger, errors.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Type:    errors.TypeIPParseFailure,
+			Message: "Failed to parse IP address. Please try again later.",
+		})
+
+		return
+	}
+
+	detailsJSON, err := json.Marshal(model.NewIPDetails(ipinfo.Classify(addr))) //nolint:errchkjson // if we don't check here, another linter complains
+	if err != nil {
+		logger.Error("Failed to marshal IP details to JSON", zap.Error(err))
+
+		errors.JSON(w, r, logger, errors.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Failed to marshal IP details to JSON.",
+		})
+
+		return
+	}
+
+	w.Header().Set(xhttp.ContentType, xhttp.ApplicationJSON)
+
+	_, err = w.Write(detailsJSON)
+	if err != nil {
+		logger.Error("Failed to write IP details JSON to response", zap.Error(err))
+
+		errors.JSON(w, r, logger, errors.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Failed to write IP details JSON to response.",
+		})
+
+		return
+	}
+
+	recordAccess(h.db, r, endpoint.IPDetails, ip, render.JSON{}.Format())
+}
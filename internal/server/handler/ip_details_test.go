@@ -0,0 +1,95 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"git.sr.ht/~jamesponddotco/accio127/internal/config"
+	"git.sr.ht/~jamesponddotco/accio127/internal/database"
+	"git.sr.ht/~jamesponddotco/accio127/internal/server/handler"
+	"git.sr.ht/~jamesponddotco/accio127/internal/server/model"
+	"go.uber.org/zap"
+)
+
+func TestIPDetailsHandler(t *testing.T) {
+	t.Parallel()
+
+	db, err := database.Open(zap.NewNop(), "memory://", database.Options{})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+
+	t.Cleanup(func() { db.Close() })
+
+	h := handler.NewIPDetailsHandler(&config.Config{}, db, zap.NewNop())
+
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		wantIP       string
+		wantVersion  int
+		wantEmbedded *model.EmbeddedIPv4
+	}{
+		{
+			name:        "ipv4",
+			remoteAddr:  "192.0.2.1:1234",
+			wantIP:      "192.0.2.1",
+			wantVersion: 4,
+		},
+		{
+			// IPv4 clients of dual-stack sockets show up as IPv4-mapped
+			// addresses, which are reported as the IPv4 address they are.
+			name:        "ipv4_mapped",
+			remoteAddr:  "[::ffff:192.0.2.1]:1234",
+			wantIP:      "192.0.2.1",
+			wantVersion: 4,
+		},
+		{
+			name:         "6to4",
+			remoteAddr:   "[2002:c000:201::1]:1234",
+			wantIP:       "2002:c000:201::1",
+			wantVersion:  6,
+			wantEmbedded: &model.EmbeddedIPv4{Type: "6to4", IP: "192.0.2.1"},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var (
+				w = httptest.NewRecorder()
+				r = httptest.NewRequest(http.MethodGet, "/v1/ip/details", http.NoBody)
+			)
+
+			r.RemoteAddr = tt.remoteAddr
+
+			h.Handle(w, r, nil)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("Handle() code = %d, want %d; body = %s", w.Code, http.StatusOK, w.Body.String())
+			}
+
+			var got model.IPDetails
+
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+
+			if got.IP != tt.wantIP || got.Version != tt.wantVersion {
+				t.Errorf("Handle() = %s version %d, want %s version %d", got.IP, got.Version, tt.wantIP, tt.wantVersion)
+			}
+
+			switch {
+			case tt.wantEmbedded == nil && got.EmbeddedIPv4 != nil:
+				t.Errorf("Handle() embeddedIPv4 = %+v, want none", got.EmbeddedIPv4)
+			case tt.wantEmbedded != nil && (got.EmbeddedIPv4 == nil || *got.EmbeddedIPv4 != *tt.wantEmbedded):
+				t.Errorf("Handle() embeddedIPv4 = %+v, want %+v", got.EmbeddedIPv4, tt.wantEmbedded)
+			}
+		})
+	}
+}
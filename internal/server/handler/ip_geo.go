@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/netip"
+
+	"git.sr.ht/~jamesponddotco/accio127/internal/config"
+	"git.sr.ht/~jamesponddotco/accio127/internal/database"
+	"git.sr.ht/~jamesponddotco/accio127/internal/endpoint"
+	"git.sr.ht/~jamesponddotco/accio127/internal/errors"
+	"git.sr.ht/~jamesponddotco/accio127/internal/geoip"
+	"git.sr.ht/~jamesponddotco/accio127/internal/requestid"
+	"git.sr.ht/~jamesponddotco/accio127/internal/server/model"
+	"git.sr.ht/~jamesponddotco/accio127/internal/server/render"
+	"git.sr.ht/~jamesponddotco/xstd-go/xnet/xhttp"
+	"github.com/julienschmidt/httprouter"
+	"go.uber.org/zap"
+)
+
+// GeoIPHandler is an HTTP handler for the /ip/geo endpoint.
+type GeoIPHandler struct {
+	cfg    *config.Config
+	db     database.Store
+	geo    *geoip.Reader
+	logger *zap.Logger
+}
+
+// NewGeoIPHandler creates a new GeoIPHandler instance.
+func NewGeoIPHandler(cfg *config.Config, db database.Store, geo *geoip.Reader, logger *zap.Logger) *GeoIPHandler {
+	return &GeoIPHandler{
+		cfg:    cfg,
+		db:     db,
+		geo:    geo,
+		logger: logger,
+	}
+}
+
+// ServeHTTP serves the /ip/geo endpoint.
+func (h *GeoIPHandler) Handle(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	logger := requestid.Logger(r.Context(), h.logger)
+
+	ip, err := ClientIP(r, h.cfg.Proxy)
+	if err != nil {
+		logger.Error("Failed to get client IP address", zap.Error(err))
+
+		errors.JSON(w, r, logger, errors.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Type:    errors.TypeIPParseFailure,
+			Message: "Failed to get IP address. Please try again later.",
+		})
+
+		return
+	}
+
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		logger.Error("Failed to parse client IP address", zap.Error(err))
+
+		errors.JSON(w, r, logger, errors.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Type:    errors.TypeIPParseFailure,
+			Message: "Failed to parse IP address. Please try again later.",
+		})
+
+		return
+	}
+
+	location, err := h.geo.Lookup(addr)
+	if err != nil {
+		logger.Error("Failed to look up client IP address", zap.Error(err))
+
+		errors.JSON(w, r, logger, errors.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Failed to look up IP address. Please try again later.",
+		})
+
+		return
+	}
+
+	geoJSON, err := json.Marshal(model.NewGeo(ip, location)) //nolint:errchkjson // if we don't check here, another linter complains
+	if err != nil {
+		logger.Error("Failed to marshal GeoIP data to JSON", zap.Error(err))
+
+		errors.JSON(w, r, logger, errors.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Failed to marshal GeoIP data to JSON.",
+		})
+
+		return
+	}
+
+	w.Header().Set(xhttp.ContentType, xhttp.ApplicationJSON)
+
+	_, err = w.Write(geoJSON)
+	if err != nil {
+		logger.Error("Failed to write GeoIP JSON to response", zap.Error(err))
+
+		errors.JSON(w, r, logger, errors.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Failed to write GeoIP JSON to response.",
+		})
+
+		return
+	}
+
+	recordAccess(h.db, r, endpoint.IPGeo, ip, render.JSON{}.Format())
+}
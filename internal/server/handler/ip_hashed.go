@@ -1,110 +1,87 @@
 package handler
 
 import (
-	"crypto/sha256"
-	"encoding/hex"
-	"encoding/json"
 	"net/http"
+	"time"
 
 	"git.sr.ht/~jamesponddotco/accio127/internal/config"
 	"git.sr.ht/~jamesponddotco/accio127/internal/database"
-	"git.sr.ht/~jamesponddotco/accio127/internal/errors"
+	"git.sr.ht/~jamesponddotco/accio127/internal/endpoint"
+	"git.sr.ht/~jamesponddotco/accio127/internal/iphash"
+	"git.sr.ht/~jamesponddotco/accio127/internal/requestid"
 	"git.sr.ht/~jamesponddotco/accio127/internal/server/model"
-	"git.sr.ht/~jamesponddotco/xstd-go/xnet/xhttp"
+	"git.sr.ht/~jamesponddotco/accio127/internal/server/render"
 	"github.com/julienschmidt/httprouter"
 	"go.uber.org/zap"
 )
 
 // HashedIPHandler is an HTTP handler for the /ip/hashed endpoint.
 type HashedIPHandler struct {
-	cfg    *config.Config
-	db     *database.DB
-	logger *zap.Logger
+	cfg        *config.Config
+	db         database.Store
+	hasher     *iphash.Hasher
+	negotiator *render.Negotiator
+	logger     *zap.Logger
 }
 
 // NewHashedIPHandler returns a new HashedIPHandler instance.
-func NewHashedIPHandler(cfg *config.Config, db *database.DB, logger *zap.Logger) *HashedIPHandler {
+func NewHashedIPHandler(
+	cfg *config.Config,
+	db database.Store,
+	hasher *iphash.Hasher,
+	negotiator *render.Negotiator,
+	logger *zap.Logger,
+) *HashedIPHandler {
 	return &HashedIPHandler{
-		cfg:    cfg,
-		db:     db,
-		logger: logger,
+		cfg:        cfg,
+		db:         db,
+		hasher:     hasher,
+		negotiator: negotiator,
+		logger:     logger,
 	}
 }
 
-// ServeHTTP serves the /ip/hashed endpoint.
-func (h *HashedIPHandler) Handle(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
-	ip, err := ClientIP(r, h.cfg.Proxy)
-	if err != nil {
-		h.logger.Error("Failed to get client IP address", zap.Error(err))
-
-		errors.JSON(w, h.logger, errors.ErrorResponse{
-			Code:    http.StatusInternalServerError,
-			Message: "Failed to get IP address. Please try again later.",
-		})
+// ServeHTTP serves the /ip/hashed and /ip/{address}/hashed endpoints.
+func (h *HashedIPHandler) Handle(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	logger := requestid.Logger(r.Context(), h.logger)
 
+	renderer := negotiate(w, r, h.negotiator, logger)
+	if renderer == nil {
 		return
 	}
 
-	var (
-		hashedIP    = HashIP(ip)
-		contentType = r.Header.Get(xhttp.ContentType)
-	)
-
-	if contentType == xhttp.ApplicationJSON {
-		w.Header().Set(xhttp.ContentType, xhttp.ApplicationJSON)
-
-		ipModel := model.NewIP(hashedIP)
-
-		ipJSON, err := json.Marshal(ipModel) //nolint:errchkjson // if we don't check here, another linter complains
-		if err != nil {
-			h.logger.Error("Failed to marshal IP address to JSON", zap.Error(err))
-
-			errors.JSON(w, h.logger, errors.ErrorResponse{
-				Code:    http.StatusInternalServerError,
-				Message: "Failed to marshal IP address to JSON. Please try again later.",
-			})
-
-			return
-		}
-
-		_, err = w.Write(ipJSON)
-		if err != nil {
-			h.logger.Error("Failed to write IP address JSON to response", zap.Error(err))
-
-			errors.JSON(w, h.logger, errors.ErrorResponse{
-				Code:    http.StatusInternalServerError,
-				Message: "Failed to write IP address JSON to response. Please try again later.",
-			})
+	ip, ok := targetIP(w, r, ps, h.cfg.Proxy, logger)
+	if !ok {
+		return
+	}
 
-			return
-		}
-	} else {
-		w.Header().Set(xhttp.ContentType, xhttp.TextPlain)
+	if !writeIP(w, r, renderer, hashIP(h.hasher, ip, time.Now()), logger) {
+		return
+	}
 
-		_, err := w.Write([]byte(hashedIP))
-		if err != nil {
-			h.logger.Error("Failed to write IP address to response", zap.Error(err))
+	recordAccess(h.db, r, lookupRoute(ps, endpoint.IPHashed, endpoint.IPLookupHashed), ip, renderer.Format())
+}
 
-			errors.JSON(w, h.logger, errors.ErrorResponse{
-				Code:    http.StatusInternalServerError,
-				Message: "Failed to write IP address to response. Please try again later.",
-			})
+// hashIP hashes ip with the key in use at now, returning nil if ip isn't a
+// valid IP address.
+func hashIP(hasher *iphash.Hasher, ip string, now time.Time) *model.IP {
+	var (
+		result   = hasher.Hash(ip, now)
+		hashedIP = model.NewHashedIP(ip, result.Hash)
+	)
 
-			return
-		}
+	if hashedIP == nil {
+		return nil
 	}
 
-	go func() {
-		_, err := h.db.Increment()
-		if err != nil {
-			h.logger.Error("Failed to increment access counter", zap.Error(err))
-		}
-	}()
-}
+	hashedIP.Key = &model.HashKey{
+		ID:    result.KeyID,
+		Epoch: result.Epoch,
+	}
 
-// HashIP hashes an IP address using SHA256.
-func HashIP(ip string) string {
-	hash := sha256.Sum256([]byte(ip))
+	if !result.Expires.IsZero() {
+		hashedIP.Key.Expires = result.Expires.Format(time.RFC3339)
+	}
 
-	return hex.EncodeToString(hash[:])
+	return hashedIP
 }
@@ -0,0 +1,282 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"git.sr.ht/~jamesponddotco/accio127/internal/config"
+	"git.sr.ht/~jamesponddotco/accio127/internal/database"
+	"git.sr.ht/~jamesponddotco/accio127/internal/endpoint"
+	"git.sr.ht/~jamesponddotco/accio127/internal/server/handler"
+	"git.sr.ht/~jamesponddotco/accio127/internal/server/render"
+	"github.com/julienschmidt/httprouter"
+	"go.uber.org/zap"
+)
+
+func TestClientIP(t *testing.T) {
+	t.Parallel()
+
+	var proxies config.Proxies
+
+	err := json.Unmarshal([]byte(`[
+		{"addresses": ["198.51.100.0/24"], "headers": ["CF-Connecting-IP", "X-Forwarded-For"]},
+		{"addresses": ["10.0.0.0/8", "fd00::/8"], "headers": ["Forwarded", "X-Forwarded-For"]}
+	]`), &proxies)
+	if err != nil {
+		t.Fatalf("Failed to parse proxies: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		headers    map[string][]string
+		want       string
+		wantErr    bool
+	}{
+		{
+			name:       "untrusted_remote_ignores_headers",
+			remoteAddr: "203.0.113.9:1234",
+			headers:    map[string][]string{"X-Forwarded-For": {"192.0.2.1"}},
+			want:       "203.0.113.9",
+		},
+		{
+			name:       "ipv6_remote",
+			remoteAddr: "[2001:db8::1]:1234",
+			want:       "2001:db8::1",
+		},
+		{
+			name:       "trusted_remote_without_headers",
+			remoteAddr: "10.0.0.1:1234",
+			want:       "10.0.0.1",
+		},
+		{
+			name:       "rightmost_untrusted_entry",
+			remoteAddr: "10.0.0.1:1234",
+			headers:    map[string][]string{"X-Forwarded-For": {"6.6.6.6, 192.0.2.1, 198.51.100.7"}},
+			want:       "192.0.2.1",
+		},
+		{
+			name:       "multiple_header_lines",
+			remoteAddr: "10.0.0.1:1234",
+			headers:    map[string][]string{"X-Forwarded-For": {"6.6.6.6", "192.0.2.1"}},
+			want:       "192.0.2.1",
+		},
+		{
+			name:       "all_entries_trusted",
+			remoteAddr: "10.0.0.1:1234",
+			headers:    map[string][]string{"X-Forwarded-For": {"10.0.0.3, 10.0.0.2"}},
+			want:       "10.0.0.3",
+		},
+		{
+			name:       "invalid_entry_stops_walk",
+			remoteAddr: "10.0.0.1:1234",
+			headers:    map[string][]string{"X-Forwarded-For": {"192.0.2.1, garbage, 10.0.0.2"}},
+			want:       "10.0.0.1",
+		},
+		{
+			name:       "forwarded_takes_precedence",
+			remoteAddr: "10.0.0.1:1234",
+			headers: map[string][]string{
+				"Forwarded":       {`for=192.0.2.60;proto=https, for="[2001:db8:cafe::17]:4711"`},
+				"X-Forwarded-For": {"192.0.2.1"},
+			},
+			want: "2001:db8:cafe::17",
+		},
+		{
+			name:       "header_not_trusted_for_group",
+			remoteAddr: "10.0.0.1:1234",
+			headers:    map[string][]string{"CF-Connecting-IP": {"192.0.2.1"}},
+			want:       "10.0.0.1",
+		},
+		{
+			name:       "group_header_order",
+			remoteAddr: "198.51.100.1:1234",
+			headers: map[string][]string{
+				"CF-Connecting-IP": {"192.0.2.1"},
+				"X-Forwarded-For":  {"192.0.2.2"},
+			},
+			want: "192.0.2.1",
+		},
+		{
+			name:       "mapped_ipv4_remote",
+			remoteAddr: "[::ffff:10.0.0.1]:1234",
+			headers:    map[string][]string{"X-Forwarded-For": {"192.0.2.1"}},
+			want:       "192.0.2.1",
+		},
+		{
+			name:       "quic_dual_stack_socket",
+			remoteAddr: "[::ffff:203.0.113.9]:51234",
+			want:       "203.0.113.9",
+		},
+		{
+			name:       "quic_link_local_with_zone",
+			remoteAddr: "[fe80::1%eth0]:51234",
+			want:       "fe80::1",
+		},
+		{
+			name:       "invalid_remote_address",
+			remoteAddr: "invalid",
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			req, err := http.NewRequest(http.MethodGet, "http://localhost/", http.NoBody)
+			if err != nil {
+				t.Fatalf("Failed to create request: %v", err)
+			}
+
+			req.RemoteAddr = tt.remoteAddr
+
+			for key, values := range tt.headers {
+				for _, value := range values {
+					req.Header.Add(key, value)
+				}
+			}
+
+			got, err := handler.ClientIP(req, proxies)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ClientIP() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Errorf("ClientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIPHandler_Lookup(t *testing.T) {
+	t.Parallel()
+
+	db, err := database.Open(zap.NewNop(), "memory://", database.Options{})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+
+	t.Cleanup(func() { db.Close() })
+
+	h := handler.NewIPHandler(&config.Config{}, db, render.DefaultNegotiator(), zap.NewNop())
+
+	tests := []struct {
+		name     string
+		give     string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "ipv4",
+			give:     "192.0.2.1",
+			wantCode: http.StatusOK,
+			wantBody: "192.0.2.1",
+		},
+		{
+			name:     "ipv6",
+			give:     "2001:db8::68",
+			wantCode: http.StatusOK,
+			wantBody: "2001:db8::68",
+		},
+		{
+			name:     "ipv4_mapped",
+			give:     "::ffff:192.0.2.1",
+			wantCode: http.StatusOK,
+			wantBody: "192.0.2.1",
+		},
+		{
+			name:     "zone",
+			give:     "fe80::1%eth0",
+			wantCode: http.StatusOK,
+			wantBody: "fe80::1",
+		},
+		{
+			name:     "invalid",
+			give:     "999.999.999.999",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "hostname",
+			give:     "example.com",
+			wantCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var (
+				w  = httptest.NewRecorder()
+				r  = httptest.NewRequest(http.MethodGet, "/v1/ip/"+url.PathEscape(tt.give), http.NoBody)
+				ps = httprouter.Params{{Key: endpoint.AddressParam, Value: tt.give}}
+			)
+
+			h.Handle(w, r, ps)
+
+			if w.Code != tt.wantCode {
+				t.Fatalf("Handle() code = %d, want %d", w.Code, tt.wantCode)
+			}
+
+			if tt.wantBody != "" && w.Body.String() != tt.wantBody {
+				t.Errorf("Handle() body = %q, want %q", w.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestIPHandler_Head(t *testing.T) {
+	t.Parallel()
+
+	db, err := database.Open(zap.NewNop(), "memory://", database.Options{})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+
+	t.Cleanup(func() { db.Close() })
+
+	h := handler.NewIPHandler(&config.Config{}, db, render.DefaultNegotiator(), zap.NewNop())
+
+	tests := []struct {
+		method    string
+		wantCount uint64
+	}{
+		{
+			method:    http.MethodHead,
+			wantCount: 0,
+		},
+		{
+			method:    http.MethodGet,
+			wantCount: 1,
+		},
+	}
+
+	start := db.Count()
+
+	for _, tt := range tests {
+		var (
+			w = httptest.NewRecorder()
+			r = httptest.NewRequest(tt.method, "/v1/ip", http.NoBody)
+		)
+
+		r.RemoteAddr = "192.0.2.1:1234"
+
+		h.Handle(w, r, nil)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Handle() %s code = %d, want %d", tt.method, w.Code, http.StatusOK)
+		}
+
+		if got := db.Count() - start; got != tt.wantCount {
+			t.Errorf("Handle() %s count = %d, want %d", tt.method, got, tt.wantCount)
+		}
+	}
+}
@@ -1,43 +1,110 @@
 package handler
 
 import (
+	"bytes"
 	"encoding/json"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	"git.sr.ht/~jamesponddotco/accio127/internal/accept"
+	"git.sr.ht/~jamesponddotco/accio127/internal/build"
 	"git.sr.ht/~jamesponddotco/accio127/internal/database"
 	"git.sr.ht/~jamesponddotco/accio127/internal/errors"
+	"git.sr.ht/~jamesponddotco/accio127/internal/jsonutil"
+	"git.sr.ht/~jamesponddotco/accio127/internal/metrics"
+	"git.sr.ht/~jamesponddotco/accio127/internal/requestid"
 	"git.sr.ht/~jamesponddotco/accio127/internal/server/model"
 	"git.sr.ht/~jamesponddotco/xstd-go/xnet/xhttp"
 	"github.com/julienschmidt/httprouter"
 	"go.uber.org/zap"
 )
 
+const (
+	// DefaultStatisticsDays is the number of days, ending today, covered by
+	// the statistics if no date range is given.
+	DefaultStatisticsDays int = 30
+
+	// MaxStatisticsDays is the largest date range, in days, statistics can be
+	// requested for.
+	MaxStatisticsDays int = 366
+)
+
+// Query parameters accepted by the /metrics endpoint.
+const (
+	fromParam  string = "from"
+	toParam    string = "to"
+	groupParam string = "group"
+)
+
 // MetricsHandler is an HTTP handler for the /metrics endpoint.
 type MetricsHandler struct {
-	db     *database.DB
-	logger *zap.Logger
+	db       database.Store
+	registry *metrics.Registry
+	logger   *zap.Logger
 }
 
 // NewMetricsHandler creates a new MetricsHandler instance.
-func NewMetricsHandler(db *database.DB, logger *zap.Logger) *MetricsHandler {
+func NewMetricsHandler(db database.Store, registry *metrics.Registry, logger *zap.Logger) *MetricsHandler {
 	return &MetricsHandler{
-		db:     db,
-		logger: logger,
+		db:       db,
+		registry: registry,
+		logger:   logger,
 	}
 }
 
 // ServeHTTP serves the /metrics endpoint.
-func (h *MetricsHandler) Handle(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+//
+// Clients preferring the OpenMetrics or Prometheus text formats in their
+// Accept header, such as Prometheus itself, receive every metric of the
+// service. Everyone else receives the access counter as JSON, with a
+// breakdown of the accesses if the from, to, or group query parameters are
+// given.
+func (h *MetricsHandler) Handle(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	logger := requestid.Logger(r.Context(), h.logger)
+
+	w.Header().Add(xhttp.Vary, xhttp.Accept)
+
+	mediaType, err := accept.Preferred(r, xhttp.ApplicationJSON, metrics.OpenMetricsType, metrics.PrometheusType)
+	if err != nil {
+		errors.JSON(w, r, logger, errors.ErrorResponse{
+			Code: http.StatusNotAcceptable,
+			Message: "Requested format is not available. Supported media types: " +
+				strings.Join([]string{xhttp.ApplicationJSON, metrics.OpenMetricsType, metrics.PrometheusType}, ", ") + ".",
+		})
+
+		return
+	}
+
+	if mediaType != xhttp.ApplicationJSON {
+		h.exposition(w, r, mediaType == metrics.OpenMetricsType, logger)
+
+		return
+	}
+
 	var (
 		count   = h.db.Count()
 		counter = model.NewCounter(count)
+		values  = r.URL.Query()
 	)
 
+	counter.FlushLag = jsonutil.Duration(h.db.FlushLag())
+
+	if values.Has(fromParam) || values.Has(toParam) || values.Has(groupParam) {
+		statistics, ok := h.statistics(w, r, logger)
+		if !ok {
+			return
+		}
+
+		counter.Statistics = statistics
+	}
+
 	counterJSON, err := json.Marshal(counter) //nolint:errchkjson // if we don't check here, another linter complains
 	if err != nil {
-		h.logger.Error("Failed to marshal access counter to JSON", zap.Error(err))
+		logger.Error("Failed to marshal access counter to JSON", zap.Error(err))
 
-		errors.JSON(w, h.logger, errors.ErrorResponse{
+		errors.JSON(w, r, logger, errors.ErrorResponse{
 			Code:    http.StatusInternalServerError,
 			Message: "Failed to marshal access counter to JSON.",
 		})
@@ -49,9 +116,9 @@ func (h *MetricsHandler) Handle(w http.ResponseWriter, _ *http.Request, _ httpro
 
 	_, err = w.Write(counterJSON)
 	if err != nil {
-		h.logger.Error("Failed to write access counter JSON to response", zap.Error(err))
+		logger.Error("Failed to write access counter JSON to response", zap.Error(err))
 
-		errors.JSON(w, h.logger, errors.ErrorResponse{
+		errors.JSON(w, r, logger, errors.ErrorResponse{
 			Code:    http.StatusInternalServerError,
 			Message: "Failed to write access counter JSON to response.",
 		})
@@ -59,3 +126,168 @@ func (h *MetricsHandler) Handle(w http.ResponseWriter, _ *http.Request, _ httpro
 		return
 	}
 }
+
+// exposition writes every metric of the service in the OpenMetrics text
+// format, or in the Prometheus text format if openMetrics is false.
+func (h *MetricsHandler) exposition(w http.ResponseWriter, r *http.Request, openMetrics bool, logger *zap.Logger) {
+	var (
+		body    bytes.Buffer
+		encoder = metrics.NewEncoder(&body, openMetrics)
+		up      float64
+	)
+
+	start := time.Now()
+
+	if err := h.db.Ping(); err != nil {
+		logger.Warn("Database is offline", zap.Error(err))
+	} else {
+		up = 1
+	}
+
+	ping := time.Since(start)
+
+	encoder.Info("accio127_build", "Build information.",
+		metrics.Label{Name: "version", Value: build.Version},
+		metrics.Label{Name: "api_version", Value: build.APIVersion},
+	)
+	encoder.Counter("accio127_accesses", "Accesses to the IP endpoints since the database was created.", metrics.Sample{
+		Value: float64(h.db.Count()),
+	})
+	encoder.Gauge("accio127_counter_flush_lag_seconds", "Age of the oldest access not yet written to the database.", metrics.Sample{
+		Value: h.db.FlushLag().Seconds(),
+	})
+	encoder.Gauge("accio127_database_up", "Whether the database answered the last ping.", metrics.Sample{
+		Value: up,
+	})
+	encoder.Gauge("accio127_database_ping_duration_seconds", "Duration of the last database ping.", metrics.Sample{
+		Value: ping.Seconds(),
+	})
+
+	h.registry.Write(encoder)
+	metrics.WriteRuntime(encoder)
+
+	if err := encoder.Close(); err != nil {
+		logger.Error("Failed to encode metrics", zap.Error(err))
+
+		errors.JSON(w, r, logger, errors.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Failed to encode metrics.",
+		})
+
+		return
+	}
+
+	w.Header().Set(xhttp.ContentType, encoder.ContentType())
+
+	if _, err := w.Write(body.Bytes()); err != nil {
+		logger.Error("Failed to write metrics to response", zap.Error(err))
+	}
+}
+
+// statistics queries the statistics requested by r. It returns false if the
+// request was already answered with an error.
+func (h *MetricsHandler) statistics(w http.ResponseWriter, r *http.Request, logger *zap.Logger) (*model.Statistics, bool) {
+	query, message := parseStatisticsQuery(r, time.Now())
+	if message != "" {
+		errors.JSON(w, r, logger, errors.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: message,
+		})
+
+		return nil, false
+	}
+
+	rows, err := h.db.Statistics(query)
+	if err != nil {
+		logger.Error("Failed to query access statistics", zap.Error(err))
+
+		errors.JSON(w, r, logger, errors.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Failed to query access statistics. Please try again later.",
+		})
+
+		return nil, false
+	}
+
+	entries := make([]model.Statistic, 0, len(rows))
+
+	for _, row := range rows {
+		entries = append(entries, model.Statistic{
+			Day:      row.Day,
+			Endpoint: row.Endpoint,
+			Family:   row.Family,
+			Format:   row.Format,
+			Count:    row.Count,
+		})
+	}
+
+	return model.NewStatistics(
+		query.From.Format(database.DayLayout),
+		query.To.Format(database.DayLayout),
+		query.GroupBy,
+		entries,
+	), true
+}
+
+// parseStatisticsQuery reads the statistics query from the request's query
+// parameters. If the parameters are invalid, it returns a message explaining
+// why.
+func parseStatisticsQuery(r *http.Request, now time.Time) (database.StatisticsQuery, string) {
+	var (
+		values = r.URL.Query()
+		query  = database.StatisticsQuery{
+			To: now.UTC().Truncate(24 * time.Hour),
+		}
+		err error
+	)
+
+	if to := values.Get(toParam); to != "" {
+		query.To, err = time.Parse(database.DayLayout, to)
+		if err != nil {
+			return query, "Invalid 'to' date. Please use the YYYY-MM-DD format."
+		}
+	}
+
+	query.From = query.To.AddDate(0, 0, 1-DefaultStatisticsDays)
+
+	if from := values.Get(fromParam); from != "" {
+		query.From, err = time.Parse(database.DayLayout, from)
+		if err != nil {
+			return query, "Invalid 'from' date. Please use the YYYY-MM-DD format."
+		}
+	}
+
+	if query.From.After(query.To) {
+		return query, "Invalid date range. The 'from' date must not be after the 'to' date."
+	}
+
+	if query.To.Sub(query.From) >= time.Duration(MaxStatisticsDays)*24*time.Hour {
+		return query, "Invalid date range. Statistics are limited to " + strconv.Itoa(MaxStatisticsDays) + " days per request."
+	}
+
+	for _, group := range strings.Split(values.Get(groupParam), ",") {
+		group = strings.TrimSpace(group)
+		if group == "" || contains(query.GroupBy, group) {
+			continue
+		}
+
+		if !contains(database.Groups(), group) {
+			return query, "Invalid group '" + group + "'. Supported groups: " + strings.Join(database.Groups(), ", ") + "."
+		}
+
+		query.GroupBy = append(query.GroupBy, group)
+	}
+
+	return query, ""
+}
+
+// contains reports whether values contains value.
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"git.sr.ht/~jamesponddotco/accio127/internal/errors"
+	"git.sr.ht/~jamesponddotco/accio127/internal/requestid"
+	"github.com/julienschmidt/httprouter"
+	"go.uber.org/zap"
+)
+
+// httpsPort is the default port of HTTPS, left out of redirect URLs.
+const httpsPort int = 443
+
+// RedirectHandler is an HTTP handler redirecting every request to HTTPS.
+type RedirectHandler struct {
+	logger *zap.Logger
+	port   int
+}
+
+// NewRedirectHandler creates a new RedirectHandler instance sending clients
+// to port on the host they asked for.
+func NewRedirectHandler(port int, logger *zap.Logger) *RedirectHandler {
+	return &RedirectHandler{
+		logger: logger,
+		port:   port,
+	}
+}
+
+// Handle redirects the request to the same host and path over HTTPS. The
+// redirect is permanent and keeps the method, so that POST requests to
+// /ip/batch are redirected too.
+func (h *RedirectHandler) Handle(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	logger := requestid.Logger(r.Context(), h.logger)
+
+	host := r.Host
+	if splitHost, _, err := net.SplitHostPort(host); err == nil {
+		host = splitHost
+	}
+
+	host = strings.Trim(host, "[]")
+	if host == "" {
+		errors.JSON(w, r, logger, errors.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Host header is missing. Please use HTTPS.",
+		})
+
+		return
+	}
+
+	authority := hostLiteral(host)
+	if h.port != httpsPort {
+		authority = net.JoinHostPort(host, strconv.Itoa(h.port))
+	}
+
+	http.Redirect(w, r, "https://"+authority+r.URL.RequestURI(), http.StatusPermanentRedirect)
+}
+
+// hostLiteral returns host as it's written in a URL, with IPv6 addresses
+// enclosed in brackets.
+func hostLiteral(host string) string {
+	if strings.Contains(host, ":") {
+		return "[" + host + "]"
+	}
+
+	return host
+}
@@ -0,0 +1,87 @@
+package handler_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"git.sr.ht/~jamesponddotco/accio127/internal/server/handler"
+	"go.uber.org/zap"
+)
+
+func TestRedirectHandler(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		port     int
+		host     string
+		target   string
+		wantCode int
+		want     string
+	}{
+		{
+			name:     "default_port",
+			port:     443,
+			host:     "api.example.com",
+			target:   "/v1/ip?format=json",
+			wantCode: http.StatusPermanentRedirect,
+			want:     "https://api.example.com/v1/ip?format=json",
+		},
+		{
+			name:     "host_with_port",
+			port:     443,
+			host:     "api.example.com:80",
+			target:   "/v1/ip",
+			wantCode: http.StatusPermanentRedirect,
+			want:     "https://api.example.com/v1/ip",
+		},
+		{
+			name:     "custom_port",
+			port:     1997,
+			host:     "api.example.com:8080",
+			target:   "/v1/ip/batch",
+			wantCode: http.StatusPermanentRedirect,
+			want:     "https://api.example.com:1997/v1/ip/batch",
+		},
+		{
+			name:     "ipv6_host",
+			port:     443,
+			host:     "[2001:db8::1]:80",
+			target:   "/v1/ip",
+			wantCode: http.StatusPermanentRedirect,
+			want:     "https://[2001:db8::1]/v1/ip",
+		},
+		{
+			name:     "missing_host",
+			port:     443,
+			target:   "/v1/ip",
+			wantCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var (
+				w = httptest.NewRecorder()
+				r = httptest.NewRequest(http.MethodGet, tt.target, http.NoBody)
+			)
+
+			r.Host = tt.host
+
+			handler.NewRedirectHandler(tt.port, zap.NewNop()).Handle(w, r, nil)
+
+			if w.Code != tt.wantCode {
+				t.Errorf("Handle() code = %d, want %d", w.Code, tt.wantCode)
+			}
+
+			if got := w.Header().Get("Location"); got != tt.want {
+				t.Errorf("Handle() Location = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"git.sr.ht/~jamesponddotco/accio127/internal/config"
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+)
+
+// reloadableHandler serves requests with the handler it was last given. The
+// HTTP/3 server can't be replaced on reload without closing its UDP socket, so
+// it's given the new routes through it instead.
+type reloadableHandler struct {
+	current atomic.Pointer[http.Handler]
+}
+
+// store makes handler serve every new request.
+func (h *reloadableHandler) store(handler http.Handler) {
+	h.current.Store(&handler)
+}
+
+// ServeHTTP implements the http.Handler interface.
+func (h *reloadableHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	(*h.current.Load()).ServeHTTP(w, r)
+}
+
+// newHTTP3Server builds the HTTP/3 server from cfg. It serves the routes of
+// the first HTTPS listener, with the same TLS configuration.
+func (s *Server) newHTTP3Server(cfg *config.Config) *http3.Server {
+	return &http3.Server{
+		Addr:      cfg.HTTP3.Address,
+		Port:      cfg.HTTP3.Port,
+		TLSConfig: s.tlsConfig,
+		Handler:   &s.http3Handler,
+		QuicConfig: &quic.Config{
+			MaxIdleTimeout: time.Duration(cfg.HTTP3.IdleTimeout),
+		},
+	}
+}
+
+// listenHTTP3 opens the UDP socket of the HTTP/3 server.
+func (s *Server) listenHTTP3() (net.PacketConn, error) {
+	conn, err := net.ListenPacket("udp", s.http3Server.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", s.http3Server.Addr, err)
+	}
+
+	return conn, nil
+}
+
+// serveHTTP3 serves the HTTP/3 server on conn in the background, reporting
+// unexpected errors to errs.
+func (s *Server) serveHTTP3(conn net.PacketConn, errs chan<- error) {
+	go func() {
+		err := s.http3Server.Serve(conn)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			select {
+			case errs <- err:
+			default:
+			}
+		}
+	}()
+}
@@ -0,0 +1,148 @@
+package server
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"git.sr.ht/~jamesponddotco/accio127/internal/retry"
+	"go.uber.org/zap"
+)
+
+// dispatcher accepts connections from a listener and hands them to the most
+// recent generation, so that the HTTP server can be replaced on reload without
+// closing the socket.
+type dispatcher struct {
+	listener net.Listener
+	logger   *zap.Logger
+	current  atomic.Pointer[generation]
+
+	// stopped is closed once the listener is closed.
+	stopped chan struct{}
+}
+
+// newDispatcher creates a new dispatcher for listener. Call run to start
+// accepting connections.
+func newDispatcher(listener net.Listener, logger *zap.Logger) *dispatcher {
+	return &dispatcher{
+		listener: listener,
+		logger:   logger,
+		stopped:  make(chan struct{}),
+	}
+}
+
+// run accepts connections until the listener is closed. Other errors, such as
+// running out of file descriptors, are retried with a growing delay, so that
+// the listener recovers once they go away.
+func (d *dispatcher) run() {
+	defer close(d.stopped)
+
+	var backoff retry.Backoff
+
+	for {
+		conn, err := d.listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+
+			delay := backoff.Next()
+
+			d.logger.Error(
+				"Failed to accept connection, retrying",
+				zap.String("address", d.listener.Addr().String()),
+				zap.Duration("delay", delay),
+				zap.Error(err),
+			)
+
+			time.Sleep(delay)
+
+			continue
+		}
+
+		backoff.Reset()
+
+		d.dispatch(conn)
+	}
+}
+
+// dispatch hands conn to the current generation, retrying with its successor
+// if the generation was closed in the meantime.
+func (d *dispatcher) dispatch(conn net.Conn) {
+	for {
+		gen := d.current.Load()
+		if gen == nil {
+			conn.Close()
+
+			return
+		}
+
+		select {
+		case gen.conns <- conn:
+			return
+		case <-gen.done:
+			if d.current.Load() == gen {
+				// Closed without a successor, so we're shutting down.
+				conn.Close()
+
+				return
+			}
+		}
+	}
+}
+
+// next creates a new generation and makes it the recipient of every new
+// connection.
+func (d *dispatcher) next() *generation {
+	gen := &generation{
+		dispatcher: d,
+		conns:      make(chan net.Conn),
+		done:       make(chan struct{}),
+	}
+
+	d.current.Store(gen)
+
+	return gen
+}
+
+// Close closes the underlying listener.
+func (d *dispatcher) Close() error {
+	return d.listener.Close() //nolint:wrapcheck // callers expect the listener's error as is
+}
+
+// generation is a net.Listener receiving connections from a dispatcher. Closing
+// it doesn't close the underlying listener.
+type generation struct {
+	dispatcher *dispatcher
+	conns      chan net.Conn
+	done       chan struct{}
+	once       sync.Once
+}
+
+// Accept implements the net.Listener interface.
+func (g *generation) Accept() (net.Conn, error) {
+	select {
+	case conn := <-g.conns:
+		return conn, nil
+	case <-g.done:
+		return nil, net.ErrClosed
+	case <-g.dispatcher.stopped:
+		return nil, net.ErrClosed
+	}
+}
+
+// Close implements the net.Listener interface.
+func (g *generation) Close() error {
+	g.once.Do(func() {
+		close(g.done)
+	})
+
+	return nil
+}
+
+// Addr implements the net.Listener interface.
+func (g *generation) Addr() net.Addr {
+	return g.dispatcher.listener.Addr()
+}
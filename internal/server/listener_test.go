@@ -0,0 +1,98 @@
+package server_test
+
+import (
+	"errors"
+	"net"
+	"os"
+	"sync"
+	"syscall"
+	"testing"
+
+	"git.sr.ht/~jamesponddotco/accio127/internal/server"
+	"go.uber.org/zap"
+)
+
+// failingListener is a net.Listener whose first calls to Accept fail with
+// errs.
+type failingListener struct {
+	net.Listener
+	mu   sync.Mutex
+	errs []error
+}
+
+func (l *failingListener) Accept() (net.Conn, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.errs) > 0 {
+		err := l.errs[0]
+		l.errs = l.errs[1:]
+
+		return nil, err
+	}
+
+	return l.Listener.Accept() //nolint:wrapcheck // must behave like the wrapped listener
+}
+
+func TestDispatch(t *testing.T) {
+	t.Parallel()
+
+	acceptError := func(errno syscall.Errno) error {
+		return &net.OpError{Op: "accept", Net: "tcp", Err: os.NewSyscallError("accept4", errno)}
+	}
+
+	tests := []struct {
+		name string
+		errs []error
+	}{
+		{
+			name: "no_errors",
+		},
+		{
+			name: "too_many_open_files",
+			errs: []error{acceptError(syscall.EMFILE), acceptError(syscall.EMFILE), acceptError(syscall.ENFILE)},
+		},
+		{
+			name: "connection_aborted",
+			errs: []error{acceptError(syscall.ECONNABORTED)},
+		},
+		{
+			name: "other",
+			errs: []error{acceptError(syscall.EINVAL)},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			inner, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatalf("Failed to listen: %v", err)
+			}
+
+			listener := server.Dispatch(&failingListener{Listener: inner, errs: tt.errs}, zap.NewNop())
+
+			client, err := net.Dial("tcp", inner.Addr().String())
+			if err != nil {
+				t.Fatalf("Failed to dial: %v", err)
+			}
+
+			defer client.Close()
+
+			conn, err := listener.Accept()
+			if err != nil {
+				t.Fatalf("Accept() error = %v, want the connection", err)
+			}
+
+			conn.Close()
+			inner.Close()
+
+			if _, err = listener.Accept(); !errors.Is(err, net.ErrClosed) {
+				t.Errorf("Accept() after Close() error = %v, want %v", err, net.ErrClosed)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package middleware
 import (
 	"fmt"
 	"net/http"
+	"strings"
 
 	"git.sr.ht/~jamesponddotco/accio127/internal/errors"
 	"github.com/julienschmidt/httprouter"
@@ -12,16 +13,57 @@ import (
 // AcceptRequests returns a 405 Method Not Allowed if the request method is not
 // GET, HEAD, or OPTIONS.
 func AcceptRequests(logger *zap.Logger, next httprouter.Handle) httprouter.Handle {
+	return AcceptMethods(logger, []string{http.MethodGet, http.MethodHead, http.MethodOptions}, next)
+}
+
+// AcceptMethods returns a 405 Method Not Allowed if the request method is not
+// one of methods. It lets specific routes accept methods other than the ones
+// allowed by AcceptRequests, such as POST.
+func AcceptMethods(logger *zap.Logger, methods []string, next httprouter.Handle) httprouter.Handle {
 	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
-		if r.Method != "GET" && r.Method != "HEAD" && r.Method != "OPTIONS" {
-			errors.JSON(w, logger, errors.ErrorResponse{
-				Code:    http.StatusMethodNotAllowed,
-				Message: fmt.Sprintf("Method %s not allowed. Must be GET, HEAD, or OPTIONS.", r.Method),
-			})
+		for _, method := range methods {
+			if r.Method == method {
+				next(w, r, ps)
 
-			return
+				return
+			}
 		}
 
-		next(w, r, ps)
+		w.Header().Set("Allow", strings.Join(methods, ", "))
+
+		methodNotAllowed(w, r, logger, methods)
+	}
+}
+
+// MethodNotAllowed returns a handler answering with a 405 Method Not Allowed
+// listing the methods in the Allow header, for use by routers that set it.
+func MethodNotAllowed(logger *zap.Logger) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		methodNotAllowed(w, r, logger, strings.Split(w.Header().Get("Allow"), ", "))
+	}
+}
+
+// methodNotAllowed answers with a 405 Method Not Allowed explaining which
+// methods are allowed.
+func methodNotAllowed(w http.ResponseWriter, r *http.Request, logger *zap.Logger, methods []string) {
+	errors.JSON(w, r, logger, errors.ErrorResponse{
+		Code:    http.StatusMethodNotAllowed,
+		Type:    errors.TypeMethodNotAllowed,
+		Message: fmt.Sprintf("Method %s not allowed. Must be %s.", r.Method, joinMethods(methods)),
+	})
+}
+
+// joinMethods joins methods into an English list, such as "GET, HEAD, or
+// OPTIONS".
+func joinMethods(methods []string) string {
+	switch len(methods) {
+	case 0:
+		return ""
+	case 1:
+		return methods[0]
+	case 2:
+		return methods[0] + " or " + methods[1]
+	default:
+		return strings.Join(methods[:len(methods)-1], ", ") + ", or " + methods[len(methods)-1]
 	}
 }
@@ -3,6 +3,7 @@ package middleware_test
 import (
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"git.sr.ht/~jamesponddotco/accio127/internal/server/middleware"
@@ -71,3 +72,59 @@ func TestAcceptRequests(t *testing.T) {
 		})
 	}
 }
+
+func TestAcceptMethods(t *testing.T) {
+	t.Parallel()
+
+	logger := zap.NewNop()
+
+	tests := []struct {
+		name        string
+		method      string
+		wantStatus  int
+		wantMessage string
+	}{
+		{
+			name:       "post_method",
+			method:     http.MethodPost,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "options_method",
+			method:     http.MethodOptions,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:        "get_method",
+			method:      http.MethodGet,
+			wantStatus:  http.StatusMethodNotAllowed,
+			wantMessage: "Method GET not allowed. Must be POST or OPTIONS.",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var (
+				recorder = httptest.NewRecorder()
+				req      = httptest.NewRequest(tt.method, "http://localhost/", http.NoBody)
+				handle   = middleware.AcceptMethods(logger, []string{http.MethodPost, http.MethodOptions}, func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+					w.Write([]byte("OK"))
+				})
+			)
+
+			handle(recorder, req, nil)
+
+			if recorder.Code != tt.wantStatus {
+				t.Errorf("AcceptMethods() status = %d, want %d", recorder.Code, tt.wantStatus)
+			}
+
+			if tt.wantMessage != "" && !strings.Contains(recorder.Body.String(), tt.wantMessage) {
+				t.Errorf("AcceptMethods() body = %q, want message %q", recorder.Body.String(), tt.wantMessage)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"git.sr.ht/~jamesponddotco/accio127/internal/accesslog"
+	"git.sr.ht/~jamesponddotco/accio127/internal/requestid"
+	"github.com/julienschmidt/httprouter"
+	"go.uber.org/zap"
+)
+
+// AccessLog writes an entry to accessLog for every request to route. The
+// client's IP address is logged as returned by client, which can anonymize or
+// hash it, or return an empty string to leave it out.
+func AccessLog(
+	logger *zap.Logger,
+	accessLog *accesslog.Logger,
+	route string,
+	client func(r *http.Request) string,
+	next httprouter.Handle,
+) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		var (
+			start    = time.Now()
+			recorder = &statusRecorder{ResponseWriter: w}
+		)
+
+		defer func() {
+			entry := &accesslog.Entry{
+				Time:      start,
+				Client:    client(r),
+				Method:    r.Method,
+				Path:      r.URL.RequestURI(),
+				Proto:     r.Proto,
+				Route:     route,
+				UserAgent: r.UserAgent(),
+				Referer:   r.Referer(),
+				RequestID: requestid.FromContext(r.Context()),
+				Latency:   time.Since(start),
+				Bytes:     recorder.bytes,
+				Status:    recorder.Status(),
+			}
+
+			if err := accessLog.Log(entry); err != nil {
+				requestid.Logger(r.Context(), logger).Error("Failed to write access log", zap.Error(err))
+			}
+		}()
+
+		next(recorder, r, ps)
+	}
+}
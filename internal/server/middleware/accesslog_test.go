@@ -0,0 +1,72 @@
+package middleware_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"git.sr.ht/~jamesponddotco/accio127/internal/accesslog"
+	"git.sr.ht/~jamesponddotco/accio127/internal/config"
+	"git.sr.ht/~jamesponddotco/accio127/internal/server/middleware"
+	"github.com/julienschmidt/httprouter"
+	"go.uber.org/zap"
+)
+
+func TestAccessLog(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		client string
+		status int
+		want   string
+	}{
+		{
+			name:   "anonymized_client",
+			client: "192.0.0.0",
+			status: http.StatusOK,
+			want:   `192.0.0.0 - - [`,
+		},
+		{
+			name:   "omitted_client",
+			client: "",
+			status: http.StatusNotFound,
+			want:   `- - - [`,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var (
+				buf      bytes.Buffer
+				logger   = accesslog.New(&buf, config.AccessLogFormatCommon)
+				recorder = httptest.NewRecorder()
+				req      = httptest.NewRequest(http.MethodGet, "http://localhost/v1/ip", http.NoBody)
+				client   = func(_ *http.Request) string { return tt.client }
+				handle   = middleware.AccessLog(zap.NewNop(), logger, "/v1/ip", client, func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+					w.WriteHeader(tt.status)
+					w.Write([]byte("hello"))
+				})
+			)
+
+			handle(recorder, req, nil)
+
+			got := buf.String()
+
+			if !strings.HasPrefix(got, tt.want) {
+				t.Errorf("AccessLog() = %q, want prefix %q", got, tt.want)
+			}
+
+			if wantSuffix := ` "GET /v1/ip HTTP/1.1" ` + strconv.Itoa(tt.status) + " 5\n"; !strings.HasSuffix(got, wantSuffix) {
+				t.Errorf("AccessLog() = %q, want suffix %q", got, wantSuffix)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// Head answers HEAD requests with the headers the route would send for a GET
+// request, including the Content-Length of the body, but without the body.
+func Head(next httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		if r.Method != http.MethodHead {
+			next(w, r, ps)
+
+			return
+		}
+
+		writer := &headWriter{ResponseWriter: w}
+
+		next(writer, r, ps)
+
+		writer.finish()
+	}
+}
+
+// headWriter is an http.ResponseWriter that counts the body instead of sending
+// it, and holds the status code back until the body is complete, so that the
+// Content-Length header can still be set.
+type headWriter struct {
+	http.ResponseWriter
+	bytes  int64
+	status int
+}
+
+// WriteHeader implements the http.ResponseWriter interface.
+func (h *headWriter) WriteHeader(code int) {
+	if h.status == 0 {
+		h.status = code
+	}
+}
+
+// Write implements the http.ResponseWriter interface.
+func (h *headWriter) Write(b []byte) (int, error) {
+	if h.status == 0 {
+		h.status = http.StatusOK
+	}
+
+	h.bytes += int64(len(b))
+
+	return len(b), nil
+}
+
+// finish sends the headers of the response.
+func (h *headWriter) finish() {
+	if h.status == 0 {
+		h.status = http.StatusOK
+	}
+
+	if h.status != http.StatusNoContent && h.status != http.StatusNotModified {
+		h.Header().Set("Content-Length", strconv.FormatInt(h.bytes, 10))
+	}
+
+	h.ResponseWriter.WriteHeader(h.status)
+}
@@ -0,0 +1,79 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"git.sr.ht/~jamesponddotco/accio127/internal/server/middleware"
+	"github.com/julienschmidt/httprouter"
+)
+
+func TestHead(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name              string
+		method            string
+		status            int
+		wantStatus        int
+		wantBody          string
+		wantContentLength string
+	}{
+		{
+			name:              "get_method",
+			method:            http.MethodGet,
+			status:            http.StatusOK,
+			wantStatus:        http.StatusOK,
+			wantBody:          "192.0.2.1",
+			wantContentLength: "",
+		},
+		{
+			name:              "head_method",
+			method:            http.MethodHead,
+			status:            http.StatusOK,
+			wantStatus:        http.StatusOK,
+			wantBody:          "",
+			wantContentLength: "9",
+		},
+		{
+			name:              "head_method_error",
+			method:            http.MethodHead,
+			status:            http.StatusBadRequest,
+			wantStatus:        http.StatusBadRequest,
+			wantBody:          "",
+			wantContentLength: "9",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var (
+				recorder = httptest.NewRecorder()
+				req      = httptest.NewRequest(tt.method, "http://localhost/", http.NoBody)
+				handle   = middleware.Head(func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+					w.WriteHeader(tt.status)
+					w.Write([]byte("192.0.2.1"))
+				})
+			)
+
+			handle(recorder, req, nil)
+
+			if recorder.Code != tt.wantStatus {
+				t.Errorf("Head() status = %d, want %d", recorder.Code, tt.wantStatus)
+			}
+
+			if got := recorder.Body.String(); got != tt.wantBody {
+				t.Errorf("Head() body = %q, want %q", got, tt.wantBody)
+			}
+
+			if got := recorder.Header().Get("Content-Length"); got != tt.wantContentLength {
+				t.Errorf("Head() Content-Length = %q, want %q", got, tt.wantContentLength)
+			}
+		})
+	}
+}
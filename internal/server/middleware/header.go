@@ -2,10 +2,16 @@ package middleware
 
 import (
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/julienschmidt/httprouter"
 )
 
+// corsMaxAge is how long browsers can cache the answer to CORS preflight
+// requests, in seconds.
+const corsMaxAge int = 86400
+
 // PrivacyPolicy adds a privacy policy header to the response.
 func PrivacyPolicy(uri string, next httprouter.Handle) httprouter.Handle {
 	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
@@ -15,6 +21,21 @@ func PrivacyPolicy(uri string, next httprouter.Handle) httprouter.Handle {
 	}
 }
 
+// AltSvc advertises the HTTP/3 listener on port in the Alt-Svc header, for
+// clients to remember for maxAge seconds. Requests already made over HTTP/3
+// don't get the header.
+func AltSvc(port, maxAge int, next httprouter.Handle) httprouter.Handle {
+	value := `h3=":` + strconv.Itoa(port) + `"; ma=` + strconv.Itoa(maxAge)
+
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		if r.ProtoMajor < 3 {
+			w.Header().Set("Alt-Svc", value)
+		}
+
+		next(w, r, ps)
+	}
+}
+
 // SecureHeader adds basic security headers to the response.
 func SecureHeader(next httprouter.Handle) httprouter.Handle {
 	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
@@ -28,14 +49,34 @@ func SecureHeader(next httprouter.Handle) httprouter.Handle {
 	}
 }
 
-// CORS adds CORS headers to the response.
+// CORS adds CORS headers to the response of routes accepting GET, HEAD, and
+// OPTIONS requests, and answers OPTIONS requests.
 func CORS(next httprouter.Handle) httprouter.Handle {
+	return CORSMethods([]string{http.MethodGet, http.MethodHead, http.MethodOptions}, next)
+}
+
+// CORSMethods adds CORS headers to the response of routes accepting methods,
+// and answers OPTIONS requests with a 204 No Content listing them in the Allow
+// header. Browsers' CORS preflight requests are also told how long they can
+// cache the answer.
+func CORSMethods(methods []string, next httprouter.Handle) httprouter.Handle {
+	allowed := strings.Join(methods, ", ")
+
 	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, HEAD, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type, Content-Length, Accept-Encoding")
+		w.Header().Set("Access-Control-Allow-Methods", allowed)
+		w.Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type, Content-Length, Accept-Encoding, X-Request-ID")
+		w.Header().Set("Access-Control-Expose-Headers", "X-Request-ID, RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset, RateLimit-Policy, Retry-After")
 
 		if r.Method == http.MethodOptions {
+			w.Header().Set("Allow", allowed)
+
+			if r.Header.Get("Origin") != "" && r.Header.Get("Access-Control-Request-Method") != "" {
+				w.Header().Set("Access-Control-Max-Age", strconv.Itoa(corsMaxAge))
+			}
+
+			w.WriteHeader(http.StatusNoContent)
+
 			return
 		}
 
@@ -49,6 +49,50 @@ func TestPrivacyPolicy(t *testing.T) {
 	}
 }
 
+func TestAltSvc(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		protoMajor int
+		want       string
+	}{
+		{
+			name:       "http2",
+			protoMajor: 2,
+			want:       `h3=":443"; ma=86400`,
+		},
+		{
+			name:       "http3",
+			protoMajor: 3,
+			want:       "",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var (
+				recorder = httptest.NewRecorder()
+				req      = httptest.NewRequest(http.MethodGet, "http://localhost/", http.NoBody)
+			)
+
+			req.ProtoMajor = tt.protoMajor
+
+			middleware.AltSvc(443, 86400, func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+				w.Write([]byte("OK"))
+			})(recorder, req, nil)
+
+			if got := recorder.Header().Get("Alt-Svc"); got != tt.want {
+				t.Errorf("Expected Alt-Svc header %q, but got %q", tt.want, got)
+			}
+		})
+	}
+}
+
 func TestSecureHeader(t *testing.T) {
 	t.Parallel()
 
@@ -120,7 +164,7 @@ func TestCORS(t *testing.T) {
 			expectedHeaders := map[string]string{
 				"Access-Control-Allow-Origin":  "*",
 				"Access-Control-Allow-Methods": "GET, HEAD, OPTIONS",
-				"Access-Control-Allow-Headers": "Accept, Content-Type, Content-Length, Accept-Encoding",
+				"Access-Control-Allow-Headers": "Accept, Content-Type, Content-Length, Accept-Encoding, X-Request-ID",
 			}
 
 			for header, expected := range expectedHeaders {
@@ -131,3 +175,75 @@ func TestCORS(t *testing.T) {
 		})
 	}
 }
+
+func TestCORSMethods(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		method     string
+		origin     string
+		wantStatus int
+		wantAllow  string
+		wantMaxAge string
+	}{
+		{
+			name:       "post_method",
+			method:     http.MethodPost,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "options_method",
+			method:     http.MethodOptions,
+			wantStatus: http.StatusNoContent,
+			wantAllow:  "POST, OPTIONS",
+		},
+		{
+			name:       "preflight",
+			method:     http.MethodOptions,
+			origin:     "https://example.com",
+			wantStatus: http.StatusNoContent,
+			wantAllow:  "POST, OPTIONS",
+			wantMaxAge: "86400",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var (
+				recorder = httptest.NewRecorder()
+				req      = httptest.NewRequest(tt.method, "http://localhost/", http.NoBody)
+				handle   = middleware.CORSMethods([]string{http.MethodPost, http.MethodOptions}, func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+					w.Write([]byte("OK"))
+				})
+			)
+
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+				req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+			}
+
+			handle(recorder, req, nil)
+
+			if recorder.Code != tt.wantStatus {
+				t.Errorf("CORSMethods() status = %d, want %d", recorder.Code, tt.wantStatus)
+			}
+
+			if got := recorder.Header().Get("Access-Control-Allow-Methods"); got != "POST, OPTIONS" {
+				t.Errorf("CORSMethods() Access-Control-Allow-Methods = %q, want %q", got, "POST, OPTIONS")
+			}
+
+			if got := recorder.Header().Get("Allow"); got != tt.wantAllow {
+				t.Errorf("CORSMethods() Allow = %q, want %q", got, tt.wantAllow)
+			}
+
+			if got := recorder.Header().Get("Access-Control-Max-Age"); got != tt.wantMaxAge {
+				t.Errorf("CORSMethods() Access-Control-Max-Age = %q, want %q", got, tt.wantMaxAge)
+			}
+		})
+	}
+}
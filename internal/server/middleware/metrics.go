@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"git.sr.ht/~jamesponddotco/accio127/internal/metrics"
+	"github.com/julienschmidt/httprouter"
+)
+
+// Metrics records the status code and latency of requests to route in
+// registry, along with the number of requests in flight.
+func Metrics(registry *metrics.Registry, route string, next httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		var (
+			start    = time.Now()
+			recorder = &statusRecorder{ResponseWriter: w}
+		)
+
+		registry.Start()
+
+		defer func() {
+			registry.Done(route, recorder.Status(), time.Since(start))
+		}()
+
+		next(recorder, r, ps)
+	}
+}
+
+// statusRecorder is an http.ResponseWriter that remembers the status code and
+// size of the response.
+type statusRecorder struct {
+	http.ResponseWriter
+	bytes  int64
+	status int
+}
+
+// WriteHeader implements the http.ResponseWriter interface.
+func (s *statusRecorder) WriteHeader(code int) {
+	if s.status == 0 {
+		s.status = code
+	}
+
+	s.ResponseWriter.WriteHeader(code)
+}
+
+// Write implements the http.ResponseWriter interface.
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+
+	n, err := s.ResponseWriter.Write(b)
+
+	s.bytes += int64(n)
+
+	return n, err //nolint:wrapcheck // must behave like the wrapped writer
+}
+
+// Status returns the status code of the response, which is 200 OK if nothing
+// was written.
+func (s *statusRecorder) Status() int {
+	if s.status == 0 {
+		return http.StatusOK
+	}
+
+	return s.status
+}
+
+// Flush implements the http.Flusher interface, if the wrapped writer does.
+func (s *statusRecorder) Flush() {
+	if flusher, ok := s.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap returns the wrapped http.ResponseWriter, for use by
+// http.ResponseController.
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
@@ -0,0 +1,77 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"git.sr.ht/~jamesponddotco/accio127/internal/metrics"
+	"git.sr.ht/~jamesponddotco/accio127/internal/server/middleware"
+	"github.com/julienschmidt/httprouter"
+)
+
+func TestMetrics(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		handler httprouter.Handle
+		want    string
+	}{
+		{
+			name: "implicit_ok",
+			handler: func(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+				w.Write([]byte("OK"))
+			},
+			want: `accio127_http_requests_total{endpoint="/test",code="200"} 1`,
+		},
+		{
+			name: "explicit_status",
+			handler: func(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+				w.WriteHeader(http.StatusTeapot)
+				w.WriteHeader(http.StatusOK)
+			},
+			want: `accio127_http_requests_total{endpoint="/test",code="418"} 1`,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			req, err := http.NewRequest(http.MethodGet, "http://localhost/", http.NoBody)
+			if err != nil {
+				t.Fatalf("Failed to create request: %v", err)
+			}
+
+			var (
+				recorder = httptest.NewRecorder()
+				registry = metrics.NewRegistry()
+				router   = httprouter.New()
+			)
+
+			router.GET("/", middleware.Metrics(registry, "/test", tt.handler))
+			router.ServeHTTP(recorder, req)
+
+			var output strings.Builder
+
+			encoder := metrics.NewEncoder(&output, true)
+			registry.Write(encoder)
+
+			if err := encoder.Close(); err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+
+			if !strings.Contains(output.String(), tt.want+"\n") {
+				t.Errorf("Metrics() output = %s, want it to contain %s", output.String(), tt.want)
+			}
+
+			if !strings.Contains(output.String(), "accio127_http_requests_in_flight 0\n") {
+				t.Errorf("Metrics() output = %s, want no requests in flight", output.String())
+			}
+		})
+	}
+}
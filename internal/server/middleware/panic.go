@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"git.sr.ht/~jamesponddotco/accio127/internal/errors"
+	"git.sr.ht/~jamesponddotco/accio127/internal/requestid"
 	"github.com/julienschmidt/httprouter"
 	"go.uber.org/zap"
 )
@@ -14,9 +15,11 @@ func PanicRecovery(logger *zap.Logger, next httprouter.Handle) httprouter.Handle
 	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 		defer func() {
 			if err := recover(); err != nil {
-				logger.Error("panic recovered", zap.Any("error", err))
+				requestLogger := requestid.Logger(r.Context(), logger)
 
-				errors.JSON(w, logger, errors.ErrorResponse{
+				requestLogger.Error("panic recovered", zap.Any("error", err))
+
+				errors.JSON(w, r, requestLogger, errors.ErrorResponse{
 					Code:    http.StatusInternalServerError,
 					Message: "Internal server error. Please try again later.",
 				})
@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"git.sr.ht/~jamesponddotco/accio127/internal/errors"
+	"git.sr.ht/~jamesponddotco/accio127/internal/ratelimit"
+	"github.com/julienschmidt/httprouter"
+	"go.uber.org/zap"
+)
+
+// RateLimit returns a 429 Too Many Requests if the client made more requests
+// than allowed by limiter. Clients are identified by key, which returns false
+// for requests that aren't rate limited.
+//
+// Every limited response carries the RateLimit-Limit, RateLimit-Remaining,
+// RateLimit-Reset, and RateLimit-Policy headers, and denied ones carry
+// Retry-After too.
+func RateLimit(
+	logger *zap.Logger,
+	limiter *ratelimit.Limiter,
+	key func(r *http.Request) (string, bool),
+	next httprouter.Handle,
+) httprouter.Handle {
+	var (
+		rule   = limiter.Rule()
+		policy = fmt.Sprintf("%d;w=%d;burst=%d", rule.Requests, seconds(rule.Period), rule.Burst)
+	)
+
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		client, ok := key(r)
+		if !ok {
+			next(w, r, ps)
+
+			return
+		}
+
+		result := limiter.Allow(client, time.Now())
+
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		w.Header().Set("RateLimit-Reset", strconv.Itoa(seconds(result.Reset)))
+		w.Header().Set("RateLimit-Policy", policy)
+
+		if result.Allowed {
+			next(w, r, ps)
+
+			return
+		}
+
+		retryAfter := seconds(result.RetryAfter)
+
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+
+		errors.JSON(w, r, logger, errors.ErrorResponse{
+			Code:    http.StatusTooManyRequests,
+			Type:    errors.TypeRateLimited,
+			Message: fmt.Sprintf("Too many requests. Please try again in %d seconds.", retryAfter),
+		})
+	}
+}
+
+// seconds rounds d up to whole seconds, so that clients waiting for that long
+// never come back too early.
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,95 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"git.sr.ht/~jamesponddotco/accio127/internal/ratelimit"
+	"git.sr.ht/~jamesponddotco/accio127/internal/server/middleware"
+	"github.com/julienschmidt/httprouter"
+	"go.uber.org/zap"
+)
+
+func TestRateLimit(t *testing.T) {
+	t.Parallel()
+
+	logger := zap.NewNop()
+
+	tests := []struct {
+		name           string
+		key            string
+		limited        bool
+		wantStatuses   []int
+		wantRemaining  []string
+		wantRetryAfter string
+	}{
+		{
+			name:           "limited_client",
+			key:            "192.0.2.1",
+			limited:        true,
+			wantStatuses:   []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests},
+			wantRemaining:  []string{"1", "0", "0"},
+			wantRetryAfter: "30",
+		},
+		{
+			name:          "allowed_client",
+			key:           "192.0.2.2",
+			limited:       false,
+			wantStatuses:  []int{http.StatusOK, http.StatusOK, http.StatusOK},
+			wantRemaining: []string{"", "", ""},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var (
+				limiter = ratelimit.New(ratelimit.Rule{
+					Period:   time.Minute,
+					Requests: 2,
+					Burst:    2,
+				}, 10)
+				key = func(_ *http.Request) (string, bool) {
+					return tt.key, tt.limited
+				}
+				handle = middleware.RateLimit(logger, limiter, key, func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+					w.Write([]byte("OK"))
+				})
+			)
+
+			for i, wantStatus := range tt.wantStatuses {
+				var (
+					recorder = httptest.NewRecorder()
+					req      = httptest.NewRequest(http.MethodGet, "http://localhost/", http.NoBody)
+				)
+
+				handle(recorder, req, nil)
+
+				if recorder.Code != wantStatus {
+					t.Errorf("RateLimit() request %d status = %d, want %d", i, recorder.Code, wantStatus)
+				}
+
+				if got := recorder.Header().Get("RateLimit-Remaining"); got != tt.wantRemaining[i] {
+					t.Errorf("RateLimit() request %d RateLimit-Remaining = %q, want %q", i, got, tt.wantRemaining[i])
+				}
+
+				if wantStatus != http.StatusTooManyRequests {
+					continue
+				}
+
+				if got := recorder.Header().Get("Retry-After"); got != tt.wantRetryAfter {
+					t.Errorf("RateLimit() Retry-After = %q, want %q", got, tt.wantRetryAfter)
+				}
+
+				if got := recorder.Header().Get("RateLimit-Policy"); got != "2;w=60;burst=2" {
+					t.Errorf("RateLimit() RateLimit-Policy = %q, want %q", got, "2;w=60;burst=2")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"net/http"
+
+	"git.sr.ht/~jamesponddotco/accio127/internal/requestid"
+	"github.com/julienschmidt/httprouter"
+	"go.uber.org/zap"
+)
+
+// RequestID identifies the request with the ID in its X-Request-ID header, or
+// with a new one if it's missing or invalid. The ID is sent back in the
+// response's X-Request-ID header, and stored in the request's context along
+// with a logger that includes it in every line.
+func RequestID(logger *zap.Logger, next httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		id := r.Header.Get(requestid.Header)
+		if !requestid.Valid(id) {
+			id = requestid.New()
+		}
+
+		w.Header().Set(requestid.Header, id)
+
+		next(w, r.WithContext(requestid.NewContext(r.Context(), id, logger)), ps)
+	}
+}
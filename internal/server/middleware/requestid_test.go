@@ -0,0 +1,75 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"git.sr.ht/~jamesponddotco/accio127/internal/requestid"
+	"git.sr.ht/~jamesponddotco/accio127/internal/server/middleware"
+	"github.com/julienschmidt/httprouter"
+	"go.uber.org/zap"
+)
+
+func TestRequestID(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		give       string
+		wantReused bool
+	}{
+		{
+			name:       "valid_id",
+			give:       "123e4567-e89b-12d3-a456-426614174000",
+			wantReused: true,
+		},
+		{
+			name:       "missing_id",
+			give:       "",
+			wantReused: false,
+		},
+		{
+			name:       "invalid_id",
+			give:       "forged\" id",
+			wantReused: false,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var (
+				contextID string
+				recorder  = httptest.NewRecorder()
+				req       = httptest.NewRequest(http.MethodGet, "http://localhost/", http.NoBody)
+				handle    = middleware.RequestID(zap.NewNop(), func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+					contextID = requestid.FromContext(r.Context())
+				})
+			)
+
+			if tt.give != "" {
+				req.Header.Set(requestid.Header, tt.give)
+			}
+
+			handle(recorder, req, nil)
+
+			got := recorder.Header().Get(requestid.Header)
+
+			if !requestid.Valid(got) {
+				t.Fatalf("RequestID() header = %q, want a valid request ID", got)
+			}
+
+			if got != contextID {
+				t.Errorf("RequestID() context ID = %q, want %q", contextID, got)
+			}
+
+			if reused := got == tt.give; reused != tt.wantReused {
+				t.Errorf("RequestID() header = %q, reused %v, want %v", got, reused, tt.wantReused)
+			}
+		})
+	}
+}
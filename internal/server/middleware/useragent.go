@@ -12,8 +12,9 @@ import (
 func UserAgent(logger *zap.Logger, next httprouter.Handle) httprouter.Handle {
 	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 		if r.UserAgent() == "" {
-			errors.JSON(w, logger, errors.ErrorResponse{
+			errors.JSON(w, r, logger, errors.ErrorResponse{
 				Code:    http.StatusBadRequest,
+				Type:    errors.TypeMissingUserAgent,
 				Message: "User agent is missing. Please provide a valid user agent.",
 			})
 
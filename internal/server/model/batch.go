@@ -0,0 +1,20 @@
+package model
+
+// BatchResult represents the outcome for one of the IP addresses sent to the
+// batch endpoint.
+type BatchResult struct {
+	// Anonymized is the anonymized address.
+	Anonymized *IP `json:"anonymized,omitempty"`
+
+	// Hashed is the hashed address, along with the key it was hashed with.
+	Hashed *IP `json:"hashed,omitempty"`
+
+	// Input is the address as it was sent.
+	Input string `json:"input"`
+
+	// Family is the family of the address, "ipv4" or "ipv6".
+	Family string `json:"family,omitempty"`
+
+	// Error explains why the address couldn't be processed.
+	Error string `json:"error,omitempty"`
+}
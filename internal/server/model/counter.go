@@ -1,8 +1,16 @@
 package model
 
+import "git.sr.ht/~jamesponddotco/accio127/internal/jsonutil"
+
 // Counter represents an access counter.
 type Counter struct {
-	Count uint64 `json:"count"`
+	// Statistics is the breakdown of the counter, if one was requested.
+	Statistics *Statistics `json:"statistics,omitempty"`
+	Count      uint64      `json:"count"`
+
+	// FlushLag is how long the oldest access not yet written to the database
+	// has been pending.
+	FlushLag jsonutil.Duration `json:"flushLag"`
 }
 
 // NewCounter creates a new Counter.
@@ -15,3 +23,40 @@ func NewCounter(count uint64) *Counter {
 		Count: count,
 	}
 }
+
+// Statistic is the number of accesses for a combination of dimensions. Fields
+// for dimensions that weren't grouped by are omitted.
+type Statistic struct {
+	Day      string `json:"day,omitempty"`
+	Endpoint string `json:"endpoint,omitempty"`
+	Family   string `json:"family,omitempty"`
+	Format   string `json:"format,omitempty"`
+	Count    uint64 `json:"count"`
+}
+
+// Statistics represents the accesses made in a date range, grouped by the
+// requested dimensions.
+type Statistics struct {
+	From    string      `json:"from"`
+	To      string      `json:"to"`
+	GroupBy []string    `json:"groupBy"`
+	Entries []Statistic `json:"entries"`
+}
+
+// NewStatistics creates a new Statistics instance.
+func NewStatistics(from, to string, groupBy []string, entries []Statistic) *Statistics {
+	if groupBy == nil {
+		groupBy = []string{}
+	}
+
+	if entries == nil {
+		entries = []Statistic{}
+	}
+
+	return &Statistics{
+		From:    from,
+		To:      to,
+		GroupBy: groupBy,
+		Entries: entries,
+	}
+}
@@ -0,0 +1,84 @@
+package model
+
+import "git.sr.ht/~jamesponddotco/accio127/internal/ipinfo"
+
+// EmbeddedIPv4 represents an IPv4 address embedded in an IPv6 address.
+type EmbeddedIPv4 struct {
+	// Type is how the address is embedded: "6to4", "teredo", or "nat64".
+	// IPv4-mapped addresses are unmapped before they're classified, as they
+	// only show IPv4 clients connecting over a dual-stack socket.
+	Type string `json:"type"`
+
+	// IP is the embedded IPv4 address.
+	IP string `json:"ip"`
+}
+
+// IPDetails represents an IP address and how it's classified.
+type IPDetails struct {
+	// IP is the address.
+	IP string `json:"ip"`
+
+	// Decimal is the address as a decimal integer.
+	Decimal string `json:"decimal"`
+
+	// Hex is the address as a hexadecimal integer.
+	Hex string `json:"hex"`
+
+	// ReverseDNS is the name used to look up the PTR record of the address.
+	ReverseDNS string `json:"reverseDNS"`
+
+	// Network is the /24 or /64 enclosing the address, in CIDR notation.
+	Network string `json:"network"`
+
+	// EmbeddedIPv4 is the IPv4 address embedded in an IPv6 address, if any.
+	EmbeddedIPv4 *EmbeddedIPv4 `json:"embeddedIPv4,omitempty"`
+
+	// Version is the IP version of the address, 4 or 6.
+	Version int `json:"version"`
+
+	Private       bool `json:"private"`
+	Loopback      bool `json:"loopback"`
+	LinkLocal     bool `json:"linkLocal"`
+	CGNAT         bool `json:"cgnat"`
+	Documentation bool `json:"documentation"`
+	Multicast     bool `json:"multicast"`
+	Unspecified   bool `json:"unspecified"`
+}
+
+// NewIPDetails creates a new IPDetails instance from the classification of an
+// IP address.
+func NewIPDetails(details ipinfo.Details) *IPDetails {
+	if !details.Addr.IsValid() {
+		return nil
+	}
+
+	version := 6
+	if details.Addr.Is4() {
+		version = 4
+	}
+
+	ipDetails := &IPDetails{
+		IP:            details.Addr.String(),
+		Decimal:       ipinfo.Decimal(details.Addr),
+		Hex:           ipinfo.Hex(details.Addr),
+		ReverseDNS:    ipinfo.ReverseName(details.Addr),
+		Network:       details.Network.String(),
+		Version:       version,
+		Private:       details.Private,
+		Loopback:      details.Loopback,
+		LinkLocal:     details.LinkLocal,
+		CGNAT:         details.CGNAT,
+		Documentation: details.Documentation,
+		Multicast:     details.Multicast,
+		Unspecified:   details.Unspecified,
+	}
+
+	if details.Embedded.IsValid() {
+		ipDetails.EmbeddedIPv4 = &EmbeddedIPv4{
+			Type: details.Embedding,
+			IP:   details.Embedded.String(),
+		}
+	}
+
+	return ipDetails
+}
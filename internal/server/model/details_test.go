@@ -0,0 +1,65 @@
+package model_test
+
+import (
+	"net/netip"
+	"reflect"
+	"testing"
+
+	"git.sr.ht/~jamesponddotco/accio127/internal/ipinfo"
+	"git.sr.ht/~jamesponddotco/accio127/internal/server/model"
+)
+
+func TestNewIPDetails(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		give ipinfo.Details
+		want *model.IPDetails
+	}{
+		{
+			name: "ipv4",
+			give: ipinfo.Classify(netip.MustParseAddr("192.168.1.10")),
+			want: &model.IPDetails{
+				IP:         "192.168.1.10",
+				Decimal:    "3232235786",
+				Hex:        "0xc0a8010a",
+				ReverseDNS: "10.1.168.192.in-addr.arpa",
+				Network:    "192.168.1.0/24",
+				Version:    4,
+				Private:    true,
+			},
+		},
+		{
+			name: "6to4",
+			give: ipinfo.Classify(netip.MustParseAddr("2002:c000:201::1")),
+			want: &model.IPDetails{
+				IP:           "2002:c000:201::1",
+				Decimal:      "42549574682098457654362589560500649985",
+				Hex:          "0x2002c000020100000000000000000001",
+				ReverseDNS:   "1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.1.0.2.0.0.0.0.c.2.0.0.2.ip6.arpa",
+				Network:      "2002:c000:201::/64",
+				EmbeddedIPv4: &model.EmbeddedIPv4{Type: ipinfo.Embedding6to4, IP: "192.0.2.1"},
+				Version:      6,
+			},
+		},
+		{
+			name: "invalid",
+			give: ipinfo.Details{},
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := model.NewIPDetails(tt.give)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("NewIPDetails() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
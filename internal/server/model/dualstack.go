@@ -0,0 +1,53 @@
+package model
+
+import (
+	"strings"
+	"time"
+
+	"git.sr.ht/~jamesponddotco/accio127/internal/dualstack"
+)
+
+// DualStack represents a step of the dual-stack flow: the addresses of the
+// client seen so far, and where to fetch the token from to add the others.
+type DualStack struct {
+	// IP holds the IPv4 and IPv6 addresses of the client seen so far.
+	IP *IP `json:"ip"`
+
+	// Token ties the requests of the client together.
+	Token string `json:"token"`
+
+	// Expires is when the token stops being accepted, in RFC 3339 format.
+	Expires string `json:"expires"`
+
+	// IPv4URL is the URL to fetch over IPv4 to add the IPv4 address.
+	IPv4URL string `json:"ipv4URL"`
+
+	// IPv6URL is the URL to fetch over IPv6 to add the IPv6 address.
+	IPv6URL string `json:"ipv6URL"`
+
+	// Complete is true once both addresses are known.
+	Complete bool `json:"complete"`
+}
+
+// NewDualStack creates a new DualStack from token, sealed as sealed, with the
+// URLs to fetch it from built from the ipv4 and ipv6 base URLs and route.
+func NewDualStack(token dualstack.Token, sealed, ipv4, ipv6, route string) *DualStack {
+	ip := &IP{}
+
+	if token.IPv4.IsValid() {
+		ip.V4 = token.IPv4.String()
+	}
+
+	if token.IPv6.IsValid() {
+		ip.V6 = token.IPv6.String()
+	}
+
+	return &DualStack{
+		IP:       ip,
+		Token:    sealed,
+		Expires:  token.Expires.Format(time.RFC3339),
+		IPv4URL:  strings.TrimSuffix(ipv4, "/") + route + "/" + sealed,
+		IPv6URL:  strings.TrimSuffix(ipv6, "/") + route + "/" + sealed,
+		Complete: token.Complete(),
+	}
+}
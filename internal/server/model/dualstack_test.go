@@ -0,0 +1,64 @@
+package model_test
+
+import (
+	"net/netip"
+	"reflect"
+	"testing"
+	"time"
+
+	"git.sr.ht/~jamesponddotco/accio127/internal/dualstack"
+	"git.sr.ht/~jamesponddotco/accio127/internal/server/model"
+)
+
+func TestNewDualStack(t *testing.T) {
+	t.Parallel()
+
+	expires := time.Date(2023, time.June, 1, 12, 1, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		token dualstack.Token
+		want  *model.DualStack
+	}{
+		{
+			name:  "ipv4_only",
+			token: dualstack.Token{Expires: expires, IPv4: netip.MustParseAddr("192.0.2.1")},
+			want: &model.DualStack{
+				IP:      &model.IP{V4: "192.0.2.1"},
+				Token:   "abc",
+				Expires: "2023-06-01T12:01:00Z",
+				IPv4URL: "https://ipv4.example.com/v1/ip/both/abc",
+				IPv6URL: "https://ipv6.example.com/v1/ip/both/abc",
+			},
+		},
+		{
+			name: "both",
+			token: dualstack.Token{
+				Expires: expires,
+				IPv4:    netip.MustParseAddr("192.0.2.1"),
+				IPv6:    netip.MustParseAddr("2001:db8::1"),
+			},
+			want: &model.DualStack{
+				IP:       &model.IP{V4: "192.0.2.1", V6: "2001:db8::1"},
+				Token:    "abc",
+				Expires:  "2023-06-01T12:01:00Z",
+				IPv4URL:  "https://ipv4.example.com/v1/ip/both/abc",
+				IPv6URL:  "https://ipv6.example.com/v1/ip/both/abc",
+				Complete: true,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := model.NewDualStack(tt.token, "abc", "https://ipv4.example.com/", "https://ipv6.example.com", "/v1/ip/both")
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("NewDualStack() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
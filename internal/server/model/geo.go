@@ -0,0 +1,29 @@
+package model
+
+import "git.sr.ht/~jamesponddotco/accio127/internal/geoip"
+
+// Geo represents the location and network of an IP address.
+type Geo struct {
+	IP           string `json:"ip"`
+	CountryCode  string `json:"countryCode,omitempty"`
+	Country      string `json:"country,omitempty"`
+	RegionCode   string `json:"regionCode,omitempty"`
+	Region       string `json:"region,omitempty"`
+	City         string `json:"city,omitempty"`
+	Organization string `json:"organization,omitempty"`
+	ASN          uint   `json:"asn,omitempty"`
+}
+
+// NewGeo creates a new Geo instance.
+func NewGeo(ip string, location geoip.Location) *Geo {
+	return &Geo{
+		IP:           ip,
+		CountryCode:  location.CountryCode,
+		Country:      location.Country,
+		RegionCode:   location.RegionCode,
+		Region:       location.Region,
+		City:         location.City,
+		Organization: location.Organization,
+		ASN:          location.ASN,
+	}
+}
@@ -4,6 +4,10 @@ package model
 type Dependency struct {
 	Service string `json:"service"`
 	Status  string `json:"status"`
+
+	// BuildDate is when the dependency's data was built, in RFC 3339 format.
+	// It's only set for data files, such as GeoIP databases.
+	BuildDate string `json:"buildDate,omitempty"`
 }
 
 // Health represents the health status of the service and its dependencies.
@@ -1,11 +1,41 @@
 package model
 
-import "net"
+import (
+	"encoding/xml"
+	"net"
+	"net/netip"
+)
 
 // Address represents an IP address.
 type IP struct {
-	V4 string `json:"ipv4,omitempty"`
-	V6 string `json:"ipv6,omitempty"`
+	XMLName xml.Name `json:"-" xml:"ip"`
+
+	// Key describes the key used to hash the address, if it was hashed.
+	Key *HashKey `json:"key,omitempty" xml:"key,omitempty"`
+
+	V4 string `json:"ipv4,omitempty" xml:"ipv4,omitempty"`
+	V6 string `json:"ipv6,omitempty" xml:"ipv6,omitempty"`
+
+	// CIDR is the anonymized address in CIDR notation, if it was anonymized.
+	CIDR string `json:"cidr,omitempty" xml:"cidr,omitempty"`
+
+	// PrefixLength is the number of bits kept from the address, if it was
+	// anonymized.
+	PrefixLength int `json:"prefixLength,omitempty" xml:"prefixLength,omitempty"`
+}
+
+// HashKey describes the key an IP address was hashed with. Hashes are only
+// comparable if they share the same key ID and epoch.
+type HashKey struct {
+	// ID identifies the secret the key is derived from.
+	ID string `json:"id" xml:"id"`
+
+	// Expires is when the key stops being used, in RFC 3339 format. It's
+	// empty if keys don't rotate.
+	Expires string `json:"expires,omitempty" xml:"expires,omitempty"`
+
+	// Epoch is the number of the rotation period the key belongs to.
+	Epoch int64 `json:"epoch" xml:"epoch"`
 }
 
 // NewIP creates a new IP address.
@@ -31,3 +61,51 @@ func NewIP(ip string) *IP {
 
 	return nil
 }
+
+// NewHashedIP creates a new IP holding the hash of an IP address in the field
+// matching the address family of ip.
+func NewHashedIP(ip, hash string) *IP {
+	address := NewIP(ip)
+	if address == nil {
+		return nil
+	}
+
+	if address.V4 != "" {
+		return &IP{
+			V4: hash,
+		}
+	}
+
+	return &IP{
+		V6: hash,
+	}
+}
+
+// NewAnonymizedIP creates a new IP holding the masked address of prefix
+// alongside its CIDR notation and length.
+func NewAnonymizedIP(prefix netip.Prefix) *IP {
+	if !prefix.IsValid() {
+		return nil
+	}
+
+	prefix = prefix.Masked()
+
+	address := NewIP(prefix.Addr().String())
+	if address == nil {
+		return nil
+	}
+
+	address.CIDR = prefix.String()
+	address.PrefixLength = prefix.Bits()
+
+	return address
+}
+
+// String returns whichever address the IP holds.
+func (ip *IP) String() string {
+	if ip.V4 != "" {
+		return ip.V4
+	}
+
+	return ip.V6
+}
@@ -1,6 +1,7 @@
 package model_test
 
 import (
+	"net/netip"
 	"testing"
 
 	"git.sr.ht/~jamesponddotco/accio127/internal/server/model"
@@ -58,3 +59,99 @@ func TestNewIP(t *testing.T) {
 		})
 	}
 }
+
+func TestNewHashedIP(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		giveIP   string
+		giveHash string
+		want     *model.IP
+	}{
+		{
+			name:     "ipv4",
+			giveIP:   "192.0.2.1",
+			giveHash: "abc",
+			want:     &model.IP{V4: "abc"},
+		},
+		{
+			name:     "ipv6",
+			giveIP:   "2001:db8::68",
+			giveHash: "abc",
+			want:     &model.IP{V6: "abc"},
+		},
+		{
+			name:     "invalid_ip",
+			giveIP:   "invalid",
+			giveHash: "abc",
+			want:     nil,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := model.NewHashedIP(tt.giveIP, tt.giveHash)
+
+			if got == nil && tt.want == nil {
+				return
+			}
+
+			if got == nil || tt.want == nil {
+				t.Fatalf("NewHashedIP(%q, %q) = %v, want %v", tt.giveIP, tt.giveHash, got, tt.want)
+			}
+
+			if got.V4 != tt.want.V4 || got.V6 != tt.want.V6 {
+				t.Fatalf("NewHashedIP(%q, %q) = %v, want %v", tt.giveIP, tt.giveHash, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewAnonymizedIP(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		give netip.Prefix
+		want *model.IP
+	}{
+		{
+			name: "ipv4",
+			give: netip.MustParsePrefix("192.0.2.0/24"),
+			want: &model.IP{V4: "192.0.2.0", CIDR: "192.0.2.0/24", PrefixLength: 24},
+		},
+		{
+			name: "ipv6_unmasked",
+			give: netip.MustParsePrefix("2001:db8:1:2::68/48"),
+			want: &model.IP{V6: "2001:db8:1::", CIDR: "2001:db8:1::/48", PrefixLength: 48},
+		},
+		{
+			name: "invalid_prefix",
+			give: netip.Prefix{},
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := model.NewAnonymizedIP(tt.give)
+
+			if got == nil && tt.want == nil {
+				return
+			}
+
+			if got == nil || tt.want == nil || *got != *tt.want {
+				t.Fatalf("NewAnonymizedIP(%v) = %v, want %v", tt.give, got, tt.want)
+			}
+		})
+	}
+}
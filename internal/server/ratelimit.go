@@ -0,0 +1,117 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/netip"
+	"time"
+
+	"git.sr.ht/~jamesponddotco/accio127/internal/config"
+	"git.sr.ht/~jamesponddotco/accio127/internal/ratelimit"
+	"git.sr.ht/~jamesponddotco/accio127/internal/server/handler"
+)
+
+// rateLimiters holds the limiters of every route, and identifies the clients
+// they limit.
+type rateLimiters struct {
+	// shared is the limiter of the routes without a rule of their own.
+	shared *ratelimit.Limiter
+
+	// routes holds the limiters of the routes with a rule of their own.
+	routes map[string]*ratelimit.Limiter
+
+	allow []netip.Prefix
+	cfg   *config.Config
+}
+
+// newRateLimiters creates the limiters configured in cfg. Limiters are created
+// along with the HTTP servers, so reloading the configuration resets them.
+func newRateLimiters(cfg *config.Config) (*rateLimiters, error) {
+	allow, err := config.ParsePrefixes(cfg.RateLimit.Allow)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse rate limit allow-list: %w", err)
+	}
+
+	limiters := &rateLimiters{
+		shared: newLimiter(cfg.RateLimit.RateLimitRule, cfg.RateLimit.MaxClients),
+		routes: make(map[string]*ratelimit.Limiter, len(cfg.RateLimit.Endpoints)),
+		allow:  allow,
+		cfg:    cfg,
+	}
+
+	for route := range cfg.RateLimit.Endpoints {
+		rule, _ := cfg.RateLimit.Rule(route)
+
+		limiters.routes[route] = newLimiter(rule, cfg.RateLimit.MaxClients)
+	}
+
+	return limiters, nil
+}
+
+// allowAddr reports whether a request from the client at addr to a service
+// without routes, such as the STUN server, is allowed. Such requests share
+// the default limiter with the routes without a rule of their own.
+func (l *rateLimiters) allowAddr(addr netip.Addr) bool {
+	if !l.cfg.RateLimit.Enabled {
+		return true
+	}
+
+	key, ok := l.addrKey(addr)
+	if !ok {
+		return true
+	}
+
+	return l.shared.Allow(key, time.Now()).Allowed
+}
+
+// limiter returns the limiter of route.
+func (l *rateLimiters) limiter(route string) *ratelimit.Limiter {
+	if limiter, ok := l.routes[route]; ok {
+		return limiter
+	}
+
+	return l.shared
+}
+
+// key identifies the client making r by the network its address belongs to,
+// sized by the configured prefix lengths. It returns false for clients in the
+// allow-list, and for requests whose client can't be identified, which are
+// left to fail in the handler.
+func (l *rateLimiters) key(r *http.Request) (string, bool) {
+	clientIP, err := handler.ClientIP(r, l.cfg.Proxy)
+	if err != nil {
+		return "", false
+	}
+
+	addr, err := netip.ParseAddr(clientIP)
+	if err != nil {
+		return "", false
+	}
+
+	return l.addrKey(addr)
+}
+
+// addrKey identifies the client at addr like key does. It returns false for
+// clients in the allow-list.
+func (l *rateLimiters) addrKey(addr netip.Addr) (string, bool) {
+	addr = addr.Unmap().WithZone("")
+
+	for _, prefix := range l.allow {
+		if prefix.Contains(addr) {
+			return "", false
+		}
+	}
+
+	network := handler.AnonymizeIP(addr.String(), l.cfg.RateLimit.IPv4Prefix, l.cfg.RateLimit.IPv6Prefix)
+
+	return network.String(), true
+}
+
+// newLimiter creates a limiter applying rule to up to maxClients clients.
+func newLimiter(rule config.RateLimitRule, maxClients int) *ratelimit.Limiter {
+	return ratelimit.New(ratelimit.Rule{
+		Period:   time.Duration(rule.Period),
+		Requests: rule.Requests,
+		Burst:    rule.Burst,
+	}, maxClients)
+}
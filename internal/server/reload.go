@@ -0,0 +1,181 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"time"
+
+	"git.sr.ht/~jamesponddotco/accio127/internal/config"
+	"go.uber.org/zap"
+)
+
+// drainTimeout is how long the HTTP server replaced on reload is given to
+// finish its in-flight requests before its connections are closed.
+const drainTimeout time.Duration = 30 * time.Second
+
+// restartRequired reports whether a setting can only be changed by restarting
+// the server, because it affects the listeners, the databases, or the TLS
+// stack.
+func restartRequired(setting string) bool {
+	switch setting {
+	case "address", "listeners", "http3", "pid", "dsn", "counter", "geoip", "tls", "minTLSVersion", "proxyProtocol", "echo", "stun":
+		return true
+	default:
+		return false
+	}
+}
+
+// keepRestartRequired copies the settings that require a restart from previous
+// to current, so that they keep their running values.
+func keepRestartRequired(previous, current *config.Config) {
+	current.Address = previous.Address
+	current.Listeners = keepListeners(previous.Listeners, current.Listeners)
+	current.HTTP3 = previous.HTTP3
+	current.PID = previous.PID
+	current.DSN = previous.DSN
+	current.Counter = previous.Counter
+	current.GeoIP = previous.GeoIP
+	current.TLS = previous.TLS
+	current.MinTLSVersion = previous.MinTLSVersion
+	current.ProxyProtocol = previous.ProxyProtocol
+	current.Echo = previous.Echo
+	current.STUN = previous.STUN
+}
+
+// keepListeners returns the previous listeners, whose sockets can only be
+// changed by restarting the server, with the timeouts of the current listener
+// on the same network and address. Timeouts only apply to new connections, and
+// defaulted ones follow the server's, so they're taken from current.
+func keepListeners(previous, current []config.Listener) []config.Listener {
+	listeners := make([]config.Listener, len(previous))
+	copy(listeners, previous)
+
+	for i := range listeners {
+		if i >= len(current) || current[i].Network != listeners[i].Network || current[i].Address != listeners[i].Address {
+			continue
+		}
+
+		listeners[i].ReadTimeout = current[i].ReadTimeout
+		listeners[i].WriteTimeout = current[i].WriteTimeout
+		listeners[i].IdleTimeout = current[i].IdleTimeout
+	}
+
+	return listeners
+}
+
+// reload re-reads the configuration file and the TLS certificate, reopens the
+// access log so that it can be rotated, and replaces the HTTP server of each
+// listener with one using the new settings. The old servers keep serving their
+// in-flight requests until they complete, while new connections go to the new
+// ones.
+func (s *Server) reload(errs chan<- error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path := s.cfg.Path()
+
+	cfg, err := config.LoadConfig(path)
+	if err != nil {
+		s.logger.Error("Failed to reload configuration", zap.String("path", path), zap.Error(err))
+
+		return
+	}
+
+	// If the listeners only differ in their timeouts, keeping them changes
+	// nothing, and the change applies without a restart.
+	timeoutsOnly := reflect.DeepEqual(keepListeners(s.cfg.Listeners, cfg.Listeners), cfg.Listeners)
+
+	for _, change := range config.Diff(s.cfg, cfg) {
+		if restartRequired(change.Setting) && !(change.Setting == "listeners" && timeoutsOnly) {
+			s.logger.Warn(
+				"Ignoring setting change that requires a restart",
+				zap.String("setting", change.Setting),
+				zap.String("old", change.Old),
+				zap.String("new", change.New),
+			)
+
+			continue
+		}
+
+		s.logger.Info(
+			"Setting changed",
+			zap.String("setting", change.Setting),
+			zap.String("old", change.Old),
+			zap.String("new", change.New),
+		)
+	}
+
+	keepRestartRequired(s.cfg, cfg)
+
+	if err := s.openAccessLog(cfg); err != nil {
+		s.logger.Error("Failed to reopen access log, keeping the current settings", zap.Error(err))
+
+		cfg.AccessLog = s.cfg.AccessLog
+	}
+
+	limiters, err := newRateLimiters(cfg)
+	if err != nil {
+		s.logger.Error("Failed to apply reloaded configuration", zap.String("path", path), zap.Error(err))
+
+		return
+	}
+
+	httpServers, err := s.newHTTPServers(cfg, limiters)
+	if err != nil {
+		s.logger.Error("Failed to apply reloaded configuration", zap.String("path", path), zap.Error(err))
+
+		return
+	}
+
+	if s.certs != nil {
+		if err := s.certs.load(cfg.CertFile, cfg.CertKey); err != nil {
+			s.logger.Error("Failed to reload TLS certificate, keeping the current one", zap.Error(err))
+
+			cfg.CertFile = s.cfg.CertFile
+			cfg.CertKey = s.cfg.CertKey
+		} else {
+			s.logger.Info("TLS certificate reloaded", zap.String("certFile", cfg.CertFile))
+		}
+	}
+
+	previous := s.httpServers
+
+	s.cfg = cfg
+	s.httpServers = httpServers
+
+	for i, d := range s.dispatchers {
+		s.serve(s.httpServers[i], d.next(), errs)
+	}
+
+	if s.http3Server != nil {
+		s.http3Handler.store(httpServers[cfg.HTTPSListener()].Handler)
+	}
+
+	s.echoes.limiters.Store(limiters)
+
+	if err := s.storeEchoLog(cfg); err != nil {
+		s.logger.Error("Failed to apply reloaded access log settings to echo servers", zap.Error(err))
+	}
+
+	for _, httpServer := range previous {
+		go s.drain(httpServer)
+	}
+
+	s.logger.Info("Configuration reloaded", zap.String("path", path))
+}
+
+// drain shuts down httpServer, which was replaced on reload, closing the
+// connections that didn't finish their requests within drainTimeout.
+func (s *Server) drain(httpServer *http.Server) {
+	ctx, cancel := context.WithTimeout(context.Background(), drainTimeout)
+	defer cancel()
+
+	if err := httpServer.Shutdown(ctx); err != nil {
+		s.logger.Warn("Closing connections that didn't drain in time", zap.Error(err))
+
+		if err := httpServer.Close(); err != nil {
+			s.logger.Error("Failed to close previous HTTP server", zap.Error(err))
+		}
+	}
+}
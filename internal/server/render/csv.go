@@ -0,0 +1,55 @@
+package render
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"git.sr.ht/~jamesponddotco/accio127/internal/server/model"
+)
+
+// CSV renders an IP address as a CSV document with a header row. Anonymized
+// addresses get two extra columns for their CIDR notation and prefix length,
+// and hashed ones three for the ID, epoch, and expiry of their key.
+type CSV struct{}
+
+// Format implements the Renderer interface.
+func (CSV) Format() string {
+	return "csv"
+}
+
+// MediaTypes implements the Renderer interface.
+func (CSV) MediaTypes() []string {
+	return []string{TextCSV}
+}
+
+// Render implements the Renderer interface.
+func (CSV) Render(_ *http.Request, ip *model.IP) ([]byte, error) {
+	var (
+		buf    bytes.Buffer
+		writer = csv.NewWriter(&buf)
+	)
+
+	records := [][]string{
+		{"ipv4", "ipv6"},
+		{ip.V4, ip.V6},
+	}
+
+	if ip.CIDR != "" {
+		records[0] = append(records[0], "cidr", "prefixLength")
+		records[1] = append(records[1], ip.CIDR, strconv.Itoa(ip.PrefixLength))
+	}
+
+	if ip.Key != nil {
+		records[0] = append(records[0], "keyId", "keyEpoch", "keyExpires")
+		records[1] = append(records[1], ip.Key.ID, strconv.FormatInt(ip.Key.Epoch, 10), ip.Key.Expires)
+	}
+
+	if err := writer.WriteAll(records); err != nil {
+		return nil, fmt.Errorf("failed to write IP address as CSV: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
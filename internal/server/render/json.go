@@ -0,0 +1,108 @@
+package render
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"git.sr.ht/~jamesponddotco/accio127/internal/server/model"
+	"git.sr.ht/~jamesponddotco/xstd-go/xnet/xhttp"
+)
+
+// maxCallbackLength is the maximum length of a JSONP callback name.
+const maxCallbackLength int = 128
+
+// JSON renders an IP address as JSON.
+type JSON struct{}
+
+// Format implements the Renderer interface.
+func (JSON) Format() string {
+	return "json"
+}
+
+// MediaTypes implements the Renderer interface.
+func (JSON) MediaTypes() []string {
+	return []string{xhttp.ApplicationJSON}
+}
+
+// Render implements the Renderer interface.
+func (JSON) Render(_ *http.Request, ip *model.IP) ([]byte, error) {
+	ipJSON, err := json.Marshal(ip)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal IP address to JSON: %w", err)
+	}
+
+	return ipJSON, nil
+}
+
+// JSONP renders an IP address as JSON wrapped in a JavaScript function call,
+// using the callback query parameter as the function name.
+type JSONP struct{}
+
+// Format implements the Renderer interface.
+func (JSONP) Format() string {
+	return "jsonp"
+}
+
+// MediaTypes implements the Renderer interface.
+func (JSONP) MediaTypes() []string {
+	return []string{xhttp.ApplicationJavascript, xhttp.TextJavascript}
+}
+
+// Render implements the Renderer interface.
+func (JSONP) Render(r *http.Request, ip *model.IP) ([]byte, error) {
+	callback := r.URL.Query().Get(CallbackParam)
+	if callback == "" {
+		callback = DefaultCallback
+	}
+
+	if !ValidCallback(callback) {
+		return nil, ErrInvalidCallback
+	}
+
+	ipJSON, err := JSON{}.Render(r, ip)
+	if err != nil {
+		return nil, err
+	}
+
+	// The leading comment protects against Rosetta Flash style attacks on
+	// older browsers.
+	return []byte("/**/" + callback + "(" + string(ipJSON) + ");"), nil
+}
+
+// ValidCallback reports whether name can safely be used as a JSONP callback.
+// Only dotted JavaScript identifiers made of ASCII letters, digits, underscores
+// and dollar signs are allowed.
+func ValidCallback(name string) bool {
+	if name == "" || len(name) > maxCallbackLength {
+		return false
+	}
+
+	start := true
+
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+
+		switch {
+		case c == '.':
+			if start {
+				return false
+			}
+
+			start = true
+
+			continue
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c == '_', c == '$':
+		case c >= '0' && c <= '9':
+			if start {
+				return false
+			}
+		default:
+			return false
+		}
+
+		start = false
+	}
+
+	return !start
+}
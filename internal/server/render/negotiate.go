@@ -0,0 +1,110 @@
+package render
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+
+	"git.sr.ht/~jamesponddotco/accio127/internal/accept"
+	"git.sr.ht/~jamesponddotco/xstd-go/xnet/xhttp"
+)
+
+// Negotiator selects a Renderer for a request based on its format query
+// parameter and Accept header.
+type Negotiator struct {
+	renderers []Renderer
+}
+
+// NewNegotiator creates a new Negotiator instance. The first renderer is used
+// when the request expresses no preference.
+func NewNegotiator(renderers ...Renderer) *Negotiator {
+	return &Negotiator{
+		renderers: renderers,
+	}
+}
+
+// DefaultNegotiator creates a new Negotiator with every renderer provided by
+// this package, defaulting to plain text.
+func DefaultNegotiator() *Negotiator {
+	return NewNegotiator(
+		Text{},
+		JSON{},
+		JSONP{},
+		XML{},
+		CSV{},
+		YAML{},
+	)
+}
+
+// MediaTypes returns the media types the Negotiator can produce, in order of
+// preference.
+func (n *Negotiator) MediaTypes() []string {
+	mediaTypes := make([]string, 0, len(n.renderers))
+
+	for _, renderer := range n.renderers {
+		mediaTypes = append(mediaTypes, renderer.MediaTypes()...)
+	}
+
+	return mediaTypes
+}
+
+// Negotiate returns the Renderer that best satisfies the request.
+//
+// The format query parameter takes precedence over the Accept header. When the
+// Accept header is missing or accepts anything, the request's Content-Type is
+// honored for backwards compatibility, and the first renderer is used
+// otherwise.
+func (n *Negotiator) Negotiate(r *http.Request) (Renderer, error) {
+	if len(n.renderers) == 0 {
+		return nil, ErrNotAcceptable
+	}
+
+	if format := r.URL.Query().Get(FormatParam); format != "" {
+		for _, renderer := range n.renderers {
+			if strings.EqualFold(renderer.Format(), format) {
+				return renderer, nil
+			}
+		}
+
+		return nil, fmt.Errorf("%w: unknown format %q", ErrNotAcceptable, format)
+	}
+
+	if accept.Anything(r) {
+		if renderer := n.byContentType(r.Header.Get(xhttp.ContentType)); renderer != nil {
+			return renderer, nil
+		}
+
+		return n.renderers[0], nil
+	}
+
+	mediaType, err := accept.Preferred(r, n.MediaTypes()...)
+	if err != nil {
+		return nil, ErrNotAcceptable
+	}
+
+	return n.byContentType(mediaType), nil
+}
+
+// byContentType returns the renderer producing the given media type, or nil if
+// there isn't one.
+func (n *Negotiator) byContentType(contentType string) Renderer {
+	if contentType == "" {
+		return nil
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil
+	}
+
+	for _, renderer := range n.renderers {
+		for _, candidate := range renderer.MediaTypes() {
+			if strings.EqualFold(candidate, mediaType) {
+				return renderer
+			}
+		}
+	}
+
+	return nil
+}
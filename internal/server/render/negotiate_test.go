@@ -0,0 +1,136 @@
+package render_test
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"git.sr.ht/~jamesponddotco/accio127/internal/server/render"
+)
+
+func TestNegotiator_Negotiate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		url         string
+		accept      string
+		contentType string
+		wantFormat  string
+		wantErr     error
+	}{
+		{
+			name:       "no_preference",
+			url:        "http://localhost/",
+			wantFormat: "text",
+		},
+		{
+			name:       "wildcard",
+			url:        "http://localhost/",
+			accept:     "*/*",
+			wantFormat: "text",
+		},
+		{
+			name:        "legacy_content_type",
+			url:         "http://localhost/",
+			accept:      "*/*",
+			contentType: "application/json",
+			wantFormat:  "json",
+		},
+		{
+			name:       "exact_match",
+			url:        "http://localhost/",
+			accept:     "application/xml",
+			wantFormat: "xml",
+		},
+		{
+			name:       "secondary_media_type",
+			url:        "http://localhost/",
+			accept:     "text/xml",
+			wantFormat: "xml",
+		},
+		{
+			name:       "quality_values",
+			url:        "http://localhost/",
+			accept:     "application/json;q=0.5, text/csv;q=0.9, text/plain;q=0.1",
+			wantFormat: "csv",
+		},
+		{
+			name:       "specific_range_wins_tie",
+			url:        "http://localhost/",
+			accept:     "text/*, application/yaml",
+			wantFormat: "yaml",
+		},
+		{
+			name:       "type_wildcard",
+			url:        "http://localhost/",
+			accept:     "application/*",
+			wantFormat: "json",
+		},
+		{
+			name:       "excluded_by_zero_quality",
+			url:        "http://localhost/",
+			accept:     "*/*, text/plain;q=0",
+			wantFormat: "json",
+		},
+		{
+			name:       "format_overrides_accept",
+			url:        "http://localhost/?format=YAML",
+			accept:     "application/json",
+			wantFormat: "yaml",
+		},
+		{
+			name:    "unknown_format",
+			url:     "http://localhost/?format=toml",
+			wantErr: render.ErrNotAcceptable,
+		},
+		{
+			name:    "not_acceptable",
+			url:     "http://localhost/",
+			accept:  "image/png",
+			wantErr: render.ErrNotAcceptable,
+		},
+		{
+			name:    "everything_excluded",
+			url:     "http://localhost/",
+			accept:  "*/*;q=0",
+			wantErr: render.ErrNotAcceptable,
+		},
+	}
+
+	negotiator := render.DefaultNegotiator()
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			req, err := http.NewRequest(http.MethodGet, tt.url, http.NoBody)
+			if err != nil {
+				t.Fatalf("Failed to create request: %v", err)
+			}
+
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+
+			got, err := negotiator.Negotiate(req)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Negotiate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr != nil {
+				return
+			}
+
+			if got.Format() != tt.wantFormat {
+				t.Errorf("Negotiate() = %s, want %s", got.Format(), tt.wantFormat)
+			}
+		})
+	}
+}
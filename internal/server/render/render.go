@@ -0,0 +1,56 @@
+// Package render provides content negotiation and renderers for IP address
+// responses.
+package render
+
+import (
+	"net/http"
+
+	"git.sr.ht/~jamesponddotco/accio127/internal/accept"
+	"git.sr.ht/~jamesponddotco/accio127/internal/server/model"
+	"git.sr.ht/~jamesponddotco/xstd-go/xerrors"
+)
+
+const (
+	// ErrNotAcceptable is returned when none of the registered renderers can
+	// satisfy the request.
+	ErrNotAcceptable xerrors.Error = accept.ErrNotAcceptable
+
+	// ErrInvalidCallback is returned when a JSONP callback name is not a valid
+	// JavaScript identifier.
+	ErrInvalidCallback xerrors.Error = "invalid JSONP callback"
+)
+
+// Media types not covered by the xhttp package.
+const (
+	TextXML           string = "text/xml"
+	TextCSV           string = "text/csv"
+	TextYAML          string = "text/yaml"
+	ApplicationYAML   string = "application/yaml"
+	ApplicationNDJSON string = "application/x-ndjson"
+)
+
+const (
+	// FormatParam is the query parameter used to force a response format.
+	FormatParam string = "format"
+
+	// CallbackParam is the query parameter holding the JSONP callback name.
+	CallbackParam string = "callback"
+
+	// DefaultCallback is the JSONP callback name used when the request does not
+	// provide one.
+	DefaultCallback string = "callback"
+)
+
+// Renderer writes an IP address in a specific representation.
+type Renderer interface {
+	// Format returns the short name of the representation, as accepted by the
+	// format query parameter.
+	Format() string
+
+	// MediaTypes returns the media types the renderer can produce. The first
+	// one is used as the response's Content-Type.
+	MediaTypes() []string
+
+	// Render returns the representation of ip for the given request.
+	Render(r *http.Request, ip *model.IP) ([]byte, error)
+}
@@ -0,0 +1,183 @@
+package render_test
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"git.sr.ht/~jamesponddotco/accio127/internal/server/model"
+	"git.sr.ht/~jamesponddotco/accio127/internal/server/render"
+)
+
+func TestRenderers(t *testing.T) {
+	t.Parallel()
+
+	var (
+		ipv4 = &model.IP{V4: "192.0.2.1"}
+		ipv6 = &model.IP{V6: "2001:db8::68"}
+		hash = &model.IP{
+			V4:  "5e8f",
+			Key: &model.HashKey{ID: "1a2b3c4d", Epoch: 19509, Expires: "2023-06-02T00:00:00Z"},
+		}
+		anonymized = &model.IP{V4: "192.0.2.0", CIDR: "192.0.2.0/24", PrefixLength: 24}
+	)
+
+	tests := []struct {
+		name     string
+		renderer render.Renderer
+		url      string
+		give     *model.IP
+		want     string
+		wantErr  error
+	}{
+		{
+			name:     "text",
+			renderer: render.Text{},
+			give:     ipv6,
+			want:     "2001:db8::68",
+		},
+		{
+			name:     "json",
+			renderer: render.JSON{},
+			give:     ipv4,
+			want:     `{"ipv4":"192.0.2.1"}`,
+		},
+		{
+			name:     "jsonp_default_callback",
+			renderer: render.JSONP{},
+			give:     ipv4,
+			want:     `/**/callback({"ipv4":"192.0.2.1"});`,
+		},
+		{
+			name:     "jsonp_custom_callback",
+			renderer: render.JSONP{},
+			url:      "http://localhost/?callback=app.handle_ip",
+			give:     ipv4,
+			want:     `/**/app.handle_ip({"ipv4":"192.0.2.1"});`,
+		},
+		{
+			name:     "jsonp_invalid_callback",
+			renderer: render.JSONP{},
+			url:      "http://localhost/?callback=alert(1)",
+			give:     ipv4,
+			wantErr:  render.ErrInvalidCallback,
+		},
+		{
+			name:     "xml",
+			renderer: render.XML{},
+			give:     ipv6,
+			want:     "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<ip><ipv6>2001:db8::68</ipv6></ip>",
+		},
+		{
+			name:     "csv",
+			renderer: render.CSV{},
+			give:     ipv4,
+			want:     "ipv4,ipv6\n192.0.2.1,\n",
+		},
+		{
+			name:     "yaml",
+			renderer: render.YAML{},
+			give:     ipv6,
+			want:     "ipv6: \"2001:db8::68\"\n",
+		},
+		{
+			name:     "json_hash_key",
+			renderer: render.JSON{},
+			give:     hash,
+			want:     `{"key":{"id":"1a2b3c4d","expires":"2023-06-02T00:00:00Z","epoch":19509},"ipv4":"5e8f"}`,
+		},
+		{
+			name:     "yaml_hash_key",
+			renderer: render.YAML{},
+			give:     hash,
+			want:     "ipv4: \"5e8f\"\nkey:\n  id: \"1a2b3c4d\"\n  epoch: 19509\n  expires: \"2023-06-02T00:00:00Z\"\n",
+		},
+		{
+			name:     "csv_hash_key",
+			renderer: render.CSV{},
+			give:     hash,
+			want:     "ipv4,ipv6,keyId,keyEpoch,keyExpires\n5e8f,,1a2b3c4d,19509,2023-06-02T00:00:00Z\n",
+		},
+		{
+			name:     "csv_hash_key_without_rotation",
+			renderer: render.CSV{},
+			give:     &model.IP{V6: "9c1d", Key: &model.HashKey{ID: "1a2b3c4d"}},
+			want:     "ipv4,ipv6,keyId,keyEpoch,keyExpires\n,9c1d,1a2b3c4d,0,\n",
+		},
+		{
+			name:     "json_anonymized",
+			renderer: render.JSON{},
+			give:     anonymized,
+			want:     `{"ipv4":"192.0.2.0","cidr":"192.0.2.0/24","prefixLength":24}`,
+		},
+		{
+			name:     "csv_anonymized",
+			renderer: render.CSV{},
+			give:     anonymized,
+			want:     "ipv4,ipv6,cidr,prefixLength\n192.0.2.0,,192.0.2.0/24,24\n",
+		},
+		{
+			name:     "yaml_anonymized",
+			renderer: render.YAML{},
+			give:     anonymized,
+			want:     "ipv4: \"192.0.2.0\"\ncidr: \"192.0.2.0/24\"\nprefixLength: 24\n",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			url := tt.url
+			if url == "" {
+				url = "http://localhost/"
+			}
+
+			req, err := http.NewRequest(http.MethodGet, url, http.NoBody)
+			if err != nil {
+				t.Fatalf("Failed to create request: %v", err)
+			}
+
+			got, err := tt.renderer.Render(req, tt.give)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Render() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if string(got) != tt.want {
+				t.Errorf("Render() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidCallback(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		give string
+		want bool
+	}{
+		{name: "identifier", give: "handleIP", want: true},
+		{name: "dotted", give: "jQuery.cb_1$", want: true},
+		{name: "empty", give: "", want: false},
+		{name: "leading_digit", give: "1cb", want: false},
+		{name: "trailing_dot", give: "cb.", want: false},
+		{name: "double_dot", give: "a..b", want: false},
+		{name: "script", give: "alert(document.cookie)", want: false},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := render.ValidCallback(tt.give); got != tt.want {
+				t.Errorf("ValidCallback(%q) = %v, want %v", tt.give, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,26 @@
+package render
+
+import (
+	"net/http"
+
+	"git.sr.ht/~jamesponddotco/accio127/internal/server/model"
+	"git.sr.ht/~jamesponddotco/xstd-go/xnet/xhttp"
+)
+
+// Text renders an IP address as plain text.
+type Text struct{}
+
+// Format implements the Renderer interface.
+func (Text) Format() string {
+	return "text"
+}
+
+// MediaTypes implements the Renderer interface.
+func (Text) MediaTypes() []string {
+	return []string{xhttp.TextPlain}
+}
+
+// Render implements the Renderer interface.
+func (Text) Render(_ *http.Request, ip *model.IP) ([]byte, error) {
+	return []byte(ip.String()), nil
+}
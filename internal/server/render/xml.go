@@ -0,0 +1,33 @@
+package render
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+
+	"git.sr.ht/~jamesponddotco/accio127/internal/server/model"
+	"git.sr.ht/~jamesponddotco/xstd-go/xnet/xhttp"
+)
+
+// XML renders an IP address as an XML document.
+type XML struct{}
+
+// Format implements the Renderer interface.
+func (XML) Format() string {
+	return "xml"
+}
+
+// MediaTypes implements the Renderer interface.
+func (XML) MediaTypes() []string {
+	return []string{xhttp.ApplicationXML, TextXML}
+}
+
+// Render implements the Renderer interface.
+func (XML) Render(_ *http.Request, ip *model.IP) ([]byte, error) {
+	ipXML, err := xml.Marshal(ip)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal IP address to XML: %w", err)
+	}
+
+	return append([]byte(xml.Header), ipXML...), nil
+}
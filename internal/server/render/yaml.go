@@ -0,0 +1,71 @@
+package render
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"git.sr.ht/~jamesponddotco/accio127/internal/server/model"
+)
+
+// YAML renders an IP address as a YAML document.
+type YAML struct{}
+
+// Format implements the Renderer interface.
+func (YAML) Format() string {
+	return "yaml"
+}
+
+// MediaTypes implements the Renderer interface.
+func (YAML) MediaTypes() []string {
+	return []string{ApplicationYAML, TextYAML, "application/x-yaml"}
+}
+
+// Render implements the Renderer interface.
+//
+// The document is small and flat, so it's written by hand instead of pulling
+// in a YAML library. Values are double-quoted, which keeps IPv6 addresses from
+// being misread as mappings.
+func (YAML) Render(_ *http.Request, ip *model.IP) ([]byte, error) {
+	var builder strings.Builder
+
+	if ip.V4 != "" {
+		builder.WriteString("ipv4: ")
+		builder.WriteString(strconv.Quote(ip.V4))
+		builder.WriteString("\n")
+	}
+
+	if ip.V6 != "" {
+		builder.WriteString("ipv6: ")
+		builder.WriteString(strconv.Quote(ip.V6))
+		builder.WriteString("\n")
+	}
+
+	if ip.CIDR != "" {
+		builder.WriteString("cidr: ")
+		builder.WriteString(strconv.Quote(ip.CIDR))
+		builder.WriteString("\nprefixLength: ")
+		builder.WriteString(strconv.Itoa(ip.PrefixLength))
+		builder.WriteString("\n")
+	}
+
+	if ip.Key != nil {
+		builder.WriteString("key:\n  id: ")
+		builder.WriteString(strconv.Quote(ip.Key.ID))
+		builder.WriteString("\n  epoch: ")
+		builder.WriteString(strconv.FormatInt(ip.Key.Epoch, 10))
+		builder.WriteString("\n")
+
+		if ip.Key.Expires != "" {
+			builder.WriteString("  expires: ")
+			builder.WriteString(strconv.Quote(ip.Key.Expires))
+			builder.WriteString("\n")
+		}
+	}
+
+	if builder.Len() == 0 {
+		return []byte("{}\n"), nil
+	}
+
+	return []byte(builder.String()), nil
+}
@@ -3,135 +3,712 @@ package server
 
 import (
 	"context"
+	"crypto/rand"
 	"crypto/tls"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"net/netip"
 	"os"
 	"os/signal"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 
+	"git.sr.ht/~jamesponddotco/accio127/internal/accesslog"
 	"git.sr.ht/~jamesponddotco/accio127/internal/config"
 	"git.sr.ht/~jamesponddotco/accio127/internal/database"
+	"git.sr.ht/~jamesponddotco/accio127/internal/dualstack"
 	"git.sr.ht/~jamesponddotco/accio127/internal/endpoint"
 	apierror "git.sr.ht/~jamesponddotco/accio127/internal/errors"
+	"git.sr.ht/~jamesponddotco/accio127/internal/geoip"
+	"git.sr.ht/~jamesponddotco/accio127/internal/iphash"
+	"git.sr.ht/~jamesponddotco/accio127/internal/metrics"
+	"git.sr.ht/~jamesponddotco/accio127/internal/proxyproto"
 	"git.sr.ht/~jamesponddotco/accio127/internal/server/handler"
 	"git.sr.ht/~jamesponddotco/accio127/internal/server/middleware"
-	"git.sr.ht/~jamesponddotco/xstd-go/xcrypto/xtls"
+	"git.sr.ht/~jamesponddotco/accio127/internal/server/render"
 	"github.com/julienschmidt/httprouter"
+	"github.com/quic-go/quic-go/http3"
 	"go.uber.org/zap"
 )
 
+// shutdownTimeout is how long Start waits for in-flight requests to complete
+// when shutting down.
+const shutdownTimeout time.Duration = 5 * time.Second
+
 type Server struct {
-	httpServer *http.Server
-	logger     *zap.Logger
+	db        database.Store
+	logger    *zap.Logger
+	tlsConfig *tls.Config
+
+	// metrics holds the request metrics, which outlive reloads.
+	metrics *metrics.Registry
+
+	// geo looks up the location of IP addresses. It's nil if GeoIP lookups
+	// are disabled.
+	geo *geoip.Reader
+
+	// accessLogFile is where the access log is written. It's nil until the
+	// access log is enabled, and is reopened on reload.
+	accessLogFile *accesslog.File
+
+	// hashSecret is the random secret used to hash IP addresses when the
+	// configuration doesn't provide one. It outlives reloads, so that hashes
+	// only change on restart.
+	hashSecret []byte
+
+	// certs holds the certificate loaded from disk. It's nil unless the TLS
+	// mode is "files".
+	certs *certificateStore
+
+	// proxyAllow holds the load balancers allowed to send PROXY protocol
+	// headers. It's nil if the PROXY protocol is disabled.
+	proxyAllow   []netip.Prefix
+	proxyTimeout time.Duration
+
+	// http3Server serves HTTP/3 requests with http3Handler over http3Conn.
+	// It's nil if HTTP/3 is disabled.
+	http3Server  *http3.Server
+	http3Handler reloadableHandler
+	http3Conn    net.PacketConn
+
+	// echoes holds the servers returning the client's IP address over plain
+	// TCP, UDP, and DNS.
+	echoes echoServers
+
+	// done is closed by Shutdown, so that Start returns.
+	done      chan struct{}
+	closeDone sync.Once
+
+	// mu guards the fields below. The HTTP servers are replaced on reload,
+	// and there's one for each listener, in the same order as the
+	// dispatchers handing them connections.
+	mu          sync.Mutex
+	cfg         *config.Config
+	httpServers []*http.Server
+	dispatchers []*dispatcher
 }
 
-func New(cfg *config.Config, db *database.DB, logger *zap.Logger) (*Server, error) {
-	cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.CertKey)
+func New(cfg *config.Config, db database.Store, logger *zap.Logger) (*Server, error) {
+	var (
+		tlsConfig *tls.Config
+		certs     *certificateStore
+		err       error
+	)
+
+	if cfg.TLS.Mode != config.TLSModeOff {
+		tlsConfig, certs, err = newTLSConfig(cfg)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var proxyAllow []netip.Prefix
+
+	if cfg.ProxyProtocol.Enabled {
+		proxyAllow, err = config.ParsePrefixes(cfg.ProxyProtocol.Allow)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse PROXY protocol allow list: %w", err)
+		}
+	}
+
+	hashSecret := make([]byte, config.MinHashSecretLength)
+
+	if _, err = rand.Read(hashSecret); err != nil {
+		return nil, fmt.Errorf("failed to generate hash secret: %w", err)
+	}
+
+	if cfg.Hash.Secret == "" {
+		logger.Warn("No hash secret configured, using a random one; hashed IP addresses will change on restart")
+	}
+
+	var geo *geoip.Reader
+
+	if cfg.GeoIP.Enabled() {
+		geo, err = geoip.Open(logger, cfg.GeoIP.Databases, time.Duration(cfg.GeoIP.CheckInterval))
+		if err != nil {
+			return nil, fmt.Errorf("failed to open GeoIP databases: %w", err)
+		}
+	}
+
+	s := &Server{
+		db:           db,
+		logger:       logger,
+		tlsConfig:    tlsConfig,
+		metrics:      metrics.NewRegistry(),
+		geo:          geo,
+		hashSecret:   hashSecret,
+		certs:        certs,
+		proxyAllow:   proxyAllow,
+		proxyTimeout: time.Duration(cfg.ProxyProtocol.Timeout),
+		done:         make(chan struct{}),
+		cfg:          cfg,
+	}
+
+	if err = s.openAccessLog(cfg); err != nil {
+		if geo != nil {
+			geo.Close()
+		}
+
+		return nil, err
+	}
+
+	var limiters *rateLimiters
+
+	if err = s.newEchoServers(cfg); err == nil {
+		limiters, err = newRateLimiters(cfg)
+	}
+
+	if err == nil {
+		s.httpServers, err = s.newHTTPServers(cfg, limiters)
+	}
+
 	if err != nil {
-		return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+		if geo != nil {
+			geo.Close()
+		}
+
+		return nil, err
 	}
 
-	var tlsConfig *tls.Config
+	s.echoes.limiters.Store(limiters)
 
-	if cfg.MinTLSVersion == "TLS13" {
-		tlsConfig = xtls.ModernServerConfig()
+	if cfg.HTTP3.Enabled {
+		s.http3Handler.store(s.httpServers[cfg.HTTPSListener()].Handler)
+		s.http3Server = s.newHTTP3Server(cfg)
 	}
 
-	if cfg.MinTLSVersion == "TLS12" {
-		tlsConfig = xtls.IntermediateServerConfig()
+	return s, nil
+}
+
+// secret returns the secret IP addresses are hashed with, which is the one
+// in cfg if any, and the random one generated on startup otherwise.
+func (s *Server) secret(cfg *config.Config) []byte {
+	if cfg.Hash.Secret != "" {
+		return []byte(cfg.Hash.Secret)
+	}
+
+	return s.hashSecret
+}
+
+// newHasher creates the IP hasher configured in cfg.
+func (s *Server) newHasher(cfg *config.Config) (*iphash.Hasher, error) {
+	hasher, err := iphash.New(cfg.Hash.Algorithm, s.secret(cfg), time.Duration(cfg.Hash.Rotation))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create IP hasher: %w", err)
+	}
+
+	return hasher, nil
+}
+
+// newHTTPServers builds an HTTP server and its routes from cfg for each of
+// the listeners, in the same order, limiting requests with limiters.
+func (s *Server) newHTTPServers(cfg *config.Config, limiters *rateLimiters) ([]*http.Server, error) {
+	var (
+		db     = s.db
+		logger = s.logger
+	)
+
+	hasher, err := s.newHasher(cfg)
+	if err != nil {
+		return nil, err
 	}
 
-	tlsConfig.Certificates = []tls.Certificate{cert}
+	// middlewares returns the middlewares of the route at path, which accepts
+	// methods.
+	middlewares := func(path string, methods []string) []func(httprouter.Handle) httprouter.Handle {
+		// Rate limiting runs after the headers below are set, so that denied
+		// requests still get them.
+		limit := func(h httprouter.Handle) httprouter.Handle { return h }
+		if cfg.RateLimit.Enabled {
+			limit = func(h httprouter.Handle) httprouter.Handle {
+				return middleware.RateLimit(logger, limiters.limiter(path), limiters.key, h)
+			}
+		}
 
-	middlewares := []func(httprouter.Handle) httprouter.Handle{
-		func(h httprouter.Handle) httprouter.Handle { return middleware.PanicRecovery(logger, h) },
-		func(h httprouter.Handle) httprouter.Handle { return middleware.UserAgent(logger, h) },
-		func(h httprouter.Handle) httprouter.Handle { return middleware.AcceptRequests(logger, h) },
-		func(h httprouter.Handle) httprouter.Handle { return middleware.PrivacyPolicy(cfg.PrivacyPolicy, h) },
-		middleware.SecureHeader,
-		middleware.CORS,
+		return []func(httprouter.Handle) httprouter.Handle{
+			func(h httprouter.Handle) httprouter.Handle { return middleware.PanicRecovery(logger, h) },
+			func(h httprouter.Handle) httprouter.Handle { return middleware.UserAgent(logger, h) },
+			func(h httprouter.Handle) httprouter.Handle { return middleware.AcceptMethods(logger, methods, h) },
+			limit,
+			func(h httprouter.Handle) httprouter.Handle { return middleware.PrivacyPolicy(cfg.PrivacyPolicy, h) },
+			middleware.SecureHeader,
+			func(h httprouter.Handle) httprouter.Handle { return middleware.CORSMethods(methods, h) },
+		}
 	}
 
 	var (
-		ipHandler           = handler.NewIPHandler(cfg, db, logger)
-		anonymizedIPHandler = handler.NewAnonymizedIPHandler(cfg, db, logger)
-		hashedIPHandler     = handler.NewHashedIPHandler(cfg, db, logger)
-		metricsHandler      = handler.NewMetricsHandler(db, logger)
-		healthHandler       = handler.NewHealthHandler(db, logger)
+		negotiator          = render.DefaultNegotiator()
+		ipHandler           = handler.NewIPHandler(cfg, db, negotiator, logger)
+		anonymizedIPHandler = handler.NewAnonymizedIPHandler(cfg, db, negotiator, logger)
+		hashedIPHandler     = handler.NewHashedIPHandler(cfg, db, hasher, negotiator, logger)
+		ipDetailsHandler    = handler.NewIPDetailsHandler(cfg, db, logger)
+		metricsHandler      = handler.NewMetricsHandler(db, s.metrics, logger)
+		healthHandler       = handler.NewHealthHandler(db, s.geo, s.echoes.stun, logger)
 		heartbeatHandler    = handler.NewHeartbeatHandler(logger)
 	)
 
-	mux := httprouter.New()
-	mux.NotFound = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		apierror.JSON(w, logger, apierror.ErrorResponse{
-			Code:    http.StatusNotFound,
-			Message: "Page not found. Check the URL and try again.",
+	// logged writes an entry to the access log for every request to the
+	// route at path, if it's enabled.
+	logged := func(_ string, h httprouter.Handle) httprouter.Handle { return h }
+
+	if cfg.AccessLog.Enabled {
+		var (
+			accessLog = accesslog.New(s.accessLogFile, cfg.AccessLog.Format)
+			client    = accessLogClient(cfg, hasher)
+		)
+
+		logged = func(path string, h httprouter.Handle) httprouter.Handle {
+			return middleware.AccessLog(logger, accessLog, path, client, h)
+		}
+	}
+
+	// unrouted wraps a handler for requests that don't match any route.
+	unrouted := func(h httprouter.Handle) http.Handler {
+		handle := middleware.RequestID(logger, logged("", middleware.Head(h)))
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handle(w, r, nil)
+		})
+	}
+
+	var (
+		notFound = unrouted(func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+			apierror.JSON(w, r, logger, apierror.ErrorResponse{
+				Code:    http.StatusNotFound,
+				Type:    apierror.TypeNotFound,
+				Message: "Page not found. Check the URL and try again.",
+			})
 		})
-	})
-
-	mux.GET(endpoint.IP, middleware.Chain(ipHandler.Handle, middlewares...))
-	mux.GET(endpoint.IPAnonymize, middleware.Chain(anonymizedIPHandler.Handle, middlewares...))
-	mux.GET(endpoint.IPHashed, middleware.Chain(hashedIPHandler.Handle, middlewares...))
-	mux.GET(endpoint.Metrics, middleware.Chain(metricsHandler.Handle, middlewares...))
-	mux.GET(endpoint.Health, middleware.Chain(healthHandler.Handle, middlewares...))
-	mux.GET(endpoint.Ping, middleware.Chain(heartbeatHandler.Handle, middlewares...))
-
-	httpServer := &http.Server{
-		Addr:         cfg.Address,
-		Handler:      mux,
-		TLSConfig:    tlsConfig,
-		ReadTimeout:  time.Duration(cfg.ReadTimeout),
-		WriteTimeout: time.Duration(cfg.WriteTimeout),
-		IdleTimeout:  time.Duration(cfg.IdleTimeout),
-	}
-
-	return &Server{
-		httpServer: httpServer,
-		logger:     logger,
-	}, nil
+		methodNotAllowed = unrouted(middleware.MethodNotAllowed(logger))
+	)
+
+	newRouter := func() *httprouter.Router {
+		router := httprouter.New()
+		router.NotFound = notFound
+		router.MethodNotAllowed = methodNotAllowed
+
+		return router
+	}
+
+	var sealer *dualstack.Sealer
+
+	if cfg.DualStack.Enabled {
+		sealer, err = dualstack.New(s.secret(cfg), time.Duration(cfg.DualStack.TokenTTL))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create dual-stack token sealer: %w", err)
+		}
+	}
+
+	// routes returns the router of listener, with the routes it serves.
+	routes := func(listener config.Listener) *httprouter.Router {
+		mux := newRouter()
+
+		// handle registers h at path on router, wrapped with the
+		// middlewares, unless the listener doesn't serve path. Routes accept
+		// GET requests unless given other methods, along with HEAD requests
+		// if they accept GET ones, and OPTIONS requests. Their requests are
+		// identified, and their metrics and access log entries are recorded
+		// under path.
+		handle := func(router *httprouter.Router, path string, h httprouter.Handle, methods ...string) {
+			if !listener.Serves(path) {
+				return
+			}
+
+			var (
+				allowed = allowedMethods(methods)
+				chain   = middleware.Chain(h, middlewares(path, allowed)...)
+			)
+
+			if listener.Protocol == config.ListenerProtocolHTTPS && cfg.HTTP3.Enabled {
+				chain = middleware.AltSvc(cfg.HTTP3.Port, int(time.Duration(cfg.HTTP3.MaxAge)/time.Second), chain)
+			}
+
+			wrapped := middleware.Metrics(s.metrics, path, middleware.RequestID(logger, logged(path, middleware.Head(chain))))
+
+			for _, method := range allowed {
+				router.Handle(method, path, wrapped)
+			}
+		}
+
+		handle(mux, endpoint.IP, ipHandler.Handle)
+		handle(mux, endpoint.IPAnonymize, anonymizedIPHandler.Handle)
+		handle(mux, endpoint.IPHashed, hashedIPHandler.Handle)
+		handle(mux, endpoint.IPDetails, ipDetailsHandler.Handle)
+
+		if s.geo != nil {
+			geoIPHandler := handler.NewGeoIPHandler(cfg, db, s.geo, logger)
+
+			handle(mux, endpoint.IPGeo, geoIPHandler.Handle)
+		}
+
+		handle(mux, endpoint.Metrics, metricsHandler.Handle)
+		handle(mux, endpoint.Health, healthHandler.Handle)
+		handle(mux, endpoint.Ping, heartbeatHandler.Handle)
+
+		if cfg.Batch.Enabled {
+			batchIPHandler := handler.NewBatchIPHandler(cfg, db, hasher, logger)
+
+			handle(mux, endpoint.IPBatch, batchIPHandler.Handle, http.MethodPost)
+		}
+
+		if sealer != nil {
+			dualStackHandler := handler.NewDualStackHandler(cfg, db, sealer, logger)
+
+			handle(mux, endpoint.IPBoth, dualStackHandler.Handle)
+			handle(mux, endpoint.IPBothToken, dualStackHandler.Handle)
+		}
+
+		// httprouter doesn't allow a parameter where static routes such as
+		// /v1/ip/hashed live, so lookups get their own router, which handles
+		// the requests the main one doesn't.
+		if cfg.Lookup.Enabled {
+			lookupMux := newRouter()
+
+			handle(lookupMux, endpoint.IPLookup, ipHandler.Handle)
+			handle(lookupMux, endpoint.IPLookupAnonymize, anonymizedIPHandler.Handle)
+			handle(lookupMux, endpoint.IPLookupHashed, hashedIPHandler.Handle)
+
+			// Routes that are disabled or not served by the listener, such as
+			// /v1/ip/geo without GeoIP databases, aren't lookups of invalid
+			// addresses, so they're not found instead.
+			mux.NotFound = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if isIPRoute(r.URL.Path) {
+					notFound.ServeHTTP(w, r)
+
+					return
+				}
+
+				lookupMux.ServeHTTP(w, r)
+			})
+		}
+
+		return mux
+	}
+
+	httpServers := make([]*http.Server, 0, len(cfg.Listeners))
+
+	for _, listener := range cfg.Listeners {
+		httpServer := &http.Server{
+			Addr:         listener.Address,
+			ReadTimeout:  time.Duration(listener.ReadTimeout),
+			WriteTimeout: time.Duration(listener.WriteTimeout),
+			IdleTimeout:  time.Duration(listener.IdleTimeout),
+		}
+
+		switch listener.Protocol {
+		case config.ListenerProtocolRedirect:
+			httpServer.Handler = unrouted(handler.NewRedirectHandler(listener.RedirectPort, logger).Handle)
+		case config.ListenerProtocolHTTPS:
+			httpServer.Handler = routes(listener)
+			httpServer.TLSConfig = s.tlsConfig
+		default:
+			httpServer.Handler = routes(listener)
+		}
+
+		httpServers = append(httpServers, httpServer)
+	}
+
+	return httpServers, nil
 }
 
+// Start starts the server and blocks until it receives SIGINT or SIGTERM, or
+// until Shutdown is called. On SIGHUP, the configuration and certificates are
+// reloaded. If any HTTP listener fails, every one of them is shut down, while
+// the echo servers only stop themselves.
 func (s *Server) Start() error {
+	s.mu.Lock()
+	cfg := s.cfg
+	s.mu.Unlock()
+
+	listeners := cfg.Listeners
+
+	netListeners := make([]net.Listener, 0, len(listeners))
+
+	for _, listener := range listeners {
+		netListener, err := s.listen(listener)
+		if err != nil {
+			for _, opened := range netListeners {
+				opened.Close()
+			}
+
+			return fmt.Errorf("failed to start server: %w", err)
+		}
+
+		s.logListening(listener.Network, listener.Address, listener.Protocol)
+
+		netListeners = append(netListeners, netListener)
+	}
+
+	if s.http3Server != nil {
+		conn, err := s.listenHTTP3()
+		if err != nil {
+			for _, opened := range netListeners {
+				opened.Close()
+			}
+
+			return fmt.Errorf("failed to start server: %w", err)
+		}
+
+		s.logListening("udp", s.http3Server.Addr, "http3")
+
+		s.http3Conn = conn
+	}
+
+	if err := s.listenEcho(cfg); err != nil {
+		for _, opened := range netListeners {
+			opened.Close()
+		}
+
+		if s.http3Conn != nil {
+			s.http3Conn.Close()
+		}
+
+		return fmt.Errorf("failed to start server: %w", err)
+	}
+
 	var (
-		sigint            = make(chan os.Signal, 1)
-		shutdownCompleted = make(chan struct{})
+		signals = make(chan os.Signal, 1)
+		errs    = make(chan error, 1)
 	)
 
-	signal.Notify(sigint, os.Interrupt, syscall.SIGTERM)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	defer signal.Stop(signals)
 
-	go func() {
-		<-sigint
+	s.mu.Lock()
 
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
+	for i, netListener := range netListeners {
+		d := newDispatcher(netListener, s.logger)
+
+		s.dispatchers = append(s.dispatchers, d)
+		s.serve(s.httpServers[i], d.next(), errs)
+
+		go d.run()
+	}
 
-		if err := s.httpServer.Shutdown(ctx); err != nil {
-			s.logger.Error("HTTP server Shutdown:", zap.Error(err))
+	if s.http3Conn != nil {
+		s.serveHTTP3(s.http3Conn, errs)
+	}
+
+	s.serveEcho()
+
+	s.mu.Unlock()
+
+	for {
+		select {
+		case sig := <-signals:
+			if sig == syscall.SIGHUP {
+				s.reload(errs)
+
+				continue
+			}
+
+			s.shutdownWithTimeout()
+
+			return nil
+		case err := <-errs:
+			s.shutdownWithTimeout()
+
+			return fmt.Errorf("failed to start server: %w", err)
+		case <-s.done:
+			return nil
 		}
+	}
+}
+
+// shutdownWithTimeout shuts the server down, giving in-flight requests
+// shutdownTimeout to complete.
+func (s *Server) shutdownWithTimeout() {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := s.Shutdown(ctx); err != nil {
+		s.logger.Error("HTTP server Shutdown:", zap.Error(err))
+	}
+}
 
-		close(shutdownCompleted)
+// serve serves httpServer on listener in the background, reporting unexpected
+// errors to errs.
+func (s *Server) serve(httpServer *http.Server, listener net.Listener, errs chan<- error) {
+	go func() {
+		var err error
+
+		if httpServer.TLSConfig == nil {
+			err = httpServer.Serve(listener)
+		} else {
+			err = httpServer.ServeTLS(listener, "", "")
+		}
+
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			select {
+			case errs <- err:
+			default:
+			}
+		}
 	}()
+}
 
-	if err := s.httpServer.ListenAndServeTLS("", ""); err != nil && !errors.Is(err, http.ErrServerClosed) {
-		return fmt.Errorf("failed to start server: %w", err)
+// isIPRoute reports whether path is one of the routes under /v1/ip other than
+// the lookups, or below one, whether it's enabled or not.
+func isIPRoute(path string) bool {
+	routes := []string{
+		endpoint.IPAnonymize,
+		endpoint.IPHashed,
+		endpoint.IPDetails,
+		endpoint.IPGeo,
+		endpoint.IPBatch,
+		endpoint.IPBoth,
 	}
 
-	<-shutdownCompleted
+	for _, route := range routes {
+		if path == route || strings.HasPrefix(path, route+"/") {
+			return true
+		}
+	}
 
-	return nil
+	return false
+}
+
+// logListening logs that the server is listening on address.
+func (s *Server) logListening(network, address, protocol string) {
+	s.logger.Info(
+		"Listening",
+		zap.String("network", network),
+		zap.String("address", address),
+		zap.String("protocol", protocol),
+	)
 }
 
+// listen opens the socket of listener. TCP listeners are wrapped to parse
+// PROXY protocol headers if enabled.
+func (s *Server) listen(listener config.Listener) (net.Listener, error) {
+	if listener.Network == config.ListenerNetworkUnix {
+		return listenUnix(listener.Address, listener.FileMode())
+	}
+
+	netListener, err := net.Listen(listener.Network, listener.Address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", listener.Address, err)
+	}
+
+	if s.proxyAllow == nil {
+		return netListener, nil
+	}
+
+	return proxyproto.NewListener(netListener, s.proxyAllow, s.proxyTimeout), nil
+}
+
+// listenUnix listens on the Unix domain socket at path, replacing the socket
+// left behind by a previous run, and restricts access to it to mode.
+func listenUnix(path string, mode os.FileMode) (net.Listener, error) {
+	if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		if err = os.Remove(path); err != nil {
+			return nil, fmt.Errorf("failed to remove stale socket %s: %w", path, err)
+		}
+	}
+
+	netListener, err := net.Listen(config.ListenerNetworkUnix, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", path, err)
+	}
+
+	if err = os.Chmod(path, mode); err != nil {
+		netListener.Close()
+
+		return nil, fmt.Errorf("failed to set permissions of %s: %w", path, err)
+	}
+
+	return netListener, nil
+}
+
+// Shutdown gracefully shuts down the HTTP servers of every listener at once,
+// then closes the listeners and the resources shared by the servers.
 func (s *Server) Shutdown(ctx context.Context) error {
-	if err := s.httpServer.Shutdown(ctx); err != nil {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var (
+		wg       sync.WaitGroup
+		shutdown = make([]error, len(s.httpServers))
+	)
+
+	for i, httpServer := range s.httpServers {
+		wg.Add(1)
+
+		go func(i int, httpServer *http.Server) {
+			defer wg.Done()
+
+			shutdown[i] = httpServer.Shutdown(ctx)
+		}(i, httpServer)
+	}
+
+	wg.Wait()
+
+	err := errors.Join(shutdown...)
+
+	// The HTTP/3 server can't wait for in-flight requests, so it's closed
+	// once the others are done.
+	if s.http3Server != nil {
+		if closeErr := s.http3Server.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+
+	if s.http3Conn != nil {
+		if closeErr := s.http3Conn.Close(); closeErr != nil && !errors.Is(closeErr, net.ErrClosed) && err == nil {
+			err = closeErr
+		}
+	}
+
+	if closeErr := s.closeEcho(); closeErr != nil && err == nil {
+		err = closeErr
+	}
+
+	for _, d := range s.dispatchers {
+		if closeErr := d.Close(); closeErr != nil && !errors.Is(closeErr, net.ErrClosed) && err == nil {
+			err = closeErr
+		}
+	}
+
+	if s.geo != nil {
+		s.geo.Close()
+	}
+
+	if s.accessLogFile != nil {
+		if closeErr := s.accessLogFile.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+
+	s.closeDone.Do(func() { close(s.done) })
+
+	if err != nil {
 		return fmt.Errorf("failed to shutdown server: %w", err)
 	}
 
 	return nil
 }
 
-func (s *Server) Addr() string {
-	return s.httpServer.Addr
+// allowedMethods returns the methods a route accepting methods answers, which
+// are methods themselves, HEAD if they include GET, and OPTIONS. Routes accept
+// GET requests if methods is empty.
+func allowedMethods(methods []string) []string {
+	if len(methods) == 0 {
+		methods = []string{http.MethodGet}
+	}
+
+	allowed := make([]string, 0, len(methods)+2)
+
+	for _, method := range methods {
+		allowed = append(allowed, method)
+
+		if method == http.MethodGet {
+			allowed = append(allowed, http.MethodHead)
+		}
+	}
+
+	return append(allowed, http.MethodOptions)
 }
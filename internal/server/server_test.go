@@ -0,0 +1,509 @@
+package server_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+
+	"git.sr.ht/~jamesponddotco/accio127/internal/config"
+	"git.sr.ht/~jamesponddotco/accio127/internal/database"
+	"git.sr.ht/~jamesponddotco/accio127/internal/server"
+	"github.com/quic-go/quic-go/http3"
+	"go.uber.org/zap"
+)
+
+func TestServer_HTTP3(t *testing.T) {
+	t.Parallel()
+
+	var (
+		dir            = t.TempDir()
+		certPool       = writeCertificate(t, dir)
+		port           = freePort(t)
+		address        = net.JoinHostPort("127.0.0.1", strconv.Itoa(port))
+		tlsClientCfg   = &tls.Config{RootCAs: certPool, MinVersion: tls.VersionTLS13}
+		httpClient     = &http.Client{Transport: &http.Transport{TLSClientConfig: tlsClientCfg}}
+		http3Transport = &http3.RoundTripper{TLSClientConfig: tlsClientCfg}
+		http3Client    = &http.Client{Transport: http3Transport}
+	)
+
+	t.Cleanup(func() { http3Transport.Close() })
+
+	srv, started := startServer(t, dir, map[string]any{
+		"proxy":     "192.0.2.1",
+		"certFile":  filepath.Join(dir, "cert.pem"),
+		"certKey":   filepath.Join(dir, "key.pem"),
+		"listeners": []map[string]string{{"network": "tcp4", "address": address}},
+		"http3":     map[string]any{"enabled": true, "maxAge": "1h"},
+	})
+
+	// get requests path from the server with client, retrying until the
+	// server is up.
+	get := func(client *http.Client, path string) (*http.Response, string) {
+		t.Helper()
+
+		req, err := http.NewRequest(http.MethodGet, "https://localhost:"+strconv.Itoa(port)+path, http.NoBody)
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+
+		req.Header.Set("User-Agent", "accio127-test")
+
+		for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(50 * time.Millisecond) {
+			resp, err := client.Do(req)
+			if err != nil {
+				if time.Now().After(deadline) {
+					t.Fatalf("GET %s error = %v", path, err)
+				}
+
+				continue
+			}
+
+			body, err := io.ReadAll(resp.Body)
+			resp.Body.Close()
+
+			if err != nil {
+				t.Fatalf("Failed to read response: %v", err)
+			}
+
+			return resp, string(body)
+		}
+	}
+
+	resp, _ := get(httpClient, "/v1/ping")
+	if got, want := resp.Header.Get("Alt-Svc"), `h3=":`+strconv.Itoa(port)+`"; ma=3600`; got != want {
+		t.Errorf("Alt-Svc = %q, want %q", got, want)
+	}
+
+	resp, body := get(http3Client, "/v1/ip")
+	if resp.ProtoMajor != 3 {
+		t.Errorf("Proto = %s, want HTTP/3.0", resp.Proto)
+	}
+
+	if resp.StatusCode != http.StatusOK || body != "127.0.0.1" {
+		t.Errorf("GET /v1/ip over HTTP/3 = %d %q, want %d %q", resp.StatusCode, body, http.StatusOK, "127.0.0.1")
+	}
+
+	if got := resp.Header.Get("Alt-Svc"); got != "" {
+		t.Errorf("Alt-Svc over HTTP/3 = %q, want none", got)
+	}
+
+	stopServer(t, srv, started)
+}
+
+func TestServer_UnixListener(t *testing.T) {
+	t.Parallel()
+
+	var (
+		dir    = t.TempDir()
+		socket = filepath.Join(dir, "admin.sock")
+		client = &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var dialer net.Dialer
+
+					return dialer.DialContext(ctx, "unix", socket)
+				},
+			},
+		}
+	)
+
+	srv, started := startServer(t, dir, map[string]any{
+		"proxy":     "127.0.0.1",
+		"tls":       map[string]string{"mode": "off"},
+		"listeners": []map[string]string{{"network": "unix", "address": socket}},
+	})
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+	}{
+		{
+			name:       "health",
+			path:       "/v1/health",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "ping",
+			path:       "/v1/ping",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "ip",
+			path:       "/v1/ip",
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	// The server starts in the background, so the cases wait for it in
+	// turn instead of running in parallel.
+	for _, tt := range tests {
+		req, err := http.NewRequest(http.MethodGet, "http://admin"+tt.path, http.NoBody)
+		if err != nil {
+			t.Fatalf("%s: failed to create request: %v", tt.name, err)
+		}
+
+		req.Header.Set("User-Agent", "accio127-test")
+
+		var resp *http.Response
+
+		for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(50 * time.Millisecond) {
+			resp, err = client.Do(req)
+			if err == nil || time.Now().After(deadline) {
+				break
+			}
+		}
+
+		if err != nil {
+			t.Fatalf("%s: GET %s error = %v", tt.name, tt.path, err)
+		}
+
+		resp.Body.Close()
+
+		if resp.StatusCode != tt.wantStatus {
+			t.Errorf("%s: GET %s = %d, want %d", tt.name, tt.path, resp.StatusCode, tt.wantStatus)
+		}
+	}
+
+	info, err := os.Stat(socket)
+	if err != nil {
+		t.Fatalf("Failed to stat socket: %v", err)
+	}
+
+	if got, want := info.Mode().Perm(), os.FileMode(0o600); got != want {
+		t.Errorf("socket mode = %v, want %v", got, want)
+	}
+
+	stopServer(t, srv, started)
+}
+
+func TestServer_LookupRoutes(t *testing.T) {
+	t.Parallel()
+
+	var (
+		dir     = t.TempDir()
+		address = net.JoinHostPort("127.0.0.1", strconv.Itoa(freePort(t)))
+	)
+
+	srv, started := startServer(t, dir, map[string]any{
+		"proxy":     "127.0.0.1",
+		"tls":       map[string]string{"mode": "off"},
+		"lookup":    map[string]bool{"enabled": true},
+		"listeners": []map[string]string{{"network": "tcp4", "address": address}},
+	})
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+	}{
+		{
+			name:       "lookup",
+			path:       "/v1/ip/192.0.2.1",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "lookup_hashed",
+			path:       "/v1/ip/2001:db8::1/hashed",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "invalid_address",
+			path:       "/v1/ip/not-an-address",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "geo_disabled",
+			path:       "/v1/ip/geo",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "batch_disabled",
+			path:       "/v1/ip/batch",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "dual_stack_disabled",
+			path:       "/v1/ip/both/token",
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	// The server starts in the background, so the cases wait for it in
+	// turn instead of running in parallel.
+	for _, tt := range tests {
+		req, err := http.NewRequest(http.MethodGet, "http://"+address+tt.path, http.NoBody)
+		if err != nil {
+			t.Fatalf("%s: failed to create request: %v", tt.name, err)
+		}
+
+		req.Header.Set("User-Agent", "accio127-test")
+
+		var resp *http.Response
+
+		for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(50 * time.Millisecond) {
+			resp, err = http.DefaultClient.Do(req)
+			if err == nil || time.Now().After(deadline) {
+				break
+			}
+		}
+
+		if err != nil {
+			t.Fatalf("%s: GET %s error = %v", tt.name, tt.path, err)
+		}
+
+		resp.Body.Close()
+
+		if resp.StatusCode != tt.wantStatus {
+			t.Errorf("%s: GET %s = %d, want %d", tt.name, tt.path, resp.StatusCode, tt.wantStatus)
+		}
+	}
+
+	stopServer(t, srv, started)
+}
+
+//nolint:paralleltest // SIGHUP is sent to the whole process
+func TestServer_ReloadTimeouts(t *testing.T) {
+	var (
+		dir      = t.TempDir()
+		port     = freePort(t)
+		address  = net.JoinHostPort("127.0.0.1", strconv.Itoa(port))
+		settings = map[string]any{
+			"proxy":       "127.0.0.1",
+			"tls":         map[string]string{"mode": "off"},
+			"readTimeout": "1m",
+			"listeners":   []map[string]string{{"network": "tcp4", "address": address}},
+		}
+	)
+
+	srv, started := startServer(t, dir, settings)
+
+	// readStalls sends an incomplete request and reports whether the server
+	// is still waiting for the rest of it after a second.
+	readStalls := func() bool {
+		t.Helper()
+
+		var (
+			conn net.Conn
+			err  error
+		)
+
+		for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(50 * time.Millisecond) {
+			conn, err = net.Dial("tcp4", address)
+			if err == nil || time.Now().After(deadline) {
+				break
+			}
+		}
+
+		if err != nil {
+			t.Fatalf("Failed to connect to server: %v", err)
+		}
+
+		defer conn.Close()
+
+		if _, err = io.WriteString(conn, "GET /v1/ping HTTP/1.1\r\n"); err != nil {
+			t.Fatalf("Failed to write request: %v", err)
+		}
+
+		if err = conn.SetReadDeadline(time.Now().Add(time.Second)); err != nil {
+			t.Fatalf("Failed to set read deadline: %v", err)
+		}
+
+		_, err = conn.Read(make([]byte, 1))
+
+		var netErr net.Error
+
+		return errors.As(err, &netErr) && netErr.Timeout()
+	}
+
+	if !readStalls() {
+		t.Fatal("server closed the connection before its read timeout")
+	}
+
+	settings["readTimeout"] = "100ms"
+
+	configJSON, err := json.Marshal(settings)
+	if err != nil {
+		t.Fatalf("Failed to marshal configuration: %v", err)
+	}
+
+	if err = os.WriteFile(filepath.Join(dir, "config.json"), configJSON, 0o600); err != nil {
+		t.Fatalf("Failed to write configuration: %v", err)
+	}
+
+	if err = syscall.Kill(os.Getpid(), syscall.SIGHUP); err != nil {
+		t.Fatalf("Failed to send SIGHUP: %v", err)
+	}
+
+	// The reload happens in the background, so new connections are tried
+	// until one gets the new read timeout.
+	for deadline := time.Now().Add(5 * time.Second); readStalls(); {
+		if time.Now().After(deadline) {
+			t.Fatal("new connections still use the old read timeout after reload")
+		}
+	}
+
+	stopServer(t, srv, started)
+}
+
+// startServer writes a configuration made of settings to dir, with an
+// in-memory database and a privacy policy, and starts a server with it in the
+// background. Start's result is sent to the returned channel.
+func startServer(t *testing.T, dir string, settings map[string]any) (*server.Server, <-chan error) {
+	t.Helper()
+
+	settings["dsn"] = "memory://"
+	settings["privacyPolicy"] = "https://example.com/privacy-policy"
+
+	configJSON, err := json.Marshal(settings)
+	if err != nil {
+		t.Fatalf("Failed to marshal configuration: %v", err)
+	}
+
+	configPath := filepath.Join(dir, "config.json")
+
+	if err = os.WriteFile(configPath, configJSON, 0o600); err != nil {
+		t.Fatalf("Failed to write configuration: %v", err)
+	}
+
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+
+	db, err := database.Open(zap.NewNop(), cfg.DSN, database.Options{})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+
+	t.Cleanup(func() { db.Close() })
+
+	srv, err := server.New(cfg, db, zap.NewNop())
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	started := make(chan error, 1)
+
+	go func() {
+		started <- srv.Start()
+	}()
+
+	return srv, started
+}
+
+// stopServer shuts srv down, checking that Start returns without an error.
+func stopServer(t *testing.T, srv *server.Server, started <-chan error) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := srv.Shutdown(ctx); err != nil {
+		t.Errorf("Shutdown() error = %v", err)
+	}
+
+	select {
+	case err := <-started:
+		if err != nil {
+			t.Errorf("Start() error = %v", err)
+		}
+	case <-ctx.Done():
+		t.Error("Start() didn't return after Shutdown()")
+	}
+}
+
+// freePort returns a port that's free for both TCP and UDP on the loopback
+// address.
+func freePort(t *testing.T) int {
+	t.Helper()
+
+	for i := 0; i < 10; i++ {
+		listener, err := net.Listen("tcp4", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Failed to find a free port: %v", err)
+		}
+
+		port := listener.Addr().(*net.TCPAddr).Port
+
+		conn, err := net.ListenPacket("udp4", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)))
+
+		listener.Close()
+
+		if err == nil {
+			conn.Close()
+
+			return port
+		}
+	}
+
+	t.Fatal("Failed to find a port free for both TCP and UDP")
+
+	return 0
+}
+
+// writeCertificate writes a self-signed certificate for localhost and its key
+// to dir, returning a pool trusting it.
+func writeCertificate(t *testing.T, dir string) *x509.CertPool {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+
+	var (
+		certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+		keyPEM  = pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	)
+
+	if err = os.WriteFile(filepath.Join(dir, "cert.pem"), certPEM, 0o600); err != nil {
+		t.Fatalf("Failed to write certificate: %v", err)
+	}
+
+	if err = os.WriteFile(filepath.Join(dir, "key.pem"), keyPEM, 0o600); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(certPEM)
+
+	return pool
+}
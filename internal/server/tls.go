@@ -0,0 +1,77 @@
+package server
+
+import (
+	"crypto/tls"
+	"fmt"
+	"sync/atomic"
+
+	"git.sr.ht/~jamesponddotco/accio127/internal/config"
+	"git.sr.ht/~jamesponddotco/xstd-go/xcrypto/xtls"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// newTLSConfig builds the TLS configuration of the server for the "files" and
+// "acme" TLS modes. In "files" mode, it also returns the certificate store
+// used to replace the certificate on reload.
+func newTLSConfig(cfg *config.Config) (*tls.Config, *certificateStore, error) {
+	tlsConfig := xtls.ModernServerConfig()
+
+	if cfg.MinTLSVersion == "TLS12" {
+		tlsConfig = xtls.IntermediateServerConfig()
+	}
+
+	if cfg.TLS.Mode == config.TLSModeACME {
+		manager := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			Cache:      autocert.DirCache(cfg.TLS.ACME.CacheDir),
+			HostPolicy: autocert.HostWhitelist(cfg.TLS.ACME.Hosts...),
+			Email:      cfg.TLS.ACME.Email,
+			Client: &acme.Client{
+				DirectoryURL: cfg.TLS.ACME.DirectoryURL,
+			},
+		}
+
+		// Certificates are validated with the TLS-ALPN-01 challenge, which is
+		// answered by GetCertificate itself.
+		tlsConfig.GetCertificate = manager.GetCertificate
+		tlsConfig.NextProtos = append(tlsConfig.NextProtos, "h2", "http/1.1", acme.ALPNProto)
+
+		return tlsConfig, nil, nil
+	}
+
+	certs := &certificateStore{}
+
+	if err := certs.load(cfg.CertFile, cfg.CertKey); err != nil {
+		return nil, nil, err
+	}
+
+	tlsConfig.GetCertificate = certs.GetCertificate
+
+	return tlsConfig, certs, nil
+}
+
+// certificateStore holds the server's certificate and allows replacing it
+// without restarting the server.
+type certificateStore struct {
+	cert atomic.Pointer[tls.Certificate]
+}
+
+// load reads a certificate and its key from disk and makes it the current
+// certificate. The previous certificate is kept if loading fails.
+func (c *certificateStore) load(certFile, keyFile string) error {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+
+	c.cert.Store(&cert)
+
+	return nil
+}
+
+// GetCertificate returns the current certificate. It's meant to be used as
+// tls.Config.GetCertificate.
+func (c *certificateStore) GetCertificate(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return c.cert.Load(), nil
+}